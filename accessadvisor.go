@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Services not used for this long are reported alongside the ones never used at all
+const UNUSED_SERVICE_DAYS = 90
+
+// How often to check whether a last accessed report has finished generating
+const ACCESS_ADVISOR_POLL_INTERVAL = 2 * time.Second
+
+// A service the principal's policies allow and when it was last used, i.e. s3 used 3 days ago in us-east-1
+type ServiceAccessResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Empty when the service has never been used within the tracking period
+	LastAuthenticated *time.Time `json:"lastAuthenticated,omitempty"`
+	Region            string     `json:"region,omitempty"`
+	// For groups, the member that last used it
+	Entity string `json:"entity,omitempty"`
+}
+
+type AccessAdvisorResult struct {
+	Principal string                `json:"principal"`
+	Arn       string                `json:"arn"`
+	Services  []ServiceAccessResult `json:"services"`
+	// Namespaces granted but never used, and granted but not used in the last 90 days
+	NeverUsed []string `json:"neverUsed"`
+	Unused    []string `json:"unused"`
+}
+
+func RunAccessAdvisorModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Access Advisor reports on users, roles and groups the same way, it only needs the ARN
+	// i.e. aws iam list-users, aws iam list-roles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting service last accessed data for every user and role...")
+	fmt.Println(MAJOR_SEPARATOR)
+	users, err := CachedUsers(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list users. Exiting...")
+		return err
+	}
+	roles, err := CachedRoles(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list roles. Exiting...")
+		return err
+	}
+	var names, arns []string
+	for _, user := range users {
+		names = append(names, *user.UserName)
+		arns = append(arns, *user.Arn)
+	}
+	for _, role := range roles {
+		// Service-linked roles are managed by AWS and their permissions can't be trimmed
+		if strings.HasPrefix(aws.ToString(role.Path), "/aws-service-role/") {
+			continue
+		}
+		names = append(names, *role.RoleName)
+		arns = append(arns, *role.Arn)
+	}
+
+	// Each report is generated asynchronously and takes a few seconds, so they're requested side by side
+	results := make([]AccessAdvisorResult, len(arns))
+	reportErrors := make([]error, len(arns))
+	ForEachConcurrently(ctx, len(arns), func(ctx context.Context, i int) {
+		results[i], reportErrors[i] = GetAccessAdvisorResult(ctx, iamClient, names[i], arns[i])
+	}, func(i int) {
+		result := results[i]
+		if reportErrors[i] != nil {
+			return
+		}
+		fmt.Printf("\tPrincipal: %v\n", result.Principal)
+		fmt.Printf("\tARN: %v\n", result.Arn)
+		for _, service := range result.Services {
+			if service.LastAuthenticated == nil {
+				continue
+			}
+			fmt.Printf("\tService: %v, last used %v in %v\n", service.Namespace, *service.LastAuthenticated, service.Region)
+		}
+		if len(result.Unused) > 0 {
+			fmt.Printf("\t[-] Not used in the last %v days: %v\n", UNUSED_SERVICE_DAYS, strings.Join(result.Unused, ", "))
+		}
+		// Permissions nobody has ever exercised can go without breaking anything, and anything
+		// done through them stands out to nobody watching for this principal's usual activity
+		if len(result.NeverUsed) > 0 {
+			fmt.Printf("\t[!] Granted %v services it has never used: %v\n", len(result.NeverUsed), strings.Join(result.NeverUsed, ", "))
+			EmitFinding("", result.Arn, fmt.Sprintf("Granted %v services it has never used: %v", len(result.NeverUsed), strings.Join(result.NeverUsed, ", ")))
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("access-advisor", "", result)
+	})
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func GetAccessAdvisorResult(ctx context.Context, iamClient *iam.Client, name string, principalArn string) (AccessAdvisorResult, error) {
+	result := AccessAdvisorResult{Principal: name, Arn: principalArn}
+
+	// i.e. aws iam generate-service-last-accessed-details --arn <principal-arn>
+	// i.e. aws iam get-service-last-accessed-details --job-id <job-id>
+	services, err := GetServiceLastAccessed(ctx, iamClient, principalArn)
+	if err != nil {
+		return result, err
+	}
+	for _, service := range services {
+		access := ServiceAccessResult{
+			Namespace:         aws.ToString(service.ServiceNamespace),
+			Name:              aws.ToString(service.ServiceName),
+			LastAuthenticated: service.LastAuthenticated,
+			Region:            aws.ToString(service.LastAuthenticatedRegion),
+			Entity:            aws.ToString(service.LastAuthenticatedEntity),
+		}
+		result.Services = append(result.Services, access)
+		if access.LastAuthenticated == nil {
+			result.NeverUsed = append(result.NeverUsed, access.Namespace)
+		} else if time.Since(*access.LastAuthenticated) >= UNUSED_SERVICE_DAYS*24*time.Hour {
+			result.Unused = append(result.Unused, access.Namespace)
+		}
+	}
+
+	return result, nil
+}
+
+func GetServiceLastAccessed(ctx context.Context, iamClient *iam.Client, principalArn string) ([]iamtypes.ServiceLastAccessed, error) {
+	job, err := iamClient.GenerateServiceLastAccessedDetails(ctx, &iam.GenerateServiceLastAccessedDetailsInput{
+		Arn:         aws.String(principalArn),
+		Granularity: iamtypes.AccessAdvisorUsageGranularityTypeServiceLevel,
+	})
+	if err != nil {
+		fmt.Printf("Couldn't generate the service last accessed report for %v. Here's why: %v\n", principalArn, err)
+		return nil, err
+	}
+
+	// Wait for the report, then follow it through its pages
+	var services []iamtypes.ServiceLastAccessed
+	var marker *string
+	for {
+		output, err := iamClient.GetServiceLastAccessedDetails(ctx, &iam.GetServiceLastAccessedDetailsInput{
+			JobId:  job.JobId,
+			Marker: marker,
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the service last accessed report for %v. Here's why: %v\n", principalArn, err)
+			return nil, err
+		}
+		switch output.JobStatus {
+		case iamtypes.JobStatusTypeInProgress:
+			select {
+			case <-time.After(ACCESS_ADVISOR_POLL_INTERVAL):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		case iamtypes.JobStatusTypeFailed:
+			err := fmt.Errorf("report generation failed")
+			if output.Error != nil {
+				err = fmt.Errorf("%v: %v", aws.ToString(output.Error.Code), aws.ToString(output.Error.Message))
+			}
+			fmt.Printf("Couldn't get the service last accessed report for %v. Here's why: %v\n", principalArn, err)
+			return nil, err
+		}
+		services = append(services, output.ServicesLastAccessed...)
+		if !output.IsTruncated {
+			break
+		}
+		marker = output.Marker
+	}
+
+	return services, nil
+}
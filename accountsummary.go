@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Shortest password and fewest remembered passwords before the policy is flagged, as in the CIS benchmark
+const MIN_PASSWORD_LENGTH = 14
+const MIN_PASSWORD_REUSE_PREVENTION = 24
+
+// Account summary entries, in the order they're printed, with the label for each
+var ACCOUNT_SUMMARY_ENTITIES = [][2]string{
+	{"Users", "Users"},
+	{"Groups", "Groups"},
+	{"Roles", "Roles"},
+	{"Policies", "Customer managed policies"},
+	{"InstanceProfiles", "Instance profiles"},
+	{"Providers", "Identity providers"},
+	{"ServerCertificates", "Server certificates"},
+	{"MFADevices", "MFA devices"},
+	{"MFADevicesInUse", "MFA devices in use"},
+}
+
+// Size quotas, in characters, that decide how much a single policy can grant
+var ACCOUNT_SUMMARY_POLICY_QUOTAS = [][2]string{
+	{"PolicySizeQuota", "Managed policy size"},
+	{"UserPolicySizeQuota", "User inline policy size"},
+	{"GroupPolicySizeQuota", "Group inline policy size"},
+	{"RolePolicySizeQuota", "Role inline policy size"},
+	{"VersionsPerPolicyQuota", "Versions per policy"},
+	{"AttachedPoliciesPerUserQuota", "Attached policies per user"},
+	{"AttachedPoliciesPerGroupQuota", "Attached policies per group"},
+	{"AttachedPoliciesPerRoleQuota", "Attached policies per role"},
+}
+
+type AccountSummaryResult struct {
+	// nil when the account has no password policy and the AWS defaults apply
+	PasswordPolicy   *iamtypes.PasswordPolicy `json:"passwordPolicy"`
+	PolicyWeaknesses []string                 `json:"policyWeaknesses"`
+	RootMFAEnabled   bool                     `json:"rootMfaEnabled"`
+	RootAccessKeys   bool                     `json:"rootAccessKeys"`
+	Summary          map[string]int32         `json:"summary"`
+}
+
+func RunAccountSummaryModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	result := AccountSummaryResult{}
+
+	// i.e. aws iam get-account-password-policy
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the account password policy and summary...")
+	fmt.Println(MAJOR_SEPARATOR)
+	passwordPolicy, err := GetAccountPasswordPolicy(ctx, iamClient)
+	if err == nil {
+		result.PasswordPolicy = passwordPolicy
+		if passwordPolicy == nil {
+			fmt.Println("\t[!] No password policy, console passwords only need 8 characters of any kind")
+			EmitFinding("", "password-policy", "The account has no password policy")
+		} else {
+			PrintPasswordPolicy(passwordPolicy)
+			result.PolicyWeaknesses = PasswordPolicyWeaknesses(passwordPolicy)
+			for _, weakness := range result.PolicyWeaknesses {
+				fmt.Printf("\t[-] %v\n", weakness)
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	// i.e. aws iam get-account-summary
+	accountSummary, err := GetAccountSummary(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't get the account summary. Exiting...")
+		return err
+	}
+	result.Summary = accountSummary.SummaryMap
+	result.RootMFAEnabled = accountSummary.SummaryMap["AccountMFAEnabled"] == 1
+	result.RootAccessKeys = accountSummary.SummaryMap["AccountAccessKeysPresent"] == 1
+
+	for _, entity := range ACCOUNT_SUMMARY_ENTITIES {
+		if count, ok := accountSummary.SummaryMap[entity[0]]; ok {
+			fmt.Printf("\t%v: %v\n", entity[1], count)
+		}
+	}
+	fmt.Printf("\tRoot MFA: %v\n", result.RootMFAEnabled)
+	if !result.RootMFAEnabled {
+		fmt.Println("\t[!] The root user has no MFA")
+	}
+	fmt.Printf("\tRoot access keys: %v\n", result.RootAccessKeys)
+	if result.RootAccessKeys {
+		// Root keys can't be limited by any policy, SCPs aside
+		fmt.Println("\t[!] The root user has access keys")
+		EmitFinding("", "root", "The root user has access keys")
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	for _, quota := range ACCOUNT_SUMMARY_POLICY_QUOTAS {
+		if limit, ok := accountSummary.SummaryMap[quota[0]]; ok {
+			fmt.Printf("\t%v: %v\n", quota[1], limit)
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("account-summary", "", result)
+
+	return nil
+}
+
+func GetAccountPasswordPolicy(ctx context.Context, iamClient *iam.Client) (*iamtypes.PasswordPolicy, error) {
+	// An account that never set a password policy has none, rather than an empty one
+	output, err := iamClient.GetAccountPasswordPolicy(ctx, &iam.GetAccountPasswordPolicyInput{})
+	if err != nil {
+		var noSuchEntity *iamtypes.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return nil, nil
+		}
+		fmt.Printf("Couldn't get the password policy. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return output.PasswordPolicy, nil
+}
+
+func PrintPasswordPolicy(policy *iamtypes.PasswordPolicy) {
+	fmt.Printf("\tMinimum length: %v\n", aws.ToInt32(policy.MinimumPasswordLength))
+	fmt.Printf("\tRequires uppercase: %v\n", policy.RequireUppercaseCharacters)
+	fmt.Printf("\tRequires lowercase: %v\n", policy.RequireLowercaseCharacters)
+	fmt.Printf("\tRequires numbers: %v\n", policy.RequireNumbers)
+	fmt.Printf("\tRequires symbols: %v\n", policy.RequireSymbols)
+	fmt.Printf("\tUsers can change their own password: %v\n", policy.AllowUsersToChangePassword)
+	if policy.ExpirePasswords {
+		fmt.Printf("\tPasswords expire after: %v days\n", aws.ToInt32(policy.MaxPasswordAge))
+	} else {
+		fmt.Println("\tPasswords expire: false")
+	}
+	fmt.Printf("\tPrevious passwords remembered: %v\n", aws.ToInt32(policy.PasswordReusePrevention))
+	// Expired passwords can't be reset by the user themselves, an admin has to do it
+	if aws.ToBool(policy.HardExpiry) {
+		fmt.Println("\tHard expiry: true")
+	}
+}
+
+func PasswordPolicyWeaknesses(policy *iamtypes.PasswordPolicy) []string {
+	var weaknesses []string
+	if aws.ToInt32(policy.MinimumPasswordLength) < MIN_PASSWORD_LENGTH {
+		weaknesses = append(weaknesses, fmt.Sprintf("Passwords can be shorter than %v characters", MIN_PASSWORD_LENGTH))
+	}
+	if aws.ToInt32(policy.PasswordReusePrevention) < MIN_PASSWORD_REUSE_PREVENTION {
+		weaknesses = append(weaknesses, fmt.Sprintf("Fewer than the last %v passwords are remembered, so old ones can be reused", MIN_PASSWORD_REUSE_PREVENTION))
+	}
+	if !policy.RequireUppercaseCharacters && !policy.RequireLowercaseCharacters && !policy.RequireNumbers && !policy.RequireSymbols {
+		weaknesses = append(weaknesses, "No character classes are required")
+	}
+
+	return weaknesses
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+	bedrockagenttypes "github.com/aws/aws-sdk-go-v2/service/bedrockagent/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type ModelAccessResult struct {
+	// Foundation models the account has been granted access to and can invoke in this region
+	ModelIds []string `json:"modelIds"`
+}
+
+type ProvisionedThroughputResult struct {
+	Name       string `json:"name"`
+	Arn        string `json:"arn"`
+	ModelArn   string `json:"modelArn"`
+	ModelUnits int32  `json:"modelUnits"`
+	Status     string `json:"status"`
+	// i.e. OneMonth or SixMonths, empty for no commitment
+	Commitment           string     `json:"commitment,omitempty"`
+	CommitmentExpiration *time.Time `json:"commitmentExpiration,omitempty"`
+}
+
+type CustomModelResult struct {
+	Name          string `json:"name"`
+	Arn           string `json:"arn"`
+	BaseModelArn  string `json:"baseModelArn"`
+	Customization string `json:"customization"`
+	// Where the model was trained from and where the customization job wrote its metrics
+	TrainingData string `json:"trainingData,omitempty"`
+	OutputData   string `json:"outputData,omitempty"`
+}
+
+type AgentResult struct {
+	Name            string   `json:"name"`
+	Arn             string   `json:"arn"`
+	Status          string   `json:"status"`
+	FoundationModel string   `json:"foundationModel"`
+	RoleArn         string   `json:"roleArn"`
+	KnowledgeBases  []string `json:"knowledgeBases"`
+}
+
+type KnowledgeBaseDataSourceResult struct {
+	Name string `json:"name"`
+	// i.e. S3, WEB, CONFLUENCE, SALESFORCE or SHAREPOINT
+	Type string `json:"type"`
+	// The bucket, site or host documents are ingested from
+	Location string `json:"location"`
+	// Account that owns the bucket, only set when it isn't the knowledge base's own
+	BucketOwner string `json:"bucketOwner,omitempty"`
+	// Secret holding the credentials Bedrock crawls the source with, for the non-S3 sources
+	CredentialsSecretArn string `json:"credentialsSecretArn,omitempty"`
+}
+
+type KnowledgeBaseResult struct {
+	Name        string                          `json:"name"`
+	Arn         string                          `json:"arn"`
+	Status      string                          `json:"status"`
+	RoleArn     string                          `json:"roleArn"`
+	DataSources []KnowledgeBaseDataSourceResult `json:"dataSources"`
+}
+
+func RunBedrockModule(ctx context.Context, sdkConfig aws.Config) error {
+	// Knowledge base buckets in another account are reported as cross-account
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := *callerIdentity.Account
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		bedrockClient := bedrock.NewFromConfig(regionalConfig)
+		agentClient := bedrockagent.NewFromConfig(regionalConfig)
+
+		// Access is granted per model, so each one the region offers is checked
+		// i.e. aws bedrock list-foundation-models
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Bedrock models, agents and knowledge bases in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		models, err := ListFoundationModels(ctx, bedrockClient)
+		if err != nil {
+			return err
+		}
+		accessible := make([]bool, len(models))
+		ForEachConcurrently(ctx, len(models), func(ctx context.Context, i int) {
+			// i.e. aws bedrock get-foundation-model-availability --model-id <model-id>
+			availability, err := bedrockClient.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+				ModelId: models[i].ModelId,
+			})
+			if err != nil {
+				return
+			}
+			accessible[i] = availability.AuthorizationStatus == bedrocktypes.AuthorizationStatusAuthorized &&
+				availability.EntitlementAvailability == bedrocktypes.EntitlementAvailabilityAvailable &&
+				availability.AgreementAvailability != nil &&
+				availability.AgreementAvailability.Status == bedrocktypes.AgreementStatusAvailable
+		}, func(i int) {})
+		modelAccess := ModelAccessResult{}
+		for i, model := range models {
+			if accessible[i] {
+				modelAccess.ModelIds = append(modelAccess.ModelIds, aws.ToString(model.ModelId))
+			}
+		}
+		fmt.Printf("\tFoundation models with access enabled: %v of %v\n", len(modelAccess.ModelIds), len(models))
+		for _, modelId := range modelAccess.ModelIds {
+			fmt.Printf("\t\t%v\n", modelId)
+		}
+		Emit("model-access", regionalConfig.Region, modelAccess)
+		fmt.Println(MINOR_SEPARATOR)
+
+		// Provisioned throughput is billed by the hour whether it's used or not
+		// i.e. aws bedrock list-provisioned-model-throughputs
+		throughputs, err := ListProvisionedModelThroughputs(ctx, bedrockClient)
+		if err == nil {
+			for _, throughput := range throughputs {
+				result := ProvisionedThroughputResult{
+					Name:                 aws.ToString(throughput.ProvisionedModelName),
+					Arn:                  aws.ToString(throughput.ProvisionedModelArn),
+					ModelArn:             aws.ToString(throughput.ModelArn),
+					ModelUnits:           aws.ToInt32(throughput.ModelUnits),
+					Status:               string(throughput.Status),
+					Commitment:           string(throughput.CommitmentDuration),
+					CommitmentExpiration: throughput.CommitmentExpirationTime,
+				}
+				fmt.Printf("\tProvisioned throughput: %v\n", result.Name)
+				fmt.Printf("\t\tModel: %v\n", result.ModelArn)
+				fmt.Printf("\t\tModel units: %v\n", result.ModelUnits)
+				fmt.Printf("\t\tStatus: %v\n", result.Status)
+				if result.Commitment != "" {
+					fmt.Printf("\t\tCommitment: %v, until %v\n", result.Commitment, aws.ToTime(result.CommitmentExpiration))
+				}
+				Emit("provisioned-throughput", regionalConfig.Region, result)
+			}
+			if len(throughputs) == 0 {
+				fmt.Println("\tNo provisioned throughput in this region")
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// The training data of a fine-tuned model is usually the organization's own documents
+		// i.e. aws bedrock list-custom-models
+		customModels, err := ListCustomModels(ctx, bedrockClient)
+		if err == nil {
+			for _, model := range customModels {
+				result := CustomModelResult{
+					Name:          aws.ToString(model.ModelName),
+					Arn:           aws.ToString(model.ModelArn),
+					BaseModelArn:  aws.ToString(model.BaseModelArn),
+					Customization: string(model.CustomizationType),
+				}
+				// i.e. aws bedrock get-custom-model --model-identifier <model-arn>
+				details, err := bedrockClient.GetCustomModel(ctx, &bedrock.GetCustomModelInput{ModelIdentifier: model.ModelArn})
+				if err == nil {
+					if details.TrainingDataConfig != nil {
+						result.TrainingData = aws.ToString(details.TrainingDataConfig.S3Uri)
+					}
+					if details.OutputDataConfig != nil {
+						result.OutputData = aws.ToString(details.OutputDataConfig.S3Uri)
+					}
+				}
+				fmt.Printf("\tCustom model: %v\n", result.Name)
+				fmt.Printf("\t\tBase model: %v\n", result.BaseModelArn)
+				fmt.Printf("\t\tCustomization: %v\n", result.Customization)
+				if result.TrainingData != "" {
+					fmt.Printf("\t\tTraining data: %v\n", result.TrainingData)
+				}
+				if result.OutputData != "" {
+					fmt.Printf("\t\tOutput data: %v\n", result.OutputData)
+				}
+				Emit("custom-model", regionalConfig.Region, result)
+			}
+			if len(customModels) == 0 {
+				fmt.Println("\tNo custom models in this region")
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws bedrock-agent list-agents
+		agents, err := ListAgents(ctx, agentClient)
+		if err == nil {
+			for _, agent := range agents {
+				result, err := GetAgentResult(ctx, agentClient, aws.ToString(agent.AgentId))
+				if err != nil {
+					continue
+				}
+				fmt.Printf("\tAgent: %v\n", result.Name)
+				fmt.Printf("\t\tStatus: %v\n", result.Status)
+				fmt.Printf("\t\tModel: %v\n", result.FoundationModel)
+				fmt.Printf("\t\tExecution role: %v\n", result.RoleArn)
+				if len(result.KnowledgeBases) > 0 {
+					fmt.Printf("\t\tKnowledge bases: %v\n", strings.Join(result.KnowledgeBases, ", "))
+				}
+				Emit("agent", regionalConfig.Region, result)
+			}
+			if len(agents) == 0 {
+				fmt.Println("\tNo agents in this region")
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws bedrock-agent list-knowledge-bases
+		knowledgeBases, err := ListKnowledgeBases(ctx, agentClient)
+		if err == nil {
+			for _, knowledgeBase := range knowledgeBases {
+				result, err := GetKnowledgeBaseResult(ctx, agentClient, aws.ToString(knowledgeBase.KnowledgeBaseId))
+				if err != nil {
+					continue
+				}
+				fmt.Printf("\tKnowledge base: %v\n", result.Name)
+				fmt.Printf("\t\tStatus: %v\n", result.Status)
+				fmt.Printf("\t\tService role: %v\n", result.RoleArn)
+				for _, dataSource := range result.DataSources {
+					fmt.Printf("\t\tData source: %v (%v) from %v\n", dataSource.Name, dataSource.Type, dataSource.Location)
+					if dataSource.CredentialsSecretArn != "" {
+						fmt.Printf("\t\t\tCredentials: %v\n", dataSource.CredentialsSecretArn)
+					}
+					if dataSource.Type != string(bedrockagenttypes.DataSourceTypeS3) {
+						continue
+					}
+					// The documents ingested into a knowledge base are whatever someone thought worth searching
+					if dataSource.BucketOwner != "" && dataSource.BucketOwner != accountId {
+						fmt.Printf("\t\t\t[!] Bucket belongs to external account %v\n", dataSource.BucketOwner)
+						EmitExposedFinding(regionalConfig.Region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Knowledge base ingests documents from a bucket in external account %v", dataSource.BucketOwner))
+						continue
+					}
+					fmt.Println("\t\t\t[-] Bucket holds the documents the knowledge base answers from")
+				}
+				Emit("knowledge-base", regionalConfig.Region, result)
+			}
+			if len(knowledgeBases) == 0 {
+				fmt.Println("\tNo knowledge bases in this region")
+			}
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetAgentResult(ctx context.Context, agentClient *bedrockagent.Client, agentId string) (AgentResult, error) {
+	// The execution role and model are only returned by get-agent
+	// i.e. aws bedrock-agent get-agent --agent-id <agent-id>
+	agent, err := agentClient.GetAgent(ctx, &bedrockagent.GetAgentInput{AgentId: aws.String(agentId)})
+	if err != nil {
+		fmt.Printf("Couldn't get the agent %v. Here's why: %v\n", agentId, err)
+		return AgentResult{}, err
+	}
+	result := AgentResult{
+		Name:            aws.ToString(agent.Agent.AgentName),
+		Arn:             aws.ToString(agent.Agent.AgentArn),
+		Status:          string(agent.Agent.AgentStatus),
+		FoundationModel: aws.ToString(agent.Agent.FoundationModel),
+		RoleArn:         aws.ToString(agent.Agent.AgentResourceRoleArn),
+	}
+
+	// The working draft is what's being changed, and what every new version is made from
+	// i.e. aws bedrock-agent list-agent-knowledge-bases --agent-id <agent-id> --agent-version DRAFT
+	paginator := bedrockagent.NewListAgentKnowledgeBasesPaginator(agentClient, &bedrockagent.ListAgentKnowledgeBasesInput{
+		AgentId:      aws.String(agentId),
+		AgentVersion: aws.String("DRAFT"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the knowledge bases of the agent %v. Here's why: %v\n", agentId, err)
+			break
+		}
+		for _, knowledgeBase := range page.AgentKnowledgeBaseSummaries {
+			result.KnowledgeBases = append(result.KnowledgeBases, aws.ToString(knowledgeBase.KnowledgeBaseId))
+		}
+	}
+
+	return result, nil
+}
+
+func GetKnowledgeBaseResult(ctx context.Context, agentClient *bedrockagent.Client, knowledgeBaseId string) (KnowledgeBaseResult, error) {
+	// i.e. aws bedrock-agent get-knowledge-base --knowledge-base-id <knowledge-base-id>
+	knowledgeBase, err := agentClient.GetKnowledgeBase(ctx, &bedrockagent.GetKnowledgeBaseInput{KnowledgeBaseId: aws.String(knowledgeBaseId)})
+	if err != nil {
+		fmt.Printf("Couldn't get the knowledge base %v. Here's why: %v\n", knowledgeBaseId, err)
+		return KnowledgeBaseResult{}, err
+	}
+	result := KnowledgeBaseResult{
+		Name:    aws.ToString(knowledgeBase.KnowledgeBase.Name),
+		Arn:     aws.ToString(knowledgeBase.KnowledgeBase.KnowledgeBaseArn),
+		Status:  string(knowledgeBase.KnowledgeBase.Status),
+		RoleArn: aws.ToString(knowledgeBase.KnowledgeBase.RoleArn),
+	}
+
+	// i.e. aws bedrock-agent list-data-sources --knowledge-base-id <knowledge-base-id>
+	var dataSourceIds []string
+	paginator := bedrockagent.NewListDataSourcesPaginator(agentClient, &bedrockagent.ListDataSourcesInput{KnowledgeBaseId: aws.String(knowledgeBaseId)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the data sources of the knowledge base %v. Here's why: %v\n", knowledgeBaseId, err)
+			return result, nil
+		}
+		for _, dataSource := range page.DataSourceSummaries {
+			dataSourceIds = append(dataSourceIds, aws.ToString(dataSource.DataSourceId))
+		}
+	}
+
+	for _, dataSourceId := range dataSourceIds {
+		// i.e. aws bedrock-agent get-data-source --knowledge-base-id <knowledge-base-id> --data-source-id <data-source-id>
+		dataSource, err := agentClient.GetDataSource(ctx, &bedrockagent.GetDataSourceInput{
+			KnowledgeBaseId: aws.String(knowledgeBaseId),
+			DataSourceId:    aws.String(dataSourceId),
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the data source %v. Here's why: %v\n", dataSourceId, err)
+			continue
+		}
+		result.DataSources = append(result.DataSources, DescribeDataSource(dataSource.DataSource))
+	}
+
+	return result, nil
+}
+
+func DescribeDataSource(dataSource *bedrockagenttypes.DataSource) KnowledgeBaseDataSourceResult {
+	result := KnowledgeBaseDataSourceResult{Name: aws.ToString(dataSource.Name)}
+	configuration := dataSource.DataSourceConfiguration
+	if configuration == nil {
+		return result
+	}
+	result.Type = string(configuration.Type)
+
+	// Each connector keeps where it reads from, and the secret it reads with, in its own shape
+	switch {
+	case configuration.S3Configuration != nil:
+		result.Location = aws.ToString(configuration.S3Configuration.BucketArn)
+		result.BucketOwner = aws.ToString(configuration.S3Configuration.BucketOwnerAccountId)
+	case configuration.WebConfiguration != nil && configuration.WebConfiguration.SourceConfiguration != nil &&
+		configuration.WebConfiguration.SourceConfiguration.UrlConfiguration != nil:
+		var urls []string
+		for _, seedUrl := range configuration.WebConfiguration.SourceConfiguration.UrlConfiguration.SeedUrls {
+			urls = append(urls, aws.ToString(seedUrl.Url))
+		}
+		result.Location = strings.Join(urls, ", ")
+	case configuration.ConfluenceConfiguration != nil && configuration.ConfluenceConfiguration.SourceConfiguration != nil:
+		result.Location = aws.ToString(configuration.ConfluenceConfiguration.SourceConfiguration.HostUrl)
+		result.CredentialsSecretArn = aws.ToString(configuration.ConfluenceConfiguration.SourceConfiguration.CredentialsSecretArn)
+	case configuration.SalesforceConfiguration != nil && configuration.SalesforceConfiguration.SourceConfiguration != nil:
+		result.Location = aws.ToString(configuration.SalesforceConfiguration.SourceConfiguration.HostUrl)
+		result.CredentialsSecretArn = aws.ToString(configuration.SalesforceConfiguration.SourceConfiguration.CredentialsSecretArn)
+	case configuration.SharePointConfiguration != nil && configuration.SharePointConfiguration.SourceConfiguration != nil:
+		result.Location = strings.Join(configuration.SharePointConfiguration.SourceConfiguration.SiteUrls, ", ")
+		result.CredentialsSecretArn = aws.ToString(configuration.SharePointConfiguration.SourceConfiguration.CredentialsSecretArn)
+	}
+
+	return result
+}
+
+func ListFoundationModels(ctx context.Context, bedrockClient *bedrock.Client) ([]bedrocktypes.FoundationModelSummary, error) {
+	// Every foundation model offered in the region, which isn't paginated
+	output, err := bedrockClient.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+	if err != nil {
+		fmt.Printf("Couldn't list the foundation models. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return output.ModelSummaries, nil
+}
+
+func ListProvisionedModelThroughputs(ctx context.Context, bedrockClient *bedrock.Client) ([]bedrocktypes.ProvisionedModelSummary, error) {
+	var throughputs []bedrocktypes.ProvisionedModelSummary
+	paginator := bedrock.NewListProvisionedModelThroughputsPaginator(bedrockClient, &bedrock.ListProvisionedModelThroughputsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(throughputs)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the provisioned throughput. Here's why: %v\n", err)
+			return nil, err
+		}
+		throughputs = append(throughputs, page.ProvisionedModelSummaries...)
+	}
+
+	return LimitItems(throughputs), nil
+}
+
+func ListCustomModels(ctx context.Context, bedrockClient *bedrock.Client) ([]bedrocktypes.CustomModelSummary, error) {
+	var models []bedrocktypes.CustomModelSummary
+	paginator := bedrock.NewListCustomModelsPaginator(bedrockClient, &bedrock.ListCustomModelsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(models)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the custom models. Here's why: %v\n", err)
+			return nil, err
+		}
+		models = append(models, page.ModelSummaries...)
+	}
+
+	return LimitItems(models), nil
+}
+
+func ListAgents(ctx context.Context, agentClient *bedrockagent.Client) ([]bedrockagenttypes.AgentSummary, error) {
+	var agents []bedrockagenttypes.AgentSummary
+	paginator := bedrockagent.NewListAgentsPaginator(agentClient, &bedrockagent.ListAgentsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(agents)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the agents. Here's why: %v\n", err)
+			return nil, err
+		}
+		agents = append(agents, page.AgentSummaries...)
+	}
+
+	return LimitItems(agents), nil
+}
+
+func ListKnowledgeBases(ctx context.Context, agentClient *bedrockagent.Client) ([]bedrockagenttypes.KnowledgeBaseSummary, error) {
+	var knowledgeBases []bedrockagenttypes.KnowledgeBaseSummary
+	paginator := bedrockagent.NewListKnowledgeBasesPaginator(agentClient, &bedrockagent.ListKnowledgeBasesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(knowledgeBases)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the knowledge bases. Here's why: %v\n", err)
+			return nil, err
+		}
+		knowledgeBases = append(knowledgeBases, page.KnowledgeBaseSummaries...)
+	}
+
+	return LimitItems(knowledgeBases), nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// Benchmarks against a simulated large account, served from memory instead of AWS, so
+// parallelism, caching and memory changes can be measured before and after, i.e.
+//
+//	make bench
+//	benchstat old.txt bench.txt
+
+const MOCK_ACCOUNT_ID = "123456789012"
+
+const MOCK_POLICY_DOCUMENT = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:ListBucket","logs:PutLogEvents"],"Resource":"*"}]}`
+
+const MOCK_TRUST_POLICY = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"lambda.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+
+// An account with this many of each resource, answering IAM and EC2 query API calls
+type mockAccount struct {
+	Roles              int
+	Policies           int
+	InstancesPerRegion int
+	// Added to every call, API round trips are what parallelism hides
+	Latency time.Duration
+	calls   atomic.Int64
+}
+
+func largeAccount(latency time.Duration) *mockAccount {
+	return &mockAccount{Roles: 5000, Policies: 10000, InstancesPerRegion: 200, Latency: latency}
+}
+
+func (a *mockAccount) Config(region string) aws.Config {
+	return aws.Config{
+		Region:      region,
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  a,
+		Retryer:     func() aws.Retryer { return aws.NopRetryer{} },
+	}
+}
+
+func (a *mockAccount) Do(request *http.Request) (*http.Response, error) {
+	// Both IAM and EC2 take form-encoded query requests
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	a.calls.Add(1)
+	if a.Latency > 0 {
+		time.Sleep(a.Latency)
+	}
+
+	response, ok := a.respond(params.Get("Action"), params)
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusBadRequest
+		response = fmt.Sprintf(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code><Message>%v isn't mocked</Message></Error><RequestId>mock</RequestId></ErrorResponse>`, params.Get("Action"))
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    request,
+	}, nil
+}
+
+func (a *mockAccount) respond(action string, params url.Values) (string, bool) {
+	switch action {
+	case "ListRoles":
+		return iamPage(action, "Roles", params, a.Roles, func(i int) string {
+			return fmt.Sprintf(`<member><Path>/</Path><RoleName>role-%[1]v</RoleName><RoleId>AROA%016[1]v</RoleId><Arn>arn:aws:iam::%[2]v:role/role-%[1]v</Arn><CreateDate>2024-01-01T00:00:00Z</CreateDate><AssumeRolePolicyDocument>%[3]v</AssumeRolePolicyDocument></member>`,
+				i, MOCK_ACCOUNT_ID, url.QueryEscape(MOCK_TRUST_POLICY))
+		}), true
+	case "ListPolicies":
+		return iamPage(action, "Policies", params, a.Policies, func(i int) string {
+			return "<member>" + mockPolicy(i) + "</member>"
+		}), true
+	case "ListAttachedRolePolicies":
+		// Every role has one customer managed policy attached
+		roleIndex, _ := strconv.Atoi(strings.TrimPrefix(params.Get("RoleName"), "role-"))
+		policyIndex := roleIndex % max(a.Policies, 1)
+		return iamResult(action, fmt.Sprintf(`<AttachedPolicies><member><PolicyName>policy-%[1]v</PolicyName><PolicyArn>arn:aws:iam::%[2]v:policy/policy-%[1]v</PolicyArn></member></AttachedPolicies><IsTruncated>false</IsTruncated>`,
+			policyIndex, MOCK_ACCOUNT_ID)), true
+	case "GetPolicy":
+		policyIndex, _ := strconv.Atoi(params.Get("PolicyArn")[strings.LastIndex(params.Get("PolicyArn"), "-")+1:])
+		return iamResult(action, "<Policy>"+mockPolicy(policyIndex)+"</Policy>"), true
+	case "GetPolicyVersion":
+		return iamResult(action, fmt.Sprintf(`<PolicyVersion><Document>%v</Document><VersionId>v1</VersionId><IsDefaultVersion>true</IsDefaultVersion><CreateDate>2024-01-01T00:00:00Z</CreateDate></PolicyVersion>`,
+			url.QueryEscape(MOCK_POLICY_DOCUMENT))), true
+	case "ListRolePolicies":
+		return iamResult(action, `<PolicyNames><member>inline</member></PolicyNames><IsTruncated>false</IsTruncated>`), true
+	case "GetRolePolicy":
+		return iamResult(action, fmt.Sprintf(`<RoleName>%v</RoleName><PolicyName>inline</PolicyName><PolicyDocument>%v</PolicyDocument>`,
+			params.Get("RoleName"), url.QueryEscape(MOCK_POLICY_DOCUMENT))), true
+	case "DescribeInstances":
+		var reservations strings.Builder
+		for i := 0; i < a.InstancesPerRegion; i++ {
+			fmt.Fprintf(&reservations, `<item><reservationId>r-%017[1]v</reservationId><ownerId>%[2]v</ownerId><instancesSet><item><instanceId>i-%017[1]v</instanceId><instanceType>t3.micro</instanceType><instanceState><code>16</code><name>running</name></instanceState><tagSet><item><key>Name</key><value>web-%[1]v</value></item></tagSet></item></instancesSet></item>`,
+				i, MOCK_ACCOUNT_ID)
+		}
+		return fmt.Sprintf(`<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>mock</requestId><reservationSet>%v</reservationSet></DescribeInstancesResponse>`, reservations.String()), true
+	}
+
+	return "", false
+}
+
+func mockPolicy(i int) string {
+	return fmt.Sprintf(`<PolicyName>policy-%[1]v</PolicyName><PolicyId>ANPA%016[1]v</PolicyId><Arn>arn:aws:iam::%[2]v:policy/policy-%[1]v</Arn><Path>/</Path><DefaultVersionId>v1</DefaultVersionId><AttachmentCount>1</AttachmentCount><IsAttachable>true</IsAttachable><CreateDate>2024-01-01T00:00:00Z</CreateDate><UpdateDate>2024-01-01T00:00:00Z</UpdateDate>`,
+		i, MOCK_ACCOUNT_ID)
+}
+
+func iamResult(action string, result string) string {
+	return fmt.Sprintf(`<%[1]vResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/"><%[1]vResult>%[2]v</%[1]vResult><ResponseMetadata><RequestId>mock</RequestId></ResponseMetadata></%[1]vResponse>`, action, result)
+}
+
+func iamPage(action string, listElement string, params url.Values, total int, member func(i int) string) string {
+	// IAM pages with an opaque marker, here just the index of the next item
+	start, _ := strconv.Atoi(params.Get("Marker"))
+	pageSize, err := strconv.Atoi(params.Get("MaxItems"))
+	if err != nil {
+		pageSize = 100
+	}
+	end := min(start+pageSize, total)
+
+	var members strings.Builder
+	for i := start; i < end; i++ {
+		members.WriteString(member(i))
+	}
+	page := fmt.Sprintf("<%[1]v>%[2]v</%[1]v><IsTruncated>%[3]v</IsTruncated>", listElement, members.String(), end < total)
+	if end < total {
+		page += fmt.Sprintf("<Marker>%v</Marker>", end)
+	}
+
+	return iamResult(action, page)
+}
+
+func useRunSettings(b *testing.B, concurrency int, regions []string) {
+	previousConcurrency, previousRegions := Concurrency, SelectedRegions
+	Concurrency, SelectedRegions = concurrency, regions
+	useDataStore(b)
+	b.Cleanup(func() {
+		Concurrency, SelectedRegions = previousConcurrency, previousRegions
+	})
+}
+
+func reportCalls(b *testing.B, account *mockAccount) {
+	b.ReportMetric(float64(account.calls.Load())/float64(b.N), "calls/op")
+}
+
+func BenchmarkListLargeAccount(b *testing.B) {
+	account := largeAccount(0)
+	iamClient := iam.NewFromConfig(account.Config("us-east-1"))
+	ctx := context.Background()
+
+	b.Run(fmt.Sprintf("roles=%v", account.Roles), func(b *testing.B) {
+		account.calls.Store(0)
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			roles, err := ListAllRoles(ctx, iamClient)
+			if err != nil || len(roles) != account.Roles {
+				b.Fatalf("listed %v roles, %v", len(roles), err)
+			}
+		}
+		reportCalls(b, account)
+	})
+	b.Run(fmt.Sprintf("policies=%v", account.Policies), func(b *testing.B) {
+		account.calls.Store(0)
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			policies, err := ListCustomerManagedPolicies(ctx, iamClient)
+			if err != nil || len(policies) != account.Policies {
+				b.Fatalf("listed %v policies, %v", len(policies), err)
+			}
+		}
+		reportCalls(b, account)
+	})
+}
+
+func BenchmarkSharedInventory(b *testing.B) {
+	// Several modules after the same roles at once should still list them only once per target
+	account := largeAccount(time.Millisecond)
+	iamClient := iam.NewFromConfig(account.Config("us-east-1"))
+	useRunSettings(b, Concurrency, nil)
+
+	for n := 0; n < b.N; n++ {
+		Store = NewDataStore()
+		var wg sync.WaitGroup
+		for _, module := range []string{"roles", "orphans", "instance-roles", "privesc"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if roles, err := CachedRoles(context.Background(), iamClient); err != nil || len(roles) != account.Roles {
+					b.Errorf("%v got %v roles, %v", module, len(roles), err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	reportCalls(b, account)
+}
+
+func BenchmarkRolePermissions(b *testing.B) {
+	// Looking up what every role can do, the slowest part of the roles module, at different --concurrency
+	account := largeAccount(100 * time.Microsecond)
+	iamClient := iam.NewFromConfig(account.Config("us-east-1"))
+	roles, err := ListAllRoles(context.Background(), iamClient)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, concurrency := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("concurrency=%v", concurrency), func(b *testing.B) {
+			useRunSettings(b, concurrency, nil)
+			account.calls.Store(0)
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				permissions := make([]*RolePermissions, len(roles))
+				ForEachConcurrently(context.Background(), len(roles), func(ctx context.Context, i int) {
+					permissions[i], _ = GetRolePermissions(ctx, iamClient, roles[i])
+				}, func(i int) {
+					if permissions[i] == nil || len(permissions[i].Policies) != 2 {
+						b.Errorf("%v has %v policies, want 2", *roles[i].RoleName, permissions[i])
+					}
+				})
+			}
+			reportCalls(b, account)
+		})
+	}
+}
+
+func BenchmarkRegions(b *testing.B) {
+	// Building the instance inventory across 50 regions
+	account := largeAccount(time.Millisecond)
+	var regions []string
+	for i := 0; i < 50; i++ {
+		regions = append(regions, fmt.Sprintf("mock-region-%v", i))
+	}
+	useRunSettings(b, Concurrency, regions)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		Store = NewDataStore()
+		var instances atomic.Int64
+		ForEachRegion(context.Background(), account.Config("us-east-1"), func(ctx context.Context, regionalConfig aws.Config) error {
+			regionInstances, err := CachedInstances(ctx, regionalConfig)
+			instances.Add(int64(len(regionInstances)))
+			return err
+		})
+		if instances.Load() != int64(len(regions)*account.InstancesPerRegion) {
+			b.Fatalf("found %v instances, want %v", instances.Load(), len(regions)*account.InstancesPerRegion)
+		}
+	}
+	reportCalls(b, account)
+}
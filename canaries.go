@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/synthetics"
+	syntheticstypes "github.com/aws/aws-sdk-go-v2/service/synthetics/types"
+)
+
+func RunCanariesModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Call the describe-canaries API to get each canary's role and where its script lives
+		// i.e. aws synthetics describe-canaries
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting CloudWatch Synthetics canaries for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		canaries, err := ListAllCanaries(ctx, synthetics.NewFromConfig(regionalConfig))
+		if err != nil {
+			return err
+		}
+
+		for _, canary := range canaries {
+			fmt.Printf("\tCanary name: %v\n", *canary.Name)
+			if canary.Status != nil {
+				fmt.Printf("\tState: %v\n", canary.Status.State)
+			}
+			fmt.Printf("\tRuntime: %v\n", aws.ToString(canary.RuntimeVersion))
+			fmt.Printf("\tExecution role: %v\n", aws.ToString(canary.ExecutionRoleArn))
+			if canary.Code != nil {
+				fmt.Printf("\tHandler: %v\n", aws.ToString(canary.Code.Handler))
+				// The script is stored as a Lambda layer, which is where it can be downloaded from
+				fmt.Printf("\tScript location: %v\n", aws.ToString(canary.Code.SourceLocationArn))
+				if DownloadCode && canary.Code.SourceLocationArn != nil {
+					DownloadCanaryScript(ctx, lambda.NewFromConfig(regionalConfig), regionalConfig.Region, *canary.Name, *canary.Code.SourceLocationArn)
+				}
+			}
+			fmt.Printf("\tArtifacts: %v\n", aws.ToString(canary.ArtifactS3Location))
+			if canary.RunConfig != nil && canary.RunConfig.ActiveTracing != nil && *canary.RunConfig.ActiveTracing {
+				fmt.Println("\tActive tracing: enabled")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("canary", regionalConfig.Region, canary)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func DownloadCanaryScript(ctx context.Context, lambdaClient *lambda.Client, region string, canaryName string, layerArn string) {
+	// The script layer is only available through the pre-signed URL get-layer-version-by-arn returns
+	// i.e. aws lambda get-layer-version-by-arn --arn <layer-version-arn> --query Content.Location
+	layerDetails, err := lambdaClient.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+		Arn: aws.String(layerArn),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the script layer for %v. Here's why: %v\n", canaryName, err)
+		return
+	}
+	if layerDetails.Content == nil || layerDetails.Content.Location == nil {
+		return
+	}
+
+	zipped, err := DownloadAsset(ctx, *layerDetails.Content.Location, MAX_CODE_PACKAGE_SIZE)
+	if err != nil {
+		fmt.Printf("Couldn't download the script for %v. Here's why: %v\n", canaryName, err)
+		return
+	}
+
+	lootPath, err := SaveLoot(filepath.Join("canaries", region, canaryName+".zip"), zipped)
+	if err != nil {
+		return
+	}
+	fmt.Printf("\tScript saved to: %v\n", lootPath)
+
+	findings, err := ScanZipForSecrets(lootPath, zipped)
+	if err != nil {
+		fmt.Printf("Couldn't scan the script for %v. Here's why: %v\n", canaryName, err)
+	}
+	PrintSecretFindings(findings)
+}
+
+func ListAllCanaries(ctx context.Context, syntheticsClient *synthetics.Client) ([]syntheticstypes.Canary, error) {
+	// Get every canary in the region
+	var canaries []syntheticstypes.Canary
+	paginator := synthetics.NewDescribeCanariesPaginator(syntheticsClient, &synthetics.DescribeCanariesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(canaries)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the canaries. Here's why: %v\n", err)
+			return nil, err
+		}
+		canaries = append(canaries, page.Canaries...)
+	}
+
+	return LimitItems(canaries), nil
+}
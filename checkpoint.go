@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Checkpoint file to pick an interrupted run back up from, set with --resume
+var ResumeFlag = ""
+
+// What an interrupted run got through, written when it's stopped with Ctrl-C
+type Checkpoint struct {
+	Modules []string `json:"modules"`
+	// Module names, prefixed with the profile or account and a slash when there's more than one
+	Completed   []string `json:"completed"`
+	Regions     string   `json:"regions,omitempty"`
+	AllProfiles bool     `json:"allProfiles,omitempty"`
+	AuditRole   string   `json:"auditRole,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+}
+
+func SaveCheckpoint(checkpoint Checkpoint) (string, error) {
+	content, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		fmt.Printf("Couldn't encode the checkpoint. Here's why: %v\n", err)
+		return "", err
+	}
+
+	return SaveLoot("checkpoint.json", content)
+}
+
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Couldn't read the checkpoint %v. Here's why: %v\n", path, err)
+		return checkpoint, err
+	}
+	if err := json.Unmarshal(content, &checkpoint); err != nil {
+		fmt.Printf("Couldn't parse the checkpoint %v. Here's why: %v\n", path, err)
+		return checkpoint, err
+	}
+
+	return checkpoint, nil
+}
+
+func CheckpointKey(target string, module string) string {
+	if target != "" {
+		return target + "/" + module
+	}
+
+	return module
+}
+
+func (c Checkpoint) IsCompleted(key string) bool {
+	for _, completed := range c.Completed {
+		if completed == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+)
+
+// Event data store "who did what" questions are answered from instead of event history, set with --cloudtrail-lake
+var CloudTrailLakeStore = ""
+
+// How many days back Lake queries look, set with --lake-days
+var LakeDays = 30
+
+// Picks the ARNs out of the resources column
+var LAKE_RESOURCE_ARN_PATTERN = regexp.MustCompile(`(?i)\barn=(arn:[^,}\]\s]+)`)
+
+// How often to check whether a Lake query has finished
+const LAKE_QUERY_POLL_INTERVAL = 2 * time.Second
+
+// How many of the principal's most recent events the timeline takes from Lake, across every region
+const TIMELINE_LAKE_EVENTS = 200
+
+// What an activity query is narrowed down to, empty fields match everything
+type LakeActivityFilter struct {
+	// Matched anywhere in the caller's ARN, i.e. a user name, role name or session name
+	Principal string `json:"principal,omitempty"`
+	// Matched against the whole of the caller's ARN, so only one user, role session or the root user
+	PrincipalArn string `json:"principalArn,omitempty"`
+	EventSource  string `json:"eventSource,omitempty"`
+	EventName    string `json:"eventName,omitempty"`
+	SourceIp     string `json:"sourceIp,omitempty"`
+	// Only calls that failed, i.e. AccessDenied
+	ErrorsOnly bool `json:"errorsOnly"`
+	Days       int  `json:"days"`
+	Limit      int  `json:"limit"`
+}
+
+type LakeEventResult struct {
+	Time        time.Time `json:"time"`
+	EventSource string    `json:"eventSource"`
+	EventName   string    `json:"eventName"`
+	Region      string    `json:"region"`
+	Principal   string    `json:"principal"`
+	AccessKeyId string    `json:"accessKeyId,omitempty"`
+	SourceIp    string    `json:"sourceIp,omitempty"`
+	ErrorCode   string    `json:"errorCode,omitempty"`
+	Resources   []string  `json:"resources"`
+}
+
+func RunActivity(ctx context.Context, filter LakeActivityFilter) error {
+	if CloudTrailLakeStore == "" {
+		return fmt.Errorf("activity needs a CloudTrail Lake event data store, given with --cloudtrail-lake")
+	}
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Lake stores events from every region and, for organization stores, every account, so one query
+	// answers what would take event history a lookup per region, and isn't limited to 90 days
+	// i.e. aws cloudtrail start-query --query-statement <sql>, aws cloudtrail get-query-results --query-id <query-id>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Querying CloudTrail Lake for the last %v days of activity...\n", filter.Days)
+	fmt.Println(MAJOR_SEPARATOR)
+	events, err := QueryLakeEvents(ctx, cloudtrail.NewFromConfig(sdkConfig), CloudTrailLakeStore, filter)
+	if err != nil {
+		return err
+	}
+	CurrentModule = "activity"
+
+	for _, event := range events {
+		PrintLakeEvent(event)
+		Emit("cloudtrail-event", event.Region, event)
+	}
+	if len(events) == 0 {
+		fmt.Println("\tNo matching events")
+	} else if len(events) == filter.Limit {
+		fmt.Printf("\t[-] Stopped at %v events, raise --limit to see more\n", filter.Limit)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return WriteResults()
+}
+
+func PrintLakeEvent(event LakeEventResult) {
+	description := fmt.Sprintf("%v %v", event.EventSource, event.EventName)
+	if len(event.Resources) > 0 {
+		description += " on " + strings.Join(event.Resources, ", ")
+	}
+	fmt.Printf("\t%v  %v (%v)\n", event.Time.UTC().Format(time.RFC3339), description, event.Region)
+	fmt.Printf("\t\tBy %v", event.Principal)
+	if event.AccessKeyId != "" {
+		fmt.Printf(" with %v", event.AccessKeyId)
+	}
+	if event.SourceIp != "" {
+		fmt.Printf(" from %v", event.SourceIp)
+	}
+	fmt.Println()
+	if event.ErrorCode != "" {
+		fmt.Printf("\t\t[-] Failed with %v\n", event.ErrorCode)
+	}
+}
+
+func LakeActivityQuery(store string, filter LakeActivityFilter) string {
+	// Queries name the event data store by its ID, the last part of its ARN
+	storeId := store[strings.LastIndex(store, "/")+1:]
+	conditions := []string{fmt.Sprintf("eventTime > '%v'", time.Now().UTC().AddDate(0, 0, -filter.Days).Format("2006-01-02 15:04:05"))}
+	if filter.Principal != "" {
+		conditions = append(conditions, fmt.Sprintf("userIdentity.arn LIKE '%%%v%%'", LakeLiteral(filter.Principal)))
+	}
+	if filter.PrincipalArn != "" {
+		conditions = append(conditions, fmt.Sprintf("userIdentity.arn = '%v'", LakeLiteral(filter.PrincipalArn)))
+	}
+	if filter.EventSource != "" {
+		conditions = append(conditions, fmt.Sprintf("eventSource = '%v'", LakeLiteral(filter.EventSource)))
+	}
+	if filter.EventName != "" {
+		conditions = append(conditions, fmt.Sprintf("eventName = '%v'", LakeLiteral(filter.EventName)))
+	}
+	if filter.SourceIp != "" {
+		conditions = append(conditions, fmt.Sprintf("sourceIPAddress = '%v'", LakeLiteral(filter.SourceIp)))
+	}
+	if filter.ErrorsOnly {
+		conditions = append(conditions, "errorCode IS NOT NULL")
+	}
+
+	// Columns are aliased so the results are keyed by names that don't depend on the nesting
+	return fmt.Sprintf("SELECT eventTime AS eventTime, eventSource AS eventSource, eventName AS eventName, awsRegion AS awsRegion, "+
+		"userIdentity.arn AS principal, userIdentity.accessKeyId AS accessKeyId, sourceIPAddress AS sourceIp, errorCode AS errorCode, "+
+		"resources AS resources FROM %v WHERE %v ORDER BY eventTime DESC LIMIT %v",
+		storeId, strings.Join(conditions, " AND "), filter.Limit)
+}
+
+func LakeLiteral(value string) string {
+	// Single quotes are doubled inside SQL string literals
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+func QueryLakeEvents(ctx context.Context, cloudtrailClient *cloudtrail.Client, store string, filter LakeActivityFilter) ([]LakeEventResult, error) {
+	rows, err := QueryLake(ctx, cloudtrailClient, LakeActivityQuery(store, filter))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []LakeEventResult
+	for _, row := range rows {
+		event := LakeEventResult{
+			EventSource: row["eventSource"],
+			EventName:   row["eventName"],
+			Region:      row["awsRegion"],
+			Principal:   row["principal"],
+			AccessKeyId: row["accessKeyId"],
+			SourceIp:    row["sourceIp"],
+			ErrorCode:   row["errorCode"],
+		}
+		// i.e. 2024-01-01 12:00:00.000
+		for _, layout := range []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05", time.RFC3339} {
+			if when, err := time.Parse(layout, row["eventTime"]); err == nil {
+				event.Time = when
+				break
+			}
+		}
+		// Arrays of rows come back flattened to text, i.e. [{accountId=123456789012, type=AWS::S3::Bucket, arn=arn:aws:s3:::bucket}]
+		for _, match := range LAKE_RESOURCE_ARN_PATTERN.FindAllStringSubmatch(row["resources"], -1) {
+			event.Resources = append(event.Resources, match[1])
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func QueryLake(ctx context.Context, cloudtrailClient *cloudtrail.Client, statement string) ([]map[string]string, error) {
+	// i.e. aws cloudtrail start-query --query-statement <sql>
+	started, err := cloudtrailClient.StartQuery(ctx, &cloudtrail.StartQueryInput{QueryStatement: aws.String(statement)})
+	if err != nil {
+		fmt.Printf("Couldn't start the CloudTrail Lake query. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	// Queries scan the whole time range asked for, which takes from seconds to minutes
+	// i.e. aws cloudtrail get-query-results --query-id <query-id>
+	var rows []map[string]string
+	var nextToken *string
+	for {
+		page, err := cloudtrailClient.GetQueryResults(ctx, &cloudtrail.GetQueryResultsInput{QueryId: started.QueryId, NextToken: nextToken})
+		if err != nil {
+			fmt.Printf("Couldn't get the CloudTrail Lake query results. Here's why: %v\n", err)
+			return nil, err
+		}
+		switch page.QueryStatus {
+		case cloudtrailtypes.QueryStatusQueued, cloudtrailtypes.QueryStatusRunning:
+			select {
+			case <-time.After(LAKE_QUERY_POLL_INTERVAL):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		case cloudtrailtypes.QueryStatusFinished:
+		default:
+			err := fmt.Errorf("query %v ended %v: %v", aws.ToString(started.QueryId), page.QueryStatus, aws.ToString(page.ErrorMessage))
+			fmt.Printf("Couldn't run the CloudTrail Lake query. Here's why: %v\n", err)
+			return nil, err
+		}
+
+		// Each row is a list of one-column maps, in the order of the SELECT
+		for _, resultRow := range page.QueryResultRows {
+			row := map[string]string{}
+			for _, column := range resultRow {
+				for name, value := range column {
+					row[name] = value
+				}
+			}
+			rows = append(rows, row)
+		}
+		if page.NextToken == nil {
+			break
+		}
+		nextToken = page.NextToken
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codeartifact"
+	codeartifacttypes "github.com/aws/aws-sdk-go-v2/service/codeartifact/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// What a principal needs to push a package into a repository, or pull one out of it
+var PACKAGE_PUBLISH_ACTIONS = []string{"codeartifact:PublishPackageVersion", "codeartifact:PutPackageMetadata"}
+var PACKAGE_READ_ACTIONS = []string{"codeartifact:ReadFromRepository"}
+
+// Every request to a repository needs a token from its domain first
+const DOMAIN_TOKEN_ACTION = "codeartifact:GetAuthorizationToken"
+
+type CodeArtifactDomainResult struct {
+	Name          string          `json:"name"`
+	Arn           string          `json:"arn"`
+	Owner         string          `json:"owner"`
+	EncryptionKey string          `json:"encryptionKey,omitempty"`
+	Policy        *PolicyDocument `json:"policy,omitempty"`
+	// Whether any AWS principal can get a token for the domain, and the other accounts that can
+	PublicTokens  bool     `json:"publicTokens"`
+	TokenAccounts []string `json:"tokenAccounts"`
+}
+
+type CodeArtifactRepositoryResult struct {
+	Name                 string `json:"name"`
+	Arn                  string `json:"arn"`
+	Domain               string `json:"domain"`
+	AdministratorAccount string `json:"administratorAccount"`
+	// Repositories in the same domain packages are pulled through from
+	Upstreams []string `json:"upstreams"`
+	// Public registries packages are pulled from, i.e. public:npmjs
+	ExternalConnections []string        `json:"externalConnections"`
+	Policy              *PolicyDocument `json:"policy,omitempty"`
+	// Whether any AWS principal can publish or read, and the other accounts that can
+	PublicPublish   bool     `json:"publicPublish"`
+	PublicRead      bool     `json:"publicRead"`
+	PublishAccounts []string `json:"publishAccounts"`
+	ReadAccounts    []string `json:"readAccounts"`
+}
+
+func RunCodeArtifactModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		codeartifactClient := codeartifact.NewFromConfig(regionalConfig)
+
+		// i.e. aws codeartifact list-domains
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting CodeArtifact domains and repositories in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		domains, err := ListCodeArtifactDomains(ctx, codeartifactClient)
+		if err != nil {
+			return err
+		}
+		for _, domain := range domains {
+			result := CodeArtifactDomainResult{
+				Name:          aws.ToString(domain.Name),
+				Arn:           aws.ToString(domain.Arn),
+				Owner:         aws.ToString(domain.Owner),
+				EncryptionKey: aws.ToString(domain.EncryptionKey),
+			}
+
+			// i.e. aws codeartifact get-domain-permissions-policy --domain <domain>
+			policy, err := codeartifactClient.GetDomainPermissionsPolicy(ctx, &codeartifact.GetDomainPermissionsPolicyInput{Domain: domain.Name, DomainOwner: domain.Owner})
+			var notFound *codeartifacttypes.ResourceNotFoundException
+			if err != nil && !errors.As(err, &notFound) {
+				fmt.Printf("Couldn't get the policy for %v. Here's why: %v\n", result.Name, err)
+			} else if err == nil && policy.Policy != nil {
+				result.Policy, err = ParsePolicyDocument(aws.ToString(policy.Policy.Document))
+				if err != nil {
+					fmt.Printf("Couldn't parse the policy for %v. Here's why: %v\n", result.Name, err)
+				}
+			}
+			result.PublicTokens, result.TokenAccounts = AnalyseResourcePolicy(result.Policy, []string{DOMAIN_TOKEN_ACTION}, account, ConditionRestricted)
+
+			fmt.Printf("\tDomain: %v\n", result.Name)
+			fmt.Printf("\tOwner: %v\n", result.Owner)
+			if result.EncryptionKey != "" {
+				fmt.Printf("\tEncrypted with: %v\n", result.EncryptionKey)
+			}
+			if result.Policy != nil {
+				fmt.Printf("\tPolicy:\n%v\n", FormatPolicyDocument(result.Policy))
+			}
+			if result.PublicTokens {
+				fmt.Println("\t[!] Any AWS principal can get a token for the domain")
+				EmitExposedFinding(regionalConfig.Region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any AWS principal can get a token for CodeArtifact domain %v", result.Name))
+			}
+			for _, tokenAccount := range result.TokenAccounts {
+				fmt.Printf("\t[-] Account %v can get a token for the domain\n", tokenAccount)
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("codeartifact-domain", regionalConfig.Region, result)
+
+			// i.e. aws codeartifact list-repositories-in-domain --domain <domain>
+			repositories, err := ListCodeArtifactRepositories(ctx, codeartifactClient, domain)
+			if err != nil {
+				continue
+			}
+			for _, repository := range repositories {
+				repositoryResult := GetCodeArtifactRepositoryResult(ctx, codeartifactClient, repository, account)
+				fmt.Printf("\tRepository: %v/%v\n", repositoryResult.Domain, repositoryResult.Name)
+				fmt.Printf("\tAdministrator account: %v\n", repositoryResult.AdministratorAccount)
+				for _, upstream := range repositoryResult.Upstreams {
+					fmt.Printf("\tUpstream: %v\n", upstream)
+				}
+				for _, connection := range repositoryResult.ExternalConnections {
+					fmt.Printf("\tExternal connection: %v\n", connection)
+				}
+				if repositoryResult.Policy != nil {
+					fmt.Printf("\tPolicy:\n%v\n", FormatPolicyDocument(repositoryResult.Policy))
+				}
+
+				// Anyone who can publish can plant a package every build pulling from the repository installs
+				if repositoryResult.PublicPublish {
+					fmt.Println("\t[!] Any AWS principal can publish packages")
+					EmitExposedFinding(regionalConfig.Region, repositoryResult.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any AWS principal can publish packages to CodeArtifact repository %v", repositoryResult.Name))
+				}
+				for _, publishAccount := range repositoryResult.PublishAccounts {
+					fmt.Printf("\t[!] Account %v can publish packages\n", publishAccount)
+					EmitExposedFinding(regionalConfig.Region, repositoryResult.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Account %v can publish packages to CodeArtifact repository %v", publishAccount, repositoryResult.Name))
+				}
+				if repositoryResult.PublicRead {
+					fmt.Println("\t[!] Any AWS principal can read packages")
+					EmitExposedFinding(regionalConfig.Region, repositoryResult.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any AWS principal can read packages from CodeArtifact repository %v", repositoryResult.Name))
+				}
+				for _, readAccount := range repositoryResult.ReadAccounts {
+					fmt.Printf("\t[-] Account %v can read packages\n", readAccount)
+				}
+				// Internal package names that aren't taken here are looked up on the public registry too
+				if len(repositoryResult.ExternalConnections) > 0 {
+					fmt.Println("\t[-] Packages missing here are fetched from a public registry, internal names should be published or blocked to avoid dependency confusion")
+				}
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("codeartifact-repository", regionalConfig.Region, repositoryResult)
+			}
+		}
+		if len(domains) == 0 {
+			fmt.Println("\tNo domains in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetCodeArtifactRepositoryResult(ctx context.Context, codeartifactClient *codeartifact.Client, repository codeartifacttypes.RepositorySummary, account string) CodeArtifactRepositoryResult {
+	result := CodeArtifactRepositoryResult{
+		Name:                 aws.ToString(repository.Name),
+		Arn:                  aws.ToString(repository.Arn),
+		Domain:               aws.ToString(repository.DomainName),
+		AdministratorAccount: aws.ToString(repository.AdministratorAccount),
+	}
+
+	// i.e. aws codeartifact describe-repository --domain <domain> --repository <repository>
+	description, err := codeartifactClient.DescribeRepository(ctx, &codeartifact.DescribeRepositoryInput{
+		Domain:      repository.DomainName,
+		DomainOwner: repository.DomainOwner,
+		Repository:  repository.Name,
+	})
+	if err != nil {
+		fmt.Printf("Couldn't describe %v. Here's why: %v\n", result.Name, err)
+	} else if description.Repository != nil {
+		for _, upstream := range description.Repository.Upstreams {
+			result.Upstreams = append(result.Upstreams, aws.ToString(upstream.RepositoryName))
+		}
+		for _, connection := range description.Repository.ExternalConnections {
+			result.ExternalConnections = append(result.ExternalConnections, aws.ToString(connection.ExternalConnectionName))
+		}
+	}
+
+	// A repository with no policy is only reachable by its own account
+	// i.e. aws codeartifact get-repository-permissions-policy --domain <domain> --repository <repository>
+	policy, err := codeartifactClient.GetRepositoryPermissionsPolicy(ctx, &codeartifact.GetRepositoryPermissionsPolicyInput{
+		Domain:      repository.DomainName,
+		DomainOwner: repository.DomainOwner,
+		Repository:  repository.Name,
+	})
+	var notFound *codeartifacttypes.ResourceNotFoundException
+	if err != nil && !errors.As(err, &notFound) {
+		fmt.Printf("Couldn't get the policy for %v. Here's why: %v\n", result.Name, err)
+	} else if err == nil && policy.Policy != nil {
+		result.Policy, err = ParsePolicyDocument(aws.ToString(policy.Policy.Document))
+		if err != nil {
+			fmt.Printf("Couldn't parse the policy for %v. Here's why: %v\n", result.Name, err)
+		}
+	}
+	result.PublicPublish, result.PublishAccounts = AnalyseResourcePolicy(result.Policy, PACKAGE_PUBLISH_ACTIONS, account, ConditionRestricted)
+	result.PublicRead, result.ReadAccounts = AnalyseResourcePolicy(result.Policy, PACKAGE_READ_ACTIONS, account, ConditionRestricted)
+
+	return result
+}
+
+func ListCodeArtifactDomains(ctx context.Context, codeartifactClient *codeartifact.Client) ([]codeartifacttypes.DomainSummary, error) {
+	var domains []codeartifacttypes.DomainSummary
+	paginator := codeartifact.NewListDomainsPaginator(codeartifactClient, &codeartifact.ListDomainsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(domains)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the CodeArtifact domains. Here's why: %v\n", err)
+			return nil, err
+		}
+		domains = append(domains, page.Domains...)
+	}
+
+	return LimitItems(domains), nil
+}
+
+func ListCodeArtifactRepositories(ctx context.Context, codeartifactClient *codeartifact.Client, domain codeartifacttypes.DomainSummary) ([]codeartifacttypes.RepositorySummary, error) {
+	var repositories []codeartifacttypes.RepositorySummary
+	paginator := codeartifact.NewListRepositoriesInDomainPaginator(codeartifactClient, &codeartifact.ListRepositoriesInDomainInput{
+		Domain:      domain.Name,
+		DomainOwner: domain.Owner,
+	})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(repositories)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the repositories in %v. Here's why: %v\n", aws.ToString(domain.Name), err)
+			return nil, err
+		}
+		repositories = append(repositories, page.Repositories...)
+	}
+
+	return LimitItems(repositories), nil
+}
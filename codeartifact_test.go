@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPackagePolicyAccess(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		policy       string
+		actions      []string
+		wantPublic   bool
+		wantAccounts []string
+	}{
+		{
+			name: "anyone can publish",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*",` +
+				`"Action":["codeartifact:PublishPackageVersion","codeartifact:PutPackageMetadata"],"Resource":"*"}]}`,
+			actions:    PACKAGE_PUBLISH_ACTIONS,
+			wantPublic: true,
+		},
+		{
+			name:       "anyone can read",
+			policy:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"codeartifact:ReadFromRepository","Resource":"*"}]}`,
+			actions:    PACKAGE_READ_ACTIONS,
+			wantPublic: true,
+		},
+		{
+			name:    "anyone can read but not publish",
+			policy:  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"codeartifact:ReadFromRepository","Resource":"*"}]}`,
+			actions: PACKAGE_PUBLISH_ACTIONS,
+		},
+		{
+			name: "anyone in the organization can publish",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"codeartifact:PublishPackageVersion","Resource":"*",` +
+				`"Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abcdefghij"}}}]}`,
+			actions: PACKAGE_PUBLISH_ACTIONS,
+		},
+		{
+			name: "other accounts can publish",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow",` +
+				`"Principal":{"AWS":["arn:aws:iam::210987654321:role/ci","arn:aws:iam::123456789012:root","111122223333"]},` +
+				`"Action":"codeartifact:Publish*","Resource":"*"}]}`,
+			actions:      PACKAGE_PUBLISH_ACTIONS,
+			wantAccounts: []string{"210987654321", "111122223333"},
+		},
+		{
+			name: "domain tokens for another account",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},` +
+				`"Action":["codeartifact:GetAuthorizationToken","sts:GetServiceBearerToken"],"Resource":"*"}]}`,
+			actions:      []string{DOMAIN_TOKEN_ACTION},
+			wantAccounts: []string{"210987654321"},
+		},
+		{
+			name:    "no policy",
+			actions: PACKAGE_PUBLISH_ACTIONS,
+		},
+	} {
+		var policy *PolicyDocument
+		if test.policy != "" {
+			var err error
+			if policy, err = ParsePolicyDocument(test.policy); err != nil {
+				t.Fatalf("%v: %v", test.name, err)
+			}
+		}
+		public, accounts := AnalyseResourcePolicy(policy, test.actions, "123456789012", ConditionRestricted)
+		if public != test.wantPublic {
+			t.Errorf("%v: public is %v, want %v", test.name, public, test.wantPublic)
+		}
+		if !slices.Equal(accounts, test.wantAccounts) {
+			t.Errorf("%v: accounts %v, want %v", test.name, accounts, test.wantAccounts)
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Settings shared by every command, filled in from the persistent flags
+var RegionsFlag = ""
+var PresetFlag = ""
+var ConfigFlag = ""
+var AssumeRoleArnFlag = ""
+var ExternalIdFlag = ""
+var SessionNameFlag = ""
+var OutputFlag = "text"
+var StreamFlag = false
+var OutputVersionFlag = OUTPUT_SCHEMA_VERSION
+
+// Most items kept from each account-wide listing, i.e. every user or every instance in a region, set
+// with --max-items. 0 is no limit. What's attached to a single principal is always listed in full
+var MaxItemsFlag = 0
+
+// Settings for the commands that run modules
+var IPv6Check = false
+var ReplAfterRun = false
+var NoPrompt = false
+var PolicyArnFlag = ""
+var VersionIdFlag = ""
+var ResolveDocuments = false
+
+// Inferred environment to scope the shared inventory to, set with --environment, i.e. prod
+var EnvironmentFlag = ""
+
+func NewRootCommand() *cobra.Command {
+	// With no subcommand the modules given with --modules are run, only IAM by default
+	var modulesFlag string
+	rootCommand := &cobra.Command{
+		Use:               "aws-enumerator",
+		Short:             "Enumerate what the current AWS credentials can see and do",
+		Args:              cobra.NoArgs,
+		SilenceUsage:      true,
+		PersistentPreRunE: ApplyPresetAndConfig,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selectedModules, err := SelectModules(modulesFlag)
+			if err != nil {
+				return err
+			}
+
+			return RunModules(cmd.Context(), selectedModules)
+		},
+	}
+
+	rootCommand.PersistentFlags().StringVar(&RegionsFlag, "regions", "", "Comma-separated list of regions to enumerate, or \"all\" for every enabled region (default is the configured region)")
+	rootCommand.PersistentFlags().BoolVar(&DownloadCode, "download-code", false, "Download Lambda deployment packages, canary scripts, edge function code, Image Builder components and EC2 user data to the loot directory and scan them for secrets")
+	rootCommand.PersistentFlags().StringVar(&LootDir, "loot-dir", LootDir, "Directory downloaded artifacts are saved to")
+	rootCommand.PersistentFlags().StringVar(&ProfileFlag, "profile", "", "Named profile from the shared AWS config files to use (default is the default credential chain)")
+	rootCommand.PersistentFlags().StringVar(&AssumeRoleArnFlag, "assume-role-arn", "", "Role to assume before enumerating, everything then runs with its credentials")
+	rootCommand.PersistentFlags().StringVar(&ExternalIdFlag, "external-id", "", "External ID to pass when assuming --assume-role-arn")
+	rootCommand.PersistentFlags().StringVar(&SessionNameFlag, "session-name", "aws-enumerator", "Session name to use when assuming --assume-role-arn, which shows up in the target account's CloudTrail")
+	rootCommand.PersistentFlags().StringVar(&PresetFlag, "preset", "", "Built-in preset to take defaults from ("+strings.Join(ListPresets(), ", ")+")")
+	rootCommand.PersistentFlags().StringVar(&ConfigFlag, "config", "", "JSON config file to take defaults from, in the same format as the presets")
+	rootCommand.PersistentFlags().StringVarP(&OutputFlag, "output", "o", OutputFlag, "Output format, text, json or ndjson. With json or ndjson the results go to stdout and progress to stderr")
+	rootCommand.PersistentFlags().BoolVar(&StreamFlag, "stream", false, "Write each ndjson result as soon as it's found instead of at the end of the run")
+	rootCommand.PersistentFlags().StringVar(&OutputVersionFlag, "output-version", OutputVersionFlag, "Version of the json and ndjson output to write, for automation built against an older one ("+strings.Join(ListSchemaVersions(), ", ")+")")
+	rootCommand.PersistentFlags().StringVar(&OTLPEndpoint, "otlp-endpoint", "", "Send a trace of the run, with spans per module, region and API call, to this OTLP/HTTP collector, i.e. http://localhost:4318")
+	rootCommand.PersistentFlags().IntVar(&MaxItemsFlag, "max-items", 0, "Stop each account-wide listing after this many items, for a quick look at a large account (default is no limit)")
+	rootCommand.PersistentFlags().StringSliceVar(&ExcludeRegions, "exclude-regions", nil, "Regions no request is ever sent to, whatever the module or --regions, repeat or comma-separate for several")
+	rootCommand.PersistentFlags().StringSliceVar(&ExcludeServices, "exclude-services", nil, "Services no request is ever sent to, named like the CLI commands, i.e. secretsmanager, repeat or comma-separate for several")
+	rootCommand.PersistentFlags().StringVar(&CloudTrailLakeStore, "cloudtrail-lake", "", "CloudTrail Lake event data store ARN or ID to answer activity questions from instead of event history")
+	rootCommand.PersistentFlags().IntVar(&LakeDays, "lake-days", LakeDays, "How many days back --cloudtrail-lake queries look")
+	rootCommand.PersistentFlags().StringVar(&MetricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address while the run is going, i.e. localhost:9100")
+	rootCommand.Flags().StringVar(&modulesFlag, "modules", "iam", "Comma-separated list of modules to run")
+	AddRunFlags(rootCommand)
+
+	rootCommand.AddCommand(
+		NewEnumerateCommand(),
+		NewWhoamiCommand(),
+		NewEffectivePermissionsCommand(),
+		NewSimulateCommand(),
+		NewWhoHasCommand(),
+		NewInspectCommand(),
+		NewWildcardTrustCommand(),
+		NewActivityCommand(),
+		NewReportCommand(),
+		NewOrgScanCommand(),
+		NewSchemaCommand(),
+		NewVersionCommand(),
+		NewSelfUpdateCommand(),
+	)
+
+	return rootCommand
+}
+
+func AddRunFlags(command *cobra.Command) {
+	command.Flags().BoolVar(&IPv6Check, "ipv6-check", false, "Check the selected modules against dual-stack endpoints instead of running them")
+	command.Flags().BoolVar(&ReplAfterRun, "repl", false, "Open an interactive prompt for follow-up queries once the modules finish")
+	command.Flags().BoolVar(&NoPrompt, "no-prompt", false, "Never wait for input, for scripted and CI runs")
+	command.Flags().BoolVar(&NoPrompt, "batch", false, "Same as --no-prompt")
+	command.Flags().StringVar(&PolicyArnFlag, "policy-arn", "", "Policy whose document the iam module prints, instead of asking for one")
+	command.Flags().StringVar(&VersionIdFlag, "version-id", "", "Version of --policy-arn to print (default is the policy's default version)")
+	command.Flags().BoolVar(&ResolveDocuments, "resolve-documents", false, "Print the default version document of every attached policy found and save it to the loot directory")
+	command.Flags().BoolVar(&AllProfiles, "all-profiles", false, "Run the modules once for every profile in the shared AWS config files, one section per profile")
+	command.Flags().IntVar(&Concurrency, "concurrency", Concurrency, "How many users, roles, groups or policies to look up at once")
+	command.Flags().StringVar(&ResumeFlag, "resume", "", "Checkpoint file from an interrupted run, runs the modules it didn't finish")
+	command.Flags().IntVar(&SampleObjects, "sample-objects", 0, "List up to this many objects in each bucket the s3 module can read and flag names matching --sample-patterns, nothing is downloaded (default is no listing)")
+	command.Flags().IntVar(&SampleDepth, "sample-depth", SampleDepth, "How many folders below a bucket's root --sample-objects looks in")
+	command.Flags().StringSliceVar(&SamplePatterns, "sample-patterns", SamplePatterns, "Object name patterns --sample-objects flags, i.e. *.pem, repeat or comma-separate for several")
+	command.Flags().StringVar(&EnvironmentFlag, "environment", "", "Only enumerate users, groups, roles and instances whose names or tags put them in this environment, i.e. prod")
+	command.Flags().BoolVar(&RetrieveSecretValues, "retrieve-values", false, "Read the value of every secret the secrets module can and save it to the loot directory (default is names and metadata only)")
+	command.Flags().StringSliceVar(&RequiredTags, "required-tags", RequiredTags, "Tag keys the untagged module expects on every resource, repeat or comma-separate for several")
+	command.Flags().BoolVar(&DecryptParameters, "with-decryption", false, "Read and decrypt the value of every parameter the parameters module can and save it to the loot directory (default is names and metadata only)")
+}
+
+func NewEnumerateCommand() *cobra.Command {
+	enumerateCommand := &cobra.Command{
+		Use:   "enumerate <module>",
+		Short: "Run a single enumeration module",
+	}
+
+	// Every module gets its own subcommand, i.e. aws-enumerator enumerate iam
+	for _, module := range MODULES {
+		moduleCommand := &cobra.Command{
+			Use:   module.Name,
+			Short: module.Description,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return RunModules(cmd.Context(), []Module{module})
+			},
+		}
+		AddRunFlags(moduleCommand)
+		enumerateCommand.AddCommand(moduleCommand)
+	}
+
+	return enumerateCommand
+}
+
+func NewReportCommand() *cobra.Command {
+	var modulesFlag string
+	reportCommand := &cobra.Command{
+		Use:   "report",
+		Short: "Run every module, or the ones given with --modules, one after another",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selectedModules := MODULES
+			if modulesFlag != "" {
+				var err error
+				selectedModules, err = SelectModules(modulesFlag)
+				if err != nil {
+					return err
+				}
+			}
+
+			return RunModules(cmd.Context(), selectedModules)
+		},
+	}
+
+	reportCommand.Flags().StringVar(&modulesFlag, "modules", "", "Comma-separated list of modules to run (default is every module)")
+	AddRunFlags(reportCommand)
+
+	return reportCommand
+}
+
+func NewWhoamiCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "whoami",
+		Short: "Show who the current credentials belong to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunWhoami(cmd.Context())
+		},
+	}
+}
+
+func NewEffectivePermissionsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "effective-permissions",
+		Short: "Combine every policy that applies to the current principal into what it's actually allowed to do",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunEffectivePermissions(cmd.Context())
+		},
+	}
+}
+
+func NewSimulateCommand() *cobra.Command {
+	var actions []string
+	var resources []string
+	var principalArn string
+	simulateCommand := &cobra.Command{
+		Use:   "simulate",
+		Short: "Ask IAM whether the current principal, or --principal-arn, can perform actions on resources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunSimulate(cmd.Context(), actions, resources, principalArn)
+		},
+	}
+
+	simulateCommand.Flags().StringSliceVar(&actions, "action", nil, "Action to simulate, i.e. s3:GetObject, repeat or comma-separate for several")
+	simulateCommand.Flags().StringSliceVar(&resources, "resource", []string{"*"}, "Resource ARN to simulate the actions against, repeat or comma-separate for several")
+	simulateCommand.Flags().StringVar(&principalArn, "principal-arn", "", "User, group or role to simulate instead of the current principal")
+	simulateCommand.MarkFlagRequired("action")
+
+	return simulateCommand
+}
+
+func NewWhoHasCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "who-has <policy-name|policy-arn>",
+		Short: "List every user, group and role a managed policy is attached to, i.e. who-has AdministratorAccess",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunWhoHas(cmd.Context(), args[0])
+		},
+	}
+}
+
+func NewInspectCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "inspect <arn>",
+		Short: "Look at one resource in depth with the checks of the module it belongs to, i.e. inspect arn:aws:iam::123456789012:role/deploy",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunInspect(cmd.Context(), args[0])
+		},
+	}
+}
+
+func NewWildcardTrustCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "wildcard-trust",
+		Short: "Quickly check every role's trust policy for principals anyone can use, such as \"*\" or a whole other account",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Run as a module so the output, checkpoints and multi-account flags work the same as enumerate
+			selectedModules, err := SelectModules("wildcard-trust")
+			if err != nil {
+				return err
+			}
+			return RunModules(cmd.Context(), selectedModules)
+		},
+	}
+}
+
+func NewActivityCommand() *cobra.Command {
+	filter := LakeActivityFilter{Limit: 100}
+	activityCommand := &cobra.Command{
+		Use:   "activity",
+		Short: "Answer who did what recently from a CloudTrail Lake event data store, given with --cloudtrail-lake",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter.Days = LakeDays
+			return RunActivity(cmd.Context(), filter)
+		},
+	}
+
+	activityCommand.Flags().StringVar(&filter.Principal, "principal", "", "Only calls by principals whose ARN contains this, i.e. a user, role or session name")
+	activityCommand.Flags().StringVar(&filter.EventSource, "event-source", "", "Only calls to this service, i.e. s3.amazonaws.com")
+	activityCommand.Flags().StringVar(&filter.EventName, "event-name", "", "Only calls to this API, i.e. CreateAccessKey")
+	activityCommand.Flags().StringVar(&filter.SourceIp, "source-ip", "", "Only calls from this IP address")
+	activityCommand.Flags().BoolVar(&filter.ErrorsOnly, "errors-only", false, "Only calls that failed, i.e. with AccessDenied")
+	activityCommand.Flags().IntVar(&filter.Limit, "limit", filter.Limit, "Most recent events to show")
+
+	return activityCommand
+}
+
+func NewVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print build information and the rule catalog version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			PrintVersion()
+		},
+	}
+}
+
+func NewSelfUpdateCommand() *cobra.Command {
+	selfUpdateCommand := &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace this binary with the latest signed release",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunSelfUpdate(cmd.Context())
+		},
+	}
+
+	selfUpdateCommand.Flags().BoolVar(&AllowDowngrade, "allow-downgrade", false, "Install the latest release even when it isn't newer than this build")
+
+	return selfUpdateCommand
+}
+
+func ApplyPresetAndConfig(cmd *cobra.Command, args []string) error {
+	// Settings given on the command line win over the config file, which wins over the preset
+	if ConfigFlag != "" {
+		userConfig, err := LoadConfigFile(ConfigFlag)
+		if err != nil {
+			return err
+		}
+		if err := ApplyConfig(cmd, userConfig); err != nil {
+			return err
+		}
+	}
+	if PresetFlag != "" {
+		preset, err := LoadPreset(PresetFlag)
+		if err != nil {
+			return err
+		}
+		if err := ApplyConfig(cmd, preset); err != nil {
+			return err
+		}
+	}
+
+	NormaliseExclusions()
+
+	if err := SetOutputFormat(OutputFlag, StreamFlag); err != nil {
+		return err
+	}
+
+	return SetOutputVersion(OutputVersionFlag)
+}
+
+func LoadAWSConfig(ctx context.Context) (aws.Config, error) {
+	var options []func(*config.LoadOptions) error
+	if ProfileFlag != "" {
+		options = append(options, config.WithSharedConfigProfile(ProfileFlag))
+	}
+
+	sdkConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		if ProfileFlag != "" {
+			fmt.Printf("Couldn't load the configuration for profile %v. Here's why: %v\n", ProfileFlag, err)
+			return aws.Config{}, err
+		}
+		fmt.Println("Couldn't load default configuration. Have you set up your AWS account?")
+		return aws.Config{}, err
+	}
+	AddMetricsMiddleware(&sdkConfig)
+	AddTracingMiddleware(&sdkConfig)
+	AddScopeMiddleware(&sdkConfig)
+
+	// Assume the role up front so a bad ARN or external ID fails here rather than in the first module
+	// i.e. aws sts assume-role --role-arn <role-arn> --role-session-name <session-name> [--external-id <external-id>]
+	if AssumeRoleArnFlag != "" {
+		sdkConfig = AssumeRoleConfig(sdkConfig, AssumeRoleArnFlag, ExternalIdFlag, SessionNameFlag)
+		if _, err := sdkConfig.Credentials.Retrieve(ctx); err != nil {
+			fmt.Printf("Couldn't assume %v. Here's why: %v\n", AssumeRoleArnFlag, err)
+			return aws.Config{}, err
+		}
+	}
+
+	return sdkConfig, nil
+}
+
+// Something the modules are run against, the default credentials, a profile or, for org-scan, a member account
+type ScanTarget struct {
+	// Empty when it's the only target, otherwise the profile name or account ID
+	Name       string
+	Profile    string
+	Account    string
+	LoadConfig func(ctx context.Context) (aws.Config, error)
+}
+
+func RunModules(ctx context.Context, selectedModules []Module) error {
+	// A resumed run picks up the interrupted run's modules and regions
+	var checkpoint Checkpoint
+	if ResumeFlag != "" {
+		var err error
+		checkpoint, err = LoadCheckpoint(ResumeFlag)
+		if err != nil {
+			return err
+		}
+		selectedModules, err = SelectModules(strings.Join(checkpoint.Modules, ","))
+		if err != nil {
+			return err
+		}
+		if RegionsFlag == "" {
+			RegionsFlag = checkpoint.Regions
+		}
+		AllProfiles = AllProfiles || checkpoint.AllProfiles
+		if OrgAuditRole == "" {
+			OrgAuditRole = checkpoint.AuditRole
+		}
+		if EnvironmentFlag == "" {
+			EnvironmentFlag = checkpoint.Environment
+		}
+	}
+
+	// Modules that read from the shared store run after the ones that fill it
+	selectedModules, err := OrderModules(selectedModules)
+	if err != nil {
+		return err
+	}
+	if ResumeFlag == "" {
+		for _, module := range selectedModules {
+			checkpoint.Modules = append(checkpoint.Modules, module.Name)
+		}
+		checkpoint.Regions = RegionsFlag
+		checkpoint.AllProfiles = AllProfiles
+		checkpoint.AuditRole = OrgAuditRole
+		checkpoint.Environment = EnvironmentFlag
+	}
+
+	PrintReportHeader()
+
+	if IPv6Check {
+		targets, err := ResolveTargets(ctx)
+		if err != nil {
+			return err
+		}
+		RunDualStackCheck(ctx, targets, selectedModules)
+		return nil
+	}
+
+	if MetricsAddr != "" {
+		if err := ServeMetrics(MetricsAddr); err != nil {
+			return err
+		}
+	}
+
+	// Spans still queued are sent before returning, even after Ctrl-C
+	if OTLPEndpoint != "" {
+		shutdownTracing, err := StartTracing(ctx, OTLPEndpoint)
+		if err != nil {
+			return err
+		}
+		defer shutdownTracing(context.WithoutCancel(ctx))
+	}
+	ctx, runSpan := StartSpan(ctx, "run")
+	defer runSpan.End()
+
+	targets, err := ResolveTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sdkConfig aws.Config
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		PrintTargetHeader(target)
+
+		// Each target can be a different account, so nothing fetched for the last one is reused
+		CurrentProfile = ""
+		if AllProfiles {
+			CurrentProfile = target.Profile
+		}
+		CurrentAccount = target.Account
+		Store = NewDataStore()
+		sdkConfig, err = target.LoadConfig(ctx)
+		if err != nil {
+			if target.Name != "" {
+				continue
+			}
+			return err
+		}
+
+		// The banner is for the operator, a run isn't stopped just because STS can't be reached
+		CurrentModule = ""
+		PrintAccountBanner(ctx, sdkConfig)
+
+		// Work out the regions up front so regional modules never call a region that isn't enabled
+		SelectedRegions, err = ResolveRegions(ctx, sdkConfig, RegionsFlag)
+		if err != nil {
+			fmt.Println("Couldn't work out which regions to enumerate. Exiting...")
+			if target.Name != "" {
+				continue
+			}
+			return err
+		}
+
+		targetCtx, targetSpan := StartSpan(ctx, "target "+target.Name,
+			attribute.String("aws.profile", target.Profile),
+			attribute.String("aws.account", target.Account),
+		)
+		for _, module := range selectedModules {
+			checkpointKey := CheckpointKey(target.Name, module.Name)
+			if checkpoint.IsCompleted(checkpointKey) {
+				continue
+			}
+
+			CurrentModule = module.Name
+			started := time.Now()
+			moduleCtx, span := StartSpan(targetCtx, "module "+module.Name, attribute.String("module", module.Name))
+			err := module.Run(moduleCtx, sdkConfig)
+			if err != nil {
+				fmt.Printf("Module %v didn't finish. Here's why: %v\n", module.Name, err)
+			}
+			EndSpan(span, err)
+			Statistics.RecordModuleDuration(module.Name, time.Since(started))
+			// A module cut short by Ctrl-C isn't finished, whatever it returned
+			if ctx.Err() != nil {
+				break
+			}
+			checkpoint.Completed = append(checkpoint.Completed, checkpointKey)
+		}
+		targetSpan.End()
+	}
+	CurrentModule = ""
+	CurrentProfile = ""
+	CurrentAccount = ""
+
+	if OrgAuditRole != "" {
+		PrintFindingRollup()
+	}
+	PrintAttackSurface()
+
+	statistics := Statistics.Snapshot()
+	PrintStatistics(statistics)
+	Emit("statistics", "", statistics)
+
+	// Whatever was found before an interrupt is still written out
+	if err := WriteResults(); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted, stopping early")
+		checkpointPath, err := SaveCheckpoint(checkpoint)
+		if err == nil {
+			fmt.Printf("Pick up where this left off with --resume %v\n", checkpointPath)
+		}
+		return ctx.Err()
+	}
+
+	if ReplAfterRun && NoPrompt {
+		fmt.Println("Skipping the interactive prompt since prompts are turned off")
+	} else if ReplAfterRun && len(targets) > 1 {
+		fmt.Println("Skipping the interactive prompt since more than one profile or account was scanned")
+	} else if ReplAfterRun {
+		RunRepl(ctx, sdkConfig)
+	}
+
+	fmt.Println("All done!")
+	return nil
+}
+
+func ResolveTargets(ctx context.Context) ([]ScanTarget, error) {
+	// org-scan goes through the member accounts, everything else through the profiles
+	if OrgAuditRole != "" {
+		return OrgAccountTargets(ctx, OrgAuditRole)
+	}
+
+	return ProfileTargets()
+}
+
+func PrintTargetHeader(target ScanTarget) {
+	// Only printed when there's more than one target to tell apart
+	if target.Name == "" {
+		return
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	if target.Account != "" {
+		fmt.Printf("Account: %v\n", target.Account)
+	} else {
+		fmt.Printf("Profile: %v\n", target.Profile)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+}
+
+func ProfileTargets() ([]ScanTarget, error) {
+	// Without --all-profiles this is just the one given with --profile, or the default credential chain
+	profiles := []string{ProfileFlag}
+	if AllProfiles {
+		var err error
+		profiles, err = ListProfiles()
+		if err != nil {
+			return nil, err
+		}
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("no profiles found in %v or %v", SharedConfigFile(), SharedCredentialsFile())
+		}
+	}
+
+	var targets []ScanTarget
+	for _, profile := range profiles {
+		target := ScanTarget{
+			Profile: profile,
+			LoadConfig: func(ctx context.Context) (aws.Config, error) {
+				ProfileFlag = profile
+				return LoadAWSConfig(ctx)
+			},
+		}
+		if AllProfiles {
+			target.Name = profile
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func RunWhoami(ctx context.Context) error {
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Call the get-caller-identity API, which works for any credentials
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Caller identity:")
+	fmt.Printf("\tAccount: %v\n", *callerIdentity.Account)
+	fmt.Printf("\tARN: %v\n", *callerIdentity.Arn)
+	fmt.Printf("\tUser ID: %v\n", *callerIdentity.UserId)
+	CurrentModule = "whoami"
+	Emit("caller-identity", "", CallerIdentityResult{
+		Account: *callerIdentity.Account,
+		Arn:     *callerIdentity.Arn,
+		UserId:  *callerIdentity.UserId,
+	})
+
+	// IAM users have more to show, i.e. aws iam get-user
+	if parsedArn, err := arn.Parse(*callerIdentity.Arn); err == nil && parsedArn.Service == "iam" && strings.HasPrefix(parsedArn.Resource, "user/") {
+		currentUserDetails, err := GetUserDetails(ctx, iam.NewFromConfig(sdkConfig))
+		if err == nil {
+			fmt.Printf("\tUsername: %v\n", *currentUserDetails.User.UserName)
+			fmt.Printf("\tCreated on: %v\n", *currentUserDetails.User.CreateDate)
+			if currentUserDetails.User.PasswordLastUsed != nil {
+				fmt.Printf("\tPassword last used: %v\n", *currentUserDetails.User.PasswordLastUsed)
+			}
+			Emit("user", "", currentUserDetails.User)
+		}
+	}
+	if roleName, ok := CallerRoleName(*callerIdentity.Arn); ok {
+		fmt.Printf("\tRole: %v\n", roleName)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return WriteResults()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Config files and presets are JSON objects keyed by flag name, i.e.
+// {"modules": "iam,quotas", "regions": "all", "download-code": true}
+type Config map[string]any
+
+//go:embed presets/*.json
+var presetFiles embed.FS
+
+func LoadPreset(name string) (Config, error) {
+	content, err := presetFiles.ReadFile("presets/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q, available presets are %v", name, strings.Join(ListPresets(), ", "))
+	}
+
+	return ParseConfig(content)
+}
+
+func ListPresets() []string {
+	var names []string
+	entries, _ := presetFiles.ReadDir("presets")
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func LoadConfigFile(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the config file: %w", err)
+	}
+
+	return ParseConfig(content)
+}
+
+func ParseConfig(content []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("couldn't parse the config: %w", err)
+	}
+
+	return config, nil
+}
+
+func ApplyConfig(command *cobra.Command, config Config) error {
+	// Flags given on the command line always win over the config
+	flags := command.Flags()
+	explicit := map[string]bool{}
+	flags.Visit(func(f *pflag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, value := range config {
+		if flags.Lookup(name) == nil {
+			// Settings for other commands are skipped, i.e. "modules" when running enumerate iam
+			if !IsSetting(command.Root(), name) {
+				return fmt.Errorf("unknown setting %q in config", name)
+			}
+			continue
+		}
+		if explicit[name] {
+			continue
+		}
+
+		// Lists can be written as JSON arrays, i.e. "modules": ["iam", "quotas"]
+		var stringValue string
+		switch v := value.(type) {
+		case []any:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			stringValue = strings.Join(items, ",")
+		default:
+			stringValue = fmt.Sprint(v)
+		}
+
+		if err := flags.Set(name, stringValue); err != nil {
+			return fmt.Errorf("invalid value for %q in config: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func IsSetting(command *cobra.Command, name string) bool {
+	// Check the command and everything under it for a flag with this name
+	if command.Flags().Lookup(name) != nil || command.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, subcommand := range command.Commands() {
+		if IsSetting(subcommand, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// CloudShell has no public API to query, so availability is judged by whether
+// the principal is allowed to start an environment and a session in it
+var CLOUDSHELL_ACTIONS = []string{
+	"cloudshell:CreateEnvironment",
+	"cloudshell:StartEnvironment",
+	"cloudshell:CreateSession",
+	"cloudshell:PutCredentials",
+}
+
+type ConsoleAccessResult struct {
+	Username         string                 `json:"username"`
+	ConsoleAccess    bool                   `json:"consoleAccess"`
+	LoginProfile     *iamtypes.LoginProfile `json:"loginProfile,omitempty"`
+	PasswordLastUsed *time.Time             `json:"passwordLastUsed,omitempty"`
+}
+
+// The parts of a policy simulation worth keeping in the results
+type SimulationResult struct {
+	Principal string `json:"principal,omitempty"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Decision  string `json:"decision"`
+}
+
+func NewSimulationResult(result iamtypes.EvaluationResult) SimulationResult {
+	return SimulationResult{
+		Action:   aws.ToString(result.EvalActionName),
+		Resource: aws.ToString(result.EvalResourceName),
+		Decision: string(result.EvalDecision),
+	}
+}
+
+func RunConsoleModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	currentUserDetails, err := GetUserDetails(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't get details for the current user. Exiting...")
+		return err
+	}
+	username := *currentUserDetails.User.UserName
+	userArn := *currentUserDetails.User.Arn
+
+	// Call the get-login-profile API to see if the user these keys belong to can also sign in to the console
+	// i.e. aws iam get-login-profile --user-name <username>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking console access for the current user...")
+	fmt.Println(MAJOR_SEPARATOR)
+	loginProfile, err := GetLoginProfile(ctx, iamClient, username)
+	if err != nil {
+		fmt.Println("Couldn't check console access for the current user")
+	} else if loginProfile == nil {
+		fmt.Println("\tConsole access: no")
+	} else {
+		fmt.Println("\tConsole access: yes")
+		fmt.Printf("\tPassword created on: %v\n", *loginProfile.CreateDate)
+		fmt.Printf("\tPassword reset required: %v\n", loginProfile.PasswordResetRequired)
+		fmt.Printf("\t[!] %v has a console password as well as the access key in use. Anyone able to reset it (iam:UpdateLoginProfile) gets a console session as this user\n", username)
+		EmitFinding("", userArn, "User has a console password as well as the access key in use")
+	}
+	if err == nil {
+		Emit("console-access", "", ConsoleAccessResult{Username: username, ConsoleAccess: loginProfile != nil, LoginProfile: loginProfile})
+	}
+
+	// Call the simulate-principal-policy API to see if the current user can use CloudShell
+	// i.e. aws iam simulate-principal-policy --policy-source-arn <user-arn> --action-names cloudshell:CreateEnvironment ...
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking CloudShell availability for the current user...")
+	fmt.Println(MAJOR_SEPARATOR)
+	results, err := SimulatePrincipalActions(ctx, iamClient, userArn, CLOUDSHELL_ACTIONS, []string{"*"})
+	if err != nil {
+		fmt.Println("Couldn't simulate CloudShell permissions for the current user")
+		return nil
+	}
+
+	allowedCount := 0
+	for _, result := range results {
+		fmt.Printf("\t%v: %v\n", *result.EvalActionName, result.EvalDecision)
+		Emit("simulation", "", NewSimulationResult(result))
+		if result.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			allowedCount++
+		}
+	}
+	if allowedCount == len(CLOUDSHELL_ACTIONS) {
+		fmt.Println("\t[!] CloudShell is available to this user, giving a shell with its credentials from the console")
+		EmitFinding("", userArn, "CloudShell is available to this user")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func GetLoginProfile(ctx context.Context, iamClient *iam.Client, username string) (*iamtypes.LoginProfile, error) {
+	// Get the console password details for the user, a missing profile means no console access
+	loginProfile, err := iamClient.GetLoginProfile(ctx, &iam.GetLoginProfileInput{
+		UserName: aws.String(username),
+	})
+	if err != nil {
+		var noSuchEntity *iamtypes.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return nil, nil
+		}
+		fmt.Printf("Couldn't get the login profile for %v. Here's why: %v\n", username, err)
+		return nil, err
+	}
+
+	return loginProfile.LoginProfile, nil
+}
+
+func SimulatePrincipalActions(ctx context.Context, iamClient *iam.Client, principalArn string, actions []string, resources []string) ([]iamtypes.EvaluationResult, error) {
+	// Evaluate the principal's policies against each action and resource
+	var results []iamtypes.EvaluationResult
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(iamClient, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principalArn),
+		ActionNames:     actions,
+		ResourceArns:    resources,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't simulate the policies for %v. Here's why: %v\n", principalArn, err)
+			return nil, err
+		}
+		results = append(results, page.EvaluationResults...)
+	}
+
+	return results, nil
+}
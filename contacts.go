@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	accounttypes "github.com/aws/aws-sdk-go-v2/service/account/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type ContactsResult struct {
+	Primary *accounttypes.ContactInformation `json:"primary,omitempty"`
+	// Alternate contacts keyed by type, SECURITY, BILLING or OPERATIONS. Missing ones are left out
+	Alternate map[string]*accounttypes.AlternateContact `json:"alternate"`
+}
+
+func RunContactsModule(ctx context.Context, sdkConfig aws.Config) error {
+	accountClient := account.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+
+	// Call the account APIs for the primary contact and each alternate contact
+	// i.e. aws account get-contact-information
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the account's contacts...")
+	fmt.Println(MAJOR_SEPARATOR)
+	result := ContactsResult{Alternate: map[string]*accounttypes.AlternateContact{}}
+	contactInformation, err := accountClient.GetContactInformation(ctx, &account.GetContactInformationInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the primary contact. Here's why: %v\n", err)
+	} else {
+		result.Primary = contactInformation.ContactInformation
+		fmt.Printf("\tPrimary contact: %v (%v)\n", aws.ToString(result.Primary.FullName), aws.ToString(result.Primary.PhoneNumber))
+	}
+
+	for _, contactType := range accounttypes.AlternateContactType("").Values() {
+		// i.e. aws account get-alternate-contact --alternate-contact-type SECURITY
+		contact, err := GetAlternateContact(ctx, accountClient, contactType)
+		if err != nil {
+			continue
+		}
+		if contact == nil {
+			fmt.Printf("\t%v contact: not set\n", contactType)
+			// AWS sends security notifications, i.e. about exposed keys, to the security contact
+			if contactType == accounttypes.AlternateContactTypeSecurity {
+				fmt.Println("\t[!] No security contact, security notifications only go to the root email address")
+				EmitFinding("", *callerIdentity.Account, "No security contact is set for the account")
+			}
+			continue
+		}
+		result.Alternate[string(contactType)] = contact
+		fmt.Printf("\t%v contact: %v <%v>\n", contactType, aws.ToString(contact.Name), aws.ToString(contact.EmailAddress))
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("contacts", "", result)
+
+	return nil
+}
+
+func GetAlternateContact(ctx context.Context, accountClient *account.Client, contactType accounttypes.AlternateContactType) (*accounttypes.AlternateContact, error) {
+	// A contact that was never set comes back as not found
+	contact, err := accountClient.GetAlternateContact(ctx, &account.GetAlternateContactInput{
+		AlternateContactType: contactType,
+	})
+	if err != nil {
+		var notFound *accounttypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		fmt.Printf("Couldn't get the %v contact. Here's why: %v\n", contactType, err)
+		return nil, err
+	}
+
+	return contact.AlternateContact, nil
+}
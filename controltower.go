@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/controltower"
+	controltowertypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// The baseline Control Tower enables on each registered OU, enrolling the accounts in it
+const CONTROL_TOWER_BASELINE = "AWSControlTowerBaseline"
+
+type ControlTowerResult struct {
+	LandingZoneArn  string   `json:"landingZoneArn"`
+	Version         string   `json:"version"`
+	LatestVersion   string   `json:"latestVersion"`
+	Status          string   `json:"status"`
+	DriftStatus     string   `json:"driftStatus"`
+	GovernedRegions []string `json:"governedRegions"`
+	// Enabled controls (guardrails) keyed by the OU or account they're enabled on
+	EnabledControls  map[string][]string                        `json:"enabledControls"`
+	EnabledBaselines []controltowertypes.EnabledBaselineSummary `json:"enabledBaselines"`
+	// Active accounts whose OU doesn't have the landing zone baseline, only known from the management account
+	UnenrolledAccounts []string `json:"unenrolledAccounts,omitempty"`
+}
+
+func RunControlTowerModule(ctx context.Context, sdkConfig aws.Config) error {
+	// The landing zone lives in the management account's home region, so look for it in each selected one
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		controlTowerClient := controltower.NewFromConfig(regionalConfig)
+
+		// i.e. aws controltower list-landing-zones
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking for a Control Tower landing zone in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		landingZones, err := ListLandingZones(ctx, controlTowerClient)
+		if err != nil {
+			return err
+		}
+		if len(landingZones) == 0 {
+			fmt.Println("\tNo landing zone in this region")
+			return nil
+		}
+
+		for _, landingZone := range landingZones {
+			// i.e. aws controltower get-landing-zone --landing-zone-identifier <arn>
+			details, err := controlTowerClient.GetLandingZone(ctx, &controltower.GetLandingZoneInput{
+				LandingZoneIdentifier: landingZone.Arn,
+			})
+			if err != nil {
+				fmt.Printf("Couldn't get the landing zone %v. Here's why: %v\n", *landingZone.Arn, err)
+				continue
+			}
+			detail := details.LandingZone
+			result := ControlTowerResult{
+				LandingZoneArn:  aws.ToString(detail.Arn),
+				Version:         aws.ToString(detail.Version),
+				LatestVersion:   aws.ToString(detail.LatestAvailableVersion),
+				Status:          string(detail.Status),
+				GovernedRegions: LandingZoneGovernedRegions(detail),
+				EnabledControls: map[string][]string{},
+			}
+			if detail.DriftStatus != nil {
+				result.DriftStatus = string(detail.DriftStatus.Status)
+			}
+
+			fmt.Printf("\tLanding zone: %v\n", result.LandingZoneArn)
+			fmt.Printf("\tStatus: %v\n", result.Status)
+			fmt.Printf("\tVersion: %v (latest %v)\n", result.Version, result.LatestVersion)
+			if result.LatestVersion != "" && result.Version != result.LatestVersion {
+				fmt.Println("\t[-] Landing zone isn't on the latest version")
+			}
+			if detail.DriftStatus != nil && detail.DriftStatus.Status == controltowertypes.LandingZoneDriftStatusDrifted {
+				fmt.Println("\t[!] Landing zone has drifted from its configuration")
+				EmitFinding(regionalConfig.Region, result.LandingZoneArn, "Landing zone has drifted from its configuration")
+			}
+			fmt.Printf("\tGoverned regions: %v\n", strings.Join(result.GovernedRegions, ", "))
+
+			// Enabled regions Control Tower doesn't govern have none of its controls or logging
+			regions, err := ListRegionStatuses(ctx, regionalConfig)
+			if err == nil && len(result.GovernedRegions) > 0 {
+				governed := map[string]bool{}
+				for _, region := range result.GovernedRegions {
+					governed[region] = true
+				}
+				for _, region := range regions {
+					if IsRegionEnabled(region.Status) && !governed[region.Name] {
+						fmt.Printf("\t[!] Region %v is enabled but not governed\n", region.Name)
+						EmitFinding(regionalConfig.Region, result.LandingZoneArn, fmt.Sprintf("Region %v is enabled but not governed by Control Tower", region.Name))
+					}
+				}
+			}
+
+			// i.e. aws controltower list-enabled-controls
+			controls, err := ListEnabledControls(ctx, controlTowerClient)
+			if err == nil {
+				for _, control := range controls {
+					target := aws.ToString(control.TargetIdentifier)
+					controlId := aws.ToString(control.ControlIdentifier)
+					result.EnabledControls[target] = append(result.EnabledControls[target], controlId)
+					if control.DriftStatusSummary != nil && control.DriftStatusSummary.DriftStatus == controltowertypes.DriftStatusDrifted {
+						fmt.Printf("\t[!] Control %v on %v has drifted\n", controlId, target)
+						EmitFinding(regionalConfig.Region, target, fmt.Sprintf("Control %v has drifted", controlId))
+					}
+				}
+				fmt.Printf("\tEnabled controls: %v\n", len(controls))
+				for _, target := range SortedKeys(result.EnabledControls) {
+					fmt.Printf("\t\t%v: %v\n", target, len(result.EnabledControls[target]))
+				}
+			}
+
+			// i.e. aws controltower list-enabled-baselines
+			baselines, err := ListEnabledBaselines(ctx, controlTowerClient)
+			baselineNames, namesErr := ListBaselineNames(ctx, controlTowerClient)
+			if err == nil && namesErr == nil {
+				result.EnabledBaselines = baselines
+				enrolledOUs := map[string]bool{}
+				for _, baseline := range baselines {
+					target := aws.ToString(baseline.TargetIdentifier)
+					status := ""
+					if baseline.StatusSummary != nil {
+						status = string(baseline.StatusSummary.Status)
+					}
+					name := baselineNames[aws.ToString(baseline.BaselineIdentifier)]
+					fmt.Printf("\tBaseline: %v on %v (%v)\n", name, target, status)
+					if status == string(controltowertypes.EnablementStatusFailed) {
+						fmt.Printf("\t[!] Baseline failed to apply to %v\n", target)
+						EmitFinding(regionalConfig.Region, target, "Control Tower baseline failed to apply")
+					}
+					if name == CONTROL_TOWER_BASELINE {
+						enrolledOUs[target[strings.LastIndex(target, "/")+1:]] = true
+					}
+				}
+
+				// Landing zones from before baselines existed have none, so enrollment can't be told from them
+				if len(enrolledOUs) > 0 {
+					result.UnenrolledAccounts = ListUnenrolledAccounts(ctx, organizations.NewFromConfig(regionalConfig), enrolledOUs)
+					for _, accountId := range result.UnenrolledAccounts {
+						fmt.Printf("\t[!] Account %v is outside the landing zone baseline\n", accountId)
+						EmitFinding(regionalConfig.Region, accountId, "Account is in an OU that isn't registered with Control Tower, so no guardrails apply to it")
+					}
+				}
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("control-tower", regionalConfig.Region, result)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func LandingZoneGovernedRegions(detail *controltowertypes.LandingZoneDetail) []string {
+	// The manifest is free-form JSON, i.e. {"governedRegions": ["us-east-1", ...], ...}
+	var manifest struct {
+		GovernedRegions []string `json:"governedRegions"`
+	}
+	if detail.Manifest == nil {
+		return nil
+	}
+	if err := detail.Manifest.UnmarshalSmithyDocument(&manifest); err != nil {
+		fmt.Printf("Couldn't read the landing zone manifest. Here's why: %v\n", err)
+		return nil
+	}
+
+	return manifest.GovernedRegions
+}
+
+func ListBaselineNames(ctx context.Context, controlTowerClient *controltower.Client) (map[string]string, error) {
+	// Enabled baselines are only identified by ARN, so the names come from the catalog
+	// i.e. aws controltower list-baselines
+	names := map[string]string{}
+	paginator := controltower.NewListBaselinesPaginator(controlTowerClient, &controltower.ListBaselinesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the baselines. Here's why: %v\n", err)
+			return nil, err
+		}
+		for _, baseline := range page.Baselines {
+			names[aws.ToString(baseline.Arn)] = aws.ToString(baseline.Name)
+		}
+	}
+
+	return names, nil
+}
+
+func ListUnenrolledAccounts(ctx context.Context, orgClient *organizations.Client, enrolledOUs map[string]bool) []string {
+	// Only the management account can list the organization's accounts, nothing is flagged otherwise
+	// i.e. aws organizations list-accounts
+	accounts, err := ListOrganizationAccounts(ctx, orgClient)
+	if err != nil {
+		return nil
+	}
+
+	var unenrolled []string
+	for _, account := range accounts {
+		if account.State != orgtypes.AccountStateActive {
+			continue
+		}
+		// i.e. aws organizations list-parents --child-id <account-id>
+		parents, err := orgClient.ListParents(ctx, &organizations.ListParentsInput{
+			ChildId: account.Id,
+		})
+		if err != nil || len(parents.Parents) == 0 {
+			continue
+		}
+		if !enrolledOUs[*parents.Parents[0].Id] {
+			unenrolled = append(unenrolled, *account.Id)
+		}
+	}
+
+	return unenrolled
+}
+
+func ListLandingZones(ctx context.Context, controlTowerClient *controltower.Client) ([]controltowertypes.LandingZoneSummary, error) {
+	var landingZones []controltowertypes.LandingZoneSummary
+	paginator := controltower.NewListLandingZonesPaginator(controlTowerClient, &controltower.ListLandingZonesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the landing zones. Here's why: %v\n", err)
+			return nil, err
+		}
+		landingZones = append(landingZones, page.LandingZones...)
+	}
+
+	return landingZones, nil
+}
+
+func ListEnabledControls(ctx context.Context, controlTowerClient *controltower.Client) ([]controltowertypes.EnabledControlSummary, error) {
+	// Without a target every control enabled anywhere in the landing zone is listed
+	var controls []controltowertypes.EnabledControlSummary
+	paginator := controltower.NewListEnabledControlsPaginator(controlTowerClient, &controltower.ListEnabledControlsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the enabled controls. Here's why: %v\n", err)
+			return nil, err
+		}
+		controls = append(controls, page.EnabledControls...)
+	}
+
+	return controls, nil
+}
+
+func ListEnabledBaselines(ctx context.Context, controlTowerClient *controltower.Client) ([]controltowertypes.EnabledBaselineSummary, error) {
+	var baselines []controltowertypes.EnabledBaselineSummary
+	paginator := controltower.NewListEnabledBaselinesPaginator(controlTowerClient, &controltower.ListEnabledBaselinesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the enabled baselines. Here's why: %v\n", err)
+			return nil, err
+		}
+		baselines = append(baselines, page.EnabledBaselines...)
+	}
+
+	return baselines, nil
+}
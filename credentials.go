@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Certificates expiring within this many days are flagged along with the ones that already have
+const CERTIFICATE_EXPIRY_WARNING_DAYS = 30
+
+// A signing certificate with the expiry read from its body
+type SigningCertificateResult struct {
+	iamtypes.SigningCertificate
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+func RunCredentialsModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-users API to check every user, or just the current one if that isn't allowed
+	// i.e. aws iam list-users
+	users, err := CachedUsers(ctx, iamClient)
+	if err != nil {
+		currentUserDetails, err := GetUserDetails(ctx, iamClient)
+		if err != nil {
+			fmt.Println("Couldn't get details for the current user. Exiting...")
+			return err
+		}
+		fmt.Println("Couldn't list users, only checking the current user")
+		users = []iamtypes.User{*currentUserDetails.User}
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting signing certificates, SSH keys and service-specific credentials for users...")
+	fmt.Println(MAJOR_SEPARATOR)
+	for _, user := range users {
+		username := *user.UserName
+		fmt.Printf("\tUsername: %v\n", username)
+
+		// i.e. aws iam list-signing-certificates --user-name <username>
+		certificates, err := ListSigningCertificates(ctx, iamClient, username)
+		if err == nil {
+			for _, certificate := range certificates {
+				result := SigningCertificateResult{SigningCertificate: certificate, Expiration: CertificateExpiration(aws.ToString(certificate.CertificateBody))}
+				fmt.Printf("\t\tSigning certificate: %v (%v, uploaded %v)\n", *certificate.CertificateId, certificate.Status, *certificate.UploadDate)
+				if result.Expiration != nil {
+					fmt.Printf("\t\t\tExpires: %v\n", *result.Expiration)
+					// An inactive certificate can't sign anything, whenever it expires
+					if expiry := CertificateExpiry(*result.Expiration); expiry != "" && certificate.Status == iamtypes.StatusTypeActive {
+						fmt.Printf("\t\t\t[!] Signing certificate %v is active but %v\n", *certificate.CertificateId, expiry)
+						EmitFinding("", *user.Arn, fmt.Sprintf("Signing certificate %v is active but %v", *certificate.CertificateId, expiry))
+					}
+				}
+				Emit("signing-certificate", "", result)
+			}
+		}
+
+		// i.e. aws iam list-ssh-public-keys --user-name <username>
+		sshKeys, err := ListSSHPublicKeys(ctx, iamClient, username)
+		if err == nil {
+			for _, key := range sshKeys {
+				fmt.Printf("\t\tSSH public key: %v (%v, uploaded %v)\n", *key.SSHPublicKeyId, key.Status, *key.UploadDate)
+				Emit("ssh-public-key", "", key)
+			}
+		}
+
+		// i.e. aws iam list-service-specific-credentials --user-name <username>
+		serviceCredentials, err := ListServiceSpecificCredentials(ctx, iamClient, username)
+		if err == nil {
+			for _, credential := range serviceCredentials {
+				fmt.Printf("\t\tService credential: %v for %v as %v (%v, created %v)\n", *credential.ServiceSpecificCredentialId, *credential.ServiceName, *credential.ServiceUserName, credential.Status, *credential.CreateDate)
+				// These work without the user's access keys or password and IAM never records when they were
+				// last used, so an active one is easily forgotten about
+				if credential.Status == iamtypes.StatusTypeActive {
+					ageDays := int(time.Since(*credential.CreateDate).Hours() / 24)
+					fmt.Printf("\t\t[!] Service-specific credential %v for %v is active and %v days old\n", *credential.ServiceSpecificCredentialId, *credential.ServiceName, ageDays)
+					EmitFinding("", *user.Arn, fmt.Sprintf("Service-specific credential %v for %v is active and %v days old", *credential.ServiceSpecificCredentialId, *credential.ServiceName, ageDays))
+				}
+				Emit("service-specific-credential", "", credential)
+			}
+		}
+
+		if len(certificates)+len(sshKeys)+len(serviceCredentials) == 0 {
+			fmt.Println("\t\tNone found")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	// Certificates uploaded to IAM for load balancers and CloudFront, from before ACM or for
+	// what ACM can't issue. Nothing renews them, so they're only replaced when someone remembers
+	// i.e. aws iam list-server-certificates
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting server certificates stored in IAM...")
+	fmt.Println(MAJOR_SEPARATOR)
+	serverCertificates, err := ListServerCertificates(ctx, iamClient)
+	if err != nil {
+		return err
+	}
+	for _, certificate := range serverCertificates {
+		fmt.Printf("\tServer certificate: %v\n", *certificate.ServerCertificateName)
+		fmt.Printf("\t\tARN: %v\n", *certificate.Arn)
+		fmt.Printf("\t\tUploaded: %v\n", aws.ToTime(certificate.UploadDate))
+		if certificate.Expiration != nil {
+			fmt.Printf("\t\tExpires: %v\n", *certificate.Expiration)
+			if expiry := CertificateExpiry(*certificate.Expiration); expiry != "" {
+				fmt.Printf("\t\t[!] Server certificate %v\n", expiry)
+				EmitFinding("", *certificate.Arn, fmt.Sprintf("Server certificate %v", expiry))
+			}
+		}
+		fmt.Println("\t\t[-] Stored in IAM rather than ACM, so it's never renewed automatically")
+		Emit("server-certificate", "", certificate)
+	}
+	if len(serverCertificates) == 0 {
+		fmt.Println("\tNo server certificates stored in IAM")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func CertificateExpiration(body string) *time.Time {
+	// Signing certificates are only listed with their PEM body, the expiry has to be read out of it
+	block, _ := pem.Decode([]byte(body))
+	if block == nil {
+		return nil
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+
+	return &certificate.NotAfter
+}
+
+func CertificateExpiry(expiration time.Time) string {
+	// Describes an expired or soon to expire certificate, or returns nothing if it has a while left
+	days := int(time.Until(expiration).Hours() / 24)
+	if time.Now().After(expiration) {
+		return fmt.Sprintf("expired %v days ago", -days)
+	}
+	if days < CERTIFICATE_EXPIRY_WARNING_DAYS {
+		return fmt.Sprintf("expires in %v days", days)
+	}
+
+	return ""
+}
+
+func ListSigningCertificates(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.SigningCertificate, error) {
+	// Get the X.509 signing certificates uploaded for the user
+	var certificates []iamtypes.SigningCertificate
+	paginator := iam.NewListSigningCertificatesPaginator(iamClient, &iam.ListSigningCertificatesInput{
+		UserName: aws.String(username),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the signing certificates for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		certificates = append(certificates, page.Certificates...)
+	}
+
+	return certificates, nil
+}
+
+func ListSSHPublicKeys(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.SSHPublicKeyMetadata, error) {
+	// Get the SSH public keys uploaded for the user, these are used for CodeCommit
+	var keys []iamtypes.SSHPublicKeyMetadata
+	paginator := iam.NewListSSHPublicKeysPaginator(iamClient, &iam.ListSSHPublicKeysInput{
+		UserName: aws.String(username),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the SSH public keys for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		keys = append(keys, page.SSHPublicKeys...)
+	}
+
+	return keys, nil
+}
+
+func ListServiceSpecificCredentials(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.ServiceSpecificCredentialMetadata, error) {
+	// Get the service-specific credentials for the user, i.e. CodeCommit HTTPS Git and Keyspaces credentials
+	credentials, err := iamClient.ListServiceSpecificCredentials(ctx, &iam.ListServiceSpecificCredentialsInput{
+		UserName: aws.String(username),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the service-specific credentials for %v. Here's why: %v\n", username, err)
+		return nil, err
+	}
+
+	return credentials.ServiceSpecificCredentials, nil
+}
+
+func ListServerCertificates(ctx context.Context, iamClient *iam.Client) ([]iamtypes.ServerCertificateMetadata, error) {
+	var certificates []iamtypes.ServerCertificateMetadata
+	paginator := iam.NewListServerCertificatesPaginator(iamClient, &iam.ListServerCertificatesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(certificates)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the server certificates. Here's why: %v\n", err)
+			return nil, err
+		}
+		certificates = append(certificates, page.ServerCertificateMetadataList...)
+	}
+
+	return LimitItems(certificates), nil
+}
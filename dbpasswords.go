@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	elasticachetypes "github.com/aws/aws-sdk-go-v2/service/elasticache/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsmanagertypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Identifiers shorter than this match too many unrelated secret names to be worth trying
+const MIN_DB_NAME_MATCH = 4
+
+type DBPasswordResult struct {
+	Database string `json:"database"`
+	// rds or elasticache
+	Service  string `json:"service"`
+	Engine   string `json:"engine"`
+	Endpoint string `json:"endpoint"`
+	// How clients authenticate, i.e. password, iam, auth-token, rbac or none
+	Auth      []string           `json:"auth"`
+	Locations []PasswordLocation `json:"locations"`
+}
+
+type PasswordLocation struct {
+	// secretsmanager or ssm
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+	// Only known for secrets, rotation isn't tracked for parameters
+	LastRotated *time.Time `json:"lastRotated,omitempty"`
+	LastChanged *time.Time `json:"lastChanged,omitempty"`
+}
+
+// Something that might be a database's credentials, from either Secrets Manager or Parameter Store
+type CredentialCandidate struct {
+	Service     string
+	Name        string
+	Arn         string
+	Description string
+	Tags        map[string]string
+	LastRotated *time.Time
+	LastChanged *time.Time
+}
+
+func RunDBPasswordsModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Only the names, descriptions and tags are read, never the values
+		// i.e. aws secretsmanager list-secrets, aws ssm describe-parameters
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Looking for database credentials in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		var candidates []CredentialCandidate
+		secrets, err := ListSecrets(ctx, secretsmanager.NewFromConfig(regionalConfig))
+		if err == nil {
+			for _, secret := range secrets {
+				candidates = append(candidates, SecretCandidate(secret))
+			}
+		}
+		parameters, err := ListParameters(ctx, ssm.NewFromConfig(regionalConfig))
+		if err == nil {
+			for _, parameter := range parameters {
+				candidates = append(candidates, CredentialCandidate{
+					Service:     "ssm",
+					Name:        aws.ToString(parameter.Name),
+					Description: aws.ToString(parameter.Description),
+					LastChanged: parameter.LastModifiedDate,
+				})
+			}
+		}
+		fmt.Printf("\tSecrets: %v\n", len(secrets))
+		fmt.Printf("\tParameters: %v\n", len(parameters))
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws rds describe-db-clusters, aws rds describe-db-instances
+		var databases []DBPasswordResult
+		managedSecrets := map[string]string{}
+		rdsClient := rds.NewFromConfig(regionalConfig)
+		clusters, err := ListDBClusters(ctx, rdsClient)
+		if err == nil {
+			for _, cluster := range clusters {
+				database := DBPasswordResult{
+					Database: aws.ToString(cluster.DBClusterIdentifier),
+					Service:  "rds",
+					Engine:   aws.ToString(cluster.Engine),
+					Endpoint: aws.ToString(cluster.Endpoint),
+					Auth:     []string{"password"},
+				}
+				if aws.ToBool(cluster.IAMDatabaseAuthenticationEnabled) {
+					database.Auth = append(database.Auth, "iam")
+				}
+				if cluster.MasterUserSecret != nil {
+					managedSecrets[database.Database] = aws.ToString(cluster.MasterUserSecret.SecretArn)
+				}
+				databases = append(databases, database)
+			}
+		}
+		instances, err := ListDBInstances(ctx, rdsClient)
+		if err == nil {
+			for _, instance := range instances {
+				// Aurora instances share their cluster's credentials
+				if instance.DBClusterIdentifier != nil {
+					continue
+				}
+				database := DBPasswordResult{
+					Database: aws.ToString(instance.DBInstanceIdentifier),
+					Service:  "rds",
+					Engine:   aws.ToString(instance.Engine),
+					Auth:     []string{"password"},
+				}
+				if instance.Endpoint != nil {
+					database.Endpoint = aws.ToString(instance.Endpoint.Address)
+				}
+				if aws.ToBool(instance.IAMDatabaseAuthenticationEnabled) {
+					database.Auth = append(database.Auth, "iam")
+				}
+				if instance.MasterUserSecret != nil {
+					managedSecrets[database.Database] = aws.ToString(instance.MasterUserSecret.SecretArn)
+				}
+				databases = append(databases, database)
+			}
+		}
+
+		// i.e. aws elasticache describe-replication-groups, aws elasticache describe-cache-clusters
+		elasticacheClient := elasticache.NewFromConfig(regionalConfig)
+		replicationGroups, err := ListReplicationGroups(ctx, elasticacheClient)
+		if err == nil {
+			for _, group := range replicationGroups {
+				database := DBPasswordResult{
+					Database: aws.ToString(group.ReplicationGroupId),
+					Service:  "elasticache",
+					Engine:   aws.ToString(group.Engine),
+					Auth:     CacheAuth(group.AuthTokenEnabled, group.UserGroupIds),
+				}
+				if group.ConfigurationEndpoint != nil {
+					database.Endpoint = aws.ToString(group.ConfigurationEndpoint.Address)
+				} else if len(group.NodeGroups) > 0 && group.NodeGroups[0].PrimaryEndpoint != nil {
+					database.Endpoint = aws.ToString(group.NodeGroups[0].PrimaryEndpoint.Address)
+				}
+				databases = append(databases, database)
+			}
+		}
+		cacheClusters, err := ListCacheClusters(ctx, elasticacheClient)
+		if err == nil {
+			for _, cluster := range cacheClusters {
+				// Nodes of a replication group were covered with the group
+				if cluster.ReplicationGroupId != nil {
+					continue
+				}
+				database := DBPasswordResult{
+					Database: aws.ToString(cluster.CacheClusterId),
+					Service:  "elasticache",
+					Engine:   aws.ToString(cluster.Engine),
+					Auth:     CacheAuth(cluster.AuthTokenEnabled, nil),
+				}
+				if cluster.ConfigurationEndpoint != nil {
+					database.Endpoint = aws.ToString(cluster.ConfigurationEndpoint.Address)
+				} else if len(cluster.CacheNodes) > 0 && cluster.CacheNodes[0].Endpoint != nil {
+					database.Endpoint = aws.ToString(cluster.CacheNodes[0].Endpoint.Address)
+				}
+				databases = append(databases, database)
+			}
+		}
+		if len(databases) == 0 {
+			fmt.Println("\tNo databases or caches in this region")
+			return nil
+		}
+
+		for _, database := range databases {
+			database.Locations = FindPasswordLocations(database, managedSecrets[database.Database], candidates)
+			fmt.Printf("\tDatabase: %v (%v %v)\n", database.Database, database.Service, database.Engine)
+			fmt.Printf("\tEndpoint: %v\n", database.Endpoint)
+			fmt.Printf("\tAuthentication: %v\n", strings.Join(database.Auth, ", "))
+			if database.Service == "elasticache" && database.Auth[0] == "none" {
+				fmt.Println("\t[!] No AUTH token or user group, anything that can reach the endpoint can connect")
+				EmitFinding(regionalConfig.Region, database.Database, "Cache has no AUTH token or RBAC user group, so anything that can reach it can connect")
+			}
+			for _, location := range database.Locations {
+				fmt.Printf("\t\tPassword likely in: %v (%v, %v)\n", location.Name, location.Service, location.Reason)
+				if location.LastRotated != nil {
+					fmt.Printf("\t\t\tLast rotated: %v\n", *location.LastRotated)
+				} else if location.LastChanged != nil {
+					fmt.Printf("\t\t\tLast changed: %v\n", *location.LastChanged)
+				}
+			}
+			if len(database.Locations) == 0 && database.Auth[0] != "none" {
+				fmt.Println("\t\tNo secret or parameter looks like its credentials")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("db-passwords", regionalConfig.Region, database)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func SecretCandidate(secret secretsmanagertypes.SecretListEntry) CredentialCandidate {
+	candidate := CredentialCandidate{
+		Service:     "secretsmanager",
+		Name:        aws.ToString(secret.Name),
+		Arn:         aws.ToString(secret.ARN),
+		Description: aws.ToString(secret.Description),
+		Tags:        map[string]string{},
+		LastRotated: secret.LastRotatedDate,
+		LastChanged: secret.LastChangedDate,
+	}
+	for _, tag := range secret.Tags {
+		candidate.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return candidate
+}
+
+func CacheAuth(authTokenEnabled *bool, userGroupIds []string) []string {
+	// Memcached and Redis without either of these accept any connection
+	var auth []string
+	if aws.ToBool(authTokenEnabled) {
+		auth = append(auth, "auth-token")
+	}
+	if len(userGroupIds) > 0 {
+		auth = append(auth, "rbac")
+	}
+	if len(auth) == 0 {
+		auth = []string{"none"}
+	}
+
+	return auth
+}
+
+func FindPasswordLocations(database DBPasswordResult, managedSecretArn string, candidates []CredentialCandidate) []PasswordLocation {
+	// Strongest evidence first: the secret RDS manages itself, then secrets tagged with the
+	// database, then names or descriptions mentioning it
+	identifier := strings.ToLower(database.Database)
+	host := strings.ToLower(strings.Split(database.Endpoint, ".")[0])
+	var locations []PasswordLocation
+	for _, candidate := range candidates {
+		reason := ""
+		switch {
+		case managedSecretArn != "" && candidate.Arn == managedSecretArn:
+			reason = "master user secret managed by RDS"
+		case CandidateTaggedWith(candidate, identifier):
+			reason = "tagged with the database"
+		case len(identifier) >= MIN_DB_NAME_MATCH && (strings.Contains(strings.ToLower(candidate.Name), identifier) || strings.Contains(strings.ToLower(candidate.Description), identifier)):
+			reason = "named after the database"
+		case len(host) >= MIN_DB_NAME_MATCH && host != identifier && strings.Contains(strings.ToLower(candidate.Name), host):
+			reason = "named after the endpoint"
+		default:
+			continue
+		}
+		locations = append(locations, PasswordLocation{
+			Service:     candidate.Service,
+			Name:        candidate.Name,
+			Reason:      reason,
+			LastRotated: candidate.LastRotated,
+			LastChanged: candidate.LastChanged,
+		})
+	}
+
+	return locations
+}
+
+func CandidateTaggedWith(candidate CredentialCandidate, identifier string) bool {
+	// RDS tags the secrets it manages with the ARN of their database, i.e. aws:rds:primaryDBInstanceArn
+	for _, value := range candidate.Tags {
+		value = strings.ToLower(value)
+		if value == identifier || strings.HasSuffix(value, ":"+identifier) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ListSecrets(ctx context.Context, secretsClient *secretsmanager.Client) ([]secretsmanagertypes.SecretListEntry, error) {
+	var secrets []secretsmanagertypes.SecretListEntry
+	paginator := secretsmanager.NewListSecretsPaginator(secretsClient, &secretsmanager.ListSecretsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the secrets. Here's why: %v\n", err)
+			return nil, err
+		}
+		secrets = append(secrets, page.SecretList...)
+	}
+
+	return secrets, nil
+}
+
+func ListParameters(ctx context.Context, ssmClient *ssm.Client) ([]ssmtypes.ParameterMetadata, error) {
+	var parameters []ssmtypes.ParameterMetadata
+	paginator := ssm.NewDescribeParametersPaginator(ssmClient, &ssm.DescribeParametersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the parameters. Here's why: %v\n", err)
+			return nil, err
+		}
+		parameters = append(parameters, page.Parameters...)
+	}
+
+	return parameters, nil
+}
+
+func ListReplicationGroups(ctx context.Context, elasticacheClient *elasticache.Client) ([]elasticachetypes.ReplicationGroup, error) {
+	var groups []elasticachetypes.ReplicationGroup
+	paginator := elasticache.NewDescribeReplicationGroupsPaginator(elasticacheClient, &elasticache.DescribeReplicationGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the replication groups. Here's why: %v\n", err)
+			return nil, err
+		}
+		groups = append(groups, page.ReplicationGroups...)
+	}
+
+	return groups, nil
+}
+
+func ListCacheClusters(ctx context.Context, elasticacheClient *elasticache.Client) ([]elasticachetypes.CacheCluster, error) {
+	// Node details are needed for the endpoints of clusters without a configuration endpoint
+	var clusters []elasticachetypes.CacheCluster
+	paginator := elasticache.NewDescribeCacheClustersPaginator(elasticacheClient, &elasticache.DescribeCacheClustersInput{
+		ShowCacheNodeInfo: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the cache clusters. Here's why: %v\n", err)
+			return nil, err
+		}
+		clusters = append(clusters, page.CacheClusters...)
+	}
+
+	return clusters, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+)
+
+// Most Identity Center OIDC events to pull from each region, client polling makes them add up quickly
+const DEVICE_AUTH_EVENTS_PER_REGION = 2000
+
+// Device authorizations one source IP can start within DEVICE_AUTH_BURST_WINDOW before it's flagged.
+// A phishing run needs a fresh code for every lure, each one lasting ten minutes
+const DEVICE_AUTH_BURST = 10
+
+const DEVICE_AUTH_BURST_WINDOW = time.Hour
+
+const DEVICE_CODE_GRANT_TYPE = "urn:ietf:params:oauth:grant-type:device_code"
+
+// User agents of the AWS tools that normally sign in through the device code flow, lowercase
+var FAMILIAR_OIDC_USER_AGENTS = []string{"aws-cli", "aws-sdk", "botocore", "boto3", "toolkit", "amazonq", "amazon q", "aws-internal"}
+
+// A client registered with Identity Center's OIDC service and what was done with it
+type OidcClientResult struct {
+	ClientId string `json:"clientId"`
+	// Unknown when the registration is older than the event history
+	ClientName     string            `json:"clientName,omitempty"`
+	ClientType     string            `json:"clientType,omitempty"`
+	Registered     *time.Time        `json:"registered,omitempty"`
+	RegisteredFrom string            `json:"registeredFrom,omitempty"`
+	UserAgent      string            `json:"userAgent,omitempty"`
+	Authorizations []OidcEventResult `json:"authorizations"`
+	// Only tokens that were issued, not the pending polls before them
+	Tokens []OidcEventResult `json:"tokens"`
+}
+
+type OidcEventResult struct {
+	Time      time.Time `json:"time"`
+	SourceIp  string    `json:"sourceIp"`
+	UserAgent string    `json:"userAgent"`
+	StartUrl  string    `json:"startUrl,omitempty"`
+	GrantType string    `json:"grantType,omitempty"`
+}
+
+// The parts of a CloudTrail record the OIDC events are read from
+type OidcCloudTrailRecord struct {
+	SourceIPAddress   string         `json:"sourceIPAddress"`
+	UserAgent         string         `json:"userAgent"`
+	ErrorCode         string         `json:"errorCode"`
+	RequestParameters map[string]any `json:"requestParameters"`
+	ResponseElements  map[string]any `json:"responseElements"`
+}
+
+func RunDeviceAuthModule(ctx context.Context, sdkConfig aws.Config) error {
+	// Identity Center logs its OIDC events in its own region, so look for them in each selected one
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Member accounts can't list the instance, so only skip regions known to have none
+		// i.e. aws sso-admin list-instances
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Looking for Identity Center device authorizations in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := ListIdentityCenterInstances(ctx, ssoadmin.NewFromConfig(regionalConfig))
+		if err == nil && len(instances) == 0 {
+			fmt.Println("\tNo Identity Center instance in this region")
+			return nil
+		}
+
+		// Client registrations can't be listed, CloudTrail is the only record of them
+		// i.e. aws cloudtrail lookup-events --lookup-attributes AttributeKey=EventSource,AttributeValue=sso-oidc.amazonaws.com
+		events, err := LookupOidcEvents(ctx, cloudtrail.NewFromConfig(regionalConfig))
+		if err != nil {
+			return err
+		}
+		clients := GroupOidcEvents(events)
+		if len(clients) == 0 {
+			fmt.Println("\tNo client registrations or device authorizations in the last 90 days")
+		}
+		for _, client := range clients {
+			PrintOidcClientResult(regionalConfig.Region, client)
+		}
+		bursts := DeviceAuthBursts(clients)
+		var sourceIps []string
+		for sourceIp := range bursts {
+			sourceIps = append(sourceIps, sourceIp)
+		}
+		sort.Strings(sourceIps)
+		for _, sourceIp := range sourceIps {
+			burst := bursts[sourceIp]
+			fmt.Printf("\t[!] %v started %v device authorizations within an hour\n", sourceIp, burst)
+			EmitFinding(regionalConfig.Region, sourceIp, fmt.Sprintf("Started %v device authorizations within an hour", burst))
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GroupOidcEvents(events []cloudtrailtypes.Event) []OidcClientResult {
+	// Oldest first so each client's registration comes before its authorizations and tokens
+	clients := map[string]*OidcClientResult{}
+	var clientIds []string
+	client := func(clientId string) *OidcClientResult {
+		if _, ok := clients[clientId]; !ok {
+			clients[clientId] = &OidcClientResult{ClientId: clientId}
+			clientIds = append(clientIds, clientId)
+		}
+		return clients[clientId]
+	}
+	slices.Reverse(events)
+	for _, event := range events {
+		var record OidcCloudTrailRecord
+		if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &record); err != nil || record.ErrorCode != "" {
+			continue
+		}
+		eventTime := aws.ToTime(event.EventTime)
+		oidcEvent := OidcEventResult{Time: eventTime, SourceIp: record.SourceIPAddress, UserAgent: record.UserAgent}
+		switch aws.ToString(event.EventName) {
+		case "RegisterClient":
+			clientId, _ := record.ResponseElements["clientId"].(string)
+			if clientId == "" {
+				continue
+			}
+			result := client(clientId)
+			result.ClientName, _ = record.RequestParameters["clientName"].(string)
+			result.ClientType, _ = record.RequestParameters["clientType"].(string)
+			result.Registered = &eventTime
+			result.RegisteredFrom = record.SourceIPAddress
+			result.UserAgent = record.UserAgent
+		case "StartDeviceAuthorization":
+			clientId, _ := record.RequestParameters["clientId"].(string)
+			if clientId == "" {
+				continue
+			}
+			oidcEvent.StartUrl, _ = record.RequestParameters["startUrl"].(string)
+			client(clientId).Authorizations = append(client(clientId).Authorizations, oidcEvent)
+		case "CreateToken":
+			clientId, _ := record.RequestParameters["clientId"].(string)
+			if clientId == "" {
+				continue
+			}
+			oidcEvent.GrantType, _ = record.RequestParameters["grantType"].(string)
+			client(clientId).Tokens = append(client(clientId).Tokens, oidcEvent)
+		}
+	}
+
+	var results []OidcClientResult
+	for _, clientId := range clientIds {
+		results = append(results, *clients[clientId])
+	}
+
+	return results
+}
+
+func PrintOidcClientResult(region string, client OidcClientResult) {
+	fmt.Printf("\tClient ID: %v\n", client.ClientId)
+	if client.Registered != nil {
+		fmt.Printf("\tClient name: %v (%v)\n", client.ClientName, client.ClientType)
+		fmt.Printf("\tRegistered: %v from %v\n", client.Registered.UTC().Format(time.RFC3339), client.RegisteredFrom)
+		fmt.Printf("\tUser agent: %v\n", client.UserAgent)
+	} else {
+		fmt.Println("\t[-] Registered before the event history starts")
+	}
+	for _, authorization := range client.Authorizations {
+		fmt.Printf("\tDevice authorization: %v from %v for %v\n", authorization.Time.UTC().Format(time.RFC3339), authorization.SourceIp, authorization.StartUrl)
+	}
+	for _, token := range client.Tokens {
+		fmt.Printf("\tToken issued: %v to %v (%v)\n", token.Time.UTC().Format(time.RFC3339), token.SourceIp, token.GrantType)
+	}
+
+	// A device code token means someone approved the code, and a tool other than AWS's own asking
+	// for one is what a phishing kit looks like
+	deviceCodeTokens := slices.ContainsFunc(client.Tokens, func(token OidcEventResult) bool { return token.GrantType == DEVICE_CODE_GRANT_TYPE })
+	if userAgent := UnfamiliarOidcUserAgent(client); deviceCodeTokens && userAgent != "" {
+		fmt.Printf("\t[!] Device code token issued to a client using %v\n", userAgent)
+		EmitFinding(region, client.ClientId, fmt.Sprintf("Device code token issued to a client using %v", userAgent))
+	} else if userAgent != "" {
+		fmt.Printf("\t[-] Client uses %v, but no device code token was issued to it\n", userAgent)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("oidc-client", region, client)
+}
+
+func UnfamiliarOidcUserAgent(client OidcClientResult) string {
+	// The first user agent the client used that isn't one of AWS's tools
+	userAgents := []string{client.UserAgent}
+	for _, event := range slices.Concat(client.Authorizations, client.Tokens) {
+		userAgents = append(userAgents, event.UserAgent)
+	}
+	for _, userAgent := range userAgents {
+		lowerUserAgent := strings.ToLower(userAgent)
+		if userAgent != "" && !slices.ContainsFunc(FAMILIAR_OIDC_USER_AGENTS, func(familiar string) bool { return strings.Contains(lowerUserAgent, familiar) }) {
+			return userAgent
+		}
+	}
+
+	return ""
+}
+
+func DeviceAuthBursts(clients []OidcClientResult) map[string]int {
+	// The most device authorizations each source IP started within any one window, for those over the limit
+	times := map[string][]time.Time{}
+	for _, client := range clients {
+		for _, authorization := range client.Authorizations {
+			times[authorization.SourceIp] = append(times[authorization.SourceIp], authorization.Time)
+		}
+	}
+
+	bursts := map[string]int{}
+	for sourceIp, sourceTimes := range times {
+		sort.Slice(sourceTimes, func(i, j int) bool { return sourceTimes[i].Before(sourceTimes[j]) })
+		start := 0
+		for end := range sourceTimes {
+			for sourceTimes[end].Sub(sourceTimes[start]) > DEVICE_AUTH_BURST_WINDOW {
+				start++
+			}
+			if count := end - start + 1; count >= DEVICE_AUTH_BURST && count > bursts[sourceIp] {
+				bursts[sourceIp] = count
+			}
+		}
+	}
+
+	return bursts
+}
+
+func LookupOidcEvents(ctx context.Context, cloudtrailClient *cloudtrail.Client) ([]cloudtrailtypes.Event, error) {
+	// Most recent first, stopping once there are enough
+	var events []cloudtrailtypes.Event
+	paginator := cloudtrail.NewLookupEventsPaginator(cloudtrailClient, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []cloudtrailtypes.LookupAttribute{{
+			AttributeKey:   cloudtrailtypes.LookupAttributeKeyEventSource,
+			AttributeValue: aws.String("sso-oidc.amazonaws.com"),
+		}},
+	})
+	for paginator.HasMorePages() && len(events) < DEVICE_AUTH_EVENTS_PER_REGION {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't look up the Identity Center OIDC events. Here's why: %v\n", err)
+			return nil, err
+		}
+		events = append(events, page.Events...)
+	}
+
+	return events[:min(len(events), DEVICE_AUTH_EVENTS_PER_REGION)], nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+func RunDualStackCheck(ctx context.Context, targets []ScanTarget, modules []Module) {
+	// Each target is probed with its own credentials, so --profile, --all-profiles,
+	// --assume-role-arn and org-scan are checked the way a real run would call AWS
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		PrintTargetHeader(target)
+		sdkConfig, err := target.LoadConfig(ctx)
+		if err != nil {
+			continue
+		}
+		CheckDualStackEndpoints(ctx, DualStackConfig(sdkConfig), modules)
+	}
+}
+
+func DualStackConfig(sdkConfig aws.Config) aws.Config {
+	// Clients take the dual-stack setting from the first config source that has one, so putting it
+	// first forces every client onto the IPv6-capable endpoint for its service whatever the profile
+	// or environment says, i.e. AWS_USE_DUALSTACK_ENDPOINT=true
+	sdkConfig.ConfigSources = append([]any{config.LoadOptions{UseDualStackEndpoint: aws.DualStackEndpointStateEnabled}}, sdkConfig.ConfigSources...)
+
+	return sdkConfig
+}
+
+func CheckDualStackEndpoints(ctx context.Context, sdkConfig aws.Config, modules []Module) {
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking selected modules against dual-stack endpoints...")
+	fmt.Println(MAJOR_SEPARATOR)
+
+	// Modules share services, each is probed once and its result reused for the others
+	serviceErrors := map[string]error{}
+	var failed []string
+	for _, module := range modules {
+		fmt.Printf("\tModule: %v\n", module.Name)
+		var failedServices []string
+		for _, probe := range module.Probes {
+			err, probed := serviceErrors[probe.Service]
+			if !probed {
+				err = probe.Probe(ctx, sdkConfig)
+				serviceErrors[probe.Service] = err
+			}
+			if err != nil {
+				fmt.Printf("\tService: %v FAIL\n", probe.Service)
+				fmt.Printf("\tError: %v\n", err)
+				failedServices = append(failedServices, probe.Service)
+			} else {
+				fmt.Printf("\tService: %v OK\n", probe.Service)
+			}
+		}
+		if len(failedServices) > 0 {
+			fmt.Printf("\tResult: FAIL\n")
+			failed = append(failed, fmt.Sprintf("%v (%v)", module.Name, strings.Join(failedServices, ", ")))
+		} else {
+			fmt.Printf("\tResult: OK\n")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	if len(failed) == 0 {
+		fmt.Println("All selected modules work over dual-stack endpoints.")
+	} else {
+		fmt.Println("Modules that would fail in an IPv6-only network, and the services they can't reach:")
+		for _, name := range failed {
+			fmt.Printf("\t%v\n", name)
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+}
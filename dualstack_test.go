@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func TestDualStackConfigOverridesProfile(t *testing.T) {
+	// A profile turning dual-stack off, i.e. use_dualstack_endpoint = false, doesn't stop the check
+	account := &mockAccount{}
+	sdkConfig := account.Config("us-east-1")
+	sdkConfig.ConfigSources = []any{config.LoadOptions{UseDualStackEndpoint: aws.DualStackEndpointStateDisabled}}
+
+	client := sts.NewFromConfig(DualStackConfig(sdkConfig))
+	if got := client.Options().EndpointOptions.UseDualStackEndpoint; got != aws.DualStackEndpointStateEnabled {
+		t.Fatalf("got dual-stack state %v, want enabled", got)
+	}
+	if len(sdkConfig.ConfigSources) != 1 {
+		t.Fatalf("the target's own config was changed")
+	}
+}
+
+func TestCheckDualStackEndpointsProbesEachServiceOnce(t *testing.T) {
+	calls := map[string]int{}
+	probe := func(service string, err error) ServiceProbe {
+		return ServiceProbe{Service: service, Probe: func(ctx context.Context, sdkConfig aws.Config) error {
+			calls[service]++
+			return err
+		}}
+	}
+	iamProbe := probe("iam", nil)
+	ec2Probe := probe("ec2", errors.New("no IPv6 endpoint"))
+	modules := []Module{
+		{Name: "users", Probes: []ServiceProbe{iamProbe}},
+		{Name: "takeover", Probes: []ServiceProbe{iamProbe, ec2Probe}},
+		{Name: "user-data", Probes: []ServiceProbe{ec2Probe}},
+	}
+
+	CheckDualStackEndpoints(context.Background(), aws.Config{}, modules)
+	for _, service := range []string{"iam", "ec2"} {
+		if calls[service] != 1 {
+			t.Errorf("%v was probed %v times, want once", service, calls[service])
+		}
+	}
+}
+
+func TestModulesProbeOncePerService(t *testing.T) {
+	// The check reuses a service's result across modules, so every module has to probe it the same way
+	probes := map[string]string{}
+	for _, module := range MODULES {
+		if len(module.Probes) == 0 {
+			t.Errorf("%v: no probes", module.Name)
+		}
+		for _, probe := range module.Probes {
+			name := runtime.FuncForPC(reflect.ValueOf(probe.Probe).Pointer()).Name()
+			if previous, ok := probes[probe.Service]; ok && previous != name {
+				t.Errorf("%v: %v is probed with %v, elsewhere with %v", module.Name, probe.Service, name, previous)
+			}
+			probes[probe.Service] = name
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Everything the ec2 module collects about one instance
+type InstanceResult struct {
+	InstanceId         string    `json:"instanceId"`
+	Name               string    `json:"name,omitempty"`
+	State              string    `json:"state"`
+	Type               string    `json:"type"`
+	ImageId            string    `json:"imageId"`
+	LaunchTime         time.Time `json:"launchTime"`
+	PrivateIp          string    `json:"privateIp,omitempty"`
+	PublicIp           string    `json:"publicIp,omitempty"`
+	KeyName            string    `json:"keyName,omitempty"`
+	InstanceProfileArn string    `json:"instanceProfileArn,omitempty"`
+	// required when only IMDSv2 is allowed, optional when IMDSv1 works too
+	MetadataTokens   string   `json:"metadataTokens,omitempty"`
+	MetadataEndpoint string   `json:"metadataEndpoint,omitempty"`
+	SecurityGroups   []string `json:"securityGroups"`
+	// Ports reachable from anywhere through the instance's security groups, i.e. tcp 22
+	OpenPorts []string `json:"openPorts"`
+}
+
+type SecurityGroupResult struct {
+	GroupId     string `json:"groupId"`
+	Name        string `json:"name"`
+	VpcId       string `json:"vpcId,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Each ingress rule as protocol, ports and source, i.e. tcp 22 from 10.0.0.0/8
+	Ingress []string `json:"ingress"`
+	// The ports in Ingress open to 0.0.0.0/0 or ::/0
+	OpenPorts []string `json:"openPorts"`
+	Instances []string `json:"instances"`
+}
+
+type KeyPairResult struct {
+	Name        string    `json:"name"`
+	Id          string    `json:"id"`
+	Type        string    `json:"type"`
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created"`
+	Instances   []string  `json:"instances"`
+}
+
+func RunEC2Module(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ec2Client := ec2.NewFromConfig(regionalConfig)
+
+		// Security groups are fetched first so each instance can be shown with what it has open
+		// i.e. aws ec2 describe-instances, aws ec2 describe-security-groups
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting EC2 instances, security groups and key pairs in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+		securityGroups, err := ListSecurityGroups(ctx, ec2Client)
+		if err != nil {
+			return err
+		}
+		groups := map[string]*SecurityGroupResult{}
+		var groupIds []string
+		for _, securityGroup := range securityGroups {
+			groups[aws.ToString(securityGroup.GroupId)] = NewSecurityGroupResult(securityGroup)
+			groupIds = append(groupIds, aws.ToString(securityGroup.GroupId))
+		}
+		keyInstances := map[string][]string{}
+
+		for _, instance := range instances {
+			result := NewInstanceResult(instance)
+			for _, groupId := range result.SecurityGroups {
+				if group, ok := groups[groupId]; ok {
+					group.Instances = append(group.Instances, result.InstanceId)
+					for _, port := range group.OpenPorts {
+						if !slices.Contains(result.OpenPorts, port) {
+							result.OpenPorts = append(result.OpenPorts, port)
+						}
+					}
+				}
+			}
+			if result.KeyName != "" {
+				keyInstances[result.KeyName] = append(keyInstances[result.KeyName], result.InstanceId)
+			}
+
+			PrintInstanceResult(regionalConfig.Region, instance, result)
+		}
+		if len(instances) == 0 {
+			fmt.Println("\tNo instances in this region")
+			fmt.Println(MINOR_SEPARATOR)
+		}
+
+		for _, groupId := range groupIds {
+			PrintSecurityGroupResult(regionalConfig.Region, groups[groupId])
+		}
+
+		// i.e. aws ec2 describe-key-pairs
+		keyPairs, err := ListKeyPairs(ctx, ec2Client)
+		if err != nil {
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+		for _, keyPair := range keyPairs {
+			result := KeyPairResult{
+				Name:        aws.ToString(keyPair.KeyName),
+				Id:          aws.ToString(keyPair.KeyPairId),
+				Type:        string(keyPair.KeyType),
+				Fingerprint: aws.ToString(keyPair.KeyFingerprint),
+				Created:     aws.ToTime(keyPair.CreateTime),
+				Instances:   keyInstances[aws.ToString(keyPair.KeyName)],
+			}
+			fmt.Printf("\tKey pair: %v (%v, created %v)\n", result.Name, result.Type, result.Created)
+			fmt.Printf("\tFingerprint: %v\n", result.Fingerprint)
+			for _, instanceId := range result.Instances {
+				fmt.Printf("\tInstance: %v\n", instanceId)
+			}
+			if len(result.Instances) == 0 {
+				fmt.Println("\t[-] Not used by any instance in this region")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("key-pair", regionalConfig.Region, result)
+		}
+		if len(keyPairs) == 0 {
+			fmt.Println("\tNo key pairs in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func PrintInstanceResult(region string, instance ec2types.Instance, result InstanceResult) {
+	fmt.Printf("\tInstance ID: %v (%v)\n", result.InstanceId, result.State)
+	if result.Name != "" {
+		fmt.Printf("\tName: %v\n", result.Name)
+	}
+	fmt.Printf("\tType: %v\n", result.Type)
+	fmt.Printf("\tImage: %v\n", result.ImageId)
+	fmt.Printf("\tLaunched on: %v\n", result.LaunchTime)
+	if result.PrivateIp != "" {
+		fmt.Printf("\tPrivate IP: %v\n", result.PrivateIp)
+	}
+	if result.PublicIp != "" {
+		fmt.Printf("\tPublic IP: %v\n", result.PublicIp)
+	}
+	if result.KeyName != "" {
+		fmt.Printf("\tKey pair: %v\n", result.KeyName)
+	}
+	if result.InstanceProfileArn != "" {
+		fmt.Printf("\tInstance profile: %v\n", result.InstanceProfileArn)
+	} else {
+		fmt.Println("\tInstance profile: none")
+	}
+	fmt.Printf("\tIMDS: %v, tokens %v\n", result.MetadataEndpoint, result.MetadataTokens)
+	for _, groupId := range result.SecurityGroups {
+		fmt.Printf("\tSecurity group: %v\n", groupId)
+	}
+
+	running := instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning
+	// With IMDSv1 a single SSRF in anything on the instance is enough to read its role's credentials
+	if running && result.MetadataEndpoint == string(ec2types.InstanceMetadataEndpointStateEnabled) && result.MetadataTokens == string(ec2types.HttpTokensStateOptional) {
+		if result.InstanceProfileArn != "" {
+			fmt.Println("\t[!] IMDSv1 is allowed, an SSRF on the instance can read its role's credentials")
+			EmitFinding(region, result.InstanceId, "IMDSv1 is allowed on an instance with a role, an SSRF can read its credentials")
+		} else {
+			fmt.Println("\t[-] IMDSv1 is allowed")
+		}
+	}
+	if running && result.PublicIp != "" && len(result.OpenPorts) > 0 {
+		fmt.Printf("\t[!] Reachable from the internet on %v\n", strings.Join(result.OpenPorts, ", "))
+		EmitExposedFinding(region, result.InstanceId, EXPOSURE_INTERNET, fmt.Sprintf("Instance has public IP %v and is open to the internet on %v", result.PublicIp, strings.Join(result.OpenPorts, ", ")))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("instance", region, result)
+}
+
+func PrintSecurityGroupResult(region string, group *SecurityGroupResult) {
+	fmt.Printf("\tSecurity group: %v (%v)\n", group.GroupId, group.Name)
+	if group.VpcId != "" {
+		fmt.Printf("\tVPC: %v\n", group.VpcId)
+	}
+	for _, rule := range group.Ingress {
+		fmt.Printf("\tIngress: %v\n", rule)
+	}
+	for _, instanceId := range group.Instances {
+		fmt.Printf("\tInstance: %v\n", instanceId)
+	}
+	// Whether it's actually reachable depends on what it's attached to, which is flagged per instance
+	if len(group.OpenPorts) > 0 {
+		fmt.Printf("\t[-] Open to the internet on %v\n", strings.Join(group.OpenPorts, ", "))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("security-group", region, group)
+}
+
+func NewInstanceResult(instance ec2types.Instance) InstanceResult {
+	result := InstanceResult{
+		InstanceId: aws.ToString(instance.InstanceId),
+		Name:       InstanceName(instance.Tags),
+		Type:       string(instance.InstanceType),
+		ImageId:    aws.ToString(instance.ImageId),
+		LaunchTime: aws.ToTime(instance.LaunchTime),
+		PrivateIp:  aws.ToString(instance.PrivateIpAddress),
+		PublicIp:   aws.ToString(instance.PublicIpAddress),
+		KeyName:    aws.ToString(instance.KeyName),
+	}
+	if instance.State != nil {
+		result.State = string(instance.State.Name)
+	}
+	if instance.IamInstanceProfile != nil {
+		result.InstanceProfileArn = aws.ToString(instance.IamInstanceProfile.Arn)
+	}
+	if instance.MetadataOptions != nil {
+		result.MetadataTokens = string(instance.MetadataOptions.HttpTokens)
+		result.MetadataEndpoint = string(instance.MetadataOptions.HttpEndpoint)
+	}
+	for _, group := range instance.SecurityGroups {
+		result.SecurityGroups = append(result.SecurityGroups, aws.ToString(group.GroupId))
+	}
+
+	return result
+}
+
+func NewSecurityGroupResult(securityGroup ec2types.SecurityGroup) *SecurityGroupResult {
+	result := &SecurityGroupResult{
+		GroupId:     aws.ToString(securityGroup.GroupId),
+		Name:        aws.ToString(securityGroup.GroupName),
+		VpcId:       aws.ToString(securityGroup.VpcId),
+		Description: aws.ToString(securityGroup.Description),
+	}
+
+	// A rule's sources can be CIDR ranges, other security groups or prefix lists
+	for _, permission := range securityGroup.IpPermissions {
+		ports := FormatIpPermission(permission)
+		var sources []string
+		for _, ipRange := range permission.IpRanges {
+			sources = append(sources, aws.ToString(ipRange.CidrIp))
+		}
+		for _, ipRange := range permission.Ipv6Ranges {
+			sources = append(sources, aws.ToString(ipRange.CidrIpv6))
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			// Groups in another account are written as <account>/<group>
+			source := aws.ToString(pair.GroupId)
+			if userId := aws.ToString(pair.UserId); userId != "" && userId != aws.ToString(securityGroup.OwnerId) {
+				source = userId + "/" + source
+			}
+			sources = append(sources, source)
+		}
+		for _, prefixList := range permission.PrefixListIds {
+			sources = append(sources, aws.ToString(prefixList.PrefixListId))
+		}
+		for _, source := range sources {
+			result.Ingress = append(result.Ingress, fmt.Sprintf("%v from %v", ports, source))
+			if (source == "0.0.0.0/0" || source == "::/0") && !slices.Contains(result.OpenPorts, ports) {
+				result.OpenPorts = append(result.OpenPorts, ports)
+			}
+		}
+	}
+
+	return result
+}
+
+func FormatIpPermission(permission ec2types.IpPermission) string {
+	// i.e. tcp 22, udp 1000-2000, icmp or all traffic
+	protocol := aws.ToString(permission.IpProtocol)
+	if protocol == "-1" {
+		return "all traffic"
+	}
+	fromPort, toPort := aws.ToInt32(permission.FromPort), aws.ToInt32(permission.ToPort)
+	if permission.FromPort == nil || fromPort == -1 || (fromPort == 0 && toPort == 65535) {
+		return protocol
+	}
+	if fromPort == toPort {
+		return fmt.Sprintf("%v %v", protocol, fromPort)
+	}
+
+	return fmt.Sprintf("%v %v-%v", protocol, fromPort, toPort)
+}
+
+func ListSecurityGroups(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.SecurityGroup, error) {
+	var securityGroups []ec2types.SecurityGroup
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(securityGroups)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the security groups. Here's why: %v\n", err)
+			return nil, err
+		}
+		securityGroups = append(securityGroups, page.SecurityGroups...)
+	}
+
+	return LimitItems(securityGroups), nil
+}
+
+func ListKeyPairs(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.KeyPairInfo, error) {
+	// Key pairs aren't paginated
+	output, err := ec2Client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		fmt.Printf("Couldn't list the key pairs. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return output.KeyPairs, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// How many task ARNs are simulated in a single call
+const TASK_SIMULATION_BATCH = 50
+
+type ExecClusterResult struct {
+	Name string `json:"name"`
+	Arn  string `json:"arn"`
+	// NONE, DEFAULT or OVERRIDE, and where OVERRIDE sends the session output
+	Logging     string `json:"logging"`
+	LogLocation string `json:"logLocation,omitempty"`
+	// Services that start their tasks with ECS Exec turned on
+	ExecServices []string `json:"execServices"`
+}
+
+type ExecTaskResult struct {
+	Cluster     string `json:"cluster"`
+	TaskArn     string `json:"taskArn"`
+	Group       string `json:"group"`
+	TaskRoleArn string `json:"taskRoleArn,omitempty"`
+	// Containers whose exec agent is running, i.e. the ones a shell can be opened in
+	Containers []string `json:"containers"`
+	Allowed    bool     `json:"allowed"`
+	Command    string   `json:"command,omitempty"`
+}
+
+func RunECSExecModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ecsClient := ecs.NewFromConfig(regionalConfig)
+
+		// AWS Batch runs its jobs on ECS clusters of its own, so they're listed here too
+		// i.e. aws ecs list-clusters
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking ECS Exec on clusters, services and tasks in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		clusters, err := ListECSClusters(ctx, ecsClient)
+		if err != nil {
+			return err
+		}
+		if len(clusters) == 0 {
+			fmt.Println("\tNo ECS clusters in this region")
+			return nil
+		}
+
+		taskRoles := map[string]string{}
+		for _, cluster := range clusters {
+			clusterResult := ExecClusterResult{Name: aws.ToString(cluster.ClusterName), Arn: aws.ToString(cluster.ClusterArn), Logging: string(ecstypes.ExecuteCommandLoggingDefault)}
+			if cluster.Configuration != nil && cluster.Configuration.ExecuteCommandConfiguration != nil {
+				execConfiguration := cluster.Configuration.ExecuteCommandConfiguration
+				if execConfiguration.Logging != "" {
+					clusterResult.Logging = string(execConfiguration.Logging)
+				}
+				if logConfiguration := execConfiguration.LogConfiguration; logConfiguration != nil {
+					if logConfiguration.CloudWatchLogGroupName != nil {
+						clusterResult.LogLocation = aws.ToString(logConfiguration.CloudWatchLogGroupName)
+					} else if logConfiguration.S3BucketName != nil {
+						clusterResult.LogLocation = "s3://" + aws.ToString(logConfiguration.S3BucketName) + "/" + aws.ToString(logConfiguration.S3KeyPrefix)
+					}
+				}
+			}
+
+			fmt.Printf("\tCluster: %v\n", clusterResult.Name)
+			fmt.Printf("\t\tExec logging: %v\n", clusterResult.Logging)
+			if clusterResult.LogLocation != "" {
+				fmt.Printf("\t\tExec logs: %v\n", clusterResult.LogLocation)
+			}
+			// With logging off, the commands run in a session are only seen by whoever ran them
+			if clusterResult.Logging == string(ecstypes.ExecuteCommandLoggingNone) {
+				fmt.Println("\t\t[-] Commands run through ECS Exec aren't logged")
+			}
+
+			// i.e. aws ecs list-services --cluster <cluster>, aws ecs describe-services --cluster <cluster> --services ...
+			services, err := ListECSServices(ctx, ecsClient, clusterResult.Arn)
+			if err == nil {
+				for _, service := range services {
+					if service.EnableExecuteCommand {
+						clusterResult.ExecServices = append(clusterResult.ExecServices, aws.ToString(service.ServiceName))
+					}
+				}
+			}
+			if len(clusterResult.ExecServices) > 0 {
+				fmt.Printf("\t\tServices with exec enabled: %v\n", strings.Join(clusterResult.ExecServices, ", "))
+			}
+			Emit("ecs-exec-cluster", regionalConfig.Region, clusterResult)
+
+			// Only running tasks started with exec turned on can be connected to, whatever the service says now
+			// i.e. aws ecs list-tasks --cluster <cluster> --desired-status RUNNING, aws ecs describe-tasks --cluster <cluster> --tasks ...
+			tasks, err := ListRunningTasks(ctx, ecsClient, clusterResult.Arn)
+			if err != nil {
+				fmt.Println(MINOR_SEPARATOR)
+				continue
+			}
+			var execTasks []ExecTaskResult
+			for _, task := range tasks {
+				if !task.EnableExecuteCommand {
+					continue
+				}
+				taskResult := ExecTaskResult{
+					Cluster: clusterResult.Name,
+					TaskArn: aws.ToString(task.TaskArn),
+					Group:   aws.ToString(task.Group),
+				}
+				for _, container := range task.Containers {
+					for _, agent := range container.ManagedAgents {
+						if agent.Name == ecstypes.ManagedAgentNameExecuteCommandAgent && aws.ToString(agent.LastStatus) == "RUNNING" {
+							taskResult.Containers = append(taskResult.Containers, aws.ToString(container.Name))
+						}
+					}
+				}
+				if len(taskResult.Containers) == 0 {
+					continue
+				}
+
+				// A shell in the container can use the task role's credentials, so that's what exec hands over
+				// i.e. aws ecs describe-task-definition --task-definition <task-definition-arn>
+				taskDefinitionArn := aws.ToString(task.TaskDefinitionArn)
+				if _, ok := taskRoles[taskDefinitionArn]; !ok {
+					taskDefinition, err := ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: task.TaskDefinitionArn})
+					if err != nil {
+						fmt.Printf("Couldn't describe the task definition %v. Here's why: %v\n", taskDefinitionArn, err)
+					} else if taskDefinition.TaskDefinition != nil {
+						taskRoles[taskDefinitionArn] = aws.ToString(taskDefinition.TaskDefinition.TaskRoleArn)
+					}
+				}
+				taskResult.TaskRoleArn = taskRoles[taskDefinitionArn]
+				if task.Overrides != nil && task.Overrides.TaskRoleArn != nil {
+					taskResult.TaskRoleArn = aws.ToString(task.Overrides.TaskRoleArn)
+				}
+				execTasks = append(execTasks, taskResult)
+			}
+
+			// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names ecs:ExecuteCommand --resource-arns <task-arn> ...
+			for start := 0; start < len(execTasks); start += TASK_SIMULATION_BATCH {
+				batch := execTasks[start:min(start+TASK_SIMULATION_BATCH, len(execTasks))]
+				var taskArns []string
+				for _, task := range batch {
+					taskArns = append(taskArns, task.TaskArn)
+				}
+				results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, []string{"ecs:ExecuteCommand"}, taskArns)
+				if err != nil {
+					return err
+				}
+				allowed := map[string]bool{}
+				for _, result := range results {
+					for _, taskArn := range AllowedResourceNames(result) {
+						allowed[taskArn] = true
+					}
+				}
+				for i := range batch {
+					batch[i].Allowed = allowed[batch[i].TaskArn]
+				}
+			}
+
+			for _, task := range execTasks {
+				fmt.Printf("\t\tTask: %v (%v)\n", task.TaskArn[strings.LastIndex(task.TaskArn, "/")+1:], task.Group)
+				fmt.Printf("\t\t\tContainers: %v\n", strings.Join(task.Containers, ", "))
+				if task.TaskRoleArn != "" {
+					fmt.Printf("\t\t\tTask role: %v\n", task.TaskRoleArn)
+				}
+				if task.Allowed {
+					task.Command = fmt.Sprintf("aws ecs execute-command --region %v --cluster %v --task %v --container %v --interactive --command /bin/sh", regionalConfig.Region, task.Cluster, task.TaskArn, task.Containers[0])
+					fmt.Println("\t\t\t[!] Current principal can open a shell in these containers with ecs:ExecuteCommand")
+					fmt.Printf("\t\t\t\t%v\n", task.Command)
+					EmitFinding(regionalConfig.Region, task.TaskArn, fmt.Sprintf("Current principal can open a shell in containers %v with ecs:ExecuteCommand", strings.Join(task.Containers, ", ")))
+				}
+				Emit("ecs-exec-task", regionalConfig.Region, task)
+			}
+			if len(execTasks) == 0 {
+				fmt.Println("\t\tNo running tasks with exec enabled")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListECSClusters(ctx context.Context, ecsClient *ecs.Client) ([]ecstypes.Cluster, error) {
+	var clusterArns []string
+	paginator := ecs.NewListClustersPaginator(ecsClient, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(clusterArns)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the ECS clusters. Here's why: %v\n", err)
+			return nil, err
+		}
+		clusterArns = append(clusterArns, page.ClusterArns...)
+	}
+	clusterArns = LimitItems(clusterArns)
+
+	// describe-clusters takes up to 100 at a time, and only returns the exec configuration when it's asked for
+	var clusters []ecstypes.Cluster
+	for start := 0; start < len(clusterArns); start += 100 {
+		output, err := ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+			Clusters: clusterArns[start:min(start+100, len(clusterArns))],
+			Include:  []ecstypes.ClusterField{ecstypes.ClusterFieldConfigurations},
+		})
+		if err != nil {
+			fmt.Printf("Couldn't describe the ECS clusters. Here's why: %v\n", err)
+			return nil, err
+		}
+		clusters = append(clusters, output.Clusters...)
+	}
+
+	return clusters, nil
+}
+
+func ListECSServices(ctx context.Context, ecsClient *ecs.Client, clusterArn string) ([]ecstypes.Service, error) {
+	var serviceArns []string
+	paginator := ecs.NewListServicesPaginator(ecsClient, &ecs.ListServicesInput{Cluster: aws.String(clusterArn)})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(serviceArns)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the services in %v. Here's why: %v\n", clusterArn, err)
+			return nil, err
+		}
+		serviceArns = append(serviceArns, page.ServiceArns...)
+	}
+	serviceArns = LimitItems(serviceArns)
+
+	// describe-services takes up to 10 at a time
+	var services []ecstypes.Service
+	for start := 0; start < len(serviceArns); start += 10 {
+		output, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(clusterArn),
+			Services: serviceArns[start:min(start+10, len(serviceArns))],
+		})
+		if err != nil {
+			fmt.Printf("Couldn't describe the services in %v. Here's why: %v\n", clusterArn, err)
+			return nil, err
+		}
+		services = append(services, output.Services...)
+	}
+
+	return services, nil
+}
+
+func ListRunningTasks(ctx context.Context, ecsClient *ecs.Client, clusterArn string) ([]ecstypes.Task, error) {
+	var taskArns []string
+	paginator := ecs.NewListTasksPaginator(ecsClient, &ecs.ListTasksInput{
+		Cluster:       aws.String(clusterArn),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(taskArns)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the tasks in %v. Here's why: %v\n", clusterArn, err)
+			return nil, err
+		}
+		taskArns = append(taskArns, page.TaskArns...)
+	}
+	taskArns = LimitItems(taskArns)
+
+	// describe-tasks takes up to 100 at a time
+	var tasks []ecstypes.Task
+	for start := 0; start < len(taskArns); start += 100 {
+		output, err := ecsClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(clusterArn),
+			Tasks:   taskArns[start:min(start+100, len(taskArns))],
+		})
+		if err != nil {
+			fmt.Printf("Couldn't describe the tasks in %v. Here's why: %v\n", clusterArn, err)
+			return nil, err
+		}
+		tasks = append(tasks, output.Tasks...)
+	}
+
+	return tasks, nil
+}
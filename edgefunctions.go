@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cloudfronttypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// A function running at the edge and where it's attached, i.e. E2ABC123 default (viewer-request)
+type EdgeFunctionResult struct {
+	Name string `json:"name"`
+	Arn  string `json:"arn"`
+	// cloudfront-function or lambda-edge
+	Kind    string `json:"kind"`
+	Runtime string `json:"runtime,omitempty"`
+	// The execution role, Lambda@Edge only
+	RoleArn     string   `json:"roleArn,omitempty"`
+	AttachedTo  []string `json:"attachedTo"`
+	CodeSavedTo string   `json:"codeSavedTo,omitempty"`
+}
+
+func RunEdgeFunctionsModule(ctx context.Context, sdkConfig aws.Config) error {
+	// CloudFront is global and Lambda@Edge functions can only be created in us-east-1,
+	// so this doesn't follow --regions
+	globalConfig := sdkConfig.Copy()
+	globalConfig.Region = "us-east-1"
+	cloudfrontClient := cloudfront.NewFromConfig(globalConfig)
+
+	// Which distribution and cache behavior each function runs on
+	// i.e. aws cloudfront list-distributions
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting CloudFront Functions and Lambda@Edge functions...")
+	fmt.Println(MAJOR_SEPARATOR)
+	distributions, err := ListDistributions(ctx, cloudfrontClient)
+	if err != nil {
+		return err
+	}
+	attachments := map[string][]string{}
+	for _, distribution := range distributions {
+		if distribution.DefaultCacheBehavior != nil {
+			AddEdgeAttachments(attachments, aws.ToString(distribution.Id)+" default", distribution.DefaultCacheBehavior.LambdaFunctionAssociations, distribution.DefaultCacheBehavior.FunctionAssociations)
+		}
+		if distribution.CacheBehaviors == nil {
+			continue
+		}
+		for _, behavior := range distribution.CacheBehaviors.Items {
+			AddEdgeAttachments(attachments, aws.ToString(distribution.Id)+" "+aws.ToString(behavior.PathPattern), behavior.LambdaFunctionAssociations, behavior.FunctionAssociations)
+		}
+	}
+
+	// Edge functions can't read environment variables, so tokens and signing keys end up in the code
+	// i.e. aws cloudfront list-functions
+	functions, err := ListCloudFrontFunctions(ctx, cloudfrontClient)
+	if err == nil {
+		for _, function := range functions {
+			result := EdgeFunctionResult{Name: aws.ToString(function.Name), Kind: "cloudfront-function"}
+			stage := cloudfronttypes.FunctionStageDevelopment
+			if function.FunctionMetadata != nil {
+				result.Arn = aws.ToString(function.FunctionMetadata.FunctionARN)
+				stage = function.FunctionMetadata.Stage
+			}
+			if function.FunctionConfig != nil {
+				result.Runtime = string(function.FunctionConfig.Runtime)
+			}
+			result.AttachedTo = attachments[result.Arn]
+
+			fmt.Printf("\tCloudFront Function: %v (%v)\n", result.Name, stage)
+			fmt.Printf("\tRuntime: %v\n", result.Runtime)
+			PrintEdgeAttachments(result.AttachedTo)
+			if DownloadCode {
+				result.CodeSavedTo = DownloadCloudFrontFunctionCode(ctx, cloudfrontClient, result.Name, stage)
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("edge-function", "", result)
+		}
+		if len(functions) == 0 {
+			fmt.Println("\tNo CloudFront Functions")
+			fmt.Println(MINOR_SEPARATOR)
+		}
+	}
+
+	// Lambda@Edge associations always name a published version, i.e. arn:aws:lambda:us-east-1:123456789012:function:auth:3
+	var lambdaArns []string
+	for _, functionArn := range SortedKeys(attachments) {
+		if strings.Contains(functionArn, ":lambda:") {
+			lambdaArns = append(lambdaArns, functionArn)
+		}
+	}
+	lambdaClient := lambda.NewFromConfig(globalConfig)
+	for _, functionArn := range lambdaArns {
+		result := EdgeFunctionResult{Name: functionArn[strings.Index(functionArn, ":function:")+len(":function:"):], Arn: functionArn, Kind: "lambda-edge", AttachedTo: attachments[functionArn]}
+
+		// i.e. aws lambda get-function --function-name <function-arn>
+		functionDetails, err := GetFunctionDetails(ctx, lambdaClient, functionArn)
+		if err == nil && functionDetails.Configuration != nil {
+			result.Runtime = string(functionDetails.Configuration.Runtime)
+			result.RoleArn = aws.ToString(functionDetails.Configuration.Role)
+		}
+
+		fmt.Printf("\tLambda@Edge function: %v\n", result.Name)
+		if result.Runtime != "" {
+			fmt.Printf("\tRuntime: %v\n", result.Runtime)
+		}
+		if result.RoleArn != "" {
+			fmt.Printf("\tExecution role: %v\n", result.RoleArn)
+		}
+		PrintEdgeAttachments(result.AttachedTo)
+		if DownloadCode && functionDetails != nil && functionDetails.Code != nil && functionDetails.Code.Location != nil {
+			result.CodeSavedTo = DownloadEdgeLambdaCode(ctx, result.Name, *functionDetails.Code.Location)
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("edge-function", "", result)
+	}
+	if len(lambdaArns) == 0 {
+		fmt.Println("\tNo Lambda@Edge functions attached to any distribution")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func AddEdgeAttachments(attachments map[string][]string, behavior string, lambdaAssociations *cloudfronttypes.LambdaFunctionAssociations, functionAssociations *cloudfronttypes.FunctionAssociations) {
+	// Key both kinds of function by ARN, listing the behaviors and events they run on
+	if lambdaAssociations != nil {
+		for _, association := range lambdaAssociations.Items {
+			functionArn := aws.ToString(association.LambdaFunctionARN)
+			attachments[functionArn] = append(attachments[functionArn], fmt.Sprintf("%v (%v)", behavior, association.EventType))
+		}
+	}
+	if functionAssociations != nil {
+		for _, association := range functionAssociations.Items {
+			functionArn := aws.ToString(association.FunctionARN)
+			attachments[functionArn] = append(attachments[functionArn], fmt.Sprintf("%v (%v)", behavior, association.EventType))
+		}
+	}
+}
+
+func PrintEdgeAttachments(attachedTo []string) {
+	if len(attachedTo) == 0 {
+		fmt.Println("\tAttached to: nothing")
+		return
+	}
+	for _, attachment := range attachedTo {
+		fmt.Printf("\tAttached to: %v\n", attachment)
+	}
+}
+
+func DownloadCloudFrontFunctionCode(ctx context.Context, cloudfrontClient *cloudfront.Client, name string, stage cloudfronttypes.FunctionStage) string {
+	// The code comes back in the response itself, there's no package to fetch
+	// i.e. aws cloudfront get-function --name <name> --stage <stage> code.js
+	function, err := cloudfrontClient.GetFunction(ctx, &cloudfront.GetFunctionInput{
+		Name:  aws.String(name),
+		Stage: stage,
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the code for %v. Here's why: %v\n", name, err)
+		return ""
+	}
+
+	lootPath, err := SaveLoot(filepath.Join("cloudfront", "functions", fmt.Sprintf("%v-%v.js", name, stage)), function.FunctionCode)
+	if err != nil {
+		return ""
+	}
+	fmt.Printf("\tCode saved to: %v\n", lootPath)
+	PrintSecretFindings(ScanForSecrets(lootPath, function.FunctionCode))
+
+	return lootPath
+}
+
+func DownloadEdgeLambdaCode(ctx context.Context, name string, location string) string {
+	// Same as for any other function, through the pre-signed URL get-function returned,
+	// saved with its version since that's what the distribution runs, i.e. auth-3.zip
+	zipped, err := DownloadAsset(ctx, location, MAX_CODE_PACKAGE_SIZE)
+	if err != nil {
+		fmt.Printf("Couldn't download the code for %v. Here's why: %v\n", name, err)
+		return ""
+	}
+
+	lootPath, err := SaveLoot(filepath.Join("cloudfront", "lambda-edge", strings.ReplaceAll(name, ":", "-")+".zip"), zipped)
+	if err != nil {
+		return ""
+	}
+	fmt.Printf("\tCode saved to: %v\n", lootPath)
+	findings, err := ScanZipForSecrets(lootPath, zipped)
+	if err != nil {
+		fmt.Printf("Couldn't scan the code for %v. Here's why: %v\n", name, err)
+	}
+	PrintSecretFindings(findings)
+
+	return lootPath
+}
+
+func ListDistributions(ctx context.Context, cloudfrontClient *cloudfront.Client) ([]cloudfronttypes.DistributionSummary, error) {
+	var distributions []cloudfronttypes.DistributionSummary
+	var marker *string
+	for {
+		output, err := cloudfrontClient.ListDistributions(ctx, &cloudfront.ListDistributionsInput{Marker: marker})
+		if err != nil {
+			fmt.Printf("Couldn't list the CloudFront distributions. Here's why: %v\n", err)
+			return nil, err
+		}
+		if output.DistributionList == nil {
+			break
+		}
+		distributions = append(distributions, output.DistributionList.Items...)
+		if !aws.ToBool(output.DistributionList.IsTruncated) || ReachedMaxItems(len(distributions)) {
+			break
+		}
+		marker = output.DistributionList.NextMarker
+	}
+
+	return LimitItems(distributions), nil
+}
+
+func ListCloudFrontFunctions(ctx context.Context, cloudfrontClient *cloudfront.Client) ([]cloudfronttypes.FunctionSummary, error) {
+	var functions []cloudfronttypes.FunctionSummary
+	var marker *string
+	for {
+		output, err := cloudfrontClient.ListFunctions(ctx, &cloudfront.ListFunctionsInput{Marker: marker})
+		if err != nil {
+			fmt.Printf("Couldn't list the CloudFront Functions. Here's why: %v\n", err)
+			return nil, err
+		}
+		if output.FunctionList == nil {
+			break
+		}
+		functions = append(functions, output.FunctionList.Items...)
+		if aws.ToString(output.FunctionList.NextMarker) == "" || ReachedMaxItems(len(functions)) {
+			break
+		}
+		marker = output.FunctionList.NextMarker
+	}
+
+	return LimitItems(functions), nil
+}
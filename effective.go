@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// An action pattern and the resources it's allowed on once every policy is combined
+type EffectivePermission struct {
+	Action    string   `json:"action"`
+	Resources []string `json:"resources"`
+	// Narrower or conditional Denies that take part of it away, i.e. s3:deletebucket on * out of s3:*
+	Except []string `json:"except,omitempty"`
+	// The policies granting it, managed policies by ARN and inline ones by principal/name
+	Sources []string `json:"sources"`
+	// Whether the permission boundary cut the action or resources down from what the policies grant
+	NarrowedByBoundary bool `json:"narrowedByBoundary,omitempty"`
+}
+
+type EffectivePermissionsResult struct {
+	Principal   string                `json:"principal"`
+	Boundary    string                `json:"boundary,omitempty"`
+	Permissions []EffectivePermission `json:"permissions"`
+	// What the policies grant that the boundary doesn't allow at all
+	RemovedByBoundary []EffectivePermission `json:"removedByBoundary,omitempty"`
+}
+
+func RunEffectivePermissions(ctx context.Context) error {
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Every inline, attached and group policy that applies to the caller
+	// i.e. aws iam list-attached-user-policies, aws iam list-user-policies, aws iam list-groups-for-user
+	principalArn, policies, err := GetCallerPolicies(ctx, sdkConfig, iamClient)
+	if err != nil {
+		return err
+	}
+	// i.e. aws iam get-user / aws iam get-role, then aws iam get-policy-version for the boundary
+	boundaryArn, boundary, err := GetPermissionsBoundary(ctx, iamClient, principalArn)
+	if err != nil {
+		return err
+	}
+
+	// An explicit Deny anywhere wins, including in the boundary
+	var documents []*PolicyDocument
+	for _, key := range SortedKeys(policies) {
+		documents = append(documents, policies[key])
+	}
+	if boundary != nil {
+		documents = append(documents, boundary)
+	}
+
+	permissions := MergeAllowStatements(policies)
+	var removed map[string]*EffectivePermission
+	if boundary != nil {
+		boundaryPermissions := MergeAllowStatements(map[string]*PolicyDocument{boundaryArn: boundary})
+		removed = PermissionsOutsideBoundary(permissions, boundaryPermissions)
+		permissions = IntersectPermissions(permissions, boundaryPermissions)
+	}
+	permissions = ApplyDenies(permissions, documents)
+	result := EffectivePermissionsResult{
+		Principal:         principalArn,
+		Boundary:          boundaryArn,
+		Permissions:       DropCoveredPermissions(permissions),
+		RemovedByBoundary: DropCoveredPermissions(removed),
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Effective permissions for %v:\n", principalArn)
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("\tPolicies: %v\n", len(policies))
+	if boundaryArn != "" {
+		fmt.Printf("\tPermission boundary: %v\n", boundaryArn)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	for _, permission := range result.Permissions {
+		fmt.Printf("\t%v on %v\n", permission.Action, strings.Join(permission.Resources, ", "))
+		for _, except := range permission.Except {
+			fmt.Printf("\t\tExcept: %v\n", except)
+		}
+		fmt.Printf("\t\tFrom: %v\n", strings.Join(permission.Sources, ", "))
+		if permission.NarrowedByBoundary {
+			fmt.Println("\t\t[-] Narrowed by the permission boundary")
+		}
+	}
+	if len(result.Permissions) == 0 {
+		fmt.Println("\tNothing is allowed")
+	}
+	if len(result.RemovedByBoundary) > 0 {
+		fmt.Println(MINOR_SEPARATOR)
+		fmt.Println("\tGranted by the policies but outside the permission boundary:")
+		for _, permission := range result.RemovedByBoundary {
+			fmt.Printf("\t[-] %v on %v\n", permission.Action, strings.Join(permission.Resources, ", "))
+			fmt.Printf("\t\tFrom: %v\n", strings.Join(permission.Sources, ", "))
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	CurrentModule = "effective-permissions"
+	Emit("effective-permissions", "", result)
+
+	return WriteResults()
+}
+
+func GetPermissionsBoundary(ctx context.Context, iamClient *iam.Client, principalArn string) (string, *PolicyDocument, error) {
+	// Both users and roles can have one, it's returned with the rest of their details
+	parsedArn, err := arn.Parse(principalArn)
+	if err != nil {
+		return "", nil, err
+	}
+	name := parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+
+	var boundary *iamtypes.AttachedPermissionsBoundary
+	if strings.HasPrefix(parsedArn.Resource, "role/") {
+		role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+		if err != nil {
+			fmt.Printf("Couldn't get details for the role %v. Here's why: %v\n", name, err)
+			return "", nil, err
+		}
+		boundary = role.Role.PermissionsBoundary
+	} else {
+		user, err := iamClient.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(name)})
+		if err != nil {
+			fmt.Printf("Couldn't get details for the user %v. Here's why: %v\n", name, err)
+			return "", nil, err
+		}
+		boundary = user.User.PermissionsBoundary
+	}
+	if boundary == nil || boundary.PermissionsBoundaryArn == nil {
+		return "", nil, nil
+	}
+
+	document, err := GetManagedPolicyDocument(ctx, iamClient, *boundary.PermissionsBoundaryArn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return *boundary.PermissionsBoundaryArn, document, nil
+}
+
+func MergeAllowStatements(policies map[string]*PolicyDocument) map[string]*EffectivePermission {
+	// One entry per action pattern, lowercased since actions aren't case-sensitive.
+	// Conditions aren't evaluated, so a conditional Allow counts like any other
+	permissions := map[string]*EffectivePermission{}
+	for _, key := range SortedKeys(policies) {
+		for _, statement := range policies[key].Statement {
+			if statement.Effect != "Allow" {
+				continue
+			}
+			actions := []string(statement.Action)
+			// NotAction in an Allow statement grants almost everything
+			if len(statement.NotAction) > 0 {
+				actions = []string{"*"}
+			}
+			resources := []string(statement.Resource)
+			if len(statement.NotResource) > 0 {
+				resources = []string{"*"}
+			}
+			for _, action := range actions {
+				AddEffectivePermission(permissions, strings.ToLower(action), resources, []string{key}, nil)
+			}
+		}
+	}
+
+	return permissions
+}
+
+func AddEffectivePermission(permissions map[string]*EffectivePermission, action string, resources []string, sources []string, except []string) {
+	permission, ok := permissions[action]
+	if !ok {
+		permission = &EffectivePermission{Action: action}
+		permissions[action] = permission
+	}
+	permission.Resources = AppendMissing(permission.Resources, resources...)
+	permission.Sources = AppendMissing(permission.Sources, sources...)
+	permission.Except = AppendMissing(permission.Except, except...)
+}
+
+func AppendMissing(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		found := false
+		for _, value := range values {
+			if value == addition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, addition)
+		}
+	}
+	sort.Strings(values)
+
+	return values
+}
+
+func IntersectPermissions(permissions map[string]*EffectivePermission, boundary map[string]*EffectivePermission) map[string]*EffectivePermission {
+	// Only what both allow is left, the narrower of each overlapping pair of patterns,
+	// i.e. * in a policy and s3:* in the boundary leaves s3:*
+	intersection := map[string]*EffectivePermission{}
+	for _, permission := range permissions {
+		for _, limit := range boundary {
+			action := ""
+			narrowed := false
+			if ActionCoveredBy(permission.Action, map[string]bool{limit.Action: true}) {
+				action = permission.Action
+			} else if ActionCoveredBy(limit.Action, map[string]bool{permission.Action: true}) {
+				action = limit.Action
+				narrowed = true
+			} else {
+				continue
+			}
+
+			var resources []string
+			for _, resource := range permission.Resources {
+				for _, limitResource := range limit.Resources {
+					if ResourceCoveredBy(resource, []string{limitResource}) {
+						resources = append(resources, resource)
+					} else if ResourceCoveredBy(limitResource, []string{resource}) {
+						resources = append(resources, limitResource)
+						narrowed = true
+					}
+				}
+			}
+			if len(resources) > 0 {
+				AddEffectivePermission(intersection, action, resources, permission.Sources, permission.Except)
+				if narrowed {
+					intersection[action].NarrowedByBoundary = true
+				}
+			}
+		}
+	}
+
+	return intersection
+}
+
+func PrintPermissionsBoundary(boundaryArn string, document *PolicyDocument) {
+	// The boundary caps what the principal's own policies can grant
+	if boundaryArn == "" {
+		return
+	}
+	fmt.Printf("\tPermission boundary: %v\n", boundaryArn)
+	if ResolveDocuments && document != nil {
+		ShowPolicyDocument(boundaryArn, document)
+	}
+}
+
+func PermissionsOutsideBoundary(permissions map[string]*EffectivePermission, boundary map[string]*EffectivePermission) map[string]*EffectivePermission {
+	// Grants with nothing in common with the boundary, which it takes away entirely
+	outside := map[string]*EffectivePermission{}
+	for action, permission := range permissions {
+		if len(IntersectPermissions(map[string]*EffectivePermission{action: permission}, boundary)) == 0 {
+			outside[action] = permission
+		}
+	}
+
+	return outside
+}
+
+func BoundaryAllowsAction(boundary *PolicyDocument, action string) bool {
+	// Whether any Allow in the boundary covers the action at all, whatever the resources
+	allowed := map[string]bool{}
+	for allowedAction := range MergeAllowStatements(map[string]*PolicyDocument{"boundary": boundary}) {
+		allowed[allowedAction] = true
+	}
+
+	return ActionCoveredBy(strings.ToLower(action), allowed)
+}
+
+func ApplyDenies(permissions map[string]*EffectivePermission, documents []*PolicyDocument) map[string]*EffectivePermission {
+	// An unconditional Deny covering the whole action removes the resources it covers, anything
+	// narrower or conditional is noted against the permission instead
+	for _, document := range documents {
+		for _, statement := range document.Statement {
+			if statement.Effect != "Deny" {
+				continue
+			}
+			for action, permission := range permissions {
+				if StatementCoversAction(statement, action) {
+					if len(statement.Condition) > 0 || len(statement.NotResource) > 0 {
+						permission.Except = AppendMissing(permission.Except, DenyDescription(statement))
+						continue
+					}
+					var remaining []string
+					for _, resource := range permission.Resources {
+						if !ResourceCoveredBy(resource, statement.Resource) {
+							remaining = append(remaining, resource)
+						}
+					}
+					if len(remaining) == 0 {
+						delete(permissions, action)
+						continue
+					}
+					if len(remaining) < len(permission.Resources) {
+						permission.Resources = remaining
+					}
+					// A Deny on part of what's left, i.e. one bucket out of *
+					for _, resource := range statement.Resource {
+						if ResourceCoveredBy(resource, permission.Resources) {
+							permission.Except = AppendMissing(permission.Except, DenyDescription(statement))
+							break
+						}
+					}
+					continue
+				}
+
+				for _, denied := range statement.Action {
+					if ActionCoveredBy(strings.ToLower(denied), map[string]bool{action: true}) {
+						permission.Except = AppendMissing(permission.Except, DenyDescription(statement))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return permissions
+}
+
+func DenyDescription(statement PolicyStatement) string {
+	actions := strings.Join(statement.Action, ", ")
+	if len(statement.NotAction) > 0 {
+		actions = "everything but " + strings.Join(statement.NotAction, ", ")
+	}
+	resources := strings.Join(statement.Resource, ", ")
+	if len(statement.NotResource) > 0 {
+		resources = "everything but " + strings.Join(statement.NotResource, ", ")
+	}
+	description := fmt.Sprintf("%v on %v", actions, resources)
+	if len(statement.Condition) > 0 {
+		description += " when its conditions match"
+	}
+
+	return description
+}
+
+func DropCoveredPermissions(permissions map[string]*EffectivePermission) []EffectivePermission {
+	// Leave out anything a broader pattern already allows on the same resources, i.e. s3:getobject
+	// when s3:* is allowed on *, as long as nothing is carved out of the broader one
+	var merged []EffectivePermission
+	for _, action := range SortedKeys(permissions) {
+		permission := permissions[action]
+		covered := false
+		for other, broader := range permissions {
+			if other == action || len(broader.Except) > 0 || !ActionCoveredBy(action, map[string]bool{other: true}) {
+				continue
+			}
+			covered = true
+			for _, resource := range permission.Resources {
+				if !ResourceCoveredBy(resource, broader.Resources) {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				break
+			}
+		}
+		if !covered {
+			merged = append(merged, *permission)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestApplyDenies(t *testing.T) {
+	type want struct {
+		resources []string
+		except    []string
+	}
+	for _, test := range []struct {
+		name  string
+		allow string
+		deny  string
+		// By lowercased action, anything missing was taken away entirely
+		want map[string]want
+	}{
+		{
+			name:  "NotAction deny takes away everything else",
+			allow: `{"Statement":{"Effect":"Allow","Action":["s3:GetObject","iam:PassRole"],"Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","NotAction":"s3:*","Resource":"*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"*"}}},
+		},
+		{
+			name:  "NotAction deny in another case",
+			allow: `{"Statement":{"Effect":"Allow","Action":["s3:GetObject","iam:PassRole"],"Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","NotAction":["S3:GetObject"],"Resource":"*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"*"}}},
+		},
+		{
+			name:  "NotAction deny with a condition",
+			allow: `{"Statement":{"Effect":"Allow","Action":"iam:PassRole","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","NotAction":"s3:*","Resource":"*","Condition":{"Bool":{"aws:MultiFactorAuthPresent":"false"}}}}`,
+			want:  map[string]want{"iam:passrole": {resources: []string{"*"}, except: []string{"everything but s3:* on * when its conditions match"}}},
+		},
+		{
+			name:  "deny everything",
+			allow: `{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"*","Resource":"*"}}`,
+			want:  map[string]want{},
+		},
+		{
+			name:  "deny on the only resource",
+			allow: `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":["arn:aws:s3:::a/*","arn:aws:s3:::b/*"]}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"s3:*","Resource":"arn:aws:s3:::a/*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"arn:aws:s3:::b/*"}}},
+		},
+		{
+			name:  "deny on part of everything",
+			allow: `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"s3:GetObject","Resource":"arn:aws:s3:::secret/*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"*"}, except: []string{"s3:GetObject on arn:aws:s3:::secret/*"}}},
+		},
+		{
+			name:  "deny on part of the action",
+			allow: `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"s3:DeleteBucket","Resource":"*"}}`,
+			want:  map[string]want{"s3:*": {resources: []string{"*"}, except: []string{"s3:DeleteBucket on *"}}},
+		},
+		{
+			name:  "deny on everything but one resource",
+			allow: `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"s3:GetObject","NotResource":"arn:aws:s3:::public/*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"*"}, except: []string{"s3:GetObject on everything but arn:aws:s3:::public/*"}}},
+		},
+		{
+			name:  "deny on another service",
+			allow: `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`,
+			deny:  `{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"*"}}`,
+			want:  map[string]want{"s3:getobject": {resources: []string{"*"}}},
+		},
+	} {
+		policies := parsePolicies(t, test.allow, test.deny)
+		permissions := ApplyDenies(MergeAllowStatements(map[string]*PolicyDocument{"allow": policies[0]}), policies[1:])
+		if len(permissions) != len(test.want) {
+			t.Errorf("%v: got %v permissions, want %v", test.name, len(permissions), len(test.want))
+		}
+		for action, want := range test.want {
+			permission, ok := permissions[action]
+			if !ok {
+				t.Errorf("%v: %v was taken away", test.name, action)
+				continue
+			}
+			if !slices.Equal(permission.Resources, want.resources) {
+				t.Errorf("%v: %v allowed on %v, want %v", test.name, action, permission.Resources, want.resources)
+			}
+			if !slices.Equal(permission.Except, want.except) {
+				t.Errorf("%v: %v except %v, want %v", test.name, action, permission.Except, want.except)
+			}
+		}
+	}
+}
+
+func TestIntersectPermissions(t *testing.T) {
+	type want struct {
+		resources []string
+		narrowed  bool
+	}
+	for _, test := range []struct {
+		name     string
+		policy   string
+		boundary string
+		want     map[string]want
+	}{
+		{
+			name:     "boundary narrows the action",
+			policy:   `{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`,
+			boundary: `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`,
+			want:     map[string]want{"s3:*": {resources: []string{"*"}, narrowed: true}},
+		},
+		{
+			name:     "boundary covers the policy",
+			policy:   `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::a/*"}}`,
+			boundary: `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`,
+			want:     map[string]want{"s3:getobject": {resources: []string{"arn:aws:s3:::a/*"}}},
+		},
+		{
+			name:     "boundary narrows the resources",
+			policy:   `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`,
+			boundary: `{"Statement":{"Effect":"Allow","Action":"S3:GetObject","Resource":"arn:aws:s3:::a/*"}}`,
+			want:     map[string]want{"s3:getobject": {resources: []string{"arn:aws:s3:::a/*"}, narrowed: true}},
+		},
+		{
+			name:     "nothing in common",
+			policy:   `{"Statement":{"Effect":"Allow","Action":"iam:*","Resource":"*"}}`,
+			boundary: `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`,
+			want:     map[string]want{},
+		},
+		{
+			name:     "different resources",
+			policy:   `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::a/*"}}`,
+			boundary: `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}}`,
+			want:     map[string]want{},
+		},
+	} {
+		policies := parsePolicies(t, test.policy, test.boundary)
+		permissions := MergeAllowStatements(map[string]*PolicyDocument{"policy": policies[0]})
+		boundary := MergeAllowStatements(map[string]*PolicyDocument{"boundary": policies[1]})
+		intersection := IntersectPermissions(permissions, boundary)
+		if len(intersection) != len(test.want) {
+			t.Errorf("%v: got %v permissions, want %v", test.name, len(intersection), len(test.want))
+		}
+		for action, want := range test.want {
+			permission, ok := intersection[action]
+			if !ok {
+				t.Errorf("%v: %v is missing", test.name, action)
+				continue
+			}
+			if !slices.Equal(permission.Resources, want.resources) || permission.NarrowedByBoundary != want.narrowed {
+				t.Errorf("%v: %v allowed on %v narrowed %v, want %v narrowed %v", test.name, action, permission.Resources, permission.NarrowedByBoundary, want.resources, want.narrowed)
+			}
+		}
+		if outside := PermissionsOutsideBoundary(permissions, boundary); len(test.want) == 0 && len(outside) != len(permissions) {
+			t.Errorf("%v: %v outside the boundary, want all of %v", test.name, len(outside), len(permissions))
+		}
+	}
+}
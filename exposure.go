@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Who a finding is exposed to, from the widest audience to the narrowest
+const EXPOSURE_INTERNET = "internet-facing"
+const EXPOSURE_CROSS_ACCOUNT = "cross-account"
+const EXPOSURE_INTERNAL = "internal"
+
+var EXPOSURE_CLASSES = []string{EXPOSURE_INTERNET, EXPOSURE_CROSS_ACCOUNT, EXPOSURE_INTERNAL}
+
+// Every finding of the run, keyed by exposure class. Findings are kept whatever the output
+// format so the attack surface can be printed at the end of a text run too
+var AttackSurface = map[string][]Result{}
+
+type AttackSurfaceSummary struct {
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+}
+
+func RecordFinding(finding Result) {
+	// Called with resultsMutex held
+	exposure := finding.Data.(Finding).Exposure
+	AttackSurface[exposure] = append(AttackSurface[exposure], finding)
+}
+
+func PrintAttackSurface() {
+	// Internet-facing first, since anyone can reach those without credentials for the account
+	summary := AttackSurfaceSummary{Counts: map[string]int{}}
+	for _, exposure := range EXPOSURE_CLASSES {
+		summary.Counts[exposure] = len(AttackSurface[exposure])
+		summary.Total += len(AttackSurface[exposure])
+	}
+	if summary.Total == 0 {
+		return
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Attack surface by exposure:")
+	fmt.Println(MAJOR_SEPARATOR)
+	for _, exposure := range EXPOSURE_CLASSES {
+		fmt.Printf("\t%v: %v\n", exposure, summary.Counts[exposure])
+	}
+	for _, exposure := range EXPOSURE_CLASSES {
+		if len(AttackSurface[exposure]) == 0 {
+			continue
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		fmt.Printf("\t%v:\n", exposure)
+		for _, result := range AttackSurface[exposure] {
+			finding := result.Data.(Finding)
+			location := result.Module
+			if result.Region != "" {
+				location += ", " + result.Region
+			}
+			if result.Account != "" {
+				location = result.Account + ", " + location
+			} else if result.Profile != "" {
+				location = result.Profile + ", " + location
+			}
+			fmt.Printf("\t\t%v: %v (%v)\n", finding.Resource, finding.Message, location)
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("attack-surface", "", summary)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+type FlowLogResult struct {
+	FlowLogId   string `json:"flowLogId"`
+	ResourceId  string `json:"resourceId"`
+	TrafficType string `json:"trafficType"`
+	// Where the records go, i.e. cloud-watch-logs, s3 or kinesis-data-firehose, and the group, bucket or stream
+	DestinationType string `json:"destinationType"`
+	Destination     string `json:"destination"`
+	DeliveryStatus  string `json:"deliveryStatus"`
+}
+
+type FlowLogCoverageResult struct {
+	FlowLogs []FlowLogResult `json:"flowLogs"`
+	// Resources with no flow log on them or on anything containing them
+	UncoveredVPCs       []string `json:"uncoveredVpcs"`
+	UncoveredSubnets    []string `json:"uncoveredSubnets"`
+	UncoveredInterfaces []string `json:"uncoveredInterfaces"`
+}
+
+func RunFlowLogsModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ec2Client := ec2.NewFromConfig(regionalConfig)
+
+		// i.e. aws ec2 describe-flow-logs
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking VPC flow log coverage in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		flowLogs, err := ListFlowLogs(ctx, ec2Client)
+		if err != nil {
+			return err
+		}
+
+		// A flow log on a VPC covers its subnets and interfaces, and one on a subnet covers its interfaces
+		result := FlowLogCoverageResult{}
+		logged := map[string]bool{}
+		for _, flowLog := range flowLogs {
+			flowLogResult := FlowLogResult{
+				FlowLogId:       aws.ToString(flowLog.FlowLogId),
+				ResourceId:      aws.ToString(flowLog.ResourceId),
+				TrafficType:     string(flowLog.TrafficType),
+				DestinationType: string(flowLog.LogDestinationType),
+				Destination:     aws.ToString(flowLog.LogDestination),
+				DeliveryStatus:  aws.ToString(flowLog.DeliverLogsStatus),
+			}
+			if flowLogResult.Destination == "" {
+				flowLogResult.Destination = aws.ToString(flowLog.LogGroupName)
+			}
+			result.FlowLogs = append(result.FlowLogs, flowLogResult)
+
+			fmt.Printf("\tFlow log: %v on %v\n", flowLogResult.FlowLogId, flowLogResult.ResourceId)
+			fmt.Printf("\t\tTraffic: %v\n", flowLogResult.TrafficType)
+			fmt.Printf("\t\tDelivered to: %v (%v)\n", flowLogResult.Destination, flowLogResult.DestinationType)
+			// A flow log that can't deliver records is no better than none at all
+			if flowLogResult.DeliveryStatus == "FAILED" {
+				fmt.Printf("\t\t[!] Delivery is failing: %v\n", aws.ToString(flowLog.DeliverLogsErrorMessage))
+				EmitFinding(regionalConfig.Region, flowLogResult.FlowLogId, "Flow log delivery is failing, so no traffic is being recorded")
+				continue
+			}
+			if flowLog.TrafficType != ec2types.TrafficTypeAll {
+				fmt.Printf("\t\t[-] Only %v traffic is recorded\n", flowLogResult.TrafficType)
+			}
+			logged[flowLogResult.ResourceId] = true
+		}
+		if len(flowLogs) == 0 {
+			fmt.Println("\tNo flow logs in this region")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws ec2 describe-vpcs
+		vpcs, err := ListVPCs(ctx, ec2Client)
+		if err != nil {
+			return err
+		}
+		for _, vpc := range vpcs {
+			if !logged[*vpc.VpcId] {
+				result.UncoveredVPCs = append(result.UncoveredVPCs, *vpc.VpcId)
+				fmt.Printf("\t[!] VPC %v has no flow log\n", *vpc.VpcId)
+				EmitFinding(regionalConfig.Region, *vpc.VpcId, "VPC has no flow log, so its network traffic isn't recorded")
+			}
+		}
+
+		// i.e. aws ec2 describe-subnets
+		subnets, err := ListSubnets(ctx, ec2Client)
+		if err != nil {
+			return err
+		}
+		for _, subnet := range subnets {
+			if !logged[*subnet.SubnetId] && !logged[aws.ToString(subnet.VpcId)] {
+				result.UncoveredSubnets = append(result.UncoveredSubnets, *subnet.SubnetId)
+				fmt.Printf("\t[-] Subnet %v in %v has no flow log\n", *subnet.SubnetId, aws.ToString(subnet.VpcId))
+			}
+		}
+
+		// i.e. aws ec2 describe-network-interfaces
+		interfaces, err := ListNetworkInterfaces(ctx, ec2Client)
+		if err != nil {
+			return err
+		}
+		for _, networkInterface := range interfaces {
+			if !logged[*networkInterface.NetworkInterfaceId] && !logged[aws.ToString(networkInterface.SubnetId)] && !logged[aws.ToString(networkInterface.VpcId)] {
+				result.UncoveredInterfaces = append(result.UncoveredInterfaces, *networkInterface.NetworkInterfaceId)
+				fmt.Printf("\t[-] Network interface %v (%v) has no flow log\n", *networkInterface.NetworkInterfaceId, aws.ToString(networkInterface.Description))
+			}
+		}
+		fmt.Printf("\tUncovered: %v of %v VPCs, %v of %v subnets, %v of %v network interfaces\n",
+			len(result.UncoveredVPCs), len(vpcs), len(result.UncoveredSubnets), len(subnets), len(result.UncoveredInterfaces), len(interfaces))
+		fmt.Println(MAJOR_SEPARATOR)
+		Emit("flow-logs", regionalConfig.Region, result)
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListFlowLogs(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.FlowLog, error) {
+	var flowLogs []ec2types.FlowLog
+	paginator := ec2.NewDescribeFlowLogsPaginator(ec2Client, &ec2.DescribeFlowLogsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the flow logs. Here's why: %v\n", err)
+			return nil, err
+		}
+		flowLogs = append(flowLogs, page.FlowLogs...)
+	}
+
+	return flowLogs, nil
+}
+
+func ListVPCs(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.Vpc, error) {
+	var vpcs []ec2types.Vpc
+	paginator := ec2.NewDescribeVpcsPaginator(ec2Client, &ec2.DescribeVpcsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the VPCs. Here's why: %v\n", err)
+			return nil, err
+		}
+		vpcs = append(vpcs, page.Vpcs...)
+	}
+
+	return vpcs, nil
+}
+
+func ListSubnets(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.Subnet, error) {
+	var subnets []ec2types.Subnet
+	paginator := ec2.NewDescribeSubnetsPaginator(ec2Client, &ec2.DescribeSubnetsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the subnets. Here's why: %v\n", err)
+			return nil, err
+		}
+		subnets = append(subnets, page.Subnets...)
+	}
+
+	return subnets, nil
+}
+
+func ListNetworkInterfaces(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.NetworkInterface, error) {
+	var interfaces []ec2types.NetworkInterface
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(ec2Client, &ec2.DescribeNetworkInterfacesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the network interfaces. Here's why: %v\n", err)
+			return nil, err
+		}
+		interfaces = append(interfaces, page.NetworkInterfaces...)
+	}
+
+	return interfaces, nil
+}
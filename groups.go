@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// A group with its members and the permissions it passes on to them
+type GroupResult struct {
+	Group   iamtypes.Group `json:"group"`
+	Members []string       `json:"members"`
+	// Policy name mapped to its document, attached managed policies are keyed by ARN
+	Policies map[string]*PolicyDocument `json:"policies"`
+	Notable  []string                   `json:"notable"`
+	// Notable actions the account's SCPs take away, only known in org-scan
+	BlockedBySCP []string `json:"blockedBySCP,omitempty"`
+}
+
+func RunGroupsModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-groups API and get the members and policies of each group
+	// i.e. aws iam list-groups
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting members and policies for every group...")
+	fmt.Println(MAJOR_SEPARATOR)
+	groups, err := CachedGroups(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list groups. Exiting...")
+		return err
+	}
+
+	// The members and policies of several groups are fetched at once, then printed in order
+	results := make([]GroupResult, len(groups))
+	ForEachConcurrently(ctx, len(groups), func(ctx context.Context, i int) {
+		results[i] = GetGroupResult(ctx, iamClient, groups[i])
+	}, func(i int) {
+		group := groups[i]
+		result := results[i]
+		fmt.Printf("\tGroup name: %v\n", *group.GroupName)
+		fmt.Printf("\tGroup ARN: %v\n", *group.Arn)
+		for _, member := range result.Members {
+			fmt.Printf("\tMember: %v\n", member)
+		}
+		for _, policyName := range SortedKeys(result.Policies) {
+			fmt.Printf("\tPolicy: %v\n", policyName)
+			if ResolveDocuments {
+				ShowPolicyDocument(PolicyDocumentKey("group/"+*group.GroupName, policyName), result.Policies[policyName])
+			}
+		}
+		for _, action := range result.Notable {
+			if action == "*" {
+				fmt.Println("\t[!] Group has full administrative access")
+				EmitFinding("", *group.Arn, fmt.Sprintf("Group has full administrative access and %v member(s)", len(result.Members)))
+				continue
+			}
+			fmt.Printf("\t[!] Grants %v\n", action)
+		}
+		for _, action := range result.BlockedBySCP {
+			fmt.Printf("\t[-] Blocked by SCP: %v\n", action)
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("group", "", result)
+	})
+
+	return nil
+}
+
+func GetGroupResult(ctx context.Context, iamClient *iam.Client, group iamtypes.Group) GroupResult {
+	result := GroupResult{
+		Group:    group,
+		Policies: map[string]*PolicyDocument{},
+	}
+
+	// i.e. aws iam get-group --group-name <group-name>
+	members, err := ListGroupMembers(ctx, iamClient, *group.GroupName)
+	if err == nil {
+		for _, member := range members {
+			result.Members = append(result.Members, *member.UserName)
+		}
+	}
+
+	// i.e. aws iam list-attached-group-policies --group-name <group-name>
+	attachedPaginator := iam.NewListAttachedGroupPoliciesPaginator(iamClient, &iam.ListAttachedGroupPoliciesInput{
+		GroupName: group.GroupName,
+	})
+	for attachedPaginator.HasMorePages() {
+		page, err := attachedPaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the attached policies for %v. Here's why: %v\n", *group.GroupName, err)
+			break
+		}
+		for _, policy := range page.AttachedPolicies {
+			document, err := GetManagedPolicyDocument(ctx, iamClient, *policy.PolicyArn)
+			if err != nil {
+				continue
+			}
+			result.Policies[*policy.PolicyArn] = document
+		}
+	}
+
+	// i.e. aws iam list-group-policies --group-name <group-name>
+	inlinePaginator := iam.NewListGroupPoliciesPaginator(iamClient, &iam.ListGroupPoliciesInput{
+		GroupName: group.GroupName,
+	})
+	for inlinePaginator.HasMorePages() {
+		page, err := inlinePaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the inline policies for %v. Here's why: %v\n", *group.GroupName, err)
+			break
+		}
+		for _, policyName := range page.PolicyNames {
+			document, err := GetInlineGroupPolicyDocument(ctx, iamClient, *group.GroupName, policyName)
+			if err != nil {
+				continue
+			}
+			result.Policies[policyName] = document
+		}
+	}
+
+	// Everything a group grants, every member gets
+	var documents []*PolicyDocument
+	for _, document := range result.Policies {
+		documents = append(documents, document)
+	}
+	result.Notable, result.BlockedBySCP = ApplySCPs(NotablePermissions(documents))
+
+	return result
+}
+
+func ListAllGroups(ctx context.Context, iamClient *iam.Client) ([]iamtypes.Group, error) {
+	// Get every group in the account
+	var groups []iamtypes.Group
+	paginator := iam.NewListGroupsPaginator(iamClient, &iam.ListGroupsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(groups)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the groups. Here's why: %v\n", err)
+			return nil, err
+		}
+		groups = append(groups, page.Groups...)
+	}
+
+	return LimitItems(groups), nil
+}
+
+func ListGroupMembers(ctx context.Context, iamClient *iam.Client, groupName string) ([]iamtypes.User, error) {
+	var members []iamtypes.User
+	paginator := iam.NewGetGroupPaginator(iamClient, &iam.GetGroupInput{
+		GroupName: aws.String(groupName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the members of %v. Here's why: %v\n", groupName, err)
+			return nil, err
+		}
+		members = append(members, page.Users...)
+	}
+
+	return members, nil
+}
+
+func GetInlineGroupPolicyDocument(ctx context.Context, iamClient *iam.Client, groupName string, policyName string) (*PolicyDocument, error) {
+	// i.e. aws iam get-group-policy --group-name <group-name> --policy-name <policy-name>
+	inlinePolicy, err := iamClient.GetGroupPolicy(ctx, &iam.GetGroupPolicyInput{
+		GroupName:  aws.String(groupName),
+		PolicyName: aws.String(policyName),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the inline policy %v for %v. Here's why: %v\n", policyName, groupName, err)
+		return nil, err
+	}
+
+	return ParsePolicyDocument(*inlinePolicy.PolicyDocument)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+func RunHybridModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ssmClient := ssm.NewFromConfig(regionalConfig)
+
+		// Call the describe-activations API to get the hybrid activations that can enrol new machines
+		// i.e. aws ssm describe-activations
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting SSM hybrid activations for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		activations, err := ListAllActivations(ctx, ssmClient)
+		if err == nil {
+			for _, activation := range activations {
+				fmt.Printf("\tActivation ID: %v\n", *activation.ActivationId)
+				fmt.Printf("\tDescription: %v\n", aws.ToString(activation.Description))
+				fmt.Printf("\tIAM role: %v\n", aws.ToString(activation.IamRole))
+				fmt.Printf("\tRegistrations: %v of %v\n", activation.RegistrationsCount, aws.ToInt32(activation.RegistrationLimit))
+				fmt.Printf("\tExpires: %v\n", aws.ToTime(activation.ExpirationDate))
+				if !activation.Expired {
+					fmt.Println("\t[-] Activation is still valid, anyone with its code can enrol a machine")
+					EmitExposedFinding(regionalConfig.Region, *activation.ActivationId, EXPOSURE_INTERNET, "Activation is still valid, anyone with its code can enrol a machine")
+				}
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("activation", regionalConfig.Region, activation)
+			}
+		}
+
+		// Call the describe-instance-information API to get managed nodes that aren't EC2 instances
+		// i.e. aws ssm describe-instance-information --filters Key=ResourceType,Values=ManagedInstance
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting non-EC2 managed nodes for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		nodes, err := ListHybridManagedNodes(ctx, ssmClient)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			fmt.Printf("\tNode ID: %v\n", *node.InstanceId)
+			fmt.Printf("\tName: %v\n", aws.ToString(node.Name))
+			fmt.Printf("\tComputer name: %v\n", aws.ToString(node.ComputerName))
+			fmt.Printf("\tIP address: %v\n", aws.ToString(node.IPAddress))
+			fmt.Printf("\tPlatform: %v %v\n", aws.ToString(node.PlatformName), aws.ToString(node.PlatformVersion))
+			fmt.Printf("\tPing status: %v\n", node.PingStatus)
+			fmt.Printf("\tIAM role: %v\n", aws.ToString(node.IamRole))
+			fmt.Printf("\tActivation ID: %v\n", aws.ToString(node.ActivationId))
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("managed-node", regionalConfig.Region, node)
+			EmitFinding(regionalConfig.Region, *node.InstanceId, "Non-EC2 machine reachable from this account with ssm:SendCommand")
+		}
+		if len(nodes) > 0 {
+			fmt.Printf("[!] %v on-prem or other-cloud machine(s) are reachable from this account with ssm:SendCommand\n", len(nodes))
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListAllActivations(ctx context.Context, ssmClient *ssm.Client) ([]ssmtypes.Activation, error) {
+	// Get every hybrid activation in the region
+	var activations []ssmtypes.Activation
+	paginator := ssm.NewDescribeActivationsPaginator(ssmClient, &ssm.DescribeActivationsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(activations)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the hybrid activations. Here's why: %v\n", err)
+			return nil, err
+		}
+		activations = append(activations, page.ActivationList...)
+	}
+
+	return LimitItems(activations), nil
+}
+
+func ListHybridManagedNodes(ctx context.Context, ssmClient *ssm.Client) ([]ssmtypes.InstanceInformation, error) {
+	// Get every managed node registered through a hybrid activation
+	var nodes []ssmtypes.InstanceInformation
+	paginator := ssm.NewDescribeInstanceInformationPaginator(ssmClient, &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmtypes.InstanceInformationStringFilter{
+			{Key: aws.String("ResourceType"), Values: []string{"ManagedInstance"}},
+		},
+	})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(nodes)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the managed nodes. Here's why: %v\n", err)
+			return nil, err
+		}
+		nodes = append(nodes, page.InstanceInformationList...)
+	}
+
+	return LimitItems(nodes), nil
+}
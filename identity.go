@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type CallerIdentityResult struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+	UserId  string `json:"userId"`
+}
+
+// What the operator is about to enumerate, printed before any module runs
+type AccountBannerResult struct {
+	Account string `json:"account"`
+	// Empty when the account has no alias or it couldn't be listed
+	Alias     string `json:"alias,omitempty"`
+	Partition string `json:"partition"`
+	Arn       string `json:"arn"`
+	Identity  string `json:"identity"`
+}
+
+func PrintAccountBanner(ctx context.Context, sdkConfig aws.Config) (*AccountBannerResult, error) {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return nil, err
+	}
+	banner := &AccountBannerResult{
+		Account:   aws.ToString(callerIdentity.Account),
+		Partition: CallerPartition(aws.ToString(callerIdentity.Arn)),
+		Arn:       aws.ToString(callerIdentity.Arn),
+		Identity:  CallerIdentityName(aws.ToString(callerIdentity.Arn)),
+	}
+
+	// An account has at most one alias, and not being allowed to list it shouldn't stop the run
+	// i.e. aws iam list-account-aliases
+	aliases, err := ListAccountAliases(ctx, iam.NewFromConfig(sdkConfig))
+	if err == nil && len(aliases) > 0 {
+		banner.Alias = aliases[0]
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("\tAccount: %v\n", banner.Account)
+	if banner.Alias != "" {
+		fmt.Printf("\tAlias: %v\n", banner.Alias)
+	} else if err != nil {
+		fmt.Println("\tAlias: unknown")
+	} else {
+		fmt.Println("\tAlias: none")
+	}
+	fmt.Printf("\tPartition: %v\n", banner.Partition)
+	fmt.Printf("\tIdentity: %v\n", banner.Identity)
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("account-banner", "", banner)
+
+	return banner, nil
+}
+
+func ListAccountAliases(ctx context.Context, iamClient *iam.Client) ([]string, error) {
+	var aliases []string
+	paginator := iam.NewListAccountAliasesPaginator(iamClient, &iam.ListAccountAliasesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the account aliases. Here's why: %v\n", err)
+			return nil, err
+		}
+		aliases = append(aliases, page.AccountAliases...)
+	}
+
+	return aliases, nil
+}
+
+func CallerIdentityName(callerArn string) string {
+	// i.e. role Admin (session jdoe) for arn:aws:sts::<account>:assumed-role/Admin/jdoe
+	roleName, ok := CallerRoleName(callerArn)
+	if ok {
+		parts := strings.Split(callerArn, "/")
+		return fmt.Sprintf("role %v (session %v)", roleName, parts[len(parts)-1])
+	}
+	parsedArn, err := arn.Parse(callerArn)
+	if err != nil {
+		return callerArn
+	}
+	if userName, ok := strings.CutPrefix(parsedArn.Resource, "user/"); ok {
+		return "user " + userName[strings.LastIndex(userName, "/")+1:]
+	}
+	if parsedArn.Resource == "root" {
+		return "root user"
+	}
+
+	return parsedArn.Resource
+}
+
+func GetCallerIdentity(ctx context.Context, stsClient *sts.Client) (*sts.GetCallerIdentityOutput, error) {
+	// Get the account, ARN and ID of whoever the credentials belong to
+	callerIdentity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the caller identity. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return callerIdentity, nil
+}
+
+func CallerPartition(callerArn string) string {
+	// i.e. aws, aws-cn or aws-us-gov
+	parsedArn, err := arn.Parse(callerArn)
+	if err != nil {
+		return "aws"
+	}
+
+	return parsedArn.Partition
+}
+
+func CallerRoleName(callerArn string) (string, bool) {
+	// Role sessions look like arn:aws:sts::<account>:assumed-role/<role>/<session>
+	parsedArn, err := arn.Parse(callerArn)
+	if err != nil || parsedArn.Service != "sts" || !strings.HasPrefix(parsedArn.Resource, "assumed-role/") {
+		return "", false
+	}
+
+	return strings.Split(strings.TrimPrefix(parsedArn.Resource, "assumed-role/"), "/")[0], true
+}
+
+func SimulationPrincipalArn(callerArn string) string {
+	// Policy simulation needs the role itself rather than the session, so
+	// arn:aws:sts::<account>:assumed-role/<role>/<session> becomes arn:aws:iam::<account>:role/<role>
+	roleName, ok := CallerRoleName(callerArn)
+	if !ok {
+		return callerArn
+	}
+
+	parsedArn, _ := arn.Parse(callerArn)
+	return fmt.Sprintf("arn:%v:iam::%v:role/%v", parsedArn.Partition, parsedArn.AccountID, roleName)
+}
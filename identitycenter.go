@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Identity Center creates one role per permission set in each account it's provisioned to,
+// named AWSReservedSSO_<permission set>_<suffix> under this path, and names its inline policy
+const SSO_ROLE_PATH = "/aws-reserved/sso.amazonaws.com/"
+const SSO_INLINE_POLICY_NAME = "AwsSSOInlinePolicy"
+
+// What a permission set says its provisioned roles should have
+type PermissionSetPolicies struct {
+	ManagedPolicyArns []string `json:"managedPolicyArns"`
+	// Customer managed policies are referenced by path and name, and resolved in each account
+	CustomerManagedPolicies []string        `json:"customerManagedPolicies"`
+	InlinePolicy            *PolicyDocument `json:"inlinePolicy,omitempty"`
+}
+
+type PermissionSetDriftResult struct {
+	PermissionSetArn string   `json:"permissionSetArn"`
+	Name             string   `json:"name"`
+	AccountId        string   `json:"accountId"`
+	RoleName         string   `json:"roleName,omitempty"`
+	Drift            []string `json:"drift"`
+}
+
+func RunIdentityCenterModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+
+	// Identity Center lives in a single region, so look for it in each selected one
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ssoClient := ssoadmin.NewFromConfig(regionalConfig)
+
+		// i.e. aws sso-admin list-instances
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking Identity Center permission sets for drift in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := ListIdentityCenterInstances(ctx, ssoClient)
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			fmt.Println("\tNo Identity Center instance in this region")
+			return nil
+		}
+
+		for _, instance := range instances {
+			permissionSetArns, err := ListPermissionSets(ctx, ssoClient, *instance.InstanceArn)
+			if err != nil {
+				continue
+			}
+
+			for _, permissionSetArn := range permissionSetArns {
+				// i.e. aws sso-admin describe-permission-set --instance-arn <instance-arn> --permission-set-arn <arn>
+				permissionSet, err := ssoClient.DescribePermissionSet(ctx, &ssoadmin.DescribePermissionSetInput{
+					InstanceArn:      instance.InstanceArn,
+					PermissionSetArn: aws.String(permissionSetArn),
+				})
+				if err != nil {
+					fmt.Printf("Couldn't describe the permission set %v. Here's why: %v\n", permissionSetArn, err)
+					continue
+				}
+				name := *permissionSet.PermissionSet.Name
+				fmt.Printf("\tPermission set: %v\n", name)
+
+				expected, err := GetPermissionSetPolicies(ctx, ssoClient, *instance.InstanceArn, permissionSetArn)
+				if err != nil {
+					continue
+				}
+
+				// i.e. aws sso-admin list-accounts-for-provisioned-permission-set
+				accountIds, err := ListAccountsForPermissionSet(ctx, ssoClient, *instance.InstanceArn, permissionSetArn)
+				if err != nil {
+					continue
+				}
+
+				for _, accountId := range accountIds {
+					accountConfig := regionalConfig
+					if accountId != *callerIdentity.Account {
+						// Roles in other accounts can only be checked through org-scan's audit role
+						if OrgAuditRole == "" {
+							fmt.Printf("\t\tAccount %v: skipped, run through org-scan to check other accounts\n", accountId)
+							continue
+						}
+						roleArn := fmt.Sprintf("arn:%v:iam::%v:role/%v", CallerPartition(*callerIdentity.Arn), accountId, OrgAuditRole)
+						accountConfig = AssumeRoleConfig(regionalConfig, roleArn, "", SessionNameFlag)
+					}
+
+					result := PermissionSetDriftResult{
+						PermissionSetArn: permissionSetArn,
+						Name:             name,
+						AccountId:        accountId,
+					}
+					role, drift, err := CheckPermissionSetDrift(ctx, iam.NewFromConfig(accountConfig), accountId, CallerPartition(*callerIdentity.Arn), name, expected)
+					if err != nil {
+						fmt.Printf("\t\tAccount %v: couldn't check the provisioned role\n", accountId)
+						continue
+					}
+					if role != nil {
+						result.RoleName = *role.RoleName
+					}
+					result.Drift = drift
+
+					if len(drift) == 0 {
+						fmt.Printf("\t\tAccount %v: matches the permission set\n", accountId)
+					} else {
+						fmt.Printf("\t\tAccount %v:\n", accountId)
+						for _, difference := range drift {
+							fmt.Printf("\t\t[!] %v\n", difference)
+							EmitFinding(regionalConfig.Region, permissionSetArn, fmt.Sprintf("Provisioned role in %v: %v", accountId, difference))
+						}
+					}
+					Emit("permission-set-drift", regionalConfig.Region, result)
+				}
+				fmt.Println(MINOR_SEPARATOR)
+			}
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetPermissionSetPolicies(ctx context.Context, ssoClient *ssoadmin.Client, instanceArn string, permissionSetArn string) (*PermissionSetPolicies, error) {
+	policies := &PermissionSetPolicies{}
+
+	// i.e. aws sso-admin list-managed-policies-in-permission-set
+	managedPaginator := ssoadmin.NewListManagedPoliciesInPermissionSetPaginator(ssoClient, &ssoadmin.ListManagedPoliciesInPermissionSetInput{
+		InstanceArn:      aws.String(instanceArn),
+		PermissionSetArn: aws.String(permissionSetArn),
+	})
+	for managedPaginator.HasMorePages() {
+		page, err := managedPaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the managed policies in %v. Here's why: %v\n", permissionSetArn, err)
+			return nil, err
+		}
+		for _, policy := range page.AttachedManagedPolicies {
+			policies.ManagedPolicyArns = append(policies.ManagedPolicyArns, *policy.Arn)
+		}
+	}
+
+	// i.e. aws sso-admin list-customer-managed-policy-references-in-permission-set
+	customerPaginator := ssoadmin.NewListCustomerManagedPolicyReferencesInPermissionSetPaginator(ssoClient, &ssoadmin.ListCustomerManagedPolicyReferencesInPermissionSetInput{
+		InstanceArn:      aws.String(instanceArn),
+		PermissionSetArn: aws.String(permissionSetArn),
+	})
+	for customerPaginator.HasMorePages() {
+		page, err := customerPaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the customer managed policies in %v. Here's why: %v\n", permissionSetArn, err)
+			return nil, err
+		}
+		for _, reference := range page.CustomerManagedPolicyReferences {
+			policies.CustomerManagedPolicies = append(policies.CustomerManagedPolicies, CustomerManagedPolicyPath(reference))
+		}
+	}
+
+	// i.e. aws sso-admin get-inline-policy-for-permission-set
+	inline, err := ssoClient.GetInlinePolicyForPermissionSet(ctx, &ssoadmin.GetInlinePolicyForPermissionSetInput{
+		InstanceArn:      aws.String(instanceArn),
+		PermissionSetArn: aws.String(permissionSetArn),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the inline policy for %v. Here's why: %v\n", permissionSetArn, err)
+		return nil, err
+	}
+	if aws.ToString(inline.InlinePolicy) != "" {
+		policies.InlinePolicy, _ = ParsePolicyDocument(*inline.InlinePolicy)
+	}
+
+	return policies, nil
+}
+
+func CustomerManagedPolicyPath(reference ssotypes.CustomerManagedPolicyReference) string {
+	// Path and name, i.e. /team/ReadOnly, which is the end of the policy's ARN in each account
+	path := aws.ToString(reference.Path)
+	if path == "" {
+		path = "/"
+	}
+
+	return path + *reference.Name
+}
+
+func CheckPermissionSetDrift(ctx context.Context, iamClient *iam.Client, accountId string, partition string, permissionSetName string, expected *PermissionSetPolicies) (*iamtypes.Role, []string, error) {
+	// Find the role Identity Center provisioned for the permission set
+	// i.e. aws iam list-roles --path-prefix /aws-reserved/sso.amazonaws.com/
+	var role *iamtypes.Role
+	paginator := iam.NewListRolesPaginator(iamClient, &iam.ListRolesInput{
+		PathPrefix: aws.String(SSO_ROLE_PATH),
+	})
+	for paginator.HasMorePages() && role == nil {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Identity Center roles in %v. Here's why: %v\n", accountId, err)
+			return nil, nil, err
+		}
+		for _, candidate := range page.Roles {
+			// The suffix never has an underscore in it, so Admin doesn't match Admin_ReadOnly's role
+			suffix, ok := strings.CutPrefix(*candidate.RoleName, "AWSReservedSSO_"+permissionSetName+"_")
+			if ok && !strings.Contains(suffix, "_") {
+				role = &candidate
+				break
+			}
+		}
+	}
+	if role == nil {
+		return nil, []string{"no provisioned role found, it may have been deleted"}, nil
+	}
+
+	// Everything the permission set attaches should be there, and nothing else
+	var drift []string
+	expectedArns := map[string]bool{}
+	for _, policyArn := range expected.ManagedPolicyArns {
+		expectedArns[policyArn] = true
+	}
+	for _, policyPath := range expected.CustomerManagedPolicies {
+		expectedArns[fmt.Sprintf("arn:%v:iam::%v:policy%v", partition, accountId, policyPath)] = true
+	}
+
+	// i.e. aws iam list-attached-role-policies --role-name <role-name>
+	attachedArns := map[string]bool{}
+	attachedPaginator := iam.NewListAttachedRolePoliciesPaginator(iamClient, &iam.ListAttachedRolePoliciesInput{
+		RoleName: role.RoleName,
+	})
+	for attachedPaginator.HasMorePages() {
+		page, err := attachedPaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the attached policies for %v. Here's why: %v\n", *role.RoleName, err)
+			return role, nil, err
+		}
+		for _, policy := range page.AttachedPolicies {
+			attachedArns[*policy.PolicyArn] = true
+		}
+	}
+	for _, policyArn := range SortedKeys(attachedArns) {
+		if !expectedArns[policyArn] {
+			drift = append(drift, fmt.Sprintf("%v is attached but isn't in the permission set", policyArn))
+		}
+	}
+	for _, policyArn := range SortedKeys(expectedArns) {
+		if !attachedArns[policyArn] {
+			drift = append(drift, fmt.Sprintf("%v is in the permission set but isn't attached", policyArn))
+		}
+	}
+
+	// i.e. aws iam list-role-policies --role-name <role-name>
+	var inlineNames []string
+	inlinePaginator := iam.NewListRolePoliciesPaginator(iamClient, &iam.ListRolePoliciesInput{
+		RoleName: role.RoleName,
+	})
+	for inlinePaginator.HasMorePages() {
+		page, err := inlinePaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the inline policies for %v. Here's why: %v\n", *role.RoleName, err)
+			return role, nil, err
+		}
+		inlineNames = append(inlineNames, page.PolicyNames...)
+	}
+	sort.Strings(inlineNames)
+
+	hasInline := false
+	for _, policyName := range inlineNames {
+		if policyName != SSO_INLINE_POLICY_NAME {
+			drift = append(drift, fmt.Sprintf("inline policy %v was added outside of Identity Center", policyName))
+			continue
+		}
+		hasInline = true
+
+		// i.e. aws iam get-role-policy --role-name <role-name> --policy-name AwsSSOInlinePolicy
+		inlinePolicy, err := iamClient.GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+			RoleName:   role.RoleName,
+			PolicyName: aws.String(policyName),
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the inline policy for %v. Here's why: %v\n", *role.RoleName, err)
+			continue
+		}
+		document, err := ParsePolicyDocument(*inlinePolicy.PolicyDocument)
+		if err != nil {
+			continue
+		}
+		if !SamePolicyDocument(document, expected.InlinePolicy) {
+			drift = append(drift, "inline policy differs from the permission set's")
+		}
+	}
+	if !hasInline && expected.InlinePolicy != nil {
+		drift = append(drift, "the permission set's inline policy is missing")
+	}
+
+	return role, drift, nil
+}
+
+func SamePolicyDocument(a *PolicyDocument, b *PolicyDocument) bool {
+	// Compare the decoded documents so whitespace and single values vs lists don't count as changes
+	if a == nil || b == nil {
+		return a == b
+	}
+	aJson, _ := json.Marshal(a)
+	bJson, _ := json.Marshal(b)
+	var aValue, bValue any
+	json.Unmarshal(aJson, &aValue)
+	json.Unmarshal(bJson, &bValue)
+
+	return reflect.DeepEqual(aValue, bValue)
+}
+
+func ListIdentityCenterInstances(ctx context.Context, ssoClient *ssoadmin.Client) ([]ssotypes.InstanceMetadata, error) {
+	var instances []ssotypes.InstanceMetadata
+	paginator := ssoadmin.NewListInstancesPaginator(ssoClient, &ssoadmin.ListInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Identity Center instances. Here's why: %v\n", err)
+			return nil, err
+		}
+		instances = append(instances, page.Instances...)
+	}
+
+	return instances, nil
+}
+
+func ListPermissionSets(ctx context.Context, ssoClient *ssoadmin.Client, instanceArn string) ([]string, error) {
+	// i.e. aws sso-admin list-permission-sets --instance-arn <instance-arn>
+	var permissionSets []string
+	paginator := ssoadmin.NewListPermissionSetsPaginator(ssoClient, &ssoadmin.ListPermissionSetsInput{
+		InstanceArn: aws.String(instanceArn),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the permission sets. Here's why: %v\n", err)
+			return nil, err
+		}
+		permissionSets = append(permissionSets, page.PermissionSets...)
+	}
+
+	return permissionSets, nil
+}
+
+func ListAccountsForPermissionSet(ctx context.Context, ssoClient *ssoadmin.Client, instanceArn string, permissionSetArn string) ([]string, error) {
+	var accountIds []string
+	paginator := ssoadmin.NewListAccountsForProvisionedPermissionSetPaginator(ssoClient, &ssoadmin.ListAccountsForProvisionedPermissionSetInput{
+		InstanceArn:      aws.String(instanceArn),
+		PermissionSetArn: aws.String(permissionSetArn),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the accounts %v is provisioned to. Here's why: %v\n", permissionSetArn, err)
+			return nil, err
+		}
+		accountIds = append(accountIds, page.AccountIds...)
+	}
+
+	return accountIds, nil
+}
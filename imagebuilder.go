@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/imagebuilder"
+	imagebuildertypes "github.com/aws/aws-sdk-go-v2/service/imagebuilder/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type ImagePipelineResult struct {
+	Name   string `json:"name"`
+	Arn    string `json:"arn"`
+	Status string `json:"status"`
+	// i.e. cron(0 0 * * ? *), empty when the pipeline is only run by hand
+	Schedule       string `json:"schedule,omitempty"`
+	ImageRecipe    string `json:"imageRecipe,omitempty"`
+	Infrastructure string `json:"infrastructure,omitempty"`
+	Distribution   string `json:"distribution,omitempty"`
+	// The role workflows run as, which defaults to the service-linked role
+	ExecutionRole string `json:"executionRole,omitempty"`
+}
+
+type ImageComponentResult struct {
+	Name    string `json:"name"`
+	Arn     string `json:"arn"`
+	Version string `json:"version"`
+	// BUILD or TEST
+	Type         string          `json:"type"`
+	Platform     string          `json:"platform"`
+	SavedTo      string          `json:"savedTo,omitempty"`
+	SecretsFound []SecretFinding `json:"secretsFound"`
+}
+
+// Where a distribution configuration sends the AMIs a pipeline builds
+type AmiDistributionResult struct {
+	Name   string `json:"name"`
+	Arn    string `json:"arn"`
+	Region string `json:"region"`
+	// Accounts the AMI is copied into, each of which then owns its own copy
+	TargetAccounts []string `json:"targetAccounts"`
+	// Accounts, organizations and OUs allowed to launch the AMI, and whether anyone can
+	LaunchAccounts      []string `json:"launchAccounts"`
+	LaunchOrganizations []string `json:"launchOrganizations"`
+	Public              bool     `json:"public"`
+}
+
+func RunImageBuilderModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		imagebuilderClient := imagebuilder.NewFromConfig(regionalConfig)
+
+		// i.e. aws imagebuilder list-image-pipelines
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Image Builder pipelines, components and distribution configurations in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		pipelines, err := ListImagePipelines(ctx, imagebuilderClient)
+		if err != nil {
+			return err
+		}
+		distributionNames := map[string]string{}
+		for _, pipeline := range pipelines {
+			result := ImagePipelineResult{
+				Name:           aws.ToString(pipeline.Name),
+				Arn:            aws.ToString(pipeline.Arn),
+				Status:         string(pipeline.Status),
+				ImageRecipe:    aws.ToString(pipeline.ImageRecipeArn),
+				Infrastructure: aws.ToString(pipeline.InfrastructureConfigurationArn),
+				Distribution:   aws.ToString(pipeline.DistributionConfigurationArn),
+				ExecutionRole:  aws.ToString(pipeline.ExecutionRole),
+			}
+			if result.ImageRecipe == "" {
+				result.ImageRecipe = aws.ToString(pipeline.ContainerRecipeArn)
+			}
+			if pipeline.Schedule != nil {
+				result.Schedule = aws.ToString(pipeline.Schedule.ScheduleExpression)
+			}
+			if result.Distribution != "" {
+				distributionNames[result.Distribution] = result.Name
+			}
+
+			fmt.Printf("\tPipeline: %v (%v)\n", result.Name, result.Status)
+			if result.Schedule != "" {
+				fmt.Printf("\tSchedule: %v\n", result.Schedule)
+			}
+			fmt.Printf("\tRecipe: %v\n", result.ImageRecipe)
+			fmt.Printf("\tInfrastructure: %v\n", result.Infrastructure)
+			if result.Distribution != "" {
+				fmt.Printf("\tDistribution: %v\n", result.Distribution)
+			}
+			if result.ExecutionRole != "" {
+				fmt.Printf("\tExecution role: %v\n", result.ExecutionRole)
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("image-pipeline", regionalConfig.Region, result)
+		}
+		if len(pipelines) == 0 {
+			fmt.Println("\tNo image pipelines in this region")
+			fmt.Println(MINOR_SEPARATOR)
+		}
+
+		// Component documents are the build scripts baked into every image, so tokens and passwords
+		// for pulling software tend to be written straight into them. Only the account's own are
+		// listed, Amazon's are the same for everyone
+		// i.e. aws imagebuilder list-components --owner Self
+		components, err := ListImageComponents(ctx, imagebuilderClient)
+		if err == nil {
+			for _, component := range components {
+				result := GetImageComponentResult(ctx, imagebuilderClient, component)
+				fmt.Printf("\tComponent: %v %v (%v, %v)\n", result.Name, result.Version, result.Type, result.Platform)
+				if result.SavedTo != "" {
+					fmt.Printf("\tDocument saved to: %v\n", result.SavedTo)
+				}
+				PrintSecretFindings(result.SecretsFound)
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("image-component", regionalConfig.Region, result)
+			}
+			if len(components) == 0 {
+				fmt.Println("\tNo components owned by this account")
+				fmt.Println(MINOR_SEPARATOR)
+			}
+		}
+
+		// i.e. aws imagebuilder list-distribution-configurations
+		distributions, err := ListDistributionConfigurations(ctx, imagebuilderClient)
+		if err != nil {
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+		for _, summary := range distributions {
+			// i.e. aws imagebuilder get-distribution-configuration --distribution-configuration-arn <arn>
+			output, err := imagebuilderClient.GetDistributionConfiguration(ctx, &imagebuilder.GetDistributionConfigurationInput{DistributionConfigurationArn: summary.Arn})
+			if err != nil {
+				fmt.Printf("Couldn't get the distribution configuration %v. Here's why: %v\n", aws.ToString(summary.Name), err)
+				continue
+			}
+			fmt.Printf("\tDistribution configuration: %v\n", aws.ToString(summary.Name))
+			if pipeline, ok := distributionNames[aws.ToString(summary.Arn)]; ok {
+				fmt.Printf("\tUsed by: %v\n", pipeline)
+			}
+			if output.DistributionConfiguration == nil {
+				fmt.Println(MINOR_SEPARATOR)
+				continue
+			}
+			for _, distribution := range output.DistributionConfiguration.Distributions {
+				result := NewAmiDistributionResult(aws.ToString(summary.Name), aws.ToString(summary.Arn), distribution, account)
+				fmt.Printf("\tRegion: %v\n", result.Region)
+				for _, targetAccount := range result.TargetAccounts {
+					fmt.Printf("\t[-] AMI copied into account %v\n", targetAccount)
+					EmitExposedFinding(result.Region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Image Builder copies the AMI into account %v", targetAccount))
+				}
+				for _, launchAccount := range result.LaunchAccounts {
+					fmt.Printf("\t[-] AMI launchable by account %v\n", launchAccount)
+					EmitExposedFinding(result.Region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Image Builder shares the AMI with account %v", launchAccount))
+				}
+				for _, organization := range result.LaunchOrganizations {
+					fmt.Printf("\tAMI launchable by %v\n", organization)
+				}
+				if result.Public {
+					fmt.Println("\t[!] AMI is launchable by anyone")
+					EmitExposedFinding(result.Region, result.Arn, EXPOSURE_INTERNET, "Image Builder makes the AMI public")
+				}
+				Emit("ami-distribution", regionalConfig.Region, result)
+			}
+			fmt.Println(MINOR_SEPARATOR)
+		}
+		if len(distributions) == 0 {
+			fmt.Println("\tNo distribution configurations in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetImageComponentResult(ctx context.Context, imagebuilderClient *imagebuilder.Client, component imagebuildertypes.ComponentVersion) ImageComponentResult {
+	result := ImageComponentResult{
+		Name:     aws.ToString(component.Name),
+		Arn:      aws.ToString(component.Arn),
+		Version:  aws.ToString(component.Version),
+		Type:     string(component.Type),
+		Platform: string(component.Platform),
+	}
+
+	// The document is kept per build, and the latest build is the one recipes pick up
+	// i.e. aws imagebuilder list-component-build-versions --component-version-arn <arn>
+	var buildArn string
+	paginator := imagebuilder.NewListComponentBuildVersionsPaginator(imagebuilderClient, &imagebuilder.ListComponentBuildVersionsInput{ComponentVersionArn: component.Arn})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the builds of %v. Here's why: %v\n", result.Name, err)
+			return result
+		}
+		for _, build := range page.ComponentSummaryList {
+			buildArn = aws.ToString(build.Arn)
+		}
+	}
+	if buildArn == "" {
+		return result
+	}
+
+	// i.e. aws imagebuilder get-component --component-build-version-arn <arn>
+	output, err := imagebuilderClient.GetComponent(ctx, &imagebuilder.GetComponentInput{ComponentBuildVersionArn: aws.String(buildArn)})
+	if err != nil {
+		fmt.Printf("Couldn't get the document for %v. Here's why: %v\n", result.Name, err)
+		return result
+	}
+	if output.Component == nil || output.Component.Data == nil {
+		return result
+	}
+	document := []byte(*output.Component.Data)
+
+	// The document comes back in the response, so it's always scanned and only saved with --download-code
+	source := buildArn
+	if DownloadCode {
+		lootPath, err := SaveLoot(filepath.Join("imagebuilder", "components", fmt.Sprintf("%v-%v.yaml", result.Name, result.Version)), document)
+		if err == nil {
+			result.SavedTo = lootPath
+			source = lootPath
+		}
+	}
+	result.SecretsFound = ScanForSecrets(source, document)
+
+	return result
+}
+
+func NewAmiDistributionResult(name string, arn string, distribution imagebuildertypes.Distribution, account string) AmiDistributionResult {
+	result := AmiDistributionResult{Name: name, Arn: arn, Region: aws.ToString(distribution.Region)}
+	amiDistribution := distribution.AmiDistributionConfiguration
+	if amiDistribution == nil {
+		return result
+	}
+	for _, targetAccount := range amiDistribution.TargetAccountIds {
+		if targetAccount != account {
+			result.TargetAccounts = append(result.TargetAccounts, targetAccount)
+		}
+	}
+	if launchPermission := amiDistribution.LaunchPermission; launchPermission != nil {
+		for _, launchAccount := range launchPermission.UserIds {
+			if launchAccount != account {
+				result.LaunchAccounts = append(result.LaunchAccounts, launchAccount)
+			}
+		}
+		result.LaunchOrganizations = append(result.LaunchOrganizations, launchPermission.OrganizationArns...)
+		result.LaunchOrganizations = append(result.LaunchOrganizations, launchPermission.OrganizationalUnitArns...)
+		// The "all" group is how EC2 marks an image public
+		result.Public = slices.Contains(launchPermission.UserGroups, "all")
+	}
+
+	return result
+}
+
+func ListImagePipelines(ctx context.Context, imagebuilderClient *imagebuilder.Client) ([]imagebuildertypes.ImagePipeline, error) {
+	var pipelines []imagebuildertypes.ImagePipeline
+	paginator := imagebuilder.NewListImagePipelinesPaginator(imagebuilderClient, &imagebuilder.ListImagePipelinesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(pipelines)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the image pipelines. Here's why: %v\n", err)
+			return nil, err
+		}
+		pipelines = append(pipelines, page.ImagePipelineList...)
+	}
+
+	return LimitItems(pipelines), nil
+}
+
+func ListImageComponents(ctx context.Context, imagebuilderClient *imagebuilder.Client) ([]imagebuildertypes.ComponentVersion, error) {
+	var components []imagebuildertypes.ComponentVersion
+	paginator := imagebuilder.NewListComponentsPaginator(imagebuilderClient, &imagebuilder.ListComponentsInput{Owner: imagebuildertypes.OwnershipSelf})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(components)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the image components. Here's why: %v\n", err)
+			return nil, err
+		}
+		components = append(components, page.ComponentVersionList...)
+	}
+
+	return LimitItems(components), nil
+}
+
+func ListDistributionConfigurations(ctx context.Context, imagebuilderClient *imagebuilder.Client) ([]imagebuildertypes.DistributionConfigurationSummary, error) {
+	var distributions []imagebuildertypes.DistributionConfigurationSummary
+	paginator := imagebuilder.NewListDistributionConfigurationsPaginator(imagebuilderClient, &imagebuilder.ListDistributionConfigurationsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(distributions)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the distribution configurations. Here's why: %v\n", err)
+			return nil, err
+		}
+		distributions = append(distributions, page.DistributionConfigurationSummaryList...)
+	}
+
+	return LimitItems(distributions), nil
+}
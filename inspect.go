@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsmanagertypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Resources inspect knows how to look at, keyed by service and the resource type at the start of
+// the ARN's resource part, i.e. iam and role for arn:aws:iam::123456789012:role/admin
+type Inspector struct {
+	Service      string
+	ResourceType string
+	Module       string
+	Run          func(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error
+}
+
+var INSPECTORS = []Inspector{
+	{"iam", "user", "users", InspectUser},
+	{"iam", "role", "roles", InspectRole},
+	{"iam", "policy", "who-has", InspectPolicy},
+	{"s3", "", "s3", InspectBucket},
+	{"ec2", "instance", "ec2", InspectInstance},
+	{"lambda", "function", "lambda", InspectFunction},
+	{"secretsmanager", "secret", "secrets", InspectSecret},
+	{"ssm", "parameter", "parameters", InspectParameter},
+	{"kms", "key", "kms", InspectKey},
+	{"sqs", "", "sqs", InspectQueue},
+	{"sns", "", "sns", InspectTopic},
+}
+
+func RunInspect(ctx context.Context, resourceArn string) error {
+	parsedArn, err := arn.Parse(resourceArn)
+	if err != nil {
+		return fmt.Errorf("%v isn't an ARN: %w", resourceArn, err)
+	}
+	inspector, ok := FindInspector(parsedArn)
+	if !ok {
+		var supported []string
+		for _, inspector := range INSPECTORS {
+			supported = append(supported, strings.TrimSuffix(inspector.Service+" "+inspector.ResourceType, " "))
+		}
+		return fmt.Errorf("can't inspect %v, supported resources are %v", resourceArn, strings.Join(supported, ", "))
+	}
+
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+	// Regional resources are looked up where the ARN says they are, whatever the configured region
+	if parsedArn.Region != "" {
+		sdkConfig.Region = parsedArn.Region
+	}
+
+	CurrentModule = "inspect"
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Inspecting %v with the %v module's checks...\n", resourceArn, inspector.Module)
+	fmt.Println(MAJOR_SEPARATOR)
+	if err := inspector.Run(ctx, sdkConfig, parsedArn); err != nil {
+		return err
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return WriteResults()
+}
+
+func FindInspector(parsedArn arn.ARN) (Inspector, bool) {
+	// The resource part is either type/name or type:name, S3 bucket ARNs are just the name
+	resourceType := parsedArn.Resource
+	if index := strings.IndexAny(resourceType, "/:"); index >= 0 {
+		resourceType = resourceType[:index]
+	}
+	for _, inspector := range INSPECTORS {
+		if inspector.Service != parsedArn.Service {
+			continue
+		}
+		if inspector.ResourceType == "" || inspector.ResourceType == resourceType {
+			return inspector, true
+		}
+	}
+
+	return Inspector{}, false
+}
+
+// The name at the end of an ARN's resource, after any type and path, i.e. admin for role/ops/admin
+func ArnResourceName(parsedArn arn.ARN) string {
+	return parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+}
+
+func InspectUser(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws iam get-user --user-name <username>
+	output, err := iamClient.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(ArnResourceName(resourceArn))})
+	if err != nil {
+		fmt.Printf("Couldn't get the user %v. Here's why: %v\n", resourceArn, err)
+		return err
+	}
+	result, mfaErr := GetUserResult(ctx, iamClient, *output.User)
+	if output.User.PasswordLastUsed != nil {
+		fmt.Printf("\tPassword last used: %v\n", *output.User.PasswordLastUsed)
+	}
+	PrintUserResult(result, mfaErr)
+
+	// Policies the user only gets through its groups, which the users module leaves to the groups one
+	// i.e. aws iam list-attached-group-policies --group-name <group-name>
+	for _, group := range result.Groups {
+		policies, err := iamClient.ListAttachedGroupPolicies(ctx, &iam.ListAttachedGroupPoliciesInput{GroupName: aws.String(group)})
+		if err != nil {
+			fmt.Printf("Couldn't get the attached policies for %v. Here's why: %v\n", group, err)
+			continue
+		}
+		for _, policy := range policies.AttachedPolicies {
+			fmt.Printf("\tThrough group %v: %v\n", group, aws.ToString(policy.PolicyArn))
+		}
+	}
+
+	return nil
+}
+
+func InspectRole(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	roleName := ArnResourceName(resourceArn)
+
+	// get-role returns the trust policy and when the role was last used, which list-roles leaves out
+	// i.e. aws iam get-role --role-name <role-name>
+	output, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		fmt.Printf("Couldn't get the role %v. Here's why: %v\n", resourceArn, err)
+		return err
+	}
+	role := *output.Role
+	if role.Description != nil {
+		fmt.Printf("\tDescription: %v\n", *role.Description)
+	}
+	fmt.Printf("\tMax session duration: %v seconds\n", aws.ToInt32(role.MaxSessionDuration))
+	if lastUsed := role.RoleLastUsed; lastUsed != nil && lastUsed.LastUsedDate != nil {
+		fmt.Printf("\tLast used: %v in %v\n", *lastUsed.LastUsedDate, aws.ToString(lastUsed.Region))
+	} else {
+		fmt.Println("\tLast used: never, or not in the last 400 days")
+	}
+
+	// i.e. aws iam list-attached-role-policies, aws iam list-role-policies, aws iam get-policy-version for the boundary
+	permissions, _ := GetRolePermissions(ctx, iamClient, role)
+	boundaryArn, boundary, _ := GetPermissionsBoundary(ctx, iamClient, *role.Arn)
+	PrintRoleResult(role, permissions, boundaryArn, boundary)
+
+	// Instance profiles hand the role to EC2, so whoever runs code on those instances has it too
+	// i.e. aws iam list-instance-profiles-for-role --role-name <role-name>
+	profiles, err := iamClient.ListInstanceProfilesForRole(ctx, &iam.ListInstanceProfilesForRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		fmt.Printf("Couldn't list the instance profiles for %v. Here's why: %v\n", roleName, err)
+		return nil
+	}
+	for _, profile := range profiles.InstanceProfiles {
+		fmt.Printf("\tInstance profile: %v\n", aws.ToString(profile.Arn))
+	}
+	if len(profiles.InstanceProfiles) == 0 {
+		fmt.Println("\tInstance profile: none")
+	}
+
+	return nil
+}
+
+func InspectPolicy(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	policyArn := resourceArn.String()
+
+	// i.e. aws iam get-policy --policy-arn <policy-arn>
+	output, err := iamClient.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(policyArn)})
+	if err != nil {
+		fmt.Printf("Couldn't get the policy %v. Here's why: %v\n", policyArn, err)
+		return err
+	}
+	policy := output.Policy
+	fmt.Printf("\tPolicy name: %v\n", aws.ToString(policy.PolicyName))
+	if policy.Description != nil {
+		fmt.Printf("\tDescription: %v\n", *policy.Description)
+	}
+	fmt.Printf("\tDefault version: %v, updated %v\n", aws.ToString(policy.DefaultVersionId), aws.ToTime(policy.UpdateDate))
+
+	// i.e. aws iam get-policy-version --policy-arn <policy-arn> --version-id <version-id>
+	document, err := GetManagedPolicyDocument(ctx, iamClient, policyArn)
+	if err == nil {
+		fmt.Printf("\tPolicy:\n%v\n", FormatPolicyDocument(document))
+		for _, action := range NotablePermissions([]*PolicyDocument{document}) {
+			if action == "*" {
+				fmt.Println("\t[!] Grants full administrative access")
+				continue
+			}
+			fmt.Printf("\t[!] Grants %v\n", action)
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+
+	// i.e. aws iam list-entities-for-policy --policy-arn <policy-arn> --policy-usage-filter PermissionsPolicy
+	entities, err := GetPolicyEntities(ctx, iamClient, policyArn)
+	if err != nil {
+		return nil
+	}
+	PrintPolicyEntities(entities)
+	Emit("policy-entities", "", entities)
+
+	return nil
+}
+
+func InspectBucket(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	// An object ARN inspects the bucket it's in
+	bucket, _, _ := strings.Cut(resourceArn.Resource, "/")
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	// Bucket ARNs have no region, and the bucket's settings have to be read from its own
+	// i.e. aws s3api get-bucket-location --bucket <bucket>
+	location, err := s3.NewFromConfig(sdkConfig).GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		fmt.Printf("Couldn't get the region of %v. Here's why: %v\n", bucket, err)
+		return err
+	}
+	region := string(location.LocationConstraint)
+	switch region {
+	case "":
+		region = "us-east-1"
+	case "EU":
+		region = "eu-west-1"
+	}
+	sdkConfig.Region = region
+	fmt.Printf("\tRegion: %v\n", region)
+
+	// i.e. aws s3control get-public-access-block --account-id <account-id>
+	accountBlock, _ := GetAccountPublicAccessBlock(ctx, s3control.NewFromConfig(sdkConfig), account)
+	result, err := GetBucketResult(ctx, s3.NewFromConfig(sdkConfig), bucket, account, accountBlock)
+	if err != nil {
+		return err
+	}
+	PrintBucketResult(region, result)
+
+	return nil
+}
+
+func InspectInstance(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	ec2Client := ec2.NewFromConfig(sdkConfig)
+	instanceId := ArnResourceName(resourceArn)
+
+	// i.e. aws ec2 describe-instances --instance-ids <instance-id>
+	output, err := ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}})
+	if err != nil {
+		fmt.Printf("Couldn't get the instance %v. Here's why: %v\n", instanceId, err)
+		return err
+	}
+	if len(output.Reservations) == 0 || len(output.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("no instance %v in %v", instanceId, sdkConfig.Region)
+	}
+	instance := output.Reservations[0].Instances[0]
+	result := NewInstanceResult(instance)
+
+	// Only the instance's own security groups are described, for the ports they open
+	// i.e. aws ec2 describe-security-groups --group-ids <group-id>...
+	var groups []*SecurityGroupResult
+	if len(result.SecurityGroups) > 0 {
+		securityGroups, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: result.SecurityGroups})
+		if err != nil {
+			fmt.Printf("Couldn't get the security groups for %v. Here's why: %v\n", instanceId, err)
+		} else {
+			for _, securityGroup := range securityGroups.SecurityGroups {
+				group := NewSecurityGroupResult(securityGroup)
+				group.Instances = []string{instanceId}
+				for _, port := range group.OpenPorts {
+					if !slices.Contains(result.OpenPorts, port) {
+						result.OpenPorts = append(result.OpenPorts, port)
+					}
+				}
+				groups = append(groups, group)
+			}
+		}
+	}
+	PrintInstanceResult(sdkConfig.Region, instance, result)
+	for _, group := range groups {
+		PrintSecurityGroupResult(sdkConfig.Region, group)
+	}
+
+	// i.e. aws ec2 describe-instance-attribute --instance-id <instance-id> --attribute userData
+	if userData := GetInstanceUserData(ctx, ec2Client, instance); userData != nil {
+		PrintUserDataResult(sdkConfig.Region, *userData)
+	}
+
+	// i.e. aws iam get-instance-profile --instance-profile-name <name>
+	if result.InstanceProfileArn == "" {
+		return nil
+	}
+	iamClient := iam.NewFromConfig(sdkConfig)
+	profile, err := GetInstanceProfileByArn(ctx, iamClient, map[string]*iamtypes.InstanceProfile{}, result.InstanceProfileArn)
+	if err != nil {
+		return nil
+	}
+	for _, role := range profile.Roles {
+		InspectRelatedRole(ctx, iamClient, sdkConfig.Region, instanceId, role)
+	}
+
+	return nil
+}
+
+func InspectFunction(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	lambdaClient := lambda.NewFromConfig(sdkConfig)
+
+	// The ARN can carry a version or alias, the function's configuration is the same for all of them
+	// i.e. aws lambda get-function-configuration --function-name <function-arn>
+	functionName := strings.Split(resourceArn.Resource, ":")[1]
+	function, err := lambdaClient.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		fmt.Printf("Couldn't get the function %v. Here's why: %v\n", functionName, err)
+		return err
+	}
+	result := GetFunctionResult(ctx, lambdaClient, sdkConfig.Region, FunctionConfigurationFromOutput(function), resourceArn.AccountID)
+	PrintFunctionResult(sdkConfig.Region, result)
+
+	// The execution role is what anyone who can change the function's code gets
+	// i.e. aws iam get-role --role-name <role-name>
+	roleArn, err := arn.Parse(result.Role)
+	if err != nil {
+		return nil
+	}
+	iamClient := iam.NewFromConfig(sdkConfig)
+	role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(ArnResourceName(roleArn))})
+	if err != nil {
+		fmt.Printf("Couldn't get the execution role %v. Here's why: %v\n", result.Role, err)
+		return nil
+	}
+	InspectRelatedRole(ctx, iamClient, sdkConfig.Region, result.Arn, *role.Role)
+
+	return nil
+}
+
+func FunctionConfigurationFromOutput(output *lambda.GetFunctionConfigurationOutput) lambdatypes.FunctionConfiguration {
+	return lambdatypes.FunctionConfiguration{
+		FunctionName: output.FunctionName,
+		FunctionArn:  output.FunctionArn,
+		Runtime:      output.Runtime,
+		PackageType:  output.PackageType,
+		Handler:      output.Handler,
+		Role:         output.Role,
+		LastModified: output.LastModified,
+		KMSKeyArn:    output.KMSKeyArn,
+		Environment:  output.Environment,
+	}
+}
+
+func InspectSecret(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	secretsClient := secretsmanager.NewFromConfig(sdkConfig)
+
+	// i.e. aws secretsmanager describe-secret --secret-id <secret-arn>
+	secret, err := secretsClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(resourceArn.String())})
+	if err != nil {
+		fmt.Printf("Couldn't get the secret %v. Here's why: %v\n", resourceArn, err)
+		return err
+	}
+	result := GetSecretResult(ctx, secretsClient, sdkConfig.Region, secretsmanagertypes.SecretListEntry{
+		ARN:               secret.ARN,
+		Name:              secret.Name,
+		Description:       secret.Description,
+		KmsKeyId:          secret.KmsKeyId,
+		OwningService:     secret.OwningService,
+		PrimaryRegion:     secret.PrimaryRegion,
+		LastChangedDate:   secret.LastChangedDate,
+		LastAccessedDate:  secret.LastAccessedDate,
+		RotationEnabled:   secret.RotationEnabled,
+		RotationLambdaARN: secret.RotationLambdaARN,
+		RotationRules:     secret.RotationRules,
+		LastRotatedDate:   secret.LastRotatedDate,
+	}, resourceArn.AccountID)
+	for _, replica := range secret.ReplicationStatus {
+		fmt.Printf("\tReplicated to: %v (%v)\n", aws.ToString(replica.Region), replica.Status)
+	}
+	PrintSecretResult(sdkConfig.Region, result)
+
+	return nil
+}
+
+func InspectParameter(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	ssmClient := ssm.NewFromConfig(sdkConfig)
+
+	// The ARN drops the leading slash of path names, i.e. parameter/prod/db for /prod/db, so both are tried
+	// i.e. aws ssm describe-parameters --parameter-filters Key=Name,Values=<name>
+	name := strings.TrimPrefix(resourceArn.Resource, "parameter")
+	output, err := ssmClient.DescribeParameters(ctx, &ssm.DescribeParametersInput{
+		ParameterFilters: []ssmtypes.ParameterStringFilter{{
+			Key:    aws.String("Name"),
+			Option: aws.String("Equals"),
+			Values: []string{name, strings.TrimPrefix(name, "/")},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the parameter %v. Here's why: %v\n", name, err)
+		return err
+	}
+	if len(output.Parameters) == 0 {
+		return fmt.Errorf("no parameter %v in %v", name, sdkConfig.Region)
+	}
+	PrintParameterResult(sdkConfig.Region, GetParameterResult(ctx, ssmClient, sdkConfig.Region, output.Parameters[0]))
+
+	return nil
+}
+
+func InspectKey(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	kmsClient := kms.NewFromConfig(sdkConfig)
+	keyId := ArnResourceName(resourceArn)
+
+	// i.e. aws kms describe-key, aws kms get-key-policy, aws kms list-grants, aws kms list-aliases --key-id <key-id>
+	result := GetKeyResult(ctx, kmsClient, keyId, resourceArn.AccountID)
+	if result == nil {
+		return fmt.Errorf("couldn't describe the key %v", keyId)
+	}
+	aliases, err := kmsClient.ListAliases(ctx, &kms.ListAliasesInput{KeyId: aws.String(keyId)})
+	if err == nil {
+		for _, alias := range aliases.Aliases {
+			result.Aliases = append(result.Aliases, aws.ToString(alias.AliasName))
+		}
+	}
+	PrintKeyResult(sdkConfig.Region, *result)
+
+	return nil
+}
+
+func InspectQueue(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	sqsClient := sqs.NewFromConfig(sdkConfig)
+
+	// Queues are addressed by URL, which the name and owning account resolve to
+	// i.e. aws sqs get-queue-url --queue-name <queue-name> --queue-owner-aws-account-id <account-id>
+	output, err := sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName:              aws.String(resourceArn.Resource),
+		QueueOwnerAWSAccountId: aws.String(resourceArn.AccountID),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the URL of %v. Here's why: %v\n", resourceArn, err)
+		return err
+	}
+	result := GetQueueResult(ctx, sqsClient, aws.ToString(output.QueueUrl), resourceArn.AccountID)
+	if result == nil {
+		return fmt.Errorf("couldn't get the attributes of %v", resourceArn)
+	}
+	PrintQueueResult(sdkConfig.Region, *result)
+
+	return nil
+}
+
+func InspectTopic(ctx context.Context, sdkConfig aws.Config, resourceArn arn.ARN) error {
+	// i.e. aws sns get-topic-attributes, aws sns list-subscriptions-by-topic --topic-arn <topic-arn>
+	result := GetTopicResult(ctx, sns.NewFromConfig(sdkConfig), resourceArn.String(), resourceArn.AccountID)
+	if result == nil {
+		return fmt.Errorf("couldn't get the attributes of %v", resourceArn)
+	}
+	PrintTopicResult(sdkConfig.Region, *result)
+
+	return nil
+}
+
+func InspectRelatedRole(ctx context.Context, iamClient *iam.Client, region string, resource string, role iamtypes.Role) {
+	// What a role the resource runs as can do, flagged against the resource like the instance-roles module does
+	// i.e. aws iam list-attached-role-policies, aws iam list-role-policies
+	fmt.Printf("\tRuns as role: %v\n", aws.ToString(role.Arn))
+	permissions, err := GetRolePermissions(ctx, iamClient, role)
+	if err != nil {
+		fmt.Println(MINOR_SEPARATOR)
+		return
+	}
+	var policyNames []string
+	for policyName := range permissions.Policies {
+		policyNames = append(policyNames, policyName)
+	}
+	sort.Strings(policyNames)
+	for _, policyName := range policyNames {
+		fmt.Printf("\t\tPolicy: %v\n", policyName)
+	}
+	for _, action := range permissions.Notable {
+		if action == "*" {
+			fmt.Println("\t\t[!] Role has full administrative access")
+			EmitFinding(region, resource, fmt.Sprintf("Role %v has full administrative access", permissions.RoleName))
+			continue
+		}
+		fmt.Printf("\t\t[!] Grants %v\n", action)
+		EmitFinding(region, resource, fmt.Sprintf("Role %v grants %v", permissions.RoleName, action))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("role-permissions", region, permissions)
+}
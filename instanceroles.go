@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+type RolePermissions struct {
+	RoleName string `json:"roleName"`
+	RoleArn  string `json:"roleArn"`
+	// Policy name mapped to its document, attached managed policies are keyed by ARN
+	Policies map[string]*PolicyDocument `json:"policies"`
+	Notable  []string                   `json:"notable"`
+	// Notable actions the account's SCPs take away, only known in org-scan
+	BlockedBySCP []string `json:"blockedBySCP,omitempty"`
+}
+
+type InstanceRoleResult struct {
+	InstanceId         string             `json:"instanceId"`
+	Name               string             `json:"name,omitempty"`
+	State              string             `json:"state"`
+	InstanceProfileArn string             `json:"instanceProfileArn,omitempty"`
+	Roles              []*RolePermissions `json:"roles"`
+}
+
+// An instance profile, the role it hands to EC2 and the instances carrying it
+type InstanceProfileResult struct {
+	Arn   string   `json:"arn"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	// Instance IDs with their region, i.e. i-0abc123 (us-east-1)
+	Instances []string `json:"instances"`
+}
+
+func RunInstanceRolesModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	profileCache := map[string]*iamtypes.InstanceProfile{}
+	roleCache := map[string]*RolePermissions{}
+
+	// Every profile comes back with its role, so the lookups for each instance are already done
+	// i.e. aws iam list-instance-profiles
+	profiles, err := CachedInstanceProfiles(ctx, iamClient)
+	if err == nil {
+		for i := range profiles {
+			profileCache[*profiles[i].Arn] = &profiles[i]
+		}
+	}
+	profileInstances := map[string][]string{}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Call the describe-instances API and follow each instance's profile through to its permissions
+		// i.e. aws ec2 describe-instances
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Mapping instance profiles to roles for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, instance := range instances {
+			result := InstanceRoleResult{
+				InstanceId: *instance.InstanceId,
+				Name:       InstanceName(instance.Tags),
+				State:      string(instance.State.Name),
+			}
+			fmt.Printf("\tInstance ID: %v (%v)\n", *instance.InstanceId, instance.State.Name)
+			if result.Name != "" {
+				fmt.Printf("\tName: %v\n", result.Name)
+			}
+			if instance.IamInstanceProfile == nil {
+				fmt.Println("\tInstance profile: none")
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("instance-role-mapping", regionalConfig.Region, result)
+				continue
+			}
+
+			profileArn := *instance.IamInstanceProfile.Arn
+			result.InstanceProfileArn = profileArn
+			profileInstances[profileArn] = append(profileInstances[profileArn], fmt.Sprintf("%v (%v)", *instance.InstanceId, regionalConfig.Region))
+			fmt.Printf("\tInstance profile: %v\n", profileArn)
+			profile, err := GetInstanceProfileByArn(ctx, iamClient, profileCache, profileArn)
+			if err != nil {
+				fmt.Println(MINOR_SEPARATOR)
+				continue
+			}
+
+			for _, role := range profile.Roles {
+				permissions, ok := roleCache[*role.RoleName]
+				if !ok {
+					permissions, err = GetRolePermissions(ctx, iamClient, role)
+					if err != nil {
+						continue
+					}
+					roleCache[*role.RoleName] = permissions
+				}
+
+				fmt.Printf("\t\tRole: %v\n", permissions.RoleArn)
+				result.Roles = append(result.Roles, permissions)
+				var policyNames []string
+				for policyName := range permissions.Policies {
+					policyNames = append(policyNames, policyName)
+				}
+				sort.Strings(policyNames)
+				for _, policyName := range policyNames {
+					fmt.Printf("\t\tPolicy: %v\n", policyName)
+				}
+				for _, action := range permissions.Notable {
+					if action == "*" {
+						fmt.Println("\t\t[!] Role has full administrative access, owning this instance means owning the account")
+						EmitFinding(regionalConfig.Region, *instance.InstanceId, fmt.Sprintf("Role %v has full administrative access", permissions.RoleName))
+						continue
+					}
+					fmt.Printf("\t\t[!] Grants %v\n", action)
+					EmitFinding(regionalConfig.Region, *instance.InstanceId, fmt.Sprintf("Role %v grants %v", permissions.RoleName, action))
+				}
+				for _, action := range permissions.BlockedBySCP {
+					fmt.Printf("\t\t[-] Blocked by SCP: %v\n", action)
+				}
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("instance-role-mapping", regionalConfig.Region, result)
+		}
+
+		return nil
+	})
+
+	// Profiles no instance carries still matter, anyone allowed iam:PassRole on the role and
+	// ec2:RunInstances or ec2:AssociateIamInstanceProfile can put one on an instance of their own
+	if profiles == nil {
+		return nil
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Mapping every instance profile to its role and instances...")
+	fmt.Println(MAJOR_SEPARATOR)
+	for _, profile := range profiles {
+		result := InstanceProfileResult{
+			Arn:       *profile.Arn,
+			Name:      *profile.InstanceProfileName,
+			Instances: profileInstances[*profile.Arn],
+		}
+		fmt.Printf("\tInstance profile: %v\n", result.Name)
+		for _, role := range profile.Roles {
+			result.Roles = append(result.Roles, *role.Arn)
+			fmt.Printf("\tRole: %v\n", *role.Arn)
+			permissions, ok := roleCache[*role.RoleName]
+			if !ok {
+				permissions, err = GetRolePermissions(ctx, iamClient, role)
+				if err != nil {
+					continue
+				}
+				roleCache[*role.RoleName] = permissions
+			}
+			for _, action := range permissions.Notable {
+				if action == "*" {
+					fmt.Println("\t\t[!] Role has full administrative access")
+					continue
+				}
+				fmt.Printf("\t\t[!] Grants %v\n", action)
+			}
+		}
+		if len(profile.Roles) == 0 {
+			fmt.Println("\tRole: none")
+		}
+		for _, instance := range result.Instances {
+			fmt.Printf("\tInstance: %v\n", instance)
+		}
+		if len(result.Instances) == 0 {
+			fmt.Println("\t[-] Not on any instance in the enumerated regions")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("instance-profile", "", result)
+	}
+
+	return nil
+}
+
+func ListInstanceProfiles(ctx context.Context, iamClient *iam.Client) ([]iamtypes.InstanceProfile, error) {
+	var profiles []iamtypes.InstanceProfile
+	paginator := iam.NewListInstanceProfilesPaginator(iamClient, &iam.ListInstanceProfilesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(profiles)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the instance profiles. Here's why: %v\n", err)
+			return nil, err
+		}
+		profiles = append(profiles, page.InstanceProfiles...)
+	}
+
+	return LimitItems(profiles), nil
+}
+
+func InstanceName(tags []ec2types.Tag) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "Name" {
+			return aws.ToString(tag.Value)
+		}
+	}
+
+	return ""
+}
+
+func GetInstanceProfileByArn(ctx context.Context, iamClient *iam.Client, cache map[string]*iamtypes.InstanceProfile, profileArn string) (*iamtypes.InstanceProfile, error) {
+	if profile, ok := cache[profileArn]; ok {
+		return profile, nil
+	}
+
+	// The profile name is the last part of arn:aws:iam::<account>:instance-profile/<path>/<name>
+	parsedArn, err := arn.Parse(profileArn)
+	if err != nil {
+		return nil, err
+	}
+	profileName := parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+
+	// i.e. aws iam get-instance-profile --instance-profile-name <name>
+	profile, err := iamClient.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the instance profile %v. Here's why: %v\n", profileName, err)
+		return nil, err
+	}
+
+	cache[profileArn] = profile.InstanceProfile
+	return profile.InstanceProfile, nil
+}
+
+func GetRolePermissions(ctx context.Context, iamClient *iam.Client, role iamtypes.Role) (*RolePermissions, error) {
+	permissions := &RolePermissions{
+		RoleName: *role.RoleName,
+		RoleArn:  *role.Arn,
+		Policies: map[string]*PolicyDocument{},
+	}
+
+	// Get the default version of each attached managed policy
+	// i.e. aws iam list-attached-role-policies --role-name <role-name>
+	attachedPaginator := iam.NewListAttachedRolePoliciesPaginator(iamClient, &iam.ListAttachedRolePoliciesInput{
+		RoleName: role.RoleName,
+	})
+	for attachedPaginator.HasMorePages() {
+		page, err := attachedPaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the attached policies for %v. Here's why: %v\n", *role.RoleName, err)
+			return nil, err
+		}
+		for _, policy := range page.AttachedPolicies {
+			document, err := GetManagedPolicyDocument(ctx, iamClient, *policy.PolicyArn)
+			if err != nil {
+				continue
+			}
+			permissions.Policies[*policy.PolicyArn] = document
+		}
+	}
+
+	// Get each inline policy
+	// i.e. aws iam list-role-policies --role-name <role-name>
+	inlinePaginator := iam.NewListRolePoliciesPaginator(iamClient, &iam.ListRolePoliciesInput{
+		RoleName: role.RoleName,
+	})
+	for inlinePaginator.HasMorePages() {
+		page, err := inlinePaginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the inline policies for %v. Here's why: %v\n", *role.RoleName, err)
+			return nil, err
+		}
+		for _, policyName := range page.PolicyNames {
+			// i.e. aws iam get-role-policy --role-name <role-name> --policy-name <policy-name>
+			inlinePolicy, err := iamClient.GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+				RoleName:   role.RoleName,
+				PolicyName: aws.String(policyName),
+			})
+			if err != nil {
+				fmt.Printf("Couldn't get the inline policy %v for %v. Here's why: %v\n", policyName, *role.RoleName, err)
+				continue
+			}
+			document, err := ParsePolicyDocument(*inlinePolicy.PolicyDocument)
+			if err != nil {
+				continue
+			}
+			permissions.Policies[policyName] = document
+		}
+	}
+
+	var documents []*PolicyDocument
+	for _, document := range permissions.Policies {
+		documents = append(documents, document)
+	}
+	permissions.Notable, permissions.BlockedBySCP = ApplySCPs(NotablePermissions(documents))
+
+	return permissions, nil
+}
+
+func GetManagedPolicyDocument(ctx context.Context, iamClient *iam.Client, policyArn string) (*PolicyDocument, error) {
+	// Look up the default version of the policy and get its document
+	// i.e. aws iam get-policy --policy-arn <policy-arn>
+	policy, err := iamClient.GetPolicy(ctx, &iam.GetPolicyInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the policy %v. Here's why: %v\n", policyArn, err)
+		return nil, err
+	}
+
+	policyVersionDetails, err := GetPolicyVersionDetails(ctx, iamClient, policyArn, *policy.Policy.DefaultVersionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicyDocument(*policyVersionDetails.PolicyVersion.Document)
+}
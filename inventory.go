@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+func RunInventoryModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Fetch the users, groups, roles and instances other modules work from, once, into the shared store
+	// i.e. aws iam list-users, aws iam list-groups, aws iam list-roles, aws iam list-instance-profiles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Building the shared inventory...")
+	fmt.Println(MAJOR_SEPARATOR)
+	users, err := CachedUsers(ctx, iamClient)
+	if err == nil {
+		fmt.Printf("\tUsers: %v\n", len(users))
+	}
+	groups, err := CachedGroups(ctx, iamClient)
+	if err == nil {
+		fmt.Printf("\tGroups: %v\n", len(groups))
+	}
+	roles, err := CachedRoles(ctx, iamClient)
+	if err == nil {
+		fmt.Printf("\tRoles: %v\n", len(roles))
+	}
+	profiles, err := CachedInstanceProfiles(ctx, iamClient)
+	if err == nil {
+		fmt.Printf("\tInstance profiles: %v\n", len(profiles))
+	}
+
+	// i.e. aws ec2 describe-instances
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\tInstances in %v: %v\n", regionalConfig.Region, len(instances))
+
+		return nil
+	})
+
+	return nil
+}
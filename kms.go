@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Actions that let a principal use a key or hand it to someone else
+var KEY_USE_ACTIONS = []string{"kms:Decrypt", "kms:Encrypt", "kms:GenerateDataKey", "kms:ReEncryptFrom", "kms:Sign", "kms:CreateGrant", "kms:PutKeyPolicy"}
+
+type KeyResult struct {
+	KeyId       string   `json:"keyId"`
+	Arn         string   `json:"arn"`
+	Aliases     []string `json:"aliases"`
+	Description string   `json:"description,omitempty"`
+	// AWS for the keys services create for themselves, CUSTOMER for the account's own
+	Manager string `json:"manager"`
+	State   string `json:"state"`
+	Usage   string `json:"usage"`
+	Spec    string `json:"spec"`
+	// AWS_KMS, EXTERNAL for imported key material, AWS_CLOUDHSM or EXTERNAL_KEY_STORE
+	Origin      string     `json:"origin"`
+	Created     *time.Time `json:"created,omitempty"`
+	MultiRegion bool       `json:"multiRegion"`
+	// Only checked for customer managed symmetric keys, AWS rotates its own
+	RotationEnabled *bool            `json:"rotationEnabled,omitempty"`
+	Policy          *PolicyDocument  `json:"policy,omitempty"`
+	PublicUse       bool             `json:"publicUse"`
+	UseAccounts     []string         `json:"useAccounts"`
+	Grants          []KeyGrantResult `json:"grants"`
+}
+
+type KeyGrantResult struct {
+	GrantId    string     `json:"grantId"`
+	Name       string     `json:"name,omitempty"`
+	Grantee    string     `json:"grantee"`
+	Retiring   string     `json:"retiring,omitempty"`
+	Operations []string   `json:"operations"`
+	Created    *time.Time `json:"created,omitempty"`
+	// The grantee's account when it isn't the key's
+	ExternalAccount string `json:"externalAccount,omitempty"`
+}
+
+func RunKMSModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		kmsClient := kms.NewFromConfig(regionalConfig)
+
+		// Key policies are resource policies, so they're read for who outside the account they let in,
+		// the same way trust and bucket policies are
+		// i.e. aws kms list-keys, aws kms list-aliases, aws kms describe-key --key-id <key-id>,
+		// aws kms get-key-policy --key-id <key-id>, aws kms list-grants --key-id <key-id>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting KMS keys in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		keys, err := ListKeys(ctx, kmsClient)
+		if err != nil {
+			return err
+		}
+		aliases := map[string][]string{}
+		keyAliases, err := ListAliases(ctx, kmsClient)
+		if err == nil {
+			for _, alias := range keyAliases {
+				if alias.TargetKeyId != nil {
+					aliases[*alias.TargetKeyId] = append(aliases[*alias.TargetKeyId], aws.ToString(alias.AliasName))
+				}
+			}
+		}
+		results := make([]*KeyResult, len(keys))
+		ForEachConcurrently(ctx, len(keys), func(ctx context.Context, i int) {
+			results[i] = GetKeyResult(ctx, kmsClient, aws.ToString(keys[i].KeyId), accountId)
+		}, func(i int) {
+			if results[i] != nil {
+				results[i].Aliases = aliases[results[i].KeyId]
+				PrintKeyResult(regionalConfig.Region, *results[i])
+			}
+		})
+		if len(keys) == 0 {
+			fmt.Println("\tNo keys in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetKeyResult(ctx context.Context, kmsClient *kms.Client, keyId string, accountId string) *KeyResult {
+	// i.e. aws kms describe-key --key-id <key-id>
+	output, err := kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyId)})
+	if err != nil {
+		fmt.Printf("Couldn't describe the key %v. Here's why: %v\n", keyId, err)
+		return nil
+	}
+	metadata := output.KeyMetadata
+	result := &KeyResult{
+		KeyId:       keyId,
+		Arn:         aws.ToString(metadata.Arn),
+		Description: aws.ToString(metadata.Description),
+		Manager:     string(metadata.KeyManager),
+		State:       string(metadata.KeyState),
+		Usage:       string(metadata.KeyUsage),
+		Spec:        string(metadata.KeySpec),
+		Origin:      string(metadata.Origin),
+		Created:     metadata.CreationDate,
+		MultiRegion: aws.ToBool(metadata.MultiRegion),
+	}
+
+	// i.e. aws kms get-key-policy --key-id <key-id> --policy-name default
+	policy, err := kmsClient.GetKeyPolicy(ctx, &kms.GetKeyPolicyInput{KeyId: aws.String(keyId), PolicyName: aws.String("default")})
+	if err != nil {
+		fmt.Printf("Couldn't get the key policy for %v. Here's why: %v\n", keyId, err)
+	} else if document, err := ParsePolicyDocument(aws.ToString(policy.Policy)); err == nil {
+		result.Policy = document
+	}
+
+	// Keys AWS manages are rotated every year whatever the setting, and only symmetric keys can be
+	// i.e. aws kms get-key-rotation-status --key-id <key-id>
+	if metadata.KeyManager == kmstypes.KeyManagerTypeCustomer && metadata.KeySpec == kmstypes.KeySpecSymmetricDefault && metadata.KeyState == kmstypes.KeyStateEnabled {
+		rotation, err := kmsClient.GetKeyRotationStatus(ctx, &kms.GetKeyRotationStatusInput{KeyId: aws.String(keyId)})
+		if err == nil {
+			result.RotationEnabled = aws.Bool(rotation.KeyRotationEnabled)
+		}
+	}
+
+	// Grants give out the key outside the key policy, and don't show up in it
+	// i.e. aws kms list-grants --key-id <key-id>
+	grants, err := ListGrants(ctx, kmsClient, keyId)
+	if err == nil {
+		for _, grant := range grants {
+			grantResult := KeyGrantResult{
+				GrantId:  aws.ToString(grant.GrantId),
+				Name:     aws.ToString(grant.Name),
+				Grantee:  aws.ToString(grant.GranteePrincipal),
+				Retiring: aws.ToString(grant.RetiringPrincipal),
+				Created:  grant.CreationDate,
+			}
+			for _, operation := range grant.Operations {
+				grantResult.Operations = append(grantResult.Operations, string(operation))
+			}
+			result.Grants = append(result.Grants, grantResult)
+		}
+	}
+	AnalyseKeyAccess(result, accountId)
+
+	return result
+}
+
+func AnalyseKeyAccess(result *KeyResult, accountId string) {
+	if result.Policy != nil {
+		result.PublicUse, result.UseAccounts = AnalyseResourcePolicy(result.Policy, KEY_USE_ACTIONS, accountId, ConditionRestricted)
+	}
+	for i, grant := range result.Grants {
+		if grantAccount := PrincipalAccount(grant.Grantee); grantAccount != "" && grantAccount != accountId {
+			result.Grants[i].ExternalAccount = grantAccount
+		}
+	}
+}
+
+func PrintKeyResult(region string, result KeyResult) {
+	fmt.Printf("\tKey ID: %v (%v, %v)\n", result.KeyId, strings.ToLower(result.Manager), result.State)
+	for _, alias := range result.Aliases {
+		fmt.Printf("\tAlias: %v\n", alias)
+	}
+	if result.Description != "" {
+		fmt.Printf("\tDescription: %v\n", result.Description)
+	}
+	fmt.Printf("\tUsage: %v, spec %v, origin %v\n", result.Usage, result.Spec, result.Origin)
+	if result.MultiRegion {
+		fmt.Println("\tMulti-Region: yes")
+	}
+	if result.Created != nil {
+		fmt.Printf("\tCreated: %v\n", result.Created.UTC().Format(time.RFC3339))
+	}
+	if result.RotationEnabled != nil && !*result.RotationEnabled {
+		fmt.Println("\t[-] Automatic key rotation is off")
+	}
+
+	// AWS managed key policies are the same everywhere, only the account's own are worth reading
+	if result.Policy != nil && result.Manager == string(kmstypes.KeyManagerTypeCustomer) {
+		fmt.Printf("\tKey policy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	PrintUnresolvedPrincipals(region, result.Arn, result.Policy)
+	if result.PublicUse {
+		fmt.Println("\t[!] Any AWS principal can use the key")
+		EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any AWS principal can use KMS key %v", result.KeyId))
+	}
+	for _, useAccount := range result.UseAccounts {
+		fmt.Printf("\t[!] Account %v can use the key\n", useAccount)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Key policy lets account %v use KMS key %v", useAccount, result.KeyId))
+	}
+
+	sort.Slice(result.Grants, func(i, j int) bool { return result.Grants[i].GrantId < result.Grants[j].GrantId })
+	for _, grant := range result.Grants {
+		fmt.Printf("\tGrant: %v to %v (%v)\n", grant.GrantId, grant.Grantee, strings.Join(grant.Operations, ", "))
+		if grant.ExternalAccount != "" && !slices.Contains(result.UseAccounts, grant.ExternalAccount) {
+			fmt.Printf("\t[!] Grant %v lets account %v use the key\n", grant.GrantId, grant.ExternalAccount)
+			EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Grant %v on KMS key %v lets account %v use it", grant.GrantId, result.KeyId, grant.ExternalAccount))
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("kms-key", region, result)
+}
+
+func ListKeys(ctx context.Context, kmsClient *kms.Client) ([]kmstypes.KeyListEntry, error) {
+	var keys []kmstypes.KeyListEntry
+	paginator := kms.NewListKeysPaginator(kmsClient, &kms.ListKeysInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(keys)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the KMS keys. Here's why: %v\n", err)
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+	}
+
+	return LimitItems(keys), nil
+}
+
+func ListAliases(ctx context.Context, kmsClient *kms.Client) ([]kmstypes.AliasListEntry, error) {
+	var aliases []kmstypes.AliasListEntry
+	paginator := kms.NewListAliasesPaginator(kmsClient, &kms.ListAliasesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the KMS aliases. Here's why: %v\n", err)
+			return nil, err
+		}
+		aliases = append(aliases, page.Aliases...)
+	}
+
+	return aliases, nil
+}
+
+func ListGrants(ctx context.Context, kmsClient *kms.Client, keyId string) ([]kmstypes.GrantListEntry, error) {
+	var grants []kmstypes.GrantListEntry
+	paginator := kms.NewListGrantsPaginator(kmsClient, &kms.ListGrantsInput{KeyId: aws.String(keyId)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the grants for %v. Here's why: %v\n", keyId, err)
+			return nil, err
+		}
+		grants = append(grants, page.Grants...)
+	}
+
+	return grants, nil
+}
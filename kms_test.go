@@ -0,0 +1,92 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyseKeyAccess(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		policy           string
+		grants           []KeyGrantResult
+		wantPublic       bool
+		wantAccounts     []string
+		wantGrantAccount []string
+	}{
+		{
+			// What kms create-key writes when no policy is given
+			name:   "default policy",
+			policy: `{"Version":"2012-10-17","Id":"key-default-1","Statement":[{"Sid":"Enable IAM User Permissions","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"kms:*","Resource":"*"}]}`,
+		},
+		{
+			name:       "decrypt for anyone",
+			policy:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"kms:Decrypt","Resource":"*"}]}`,
+			wantPublic: true,
+		},
+		{
+			name:       "everything for anyone",
+			policy:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"kms:*","Resource":"*"}]}`,
+			wantPublic: true,
+		},
+		{
+			name: "decrypt for anyone in the organization",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"kms:Decrypt","Resource":"*",` +
+				`"Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abcdefghij"}}}]}`,
+		},
+		{
+			name:         "decrypt for another account",
+			policy:       `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":["kms:Decrypt","kms:DescribeKey"],"Resource":"*"}]}`,
+			wantAccounts: []string{"210987654321"},
+		},
+		{
+			name:         "grants for a role in another account",
+			policy:       `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:role/backup"},"Action":"kms:CreateGrant","Resource":"*"}]}`,
+			wantAccounts: []string{"210987654321"},
+		},
+		{
+			name:   "describe for another account",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"210987654321"},"Action":"kms:DescribeKey","Resource":"*"}]}`,
+		},
+		{
+			// Grants don't show up in the key policy
+			name: "grant to a role in another account",
+			grants: []KeyGrantResult{
+				{GrantId: "grant-1", Grantee: "arn:aws:iam::123456789012:role/app", Operations: []string{"Decrypt"}},
+				{GrantId: "grant-2", Grantee: "arn:aws:iam::210987654321:role/backup", Operations: []string{"Decrypt"}},
+			},
+			wantGrantAccount: []string{"", "210987654321"},
+		},
+		{
+			// Services are granted the key by name, not account
+			name: "grant to a service",
+			grants: []KeyGrantResult{
+				{GrantId: "grant-1", Grantee: "dynamodb.us-east-1.amazonaws.com", Operations: []string{"Decrypt"}},
+			},
+			wantGrantAccount: []string{""},
+		},
+	} {
+		result := &KeyResult{KeyId: "1234abcd-12ab-34cd-56ef-1234567890ab", Grants: test.grants}
+		if test.policy != "" {
+			policy, err := ParsePolicyDocument(test.policy)
+			if err != nil {
+				t.Fatalf("%v: %v", test.name, err)
+			}
+			result.Policy = policy
+		}
+		AnalyseKeyAccess(result, "123456789012")
+		if result.PublicUse != test.wantPublic {
+			t.Errorf("%v: public use is %v, want %v", test.name, result.PublicUse, test.wantPublic)
+		}
+		if !slices.Equal(result.UseAccounts, test.wantAccounts) {
+			t.Errorf("%v: use accounts %v, want %v", test.name, result.UseAccounts, test.wantAccounts)
+		}
+		var grantAccounts []string
+		for _, grant := range result.Grants {
+			grantAccounts = append(grantAccounts, grant.ExternalAccount)
+		}
+		if !slices.Equal(grantAccounts, test.wantGrantAccount) {
+			t.Errorf("%v: grant accounts %v, want %v", test.name, grantAccounts, test.wantGrantAccount)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Actions that let a principal run the function, directly or through its URL
+var FUNCTION_INVOKE_ACTIONS = []string{"lambda:InvokeFunction", "lambda:InvokeFunctionUrl"}
+
+// Environment variable names that usually hold a credential, whatever its value looks like
+var SENSITIVE_VARIABLE_PATTERN = regexp.MustCompile(`(?i)(password|passwd|pwd|secret|token|api_?key|private_?key|credential)`)
+
+// Condition keys the console adds to public function URL statements, they don't limit who can invoke
+var FUNCTION_URL_CONDITION_KEYS = []string{"lambda:functionurlauthtype", "lambda:invokedviafunctionurl"}
+
+type FunctionResult struct {
+	Name         string `json:"name"`
+	Arn          string `json:"arn"`
+	Runtime      string `json:"runtime,omitempty"`
+	PackageType  string `json:"packageType"`
+	Handler      string `json:"handler,omitempty"`
+	Role         string `json:"role"`
+	LastModified string `json:"lastModified"`
+	KmsKey       string `json:"kmsKey,omitempty"`
+	// Only the names, values are scanned but not kept
+	Variables          []string            `json:"variables"`
+	SensitiveVariables []string            `json:"sensitiveVariables"`
+	SecretsFound       []SecretFinding     `json:"secretsFound"`
+	Policy             *PolicyDocument     `json:"policy,omitempty"`
+	PublicInvoke       bool                `json:"publicInvoke"`
+	InvokeAccounts     []string            `json:"invokeAccounts"`
+	Urls               []FunctionUrlResult `json:"urls"`
+	// Only with --download-code, and only for zip packages
+	Code *FunctionCode `json:"code,omitempty"`
+}
+
+type FunctionUrlResult struct {
+	Url string `json:"url"`
+	// NONE or AWS_IAM
+	AuthType string `json:"authType"`
+	// Aliases have their own URL
+	Qualifier    string   `json:"qualifier,omitempty"`
+	AllowOrigins []string `json:"allowOrigins,omitempty"`
+}
+
+func RunLambdaModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		lambdaClient := lambda.NewFromConfig(regionalConfig)
+
+		// Environment variables come back with list-functions, the policy and URLs need a call each
+		// i.e. aws lambda list-functions, aws lambda get-policy --function-name <function-name>,
+		// aws lambda list-function-url-configs --function-name <function-name>, and with --download-code
+		// aws lambda get-function --function-name <function-name>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Lambda functions in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		functions, err := ListAllFunctions(ctx, lambdaClient)
+		if err != nil {
+			return err
+		}
+		results := make([]FunctionResult, len(functions))
+		ForEachConcurrently(ctx, len(functions), func(ctx context.Context, i int) {
+			results[i] = GetFunctionResult(ctx, lambdaClient, regionalConfig.Region, functions[i], accountId)
+		}, func(i int) {
+			PrintFunctionResult(regionalConfig.Region, results[i])
+		})
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetFunctionResult(ctx context.Context, lambdaClient *lambda.Client, region string, function lambdatypes.FunctionConfiguration, accountId string) FunctionResult {
+	result := FunctionResult{
+		Name:         aws.ToString(function.FunctionName),
+		Arn:          aws.ToString(function.FunctionArn),
+		Runtime:      string(function.Runtime),
+		PackageType:  string(function.PackageType),
+		Handler:      aws.ToString(function.Handler),
+		Role:         aws.ToString(function.Role),
+		LastModified: aws.ToString(function.LastModified),
+		KmsKey:       aws.ToString(function.KMSKeyArn),
+	}
+
+	if function.Environment != nil {
+		// Scanned as KEY=value lines so assignment rules match, with the function as the source
+		var lines []string
+		for name, value := range function.Environment.Variables {
+			result.Variables = append(result.Variables, name)
+			if SENSITIVE_VARIABLE_PATTERN.MatchString(name) && value != "" {
+				result.SensitiveVariables = append(result.SensitiveVariables, name)
+			}
+			lines = append(lines, name+"="+value)
+		}
+		sort.Strings(result.Variables)
+		sort.Strings(result.SensitiveVariables)
+		sort.Strings(lines)
+		result.SecretsFound = ScanForSecrets(result.Arn, []byte(strings.Join(lines, "\n")))
+	}
+
+	policy, err := GetFunctionPolicy(ctx, lambdaClient, result.Name)
+	if err == nil && policy != nil {
+		result.Policy = policy
+		result.PublicInvoke, result.InvokeAccounts = AnalyseResourcePolicy(policy, FUNCTION_INVOKE_ACTIONS, accountId, FunctionPolicyRestricted)
+	}
+
+	urls, err := ListFunctionUrls(ctx, lambdaClient, result.Name)
+	if err == nil {
+		for _, url := range urls {
+			urlResult := FunctionUrlResult{
+				Url:      aws.ToString(url.FunctionUrl),
+				AuthType: string(url.AuthType),
+			}
+			if parts := strings.Split(aws.ToString(url.FunctionArn), ":"); len(parts) == 8 {
+				urlResult.Qualifier = parts[7]
+			}
+			if url.Cors != nil {
+				urlResult.AllowOrigins = url.Cors.AllowOrigins
+			}
+			result.Urls = append(result.Urls, urlResult)
+		}
+	}
+
+	// Container image functions keep their code in ECR, there's no package to download
+	if DownloadCode && function.PackageType == lambdatypes.PackageTypeZip {
+		code, err := CachedFunctionCode(ctx, lambdaClient, region, result.Name)
+		if err == nil {
+			result.Code = &code
+		}
+	}
+
+	return result
+}
+
+func PrintFunctionResult(region string, result FunctionResult) {
+	fmt.Printf("\tFunction name: %v\n", result.Name)
+	if result.Runtime != "" {
+		fmt.Printf("\tRuntime: %v\n", result.Runtime)
+	}
+	fmt.Printf("\tPackage type: %v\n", result.PackageType)
+	if result.Handler != "" {
+		fmt.Printf("\tHandler: %v\n", result.Handler)
+	}
+	fmt.Printf("\tExecution role: %v\n", result.Role)
+	fmt.Printf("\tLast modified: %v\n", result.LastModified)
+	if result.KmsKey != "" {
+		fmt.Printf("\tEnvironment KMS key: %v\n", result.KmsKey)
+	}
+	if len(result.Variables) > 0 {
+		fmt.Printf("\tEnvironment variables: %v\n", strings.Join(result.Variables, ", "))
+	}
+	for _, name := range result.SensitiveVariables {
+		fmt.Printf("\t[!] Environment variable %v looks like it holds a credential\n", name)
+		EmitFinding(region, result.Arn, fmt.Sprintf("Environment variable %v looks like it holds a credential", name))
+	}
+	PrintSecretFindings(result.SecretsFound)
+	if len(result.SecretsFound) > 0 {
+		EmitFinding(region, result.Arn, fmt.Sprintf("Environment variables hold %v possible secrets", len(result.SecretsFound)))
+	}
+
+	if result.Policy != nil {
+		fmt.Printf("\tResource policy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	PrintUnresolvedPrincipals(region, result.Arn, result.Policy)
+	if result.PublicInvoke {
+		fmt.Println("\t[!] Any principal can invoke the function")
+		EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any principal can invoke function %v", result.Name))
+	}
+	for _, invokeAccount := range result.InvokeAccounts {
+		fmt.Printf("\t[!] Account %v can invoke the function\n", invokeAccount)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Account %v can invoke function %v", invokeAccount, result.Name))
+	}
+
+	for _, url := range result.Urls {
+		fmt.Printf("\tFunction URL: %v (auth type %v)\n", url.Url, url.AuthType)
+		if len(url.AllowOrigins) > 0 {
+			fmt.Printf("\t\tCORS origins: %v\n", strings.Join(url.AllowOrigins, ", "))
+		}
+		if url.AuthType != string(lambdatypes.FunctionUrlAuthTypeNone) {
+			continue
+		}
+		// Without IAM auth the resource policy still has to let anyone call the URL
+		if result.PublicInvoke {
+			fmt.Println("\t\t[!] URL can be called without authentication")
+			EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Function %v has a URL anyone can call without authentication", result.Name))
+		} else {
+			fmt.Println("\t\t[-] URL has no IAM auth, but the resource policy doesn't let anyone call it")
+		}
+	}
+	if result.Code != nil {
+		fmt.Printf("\tCode saved to: %v\n", result.Code.SavedTo)
+		PrintSecretFindings(result.Code.SecretsFound)
+		if len(result.Code.SecretsFound) > 0 {
+			EmitFinding(region, result.Arn, fmt.Sprintf("Deployment package holds %v possible secrets", len(result.Code.SecretsFound)))
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("function", region, result)
+}
+
+func FunctionPolicyRestricted(statement PolicyStatement) bool {
+	// Public function URL statements carry an auth type condition, which doesn't narrow who can call it
+	for _, keys := range statement.Condition {
+		for key := range keys {
+			if !slices.Contains(FUNCTION_URL_CONDITION_KEYS, strings.ToLower(key)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func GetFunctionPolicy(ctx context.Context, lambdaClient *lambda.Client, functionName string) (*PolicyDocument, error) {
+	// i.e. aws lambda get-policy --function-name <function-name>
+	output, err := lambdaClient.GetPolicy(ctx, &lambda.GetPolicyInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		// Functions without a resource policy return not found
+		var notFound *lambdatypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		fmt.Printf("Couldn't get the resource policy for %v. Here's why: %v\n", functionName, err)
+		return nil, err
+	}
+
+	policy, err := ParsePolicyDocument(aws.ToString(output.Policy))
+	if err != nil {
+		fmt.Printf("Couldn't parse the resource policy for %v. Here's why: %v\n", functionName, err)
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func ListFunctionUrls(ctx context.Context, lambdaClient *lambda.Client, functionName string) ([]lambdatypes.FunctionUrlConfig, error) {
+	// Every URL of the function, the unqualified one and one per alias
+	var urls []lambdatypes.FunctionUrlConfig
+	paginator := lambda.NewListFunctionUrlConfigsPaginator(lambdaClient, &lambda.ListFunctionUrlConfigsInput{FunctionName: aws.String(functionName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the function URLs for %v. Here's why: %v\n", functionName, err)
+			return nil, err
+		}
+		urls = append(urls, page.FunctionUrlConfigs...)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFunctionPolicyAccess(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		policy       string
+		wantPublic   bool
+		wantAccounts []string
+	}{
+		{
+			// What aws lambda add-permission --principal '*' writes
+			name: "public invoke",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"public","Effect":"Allow","Principal":"*",` +
+				`"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:us-east-1:123456789012:function:target"}]}`,
+			wantPublic: true,
+		},
+		{
+			name: "public function URL",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"FunctionURLAllowPublicAccess","Effect":"Allow","Principal":"*",` +
+				`"Action":"lambda:InvokeFunctionUrl","Resource":"arn:aws:lambda:us-east-1:123456789012:function:target",` +
+				`"Condition":{"StringEquals":{"lambda:FunctionUrlAuthType":"NONE"}}}]}`,
+			wantPublic: true,
+		},
+		{
+			name: "other account",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"cross-account","Effect":"Allow",` +
+				`"Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":"lambda:InvokeFunction",` +
+				`"Resource":"arn:aws:lambda:us-east-1:123456789012:function:target"}]}`,
+			wantAccounts: []string{"210987654321"},
+		},
+		{
+			name: "own account",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"own","Effect":"Allow",` +
+				`"Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"lambda:InvokeFunction",` +
+				`"Resource":"arn:aws:lambda:us-east-1:123456789012:function:target"}]}`,
+		},
+		{
+			// What aws lambda add-permission --principal s3.amazonaws.com --source-arn <bucket-arn> writes
+			name: "service principal",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"s3","Effect":"Allow",` +
+				`"Principal":{"Service":"s3.amazonaws.com"},"Action":"lambda:InvokeFunction",` +
+				`"Resource":"arn:aws:lambda:us-east-1:123456789012:function:target",` +
+				`"Condition":{"ArnLike":{"AWS:SourceArn":"arn:aws:s3:::bucket"}}}]}`,
+		},
+		{
+			name: "public with a source condition",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"public","Effect":"Allow","Principal":"*",` +
+				`"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:us-east-1:123456789012:function:target",` +
+				`"Condition":{"StringEquals":{"AWS:SourceAccount":"123456789012"}}}]}`,
+		},
+		{
+			name: "public but something else",
+			policy: `{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"public","Effect":"Allow","Principal":"*",` +
+				`"Action":"lambda:GetFunction","Resource":"arn:aws:lambda:us-east-1:123456789012:function:target"}]}`,
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		public, accounts := AnalyseResourcePolicy(policy, FUNCTION_INVOKE_ACTIONS, "123456789012", FunctionPolicyRestricted)
+		if public != test.wantPublic {
+			t.Errorf("%v: public is %v, want %v", test.name, public, test.wantPublic)
+		}
+		if !slices.Equal(accounts, test.wantAccounts) {
+			t.Errorf("%v: accounts %v, want %v", test.name, accounts, test.wantAccounts)
+		}
+	}
+}
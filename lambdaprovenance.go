@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Lambda caps code at 250MB unzipped, so no function or layer zip is bigger than this
+const MAX_CODE_PACKAGE_SIZE = 256 * 1024 * 1024
+
+type FunctionImageResult struct {
+	FunctionName string `json:"functionName"`
+	ImageUri     string `json:"imageUri"`
+	ImageDigest  string `json:"imageDigest"`
+}
+
+type LayerResult struct {
+	LayerArn string   `json:"layerArn"`
+	UsedBy   []string `json:"usedBy"`
+}
+
+func RunLambdaProvenanceModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := *callerIdentity.Account
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		lambdaClient := lambda.NewFromConfig(regionalConfig)
+
+		// Call the list-functions API and map each function to the layers and images it runs
+		// i.e. aws lambda list-functions
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Lambda layer and image provenance for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		functions, err := ListAllFunctions(ctx, lambdaClient)
+		if err != nil {
+			return err
+		}
+
+		layerConsumers := map[string][]string{}
+		for _, function := range functions {
+			for _, layer := range function.Layers {
+				layerConsumers[*layer.Arn] = append(layerConsumers[*layer.Arn], *function.FunctionName)
+			}
+
+			if function.PackageType != lambdatypes.PackageTypeImage {
+				if DownloadCode {
+					DownloadFunctionCode(ctx, lambdaClient, regionalConfig.Region, *function.FunctionName)
+				}
+				continue
+			}
+
+			// Container image functions only report their image through get-function
+			// i.e. aws lambda get-function --function-name <function-name>
+			functionDetails, err := GetFunctionDetails(ctx, lambdaClient, *function.FunctionName)
+			if err != nil || functionDetails.Code == nil {
+				continue
+			}
+			imageUri := aws.ToString(functionDetails.Code.ImageUri)
+			imageAccount := strings.SplitN(imageUri, ".", 2)[0]
+
+			fmt.Printf("\tFunction name: %v\n", *function.FunctionName)
+			fmt.Printf("\tImage: %v\n", imageUri)
+			fmt.Printf("\tImage digest: %v\n", aws.ToString(functionDetails.Code.ResolvedImageUri))
+			if imageAccount != accountId {
+				fmt.Printf("\t[!] Image comes from external account %v\n", imageAccount)
+				EmitExposedFinding(regionalConfig.Region, *function.FunctionArn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Image comes from external account %v", imageAccount))
+			}
+			Emit("function-image", regionalConfig.Region, FunctionImageResult{
+				FunctionName: *function.FunctionName,
+				ImageUri:     imageUri,
+				ImageDigest:  aws.ToString(functionDetails.Code.ResolvedImageUri),
+			})
+			fmt.Println(MINOR_SEPARATOR)
+		}
+
+		// Include layers published in this account even when nothing uses them
+		// i.e. aws lambda list-layers
+		layers, err := ListAllLayers(ctx, lambdaClient)
+		if err == nil {
+			for _, layer := range layers {
+				if layer.LatestMatchingVersion == nil {
+					continue
+				}
+				if _, ok := layerConsumers[*layer.LatestMatchingVersion.LayerVersionArn]; !ok {
+					layerConsumers[*layer.LatestMatchingVersion.LayerVersionArn] = nil
+				}
+			}
+		}
+
+		var layerArns []string
+		for layerArn := range layerConsumers {
+			layerArns = append(layerArns, layerArn)
+		}
+		sort.Strings(layerArns)
+
+		for _, layerArn := range layerArns {
+			fmt.Printf("\tLayer ARN: %v\n", layerArn)
+			if parsedArn, err := arn.Parse(layerArn); err == nil && parsedArn.AccountID != accountId {
+				fmt.Printf("\t[!] Layer comes from external account %v\n", parsedArn.AccountID)
+				EmitExposedFinding(regionalConfig.Region, layerArn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Layer comes from external account %v", parsedArn.AccountID))
+			}
+			Emit("layer", regionalConfig.Region, LayerResult{LayerArn: layerArn, UsedBy: layerConsumers[layerArn]})
+			if len(layerConsumers[layerArn]) == 0 {
+				fmt.Println("\tUsed by: nothing")
+			} else {
+				fmt.Printf("\tUsed by: %v\n", strings.Join(layerConsumers[layerArn], ", "))
+			}
+			fmt.Println(MINOR_SEPARATOR)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+// A function's deployment package once it's been saved to the loot directory and scanned
+type FunctionCode struct {
+	SavedTo      string          `json:"savedTo"`
+	SecretsFound []SecretFinding `json:"secretsFound"`
+}
+
+func DownloadFunctionCode(ctx context.Context, lambdaClient *lambda.Client, region string, functionName string) {
+	code, err := CachedFunctionCode(ctx, lambdaClient, region, functionName)
+	if err != nil {
+		return
+	}
+	fmt.Printf("\tFunction name: %v\n", functionName)
+	fmt.Printf("\tCode saved to: %v\n", code.SavedTo)
+	PrintSecretFindings(code.SecretsFound)
+	fmt.Println(MINOR_SEPARATOR)
+}
+
+func CachedFunctionCode(ctx context.Context, lambdaClient *lambda.Client, region string, functionName string) (FunctionCode, error) {
+	// The lambda and lambda-provenance modules both download code, each package is only fetched once
+	return Cached("lambda:code:"+region+":"+functionName, func() (FunctionCode, error) {
+		return FetchFunctionCode(ctx, lambdaClient, region, functionName)
+	})
+}
+
+func FetchFunctionCode(ctx context.Context, lambdaClient *lambda.Client, region string, functionName string) (FunctionCode, error) {
+	// The deployment package is only available through the pre-signed URL get-function returns
+	// i.e. aws lambda get-function --function-name <function-name> --query Code.Location
+	functionDetails, err := GetFunctionDetails(ctx, lambdaClient, functionName)
+	if err != nil {
+		return FunctionCode{}, err
+	}
+	if functionDetails.Code == nil || functionDetails.Code.Location == nil {
+		return FunctionCode{}, fmt.Errorf("no code location for %v", functionName)
+	}
+
+	zipped, err := DownloadAsset(ctx, *functionDetails.Code.Location, MAX_CODE_PACKAGE_SIZE)
+	if err != nil {
+		fmt.Printf("Couldn't download the code for %v. Here's why: %v\n", functionName, err)
+		return FunctionCode{}, err
+	}
+
+	lootPath, err := SaveLoot(filepath.Join("lambda", region, functionName+".zip"), zipped)
+	if err != nil {
+		return FunctionCode{}, err
+	}
+
+	findings, err := ScanZipForSecrets(lootPath, zipped)
+	if err != nil {
+		fmt.Printf("Couldn't scan the code for %v. Here's why: %v\n", functionName, err)
+	}
+
+	return FunctionCode{SavedTo: lootPath, SecretsFound: findings}, nil
+}
+
+func ListAllFunctions(ctx context.Context, lambdaClient *lambda.Client) ([]lambdatypes.FunctionConfiguration, error) {
+	// Get every function in the region
+	var functions []lambdatypes.FunctionConfiguration
+	paginator := lambda.NewListFunctionsPaginator(lambdaClient, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(functions)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the functions. Here's why: %v\n", err)
+			return nil, err
+		}
+		functions = append(functions, page.Functions...)
+	}
+
+	return LimitItems(functions), nil
+}
+
+func ListAllLayers(ctx context.Context, lambdaClient *lambda.Client) ([]lambdatypes.LayersListItem, error) {
+	// Get every layer published in the region
+	var layers []lambdatypes.LayersListItem
+	paginator := lambda.NewListLayersPaginator(lambdaClient, &lambda.ListLayersInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(layers)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the layers. Here's why: %v\n", err)
+			return nil, err
+		}
+		layers = append(layers, page.Layers...)
+	}
+
+	return LimitItems(layers), nil
+}
+
+func GetFunctionDetails(ctx context.Context, lambdaClient *lambda.Client, functionName string) (*lambda.GetFunctionOutput, error) {
+	// Get the configuration and code location of the function
+	functionDetails, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get details for the function %v. Here's why: %v\n", functionName, err)
+		return nil, err
+	}
+
+	return functionDetails, nil
+}
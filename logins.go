@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Either of these against another user is enough to take over their console access
+var LOGIN_PROFILE_ACTIONS = []string{
+	"iam:UpdateLoginProfile",
+	"iam:CreateLoginProfile",
+}
+
+func RunLoginsModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// get-user only works for users, so the caller is identified through STS and a role session
+	// is simulated as the role itself
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current identity. Exiting...")
+		return err
+	}
+	callerArn := aws.ToString(callerIdentity.Arn)
+	currentPrincipalArn := SimulationPrincipalArn(callerArn)
+
+	// Call the list-users API to check every user, or just the current one if that isn't allowed
+	// i.e. aws iam list-users
+	users, err := CachedUsers(ctx, iamClient)
+	if err != nil {
+		if _, isRole := CallerRoleName(callerArn); isRole {
+			fmt.Println("Couldn't list users, and a role has no console password of its own to check. Exiting...")
+			return err
+		}
+		fmt.Println("Couldn't list users, only checking the current user")
+		currentUserDetails, err := GetUserDetails(ctx, iamClient)
+		if err != nil {
+			fmt.Println("Couldn't get details for the current user. Exiting...")
+			return err
+		}
+		users = []iamtypes.User{*currentUserDetails.User}
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting console access for users...")
+	fmt.Println(MAJOR_SEPARATOR)
+	for _, user := range users {
+		username := *user.UserName
+
+		// i.e. aws iam get-login-profile --user-name <username>
+		fmt.Printf("\tUsername: %v\n", username)
+		loginProfile, err := GetLoginProfile(ctx, iamClient, username)
+		if err != nil {
+			fmt.Println("\tConsole access: unknown")
+			fmt.Println(MINOR_SEPARATOR)
+			continue
+		}
+		if loginProfile == nil {
+			fmt.Println("\tConsole access: no")
+		} else {
+			fmt.Println("\tConsole access: yes")
+			fmt.Printf("\tPassword created on: %v\n", *loginProfile.CreateDate)
+			fmt.Printf("\tPassword reset required: %v\n", loginProfile.PasswordResetRequired)
+		}
+		if user.PasswordLastUsed != nil {
+			fmt.Printf("\tPassword last used: %v\n", *user.PasswordLastUsed)
+		}
+		Emit("console-access", "", ConsoleAccessResult{
+			Username:         username,
+			ConsoleAccess:    loginProfile != nil,
+			LoginProfile:     loginProfile,
+			PasswordLastUsed: user.PasswordLastUsed,
+		})
+
+		// Check whether the current principal, user or role, could set this user's password
+		// i.e. aws iam simulate-principal-policy --policy-source-arn <current-principal-arn> --action-names iam:UpdateLoginProfile --resource-arns <user-arn>
+		if *user.Arn != currentPrincipalArn {
+			results, err := SimulatePrincipalActions(ctx, iamClient, currentPrincipalArn, LOGIN_PROFILE_ACTIONS, []string{*user.Arn})
+			if err == nil {
+				for _, result := range results {
+					if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+						continue
+					}
+					// Updating needs an existing password, creating needs there to be none
+					action := *result.EvalActionName
+					if (action == "iam:UpdateLoginProfile" && loginProfile != nil) || (action == "iam:CreateLoginProfile" && loginProfile == nil) {
+						fmt.Printf("\t[!] Console takeover: the current principal is allowed %v on %v\n", action, username)
+						EmitFinding("", *user.Arn, fmt.Sprintf("Console takeover: the current principal is allowed %v", action))
+					}
+				}
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	return nil
+}
+
+func ListAllUsers(ctx context.Context, iamClient *iam.Client) ([]iamtypes.User, error) {
+	// Get every user in the account
+	var users []iamtypes.User
+	paginator := iam.NewListUsersPaginator(iamClient, &iam.ListUsersInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(users)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the users. Here's why: %v\n", err)
+			return nil, err
+		}
+		users = append(users, page.Users...)
+	}
+
+	return LimitItems(users), nil
+}
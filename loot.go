@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Where downloaded artifacts are written, set with --loot-dir
+var LootDir = "loot"
+
+// Whether Lambda deployment packages and other function code are downloaded and scanned, set with --download-code
+var DownloadCode = false
+
+func SaveLoot(relativePath string, content []byte) (string, error) {
+	// Write the content under the loot directory, creating any parent directories
+	lootPath := filepath.Join(LootDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(lootPath), 0700); err != nil {
+		fmt.Printf("Couldn't create the loot directory. Here's why: %v\n", err)
+		return "", err
+	}
+	if err := os.WriteFile(lootPath, content, 0600); err != nil {
+		fmt.Printf("Couldn't save %v. Here's why: %v\n", lootPath, err)
+		return "", err
+	}
+
+	return lootPath, nil
+}
@@ -2,32 +2,63 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 const MAJOR_SEPARATOR = "====================================="
 const MINOR_SEPARATOR = "-------------------------------------"
 
-func main() {
+// Policies as they appear in the structured results, with their documents decoded
+type AttachedPolicyResult struct {
+	PolicyName string          `json:"policyName"`
+	PolicyArn  string          `json:"policyArn"`
+	Document   *PolicyDocument `json:"document,omitempty"`
+}
 
-	// Take command line arguments for maximum number of policies to list
-	// If no arguments are provided, list up to 10 policies
+type InlinePolicyResult struct {
+	PolicyName string          `json:"policyName"`
+	Document   *PolicyDocument `json:"document,omitempty"`
+}
 
-	// maxPols := flag.Int("max", 10, "Maximum number of policies to list")
-	// flag.Parse()
+// Loot paths of the policy documents saved with --resolve-documents
+var SavedPolicyDocuments = map[string]bool{}
 
-	ctx := context.Background()
-	sdkConfig, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		fmt.Println("Couldn't load default configuration. Have you set up your AWS account?")
-		fmt.Println(err)
-		return
+type PolicyVersionResult struct {
+	PolicyArn  string          `json:"policyArn"`
+	VersionId  string          `json:"versionId"`
+	CreateDate time.Time       `json:"createDate"`
+	Document   *PolicyDocument `json:"document,omitempty"`
+}
+
+func main() {
+	// Ctrl-C cancels the context so in-flight API calls stop, a second one exits straight away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Everything is a cobra command now, running with no subcommand behaves like the old single flow
+	if err := NewRootCommand().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
 	}
+}
+
+func RunIAMModule(ctx context.Context, sdkConfig aws.Config) error {
 	iamClient := iam.NewFromConfig(sdkConfig)
 
 	fmt.Println("Getting details for the current user...")
@@ -37,8 +68,9 @@ func main() {
 	fmt.Println(MAJOR_SEPARATOR)
 	currentUserDetails, err := GetUserDetails(ctx, iamClient)
 	if err != nil {
-		fmt.Println("Couldn't get details for the current user. Exiting...")
-		return
+		// Role credentials, i.e. an instance profile, an assumed role or a Lambda, can't call get-user,
+		// so work out who they belong to from STS instead
+		return RunIAMModuleForRole(ctx, sdkConfig, iamClient, err)
 	}
 
 	fmt.Println("User details:")
@@ -47,6 +79,7 @@ func main() {
 	fmt.Printf("\tUser ID: %v\n", *currentUserDetails.User.UserId)
 	fmt.Printf("\tCreated on: %v\n", *currentUserDetails.User.CreateDate)
 	fmt.Println(MAJOR_SEPARATOR)
+	Emit("user", "", currentUserDetails.User)
 
 	// Call the list-groups-for-user API to get the policies attached to the current user and print them
 	// i.e. aws iam list-groups-for-user --user-name <username>
@@ -56,15 +89,16 @@ func main() {
 	userGroups, err := ListUserGroups(ctx, iamClient, *currentUserDetails.User.UserName)
 	if err != nil {
 		fmt.Println("Couldn't get groups for the current user. Exiting...")
-		return
+		return err
 	}
 
-	for _, group := range userGroups.Groups {
+	for _, group := range userGroups {
 		fmt.Printf("\tGroup name: %v\n", *group.GroupName)
 		fmt.Printf("\tGroup ARN: %v\n", *group.Arn)
 		fmt.Printf("\tGroup ID: %v\n", *group.GroupId)
 		fmt.Printf("\tCreated on: %v\n", *group.CreateDate)
 		fmt.Println(MINOR_SEPARATOR)
+		Emit("group", "", group)
 	}
 
 	// Call the list-attached-user-policies API to get the policies attached to the current user and print them
@@ -75,17 +109,30 @@ func main() {
 	userPolicies, err := ListAttachedUserPolicies(ctx, iamClient, *currentUserDetails.User.UserName)
 	if err != nil {
 		fmt.Println("Couldn't get attached policies for the current user. Exiting...")
-		return
+		return err
 	}
 
-	for _, policy := range userPolicies.AttachedPolicies {
+	for _, policy := range userPolicies {
 		fmt.Printf("\tPolicy name: %v\n", *policy.PolicyName)
 		fmt.Printf("\tPolicy ARN: %v\n", *policy.PolicyArn)
+		if StructuredOutput() || ResolveDocuments {
+			// The document of the default version is only fetched when it's going into the results or was asked for
+			document, _ := GetManagedPolicyDocument(ctx, iamClient, *policy.PolicyArn)
+			if ResolveDocuments && document != nil {
+				ShowPolicyDocument(*policy.PolicyArn, document)
+			}
+			Emit("attached-policy", "", AttachedPolicyResult{PolicyName: *policy.PolicyName, PolicyArn: *policy.PolicyArn, Document: document})
+		}
 		fmt.Println(MINOR_SEPARATOR)
 	}
 
-	// Prompt the user if they want to get the details of any policy's latest version
-	PromptUserForPolicyVersionDetails(ctx, iamClient)
+	// Prompt the user if they want to get the details of any policy's latest version,
+	// unless the policy was given up front or prompts are turned off for scripted runs
+	if PolicyArnFlag != "" {
+		PrintPolicyVersionDetails(ctx, iamClient, PolicyArnFlag, VersionIdFlag)
+	} else if !NoPrompt {
+		PromptUserForPolicyVersionDetails(ctx, iamClient)
+	}
 
 	// Call the list-user-policies API to get the inline policies attached to the current user and print them
 	// i.e. aws iam list-user-policies --user-name <username>
@@ -95,16 +142,115 @@ func main() {
 	userInlinePolicies, err := ListInlineUserPolicies(ctx, iamClient, *currentUserDetails.User.UserName)
 	if err != nil {
 		fmt.Println("Couldn't get inline policies for the current user. Exiting...")
-		return
+		return err
 	}
 
-	for _, policy := range userInlinePolicies.PolicyNames {
+	for _, policy := range userInlinePolicies {
 		fmt.Printf("\tPolicy name: %v\n", policy)
 		fmt.Println(MINOR_SEPARATOR)
+		if StructuredOutput() {
+			// i.e. aws iam get-user-policy --user-name <username> --policy-name <policy-name>
+			document, _ := GetInlineUserPolicyDocument(ctx, iamClient, *currentUserDetails.User.UserName, policy)
+			Emit("inline-policy", "", InlinePolicyResult{PolicyName: policy, Document: document})
+		}
 	}
 
-	fmt.Println("All done!")
+	return nil
+}
 
+func RunIAMModuleForRole(ctx context.Context, sdkConfig aws.Config, iamClient *iam.Client, getUserErr error) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get details for the current user. Exiting...")
+		return getUserErr
+	}
+	roleName, ok := CallerRoleName(*callerIdentity.Arn)
+	if !ok {
+		fmt.Printf("\tCaller ARN: %v\n", *callerIdentity.Arn)
+		fmt.Println("Couldn't get details for the current user and the caller isn't a role. Exiting...")
+		return getUserErr
+	}
+
+	// Call the get-role API to get the details of the role behind the current session and print them
+	// i.e. aws iam get-role --role-name <role-name>
+	fmt.Println("Current credentials belong to a role session:")
+	fmt.Printf("\tCaller ARN: %v\n", *callerIdentity.Arn)
+	fmt.Printf("\tAccount: %v\n", *callerIdentity.Account)
+	Emit("caller-identity", "", CallerIdentityResult{
+		Account: *callerIdentity.Account,
+		Arn:     *callerIdentity.Arn,
+		UserId:  *callerIdentity.UserId,
+	})
+	fmt.Println(MAJOR_SEPARATOR)
+	role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get details for the role %v. Here's why: %v\n", roleName, err)
+		return err
+	}
+
+	result := RoleResult{Role: *role.Role}
+	fmt.Println("Role details:")
+	fmt.Printf("\tRole name: %v\n", *role.Role.RoleName)
+	fmt.Printf("\tRole ARN: %v\n", *role.Role.Arn)
+	fmt.Printf("\tRole ID: %v\n", *role.Role.RoleId)
+	fmt.Printf("\tCreated on: %v\n", *role.Role.CreateDate)
+	if role.Role.AssumeRolePolicyDocument != nil {
+		trustPolicy, err := ParsePolicyDocument(*role.Role.AssumeRolePolicyDocument)
+		if err == nil {
+			result.TrustPolicy = trustPolicy
+			PrintTrustPolicy(*role.Role.Arn, trustPolicy)
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	// Call the list-attached-role-policies and list-role-policies APIs to get what the role can do
+	// i.e. aws iam list-attached-role-policies --role-name <role-name>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting policies for the current role...")
+	fmt.Println(MAJOR_SEPARATOR)
+	permissions, err := GetRolePermissions(ctx, iamClient, *role.Role)
+	if err != nil {
+		fmt.Println("Couldn't get policies for the current role. Exiting...")
+		Emit("role", "", result)
+		return err
+	}
+	result.Permissions = permissions
+
+	var policyNames []string
+	for policyName := range permissions.Policies {
+		policyNames = append(policyNames, policyName)
+	}
+	sort.Strings(policyNames)
+	for _, policyName := range policyNames {
+		fmt.Printf("\tPolicy: %v\n", policyName)
+		if ResolveDocuments {
+			ShowPolicyDocument(PolicyDocumentKey("role/"+*role.Role.RoleName, policyName), permissions.Policies[policyName])
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	}
+	for _, action := range permissions.Notable {
+		if action == "*" {
+			fmt.Println("\t[!] Role has full administrative access")
+			continue
+		}
+		fmt.Printf("\t[!] Grants %v\n", action)
+	}
+	for _, action := range permissions.BlockedBySCP {
+		fmt.Printf("\t[-] Blocked by SCP: %v\n", action)
+	}
+	Emit("role", "", result)
+
+	// Same as for users, the policy version prompt works off the policy ARN alone
+	if PolicyArnFlag != "" {
+		PrintPolicyVersionDetails(ctx, iamClient, PolicyArnFlag, VersionIdFlag)
+	} else if !NoPrompt {
+		PromptUserForPolicyVersionDetails(ctx, iamClient)
+	}
+
+	return nil
 }
 
 func PromptUserForPolicyVersionDetails(ctx context.Context, iamClient *iam.Client) {
@@ -127,7 +273,7 @@ func PromptUserForPolicyVersionDetails(ctx context.Context, iamClient *iam.Clien
 			return
 		}
 
-		for _, version := range policyVersions.Versions {
+		for _, version := range policyVersions {
 			fmt.Printf("\tVersion ID: %v\n", *version.VersionId)
 			fmt.Printf("\tCreated on: %v\n", *version.CreateDate)
 			fmt.Println()
@@ -136,41 +282,104 @@ func PromptUserForPolicyVersionDetails(ctx context.Context, iamClient *iam.Clien
 		fmt.Print("Enter the version ID to retrieve: ")
 		var versionId string
 		fmt.Scanln(&versionId)
-		fmt.Println("Getting details for version ", versionId)
-		policyVersionDetails, err := GetPolicyVersionDetails(ctx, iamClient, policyArn, versionId)
-		if err != nil {
-			fmt.Println("Couldn't get details for the policy version")
-			return
-		}
+		PrintPolicyVersionDetails(ctx, iamClient, policyArn, versionId)
 
-		// Print out the VersionID, CreateDate, and Document of the policy version
-		fmt.Println(MAJOR_SEPARATOR)
-		fmt.Println("Policy version details:")
-		fmt.Printf("\tVersion ID: %v\n", *policyVersionDetails.PolicyVersion.VersionId)
-		fmt.Printf("\tCreated on: %v\n", *policyVersionDetails.PolicyVersion.CreateDate)
-		decodedDocument, err := url.QueryUnescape(*policyVersionDetails.PolicyVersion.Document)
+	} else {
+		return
+	}
+
+}
+
+func PolicyDocumentKey(principal string, policyName string) string {
+	// Managed policies are keyed by their ARN, inline ones only exist on their principal, i.e. role/<name>
+	if strings.HasPrefix(policyName, "arn:") {
+		return policyName
+	}
+
+	return principal + "/" + policyName
+}
+
+func ShowPolicyDocument(key string, document *PolicyDocument) {
+	// Print the decoded document under the policy, then save it to the loot directory once,
+	// however many principals it's attached to
+	printed, err := json.MarshalIndent(document, "\t\t", "  ")
+	if err != nil {
+		fmt.Printf("Couldn't encode the document for %v. Here's why: %v\n", key, err)
+		return
+	}
+	fmt.Printf("\tDocument:\n\t\t%v\n", string(printed))
+	PrintDangerousStatements(document)
+
+	lootPath := filepath.Join("policies", strings.NewReplacer(":", "_", "/", "_").Replace(key)+".json")
+	if SavedPolicyDocuments[lootPath] {
+		return
+	}
+	content, _ := json.MarshalIndent(document, "", "  ")
+	savedPath, err := SaveLoot(lootPath, content)
+	if err != nil {
+		return
+	}
+	SavedPolicyDocuments[lootPath] = true
+	fmt.Printf("\tDocument saved to: %v\n", savedPath)
+}
+
+func PrintPolicyVersionDetails(ctx context.Context, iamClient *iam.Client, policyArn string, versionId string) {
+	// Without a version ID, use whichever version is the default
+	// i.e. aws iam get-policy --policy-arn <policy-arn>
+	if versionId == "" {
+		policy, err := iamClient.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(policyArn)})
 		if err != nil {
-			fmt.Println("Couldn't encode the document. Exiting...")
+			fmt.Printf("Couldn't get the policy %v. Here's why: %v\n", policyArn, err)
 			return
 		}
+		versionId = *policy.Policy.DefaultVersionId
+	}
 
-		fmt.Printf("\tDocument: \n%v\n", decodedDocument)
-		fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting details for version ", versionId)
+	policyVersionDetails, err := GetPolicyVersionDetails(ctx, iamClient, policyArn, versionId)
+	if err != nil {
+		fmt.Println("Couldn't get details for the policy version")
+		return
+	}
 
-	} else {
+	// Print out the VersionID, CreateDate, and Document of the policy version
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Policy version details:")
+	fmt.Printf("\tVersion ID: %v\n", *policyVersionDetails.PolicyVersion.VersionId)
+	fmt.Printf("\tCreated on: %v\n", *policyVersionDetails.PolicyVersion.CreateDate)
+	decodedDocument, err := url.QueryUnescape(*policyVersionDetails.PolicyVersion.Document)
+	if err != nil {
+		fmt.Println("Couldn't encode the document. Exiting...")
 		return
 	}
 
+	fmt.Printf("\tDocument: \n%v\n", decodedDocument)
+	document, _ := ParsePolicyDocument(*policyVersionDetails.PolicyVersion.Document)
+	if document != nil {
+		PrintDangerousStatements(document)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("policy-version", "", PolicyVersionResult{
+		PolicyArn:  policyArn,
+		VersionId:  *policyVersionDetails.PolicyVersion.VersionId,
+		CreateDate: *policyVersionDetails.PolicyVersion.CreateDate,
+		Document:   document,
+	})
 }
 
-func ListLatestPolicyVersions(ctx context.Context, iamClient *iam.Client, policyArn string) (*iam.ListPolicyVersionsOutput, error) {
+func ListLatestPolicyVersions(ctx context.Context, iamClient *iam.Client, policyArn string) ([]iamtypes.PolicyVersion, error) {
 	// Get the details of the policy version
-	policyVersions, err := iamClient.ListPolicyVersions(ctx, &iam.ListPolicyVersionsInput{
+	var policyVersions []iamtypes.PolicyVersion
+	paginator := iam.NewListPolicyVersionsPaginator(iamClient, &iam.ListPolicyVersionsInput{
 		PolicyArn: aws.String(policyArn),
 	})
-	if err != nil {
-		fmt.Printf("Couldn't get details for the policy version. Here's why: %v\n", err)
-		return nil, err
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get details for the policy version. Here's why: %v\n", err)
+			return nil, err
+		}
+		policyVersions = append(policyVersions, page.Versions...)
 	}
 
 	return policyVersions, nil
@@ -201,41 +410,70 @@ func GetUserDetails(ctx context.Context, iamClient *iam.Client) (*iam.GetUserOut
 	return userDetails, nil
 }
 
-func ListUserGroups(ctx context.Context, iamClient *iam.Client, username string) (*iam.ListGroupsForUserOutput, error) {
+func ListUserGroups(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.Group, error) {
 	// Get the groups that the user belongs to
-	userGroups, err := iamClient.ListGroupsForUser(ctx, &iam.ListGroupsForUserInput{
+	var userGroups []iamtypes.Group
+	paginator := iam.NewListGroupsForUserPaginator(iamClient, &iam.ListGroupsForUserInput{
 		UserName: aws.String(username),
 	})
-	if err != nil {
-		fmt.Printf("Couldn't get the groups for the user. Here's why: %v\n", err)
-		return nil, err
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the groups for the user. Here's why: %v\n", err)
+			return nil, err
+		}
+		userGroups = append(userGroups, page.Groups...)
 	}
 
 	return userGroups, nil
 }
 
-func ListAttachedUserPolicies(ctx context.Context, iamClient *iam.Client, username string) (*iam.ListAttachedUserPoliciesOutput, error) {
+func ListAttachedUserPolicies(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.AttachedPolicy, error) {
 	// Get the policies attached to the user
-	userPolicies, err := iamClient.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
+	var userPolicies []iamtypes.AttachedPolicy
+	paginator := iam.NewListAttachedUserPoliciesPaginator(iamClient, &iam.ListAttachedUserPoliciesInput{
 		UserName: aws.String(username),
 	})
-	if err != nil {
-		fmt.Printf("Couldn't get the policies attached to the user. Here's why: %v\n", err)
-		return nil, err
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the policies attached to the user. Here's why: %v\n", err)
+			return nil, err
+		}
+		userPolicies = append(userPolicies, page.AttachedPolicies...)
 	}
 
 	return userPolicies, nil
 }
 
-func ListInlineUserPolicies(ctx context.Context, iamClient *iam.Client, username string) (*iam.ListUserPoliciesOutput, error) {
+func ListInlineUserPolicies(ctx context.Context, iamClient *iam.Client, username string) ([]string, error) {
 	// Get the inline policies attached to the user
-	userPolicies, err := iamClient.ListUserPolicies(ctx, &iam.ListUserPoliciesInput{
+	var userPolicies []string
+	paginator := iam.NewListUserPoliciesPaginator(iamClient, &iam.ListUserPoliciesInput{
 		UserName: aws.String(username),
 	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't get the inline policies attached to the user. Here's why: %v\n", err)
+			return nil, err
+		}
+		userPolicies = append(userPolicies, page.PolicyNames...)
+	}
+
+	return userPolicies, nil
+}
+
+func GetInlineUserPolicyDocument(ctx context.Context, iamClient *iam.Client, username string, policyName string) (*PolicyDocument, error) {
+	// Get the document of one of the user's inline policies
+	userPolicy, err := iamClient.GetUserPolicy(ctx, &iam.GetUserPolicyInput{
+		UserName:   aws.String(username),
+		PolicyName: aws.String(policyName),
+	})
 	if err != nil {
-		fmt.Printf("Couldn't get the inline policies attached to the user. Here's why: %v\n", err)
+		fmt.Printf("Couldn't get the inline policy %v. Here's why: %v\n", policyName, err)
 		return nil, err
 	}
 
-	return userPolicies, nil
+	return ParsePolicyDocument(*userPolicy.PolicyDocument)
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sesv2types "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// What sending email or SMS takes, checked against every resource
+var EMAIL_SEND_ACTIONS = []string{"ses:SendEmail", "ses:SendRawEmail"}
+
+const SMS_SEND_ACTION = "sns:Publish"
+
+type EmailSendingResult struct {
+	// Out of the sandbox, mail can go to any address rather than only verified ones
+	ProductionAccess  bool    `json:"productionAccess"`
+	SendingEnabled    bool    `json:"sendingEnabled"`
+	EnforcementStatus string  `json:"enforcementStatus,omitempty"`
+	Max24HourSend     float64 `json:"max24HourSend"`
+	MaxSendRate       float64 `json:"maxSendRate"`
+	SentLast24Hours   float64 `json:"sentLast24Hours"`
+	// Domains and addresses mail can be sent from
+	VerifiedIdentities []string `json:"verifiedIdentities"`
+}
+
+type SMSSendingResult struct {
+	InSandbox bool `json:"inSandbox"`
+	// In USD, AWS's default for a new account is 1
+	MonthlySpendLimit string `json:"monthlySpendLimit,omitempty"`
+	DefaultSMSType    string `json:"defaultSmsType,omitempty"`
+	DefaultSenderId   string `json:"defaultSenderId,omitempty"`
+}
+
+type MessagingResult struct {
+	Email        *EmailSendingResult `json:"email,omitempty"`
+	SMS          *SMSSendingResult   `json:"sms,omitempty"`
+	CanSendEmail bool                `json:"canSendEmail"`
+	CanSendSMS   bool                `json:"canSendSms"`
+}
+
+func RunMessagingModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+
+	// Whether the principal could send at all, before looking at how much each region allows
+	// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names ses:SendEmail ses:SendRawEmail sns:Publish
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking whether the current principal can send email and SMS...")
+	fmt.Println(MAJOR_SEPARATOR)
+	allowed := map[string]bool{}
+	results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, append(EMAIL_SEND_ACTIONS, SMS_SEND_ACTION), []string{"*"})
+	if err == nil {
+		for _, result := range results {
+			fmt.Printf("\t%v: %v\n", *result.EvalActionName, result.EvalDecision)
+			allowed[*result.EvalActionName] = result.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed
+			Emit("simulation", "", NewSimulationResult(result))
+		}
+	}
+	canSendEmail := allowed["ses:SendEmail"] || allowed["ses:SendRawEmail"]
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		result := MessagingResult{CanSendEmail: canSendEmail, CanSendSMS: allowed[SMS_SEND_ACTION]}
+
+		// i.e. aws sesv2 get-account
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting SES and SNS SMS sending limits in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		sesClient := sesv2.NewFromConfig(regionalConfig)
+		email, err := GetEmailSending(ctx, sesClient)
+		if err == nil {
+			result.Email = email
+			fmt.Printf("\tSES production access: %v\n", email.ProductionAccess)
+			fmt.Printf("\tSending enabled: %v\n", email.SendingEnabled)
+			if email.EnforcementStatus != "" {
+				fmt.Printf("\tEnforcement status: %v\n", email.EnforcementStatus)
+			}
+			fmt.Printf("\tSending quota: %v a day, %v a second (%v sent in the last 24 hours)\n", email.Max24HourSend, email.MaxSendRate, email.SentLast24Hours)
+			for _, identity := range email.VerifiedIdentities {
+				fmt.Printf("\tVerified identity: %v\n", identity)
+			}
+			// In the sandbox mail only reaches verified addresses, so it can't be used for phishing or spam
+			if email.ProductionAccess && email.SendingEnabled && len(email.VerifiedIdentities) > 0 && canSendEmail {
+				fmt.Printf("\t[!] Current principal can send up to %v emails a day to any address\n", email.Max24HourSend)
+				EmitFinding(regionalConfig.Region, *callerIdentity.Account, fmt.Sprintf("Current principal can send up to %v emails a day to any address from %v verified identities", email.Max24HourSend, len(email.VerifiedIdentities)))
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws sns get-sms-attributes, aws sns get-sms-sandbox-account-status
+		sms, err := GetSMSSending(ctx, sns.NewFromConfig(regionalConfig))
+		if err == nil {
+			result.SMS = sms
+			fmt.Printf("\tSNS SMS sandbox: %v\n", sms.InSandbox)
+			if sms.MonthlySpendLimit != "" {
+				fmt.Printf("\tMonthly SMS spend limit: $%v\n", sms.MonthlySpendLimit)
+			}
+			if sms.DefaultSMSType != "" {
+				fmt.Printf("\tDefault SMS type: %v\n", sms.DefaultSMSType)
+			}
+			if sms.DefaultSenderId != "" {
+				fmt.Printf("\tDefault sender ID: %v\n", sms.DefaultSenderId)
+			}
+			// Out of the sandbox, SMS can be sent to any number until the spend limit runs out
+			if !sms.InSandbox && result.CanSendSMS {
+				fmt.Printf("\t[!] Current principal can send SMS to any number, up to $%v a month\n", sms.MonthlySpendLimit)
+				EmitFinding(regionalConfig.Region, *callerIdentity.Account, fmt.Sprintf("Current principal can send SMS to any number, up to $%v a month", sms.MonthlySpendLimit))
+			}
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+		Emit("messaging", regionalConfig.Region, result)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetEmailSending(ctx context.Context, sesClient *sesv2.Client) (*EmailSendingResult, error) {
+	account, err := sesClient.GetAccount(ctx, &sesv2.GetAccountInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the SES account details. Here's why: %v\n", err)
+		return nil, err
+	}
+	result := &EmailSendingResult{
+		ProductionAccess:  account.ProductionAccessEnabled,
+		SendingEnabled:    account.SendingEnabled,
+		EnforcementStatus: aws.ToString(account.EnforcementStatus),
+	}
+	if account.SendQuota != nil {
+		result.Max24HourSend = account.SendQuota.Max24HourSend
+		result.MaxSendRate = account.SendQuota.MaxSendRate
+		result.SentLast24Hours = account.SendQuota.SentLast24Hours
+	}
+
+	// i.e. aws sesv2 list-email-identities
+	paginator := sesv2.NewListEmailIdentitiesPaginator(sesClient, &sesv2.ListEmailIdentitiesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(result.VerifiedIdentities)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the SES identities. Here's why: %v\n", err)
+			break
+		}
+		for _, identity := range page.EmailIdentities {
+			if identity.VerificationStatus == sesv2types.VerificationStatusSuccess && identity.SendingEnabled {
+				result.VerifiedIdentities = append(result.VerifiedIdentities, aws.ToString(identity.IdentityName))
+			}
+		}
+	}
+	result.VerifiedIdentities = LimitItems(result.VerifiedIdentities)
+
+	return result, nil
+}
+
+func GetSMSSending(ctx context.Context, snsClient *sns.Client) (*SMSSendingResult, error) {
+	attributes, err := snsClient.GetSMSAttributes(ctx, &sns.GetSMSAttributesInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the SNS SMS attributes. Here's why: %v\n", err)
+		return nil, err
+	}
+	result := &SMSSendingResult{
+		MonthlySpendLimit: attributes.Attributes["MonthlySpendLimit"],
+		DefaultSMSType:    attributes.Attributes["DefaultSMSType"],
+		DefaultSenderId:   attributes.Attributes["DefaultSenderID"],
+		InSandbox:         true,
+	}
+
+	sandbox, err := snsClient.GetSMSSandboxAccountStatus(ctx, &sns.GetSMSSandboxAccountStatusInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the SNS SMS sandbox status. Here's why: %v\n", err)
+		return result, nil
+	}
+	result.InSandbox = aws.ToBool(sandbox.IsInSandbox)
+
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Address to serve Prometheus metrics on while the run is going, set with --metrics-addr
+var MetricsAddr = ""
+
+// API calls and module durations for the current run, keyed by the SDK's service ID, i.e. "IAM" or "EC2"
+type RunStatistics struct {
+	mutex         sync.Mutex
+	APICalls      map[string]int     `json:"apiCalls"`
+	Throttles     map[string]int     `json:"throttles"`
+	ModuleSeconds map[string]float64 `json:"moduleSeconds"`
+}
+
+var Statistics = NewRunStatistics()
+
+func NewRunStatistics() *RunStatistics {
+	return &RunStatistics{
+		APICalls:      map[string]int{},
+		Throttles:     map[string]int{},
+		ModuleSeconds: map[string]float64{},
+	}
+}
+
+func (s *RunStatistics) RecordAPICall(service string, throttled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.APICalls[service]++
+	if throttled {
+		s.Throttles[service]++
+	}
+}
+
+func (s *RunStatistics) RecordModuleDuration(module string, duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ModuleSeconds[module] += duration.Seconds()
+}
+
+func (s *RunStatistics) Snapshot() *RunStatistics {
+	// A copy that's safe to encode while calls are still being recorded
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := NewRunStatistics()
+	for service, count := range s.APICalls {
+		snapshot.APICalls[service] = count
+	}
+	for service, count := range s.Throttles {
+		snapshot.Throttles[service] = count
+	}
+	for module, seconds := range s.ModuleSeconds {
+		snapshot.ModuleSeconds[module] = seconds
+	}
+
+	return snapshot
+}
+
+func AddMetricsMiddleware(sdkConfig *aws.Config) {
+	// Count every attempt, retries included, as it comes back. Sitting at the front of the
+	// deserialize step means API errors have already been decoded, so throttles can be told apart
+	sdkConfig.APIOptions = append(sdkConfig.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("RunStatistics", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+			throttled := err != nil && retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+			Statistics.RecordAPICall(awsmiddleware.GetServiceID(ctx), throttled)
+			return out, metadata, err
+		}), middleware.Before)
+	})
+}
+
+func PrintStatistics(statistics *RunStatistics) {
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Run statistics:")
+	for _, service := range SortedKeys(statistics.APICalls) {
+		fmt.Printf("\t%v API calls: %v (%v throttled)\n", service, statistics.APICalls[service], statistics.Throttles[service])
+	}
+	for _, module := range SortedKeys(statistics.ModuleSeconds) {
+		fmt.Printf("\tModule %v: %.1fs\n", module, statistics.ModuleSeconds[module])
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+}
+
+func ServeMetrics(addr string) error {
+	// Listen up front so a bad address fails the run instead of being logged and ignored
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Printf("Couldn't listen on %v for metrics. Here's why: %v\n", addr, err)
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", WriteMetrics)
+	go http.Serve(listener, mux)
+	fmt.Printf("Serving metrics on http://%v/metrics\n", listener.Addr())
+
+	return nil
+}
+
+func WriteMetrics(w http.ResponseWriter, r *http.Request) {
+	// Prometheus text exposition format
+	statistics := Statistics.Snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP aws_enumerator_api_calls_total AWS API calls made, retries included.")
+	fmt.Fprintln(w, "# TYPE aws_enumerator_api_calls_total counter")
+	for _, service := range SortedKeys(statistics.APICalls) {
+		fmt.Fprintf(w, "aws_enumerator_api_calls_total{service=%q} %v\n", service, statistics.APICalls[service])
+	}
+	fmt.Fprintln(w, "# HELP aws_enumerator_api_throttles_total AWS API calls that were throttled.")
+	fmt.Fprintln(w, "# TYPE aws_enumerator_api_throttles_total counter")
+	for _, service := range SortedKeys(statistics.Throttles) {
+		fmt.Fprintf(w, "aws_enumerator_api_throttles_total{service=%q} %v\n", service, statistics.Throttles[service])
+	}
+	fmt.Fprintln(w, "# HELP aws_enumerator_module_duration_seconds How long each finished module took.")
+	fmt.Fprintln(w, "# TYPE aws_enumerator_module_duration_seconds gauge")
+	for _, module := range SortedKeys(statistics.ModuleSeconds) {
+		fmt.Fprintf(w, "aws_enumerator_module_duration_seconds{module=%q} %v\n", module, statistics.ModuleSeconds[module])
+	}
+}
+
+func SortedKeys[T any](m map[string]T) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+type UserMFAResult struct {
+	Username      string `json:"username"`
+	ConsoleAccess bool   `json:"consoleAccess"`
+	// Serial numbers, or ARNs for virtual devices
+	Devices []string `json:"devices"`
+}
+
+type MFASummaryResult struct {
+	RootMFAEnabled bool `json:"rootMfaEnabled"`
+	// Whether the root MFA is a virtual device, otherwise it's a hardware key or passkey
+	RootVirtualMFA  bool     `json:"rootVirtualMfa"`
+	UsersWithoutMFA []string `json:"usersWithoutMfa"`
+	// Virtual devices created but never assigned, or left behind when their user was deleted
+	UnassignedDevices []string `json:"unassignedDevices"`
+}
+
+func RunMFAModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	summary := MFASummaryResult{}
+
+	// The root user doesn't show up in list-users, the account summary is the only place its MFA is reported
+	// i.e. aws iam get-account-summary
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting MFA devices for the root user and every IAM user...")
+	fmt.Println(MAJOR_SEPARATOR)
+	accountSummary, err := GetAccountSummary(ctx, iamClient)
+	if err == nil {
+		summary.RootMFAEnabled = accountSummary.SummaryMap["AccountMFAEnabled"] == 1
+	}
+
+	// i.e. aws iam list-virtual-mfa-devices
+	virtualDevices, err := ListVirtualMFADevices(ctx, iamClient)
+	if err == nil {
+		for _, device := range virtualDevices {
+			if device.User == nil {
+				summary.UnassignedDevices = append(summary.UnassignedDevices, *device.SerialNumber)
+				continue
+			}
+			if strings.HasSuffix(aws.ToString(device.User.Arn), ":root") {
+				summary.RootVirtualMFA = true
+			}
+		}
+	}
+
+	if accountSummary != nil {
+		fmt.Printf("\tRoot MFA: %v\n", summary.RootMFAEnabled)
+		if summary.RootMFAEnabled && summary.RootVirtualMFA {
+			fmt.Println("\tRoot MFA device: virtual")
+		} else if summary.RootMFAEnabled && virtualDevices != nil {
+			fmt.Println("\tRoot MFA device: hardware key or passkey")
+		}
+		if !summary.RootMFAEnabled {
+			fmt.Println("\t[!] The root user has no MFA")
+			EmitFinding("", "root", "The root user has no MFA")
+		}
+	}
+	for _, serialNumber := range summary.UnassignedDevices {
+		fmt.Printf("\t[-] Virtual MFA device %v isn't assigned to anyone\n", serialNumber)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+
+	// i.e. aws iam list-users
+	users, err := CachedUsers(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list users. Exiting...")
+		return err
+	}
+
+	// Whether each user has a password decides how much a missing MFA device matters
+	results := make([]UserMFAResult, len(users))
+	listErrors := make([]error, len(users))
+	ForEachConcurrently(ctx, len(users), func(ctx context.Context, i int) {
+		results[i], listErrors[i] = GetUserMFAResult(ctx, iamClient, *users[i].UserName)
+	}, func(i int) {
+		user := users[i]
+		result := results[i]
+		if listErrors[i] != nil {
+			return
+		}
+		fmt.Printf("\tUsername: %v\n", result.Username)
+		fmt.Printf("\tConsole access: %v\n", result.ConsoleAccess)
+		for _, device := range result.Devices {
+			fmt.Printf("\tMFA device: %v\n", device)
+		}
+		if len(result.Devices) == 0 {
+			summary.UsersWithoutMFA = append(summary.UsersWithoutMFA, result.Username)
+			if result.ConsoleAccess {
+				fmt.Println("\t[!] Has a console password but no MFA device")
+				EmitFinding("", *user.Arn, "Has a console password but no MFA device")
+			} else {
+				fmt.Println("\t[-] No MFA device, only matters if it's given a console password")
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("user-mfa", "", result)
+	})
+
+	fmt.Printf("\tUsers without MFA: %v of %v\n", len(summary.UsersWithoutMFA), len(users))
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("mfa-summary", "", summary)
+
+	return nil
+}
+
+func GetUserMFAResult(ctx context.Context, iamClient *iam.Client, username string) (UserMFAResult, error) {
+	result := UserMFAResult{Username: username}
+
+	// i.e. aws iam get-login-profile --user-name <username>
+	loginProfile, err := GetLoginProfile(ctx, iamClient, username)
+	if err != nil {
+		return result, err
+	}
+	result.ConsoleAccess = loginProfile != nil
+
+	// i.e. aws iam list-mfa-devices --user-name <username>
+	devices, err := ListMFADevices(ctx, iamClient, username)
+	if err != nil {
+		return result, err
+	}
+	for _, device := range devices {
+		result.Devices = append(result.Devices, *device.SerialNumber)
+	}
+
+	return result, nil
+}
+
+func ListVirtualMFADevices(ctx context.Context, iamClient *iam.Client) ([]iamtypes.VirtualMFADevice, error) {
+	// Both assigned and unassigned devices, the root user's included
+	var devices []iamtypes.VirtualMFADevice
+	paginator := iam.NewListVirtualMFADevicesPaginator(iamClient, &iam.ListVirtualMFADevicesInput{
+		AssignmentStatus: iamtypes.AssignmentStatusTypeAny,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the virtual MFA devices. Here's why: %v\n", err)
+			return nil, err
+		}
+		devices = append(devices, page.VirtualMFADevices...)
+	}
+
+	return devices, nil
+}
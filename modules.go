@@ -0,0 +1,701 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/codeartifact"
+	"github.com/aws/aws-sdk-go-v2/service/controltower"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/imagebuilder"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/rolesanywhere"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/synthetics"
+)
+
+// Module is a single enumeration stage that can be selected with --modules or run with enumerate <name>
+type Module struct {
+	Name        string
+	Description string
+	// Run performs the full enumeration and prints the results
+	Run func(ctx context.Context, sdkConfig aws.Config) error
+	// Probes cover every service Run calls, so a check can tell which of them isn't reachable
+	Probes []ServiceProbe
+	// DependsOn names the modules whose data this one reads from the shared store,
+	// they're run first even when they weren't selected
+	DependsOn []string
+}
+
+// ServiceProbe makes one cheap, read-only call to check a service is reachable, there's one per service
+type ServiceProbe struct {
+	Service string
+	Probe   func(ctx context.Context, sdkConfig aws.Config) error
+}
+
+var (
+	IAM_PROBE             = ServiceProbe{Service: "iam", Probe: ProbeIAM}
+	STS_PROBE             = ServiceProbe{Service: "sts", Probe: ProbeSTS}
+	EC2_PROBE             = ServiceProbe{Service: "ec2", Probe: ProbeEC2}
+	QUOTAS_PROBE          = ServiceProbe{Service: "service-quotas", Probe: ProbeQuotas}
+	ACCOUNT_PROBE         = ServiceProbe{Service: "account", Probe: ProbeAccount}
+	LAMBDA_PROBE          = ServiceProbe{Service: "lambda", Probe: ProbeLambda}
+	SCHEDULER_PROBE       = ServiceProbe{Service: "scheduler", Probe: ProbeScheduler}
+	EVENTBRIDGE_PROBE     = ServiceProbe{Service: "events", Probe: ProbeEventBridge}
+	SYNTHETICS_PROBE      = ServiceProbe{Service: "synthetics", Probe: ProbeSynthetics}
+	SSM_PROBE             = ServiceProbe{Service: "ssm", Probe: ProbeSSM}
+	IDENTITY_CENTER_PROBE = ServiceProbe{Service: "sso-admin", Probe: ProbeIdentityCenter}
+	CONTROL_TOWER_PROBE   = ServiceProbe{Service: "controltower", Probe: ProbeControlTower}
+	ORGANIZATIONS_PROBE   = ServiceProbe{Service: "organizations", Probe: ProbeOrganizations}
+	RESOLVER_PROBE        = ServiceProbe{Service: "route53resolver", Probe: ProbeResolver}
+	RDS_PROBE             = ServiceProbe{Service: "rds", Probe: ProbeRDS}
+	ELASTICACHE_PROBE     = ServiceProbe{Service: "elasticache", Probe: ProbeElastiCache}
+	SECRETS_MANAGER_PROBE = ServiceProbe{Service: "secretsmanager", Probe: ProbeSecretsManager}
+	BEDROCK_PROBE         = ServiceProbe{Service: "bedrock", Probe: ProbeBedrock}
+	BEDROCK_AGENT_PROBE   = ServiceProbe{Service: "bedrock-agent", Probe: ProbeBedrockAgent}
+	ECS_PROBE             = ServiceProbe{Service: "ecs", Probe: ProbeECS}
+	CLOUDFRONT_PROBE      = ServiceProbe{Service: "cloudfront", Probe: ProbeCloudFront}
+	SES_PROBE             = ServiceProbe{Service: "sesv2", Probe: ProbeSES}
+	SNS_PROBE             = ServiceProbe{Service: "sns", Probe: ProbeSNS}
+	S3_PROBE              = ServiceProbe{Service: "s3", Probe: ProbeS3}
+	S3_CONTROL_PROBE      = ServiceProbe{Service: "s3-control", Probe: ProbeS3Control}
+	CLOUDTRAIL_PROBE      = ServiceProbe{Service: "cloudtrail", Probe: ProbeCloudTrail}
+	ROLES_ANYWHERE_PROBE  = ServiceProbe{Service: "rolesanywhere", Probe: ProbeRolesAnywhere}
+	IMAGE_BUILDER_PROBE   = ServiceProbe{Service: "imagebuilder", Probe: ProbeImageBuilder}
+	CODEARTIFACT_PROBE    = ServiceProbe{Service: "codeartifact", Probe: ProbeCodeArtifact}
+	SIGNER_PROBE          = ServiceProbe{Service: "signer", Probe: ProbeSigner}
+	KMS_PROBE             = ServiceProbe{Service: "kms", Probe: ProbeKMS}
+	TAGGING_PROBE         = ServiceProbe{Service: "tagging", Probe: ProbeTagging}
+	SQS_PROBE             = ServiceProbe{Service: "sqs", Probe: ProbeSQS}
+	GUARDDUTY_PROBE       = ServiceProbe{Service: "guardduty", Probe: ProbeGuardDuty}
+	SECURITY_HUB_PROBE    = ServiceProbe{Service: "securityhub", Probe: ProbeSecurityHub}
+)
+
+var MODULES = []Module{
+	{
+		Name:        "account-summary",
+		Description: "Password policy, IAM entity counts, root MFA and access keys, and policy size quotas",
+		Run:         RunAccountSummaryModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+	},
+	{
+		Name:        "iam",
+		Description: "Current user, groups, attached and inline policies",
+		Run:         RunIAMModule,
+		Probes:      []ServiceProbe{IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "quotas",
+		Description: "IAM entity counts and EC2 instance usage compared against their quotas",
+		Run:         RunQuotasModule,
+		Probes:      []ServiceProbe{QUOTAS_PROBE, IAM_PROBE, EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "regions",
+		Description: "Region opt-in status, highlighting opted-in regions",
+		Run:         RunRegionsModule,
+		Probes:      []ServiceProbe{ACCOUNT_PROBE, EC2_PROBE},
+	},
+	{
+		Name:        "console",
+		Description: "Console access and CloudShell availability for the current user",
+		Run:         RunConsoleModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+	},
+	{
+		Name:        "logins",
+		Description: "Console access for every user and who could take it over",
+		Run:         RunLoginsModule,
+		Probes:      []ServiceProbe{IAM_PROBE, STS_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "credentials",
+		Description: "Signing certificates, SSH keys and service-specific credentials for every user, and server certificates stored in IAM",
+		Run:         RunCredentialsModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "orphans",
+		Description: "Role trust policies that still reference deleted users and roles",
+		Run:         RunOrphansModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "policyversions",
+		Description: "Non-default customer managed policy versions that allow more than the default",
+		Run:         RunPolicyVersionsModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+	},
+	{
+		Name:        "lambda-provenance",
+		Description: "Lambda layers and container images, and the accounts they come from",
+		Run:         RunLambdaProvenanceModule,
+		Probes:      []ServiceProbe{LAMBDA_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "schedules",
+		Description: "EventBridge schedules and scheduled rules that could be used for persistence",
+		Run:         RunSchedulesModule,
+		Probes:      []ServiceProbe{SCHEDULER_PROBE, EVENTBRIDGE_PROBE, IAM_PROBE},
+	},
+	{
+		Name:        "canaries",
+		Description: "CloudWatch Synthetics canaries, their execution roles, and where their scripts live",
+		Run:         RunCanariesModule,
+		Probes:      []ServiceProbe{SYNTHETICS_PROBE, LAMBDA_PROBE},
+	},
+	{
+		Name:        "hybrid",
+		Description: "SSM hybrid activations and the non-EC2 machines enrolled through them",
+		Run:         RunHybridModule,
+		Probes:      []ServiceProbe{SSM_PROBE},
+	},
+	{
+		Name:        "instance-roles",
+		Description: "EC2 instances mapped through their instance profiles to roles, policies, and notable permissions, and every instance profile with the instances carrying it",
+		Run:         RunInstanceRolesModule,
+		Probes:      []ServiceProbe{IAM_PROBE, EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "takeover",
+		Description: "Running instances the current principal could hijack through user data, SSM, the serial console or EC2 Instance Connect",
+		Run:         RunTakeoverModule,
+		Probes:      []ServiceProbe{IAM_PROBE, EC2_PROBE, STS_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "inventory",
+		Description: "Users, groups, roles, instance profiles and instances fetched once into the shared store for the other modules",
+		Run:         RunInventoryModule,
+		Probes:      []ServiceProbe{IAM_PROBE, EC2_PROBE},
+	},
+	{
+		Name:        "users",
+		Description: "Every user in the account with their groups, policies, access keys and MFA devices",
+		Run:         RunUsersModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "roles",
+		Description: "Every role with its decoded trust policy and its attached and inline policies",
+		Run:         RunRolesModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "wildcard-trust",
+		Description: "Roles whose trust policy lets in anyone, or a whole other account, without a narrowing condition",
+		Run:         RunWildcardTrustModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+	},
+	{
+		Name:        "groups",
+		Description: "Every group with its members and its attached and inline policies",
+		Run:         RunGroupsModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "identity-center",
+		Description: "Identity Center permission sets compared against the roles provisioned from them",
+		Run:         RunIdentityCenterModule,
+		Probes:      []ServiceProbe{IDENTITY_CENTER_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "privesc",
+		Description: "Privilege escalation paths open to the current principal, from its policy documents",
+		Run:         RunPrivescModule,
+		Probes:      []ServiceProbe{IAM_PROBE, STS_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "contacts",
+		Description: "Primary and alternate contacts, flagging a missing security contact",
+		Run:         RunContactsModule,
+		Probes:      []ServiceProbe{ACCOUNT_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "control-tower",
+		Description: "Control Tower landing zone, enabled controls and accounts outside its baseline",
+		Run:         RunControlTowerModule,
+		Probes:      []ServiceProbe{CONTROL_TOWER_PROBE, ORGANIZATIONS_PROBE},
+	},
+	{
+		Name:        "flow-logs",
+		Description: "VPC flow logs, where they're delivered, and the VPCs, subnets and network interfaces without one",
+		Run:         RunFlowLogsModule,
+		Probes:      []ServiceProbe{EC2_PROBE},
+	},
+	{
+		Name:        "resolver",
+		Description: "Route 53 Resolver endpoints and forwarding rules, and the VPCs without DNS query logging",
+		Run:         RunResolverModule,
+		Probes:      []ServiceProbe{RESOLVER_PROBE, EC2_PROBE},
+	},
+	{
+		Name:        "rds-iam-auth",
+		Description: "Databases with IAM authentication the current principal can connect to, with auth tokens and connection commands",
+		Run:         RunRDSAuthModule,
+		Probes:      []ServiceProbe{RDS_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "db-passwords",
+		Description: "RDS databases and ElastiCache clusters, how they authenticate, and the secrets and parameters that look like their credentials",
+		Run:         RunDBPasswordsModule,
+		Probes:      []ServiceProbe{RDS_PROBE, ELASTICACHE_PROBE, SECRETS_MANAGER_PROBE, SSM_PROBE},
+	},
+	{
+		Name:        "snapshot-sharing",
+		Description: "Manual RDS and EBS snapshots the current principal could share with another account or make public",
+		Run:         RunSnapshotSharingModule,
+		Probes:      []ServiceProbe{EC2_PROBE, RDS_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "mfa",
+		Description: "MFA for the root user and every IAM user, and virtual MFA devices nobody is using",
+		Run:         RunMFAModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "naming",
+		Description: "Environments and applications inferred from the names and tags of users, groups, roles and instances",
+		Run:         RunNamingModule,
+		Probes:      []ServiceProbe{IAM_PROBE, EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "bedrock",
+		Description: "Bedrock model access, provisioned throughput, custom models, agents and their roles, and knowledge base data sources",
+		Run:         RunBedrockModule,
+		Probes:      []ServiceProbe{BEDROCK_PROBE, BEDROCK_AGENT_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "ecs-exec",
+		Description: "ECS and Batch containers with ECS Exec enabled that the current principal can open a shell in",
+		Run:         RunECSExecModule,
+		Probes:      []ServiceProbe{ECS_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "edge-functions",
+		Description: "CloudFront Functions and Lambda@Edge functions, the distributions they run on, and with --download-code their code",
+		Run:         RunEdgeFunctionsModule,
+		Probes:      []ServiceProbe{CLOUDFRONT_PROBE, LAMBDA_PROBE},
+	},
+	{
+		Name:        "messaging",
+		Description: "SES sending quota and production access, SNS SMS spend limit and sandbox status, and whether the current principal can send",
+		Run:         RunMessagingModule,
+		Probes:      []ServiceProbe{SES_PROBE, SNS_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "access-advisor",
+		Description: "Service last accessed data for every user and role, flagging services they're granted but have never used",
+		Run:         RunAccessAdvisorModule,
+		Probes:      []ServiceProbe{IAM_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "s3-logging",
+		Description: "Whether object reads and writes in each bucket are recorded by server access logging or CloudTrail data events",
+		Run:         RunS3LoggingModule,
+		Probes:      []ServiceProbe{S3_PROBE, CLOUDTRAIL_PROBE},
+	},
+	{
+		Name:        "timeline",
+		Description: "Activity profile of the current principal, from IAM, the credential report and recent CloudTrail events, oldest first",
+		Run:         RunTimelineModule,
+		Probes:      []ServiceProbe{CLOUDTRAIL_PROBE, IAM_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "trails",
+		Description: "CloudTrail trails recording the account, flagging organization trails and logs delivered to a central logging account",
+		Run:         RunTrailsModule,
+		Probes:      []ServiceProbe{CLOUDTRAIL_PROBE, S3_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "s3",
+		Description: "S3 buckets with their policy, ACL, public access block, encryption, versioning and website hosting, flagging anything anyone can read or write",
+		Run:         RunS3Module,
+		Probes:      []ServiceProbe{S3_PROBE, S3_CONTROL_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "rolesanywhere",
+		Description: "IAM Roles Anywhere trust anchors and profiles, listing which external CAs can exchange certificates for which roles' credentials",
+		Run:         RunRolesAnywhereModule,
+		Probes:      []ServiceProbe{ROLES_ANYWHERE_PROBE, IAM_PROBE},
+	},
+	{
+		Name:        "imagebuilder",
+		Description: "EC2 Image Builder pipelines, the account's components scanned for secrets, and distribution configurations sharing AMIs with other accounts",
+		Run:         RunImageBuilderModule,
+		Probes:      []ServiceProbe{IMAGE_BUILDER_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "ec2",
+		Description: "EC2 instances with their instance profile, public IP, key pair and IMDS settings, security groups and key pairs",
+		Run:         RunEC2Module,
+		Probes:      []ServiceProbe{EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "codeartifact",
+		Description: "CodeArtifact domains and repositories with their policies and upstreams, flagging repositories anyone or other accounts can publish to",
+		Run:         RunCodeArtifactModule,
+		Probes:      []ServiceProbe{CODEARTIFACT_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "user-data",
+		Description: "User data of every instance and launch template version, decoded and scanned for secrets",
+		Run:         RunUserDataModule,
+		Probes:      []ServiceProbe{EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "signer",
+		Description: "Signer profiles and who can use them, Lambda code signing configurations, and which functions enforce code signing",
+		Run:         RunSignerModule,
+		Probes:      []ServiceProbe{SIGNER_PROBE, LAMBDA_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "public-snapshots",
+		Description: "Owned AMIs and EBS and RDS snapshots that are public or shared with other accounts, and the region's block public access settings",
+		Run:         RunPublicSnapshotsModule,
+		Probes:      []ServiceProbe{EC2_PROBE, RDS_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "lambda",
+		Description: "Lambda functions with their runtime, execution role, environment variables scanned for secrets, resource policy, function URLs and with --download-code their code",
+		Run:         RunLambdaModule,
+		Probes:      []ServiceProbe{LAMBDA_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "device-auth",
+		Description: "Identity Center OIDC client registrations, device authorizations and tokens from CloudTrail, flagging signs of device code phishing",
+		Run:         RunDeviceAuthModule,
+		Probes:      []ServiceProbe{CLOUDTRAIL_PROBE, IDENTITY_CENTER_PROBE},
+	},
+	{
+		Name:        "secrets",
+		Description: "Secrets Manager secrets with their resource policy, KMS key and rotation state, and with --retrieve-values the values the caller can read",
+		Run:         RunSecretsManagerModule,
+		Probes:      []ServiceProbe{SECRETS_MANAGER_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "parameters",
+		Description: "SSM Parameter Store parameters with their type and KMS key, flagging credential-like names, and with --with-decryption the values the caller can read",
+		Run:         RunParametersModule,
+		Probes:      []ServiceProbe{SSM_PROBE},
+	},
+	{
+		Name:        "kms",
+		Description: "KMS keys with their aliases, key policy, rotation and grants, flagging keys other accounts or any principal can use",
+		Run:         RunKMSModule,
+		Probes:      []ServiceProbe{KMS_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "untagged",
+		Description: "Users, roles, instances and tagged resources of every service missing the tags given with --required-tags, grouped by service and region",
+		Run:         RunUntaggedModule,
+		Probes:      []ServiceProbe{TAGGING_PROBE, IAM_PROBE, EC2_PROBE},
+		DependsOn:   []string{"inventory"},
+	},
+	{
+		Name:        "sqs",
+		Description: "SQS queues, their encryption and dead-letter queues, and queue policies that let in any AWS principal or other accounts",
+		Run:         RunSQSModule,
+		Probes:      []ServiceProbe{SQS_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "security-admins",
+		Description: "Delegated administrators for GuardDuty, Security Hub and other security services, and where their findings aggregate in each region",
+		Run:         RunSecurityAdminsModule,
+		Probes:      []ServiceProbe{GUARDDUTY_PROBE, SECURITY_HUB_PROBE, ORGANIZATIONS_PROBE, STS_PROBE},
+	},
+	{
+		Name:        "sns",
+		Description: "SNS topics, topic policies that let in any AWS principal or other accounts, and subscriptions delivering over plain HTTP or to other accounts",
+		Run:         RunSNSModule,
+		Probes:      []ServiceProbe{SNS_PROBE, STS_PROBE},
+	},
+}
+
+func SelectModules(names string) ([]Module, error) {
+	// Look up each requested module by name, keeping the order they were given in
+	var selected []Module
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, module := range MODULES {
+			if module.Name == name {
+				selected = append(selected, module)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown module %q", name)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no modules selected")
+	}
+
+	return selected, nil
+}
+
+func ProbeIAM(ctx context.Context, sdkConfig aws.Config) error {
+	// get-user without a name fails for roles, so the probe lists users instead
+	// i.e. aws iam list-users --max-items 1
+	_, err := iam.NewFromConfig(sdkConfig).ListUsers(ctx, &iam.ListUsersInput{MaxItems: aws.Int32(1)})
+	return err
+}
+
+func ProbeSTS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	_, err := sts.NewFromConfig(sdkConfig).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	return err
+}
+
+func ProbeIdentityCenter(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sso-admin list-instances --max-results 1
+	_, err := ssoadmin.NewFromConfig(sdkConfig).ListInstances(ctx, &ssoadmin.ListInstancesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeControlTower(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws controltower list-landing-zones --max-results 1
+	_, err := controltower.NewFromConfig(sdkConfig).ListLandingZones(ctx, &controltower.ListLandingZonesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeOrganizations(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws organizations describe-organization
+	_, err := organizations.NewFromConfig(sdkConfig).DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	return err
+}
+
+func ProbeResolver(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws route53resolver list-resolver-endpoints --max-results 1
+	_, err := route53resolver.NewFromConfig(sdkConfig).ListResolverEndpoints(ctx, &route53resolver.ListResolverEndpointsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeRDS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws rds describe-db-instances --max-records 20
+	_, err := rds.NewFromConfig(sdkConfig).DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{MaxRecords: aws.Int32(20)})
+	return err
+}
+
+func ProbeElastiCache(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws elasticache describe-cache-clusters --max-records 20
+	_, err := elasticache.NewFromConfig(sdkConfig).DescribeCacheClusters(ctx, &elasticache.DescribeCacheClustersInput{MaxRecords: aws.Int32(20)})
+	return err
+}
+
+func ProbeQuotas(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws service-quotas list-services --max-results 1
+	_, err := servicequotas.NewFromConfig(sdkConfig).ListServices(ctx, &servicequotas.ListServicesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeAccount(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws account list-regions --max-results 1
+	_, err := account.NewFromConfig(sdkConfig).ListRegions(ctx, &account.ListRegionsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeLambda(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws lambda list-functions --max-items 1
+	_, err := lambda.NewFromConfig(sdkConfig).ListFunctions(ctx, &lambda.ListFunctionsInput{MaxItems: aws.Int32(1)})
+	return err
+}
+
+func ProbeScheduler(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws scheduler list-schedules --max-results 1
+	_, err := scheduler.NewFromConfig(sdkConfig).ListSchedules(ctx, &scheduler.ListSchedulesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeEventBridge(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws events list-rules --limit 1
+	_, err := eventbridge.NewFromConfig(sdkConfig).ListRules(ctx, &eventbridge.ListRulesInput{Limit: aws.Int32(1)})
+	return err
+}
+
+func ProbeSynthetics(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws synthetics describe-canaries --max-results 1
+	_, err := synthetics.NewFromConfig(sdkConfig).DescribeCanaries(ctx, &synthetics.DescribeCanariesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeBedrock(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws bedrock list-custom-models --max-results 1
+	_, err := bedrock.NewFromConfig(sdkConfig).ListCustomModels(ctx, &bedrock.ListCustomModelsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeBedrockAgent(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws bedrock-agent list-agents --max-results 1
+	_, err := bedrockagent.NewFromConfig(sdkConfig).ListAgents(ctx, &bedrockagent.ListAgentsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeECS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws ecs list-clusters --max-results 1
+	_, err := ecs.NewFromConfig(sdkConfig).ListClusters(ctx, &ecs.ListClustersInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeCloudFront(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws cloudfront list-distributions --max-items 1
+	_, err := cloudfront.NewFromConfig(sdkConfig).ListDistributions(ctx, &cloudfront.ListDistributionsInput{MaxItems: aws.Int32(1)})
+	return err
+}
+
+func ProbeSES(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sesv2 get-account
+	_, err := sesv2.NewFromConfig(sdkConfig).GetAccount(ctx, &sesv2.GetAccountInput{})
+	return err
+}
+
+func ProbeS3(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws s3api list-buckets --max-buckets 1
+	_, err := s3.NewFromConfig(sdkConfig).ListBuckets(ctx, &s3.ListBucketsInput{MaxBuckets: aws.Int32(1)})
+	return err
+}
+
+func ProbeS3Control(ctx context.Context, sdkConfig aws.Config) error {
+	// The endpoint is per account, so the account ID is looked up first
+	// i.e. aws s3control list-access-points --account-id <account-id> --max-results 1
+	callerIdentity, err := sts.NewFromConfig(sdkConfig).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return err
+	}
+	_, err = s3control.NewFromConfig(sdkConfig).ListAccessPoints(ctx, &s3control.ListAccessPointsInput{AccountId: callerIdentity.Account, MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeCloudTrail(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws cloudtrail lookup-events --max-results 1
+	_, err := cloudtrail.NewFromConfig(sdkConfig).LookupEvents(ctx, &cloudtrail.LookupEventsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeRolesAnywhere(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws rolesanywhere list-trust-anchors --page-size 1
+	_, err := rolesanywhere.NewFromConfig(sdkConfig).ListTrustAnchors(ctx, &rolesanywhere.ListTrustAnchorsInput{PageSize: aws.Int32(1)})
+	return err
+}
+
+func ProbeImageBuilder(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws imagebuilder list-image-pipelines --max-results 1
+	_, err := imagebuilder.NewFromConfig(sdkConfig).ListImagePipelines(ctx, &imagebuilder.ListImagePipelinesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeEC2(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws ec2 describe-instances --max-results 5
+	_, err := ec2.NewFromConfig(sdkConfig).DescribeInstances(ctx, &ec2.DescribeInstancesInput{MaxResults: aws.Int32(5)})
+	return err
+}
+
+func ProbeCodeArtifact(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws codeartifact list-domains --max-results 1
+	_, err := codeartifact.NewFromConfig(sdkConfig).ListDomains(ctx, &codeartifact.ListDomainsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeSigner(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws signer list-signing-profiles --max-results 1
+	_, err := signer.NewFromConfig(sdkConfig).ListSigningProfiles(ctx, &signer.ListSigningProfilesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeSecretsManager(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws secretsmanager list-secrets --max-results 1
+	_, err := secretsmanager.NewFromConfig(sdkConfig).ListSecrets(ctx, &secretsmanager.ListSecretsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeSSM(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws ssm describe-parameters --max-results 1
+	_, err := ssm.NewFromConfig(sdkConfig).DescribeParameters(ctx, &ssm.DescribeParametersInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeKMS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws kms list-keys --limit 1
+	_, err := kms.NewFromConfig(sdkConfig).ListKeys(ctx, &kms.ListKeysInput{Limit: aws.Int32(1)})
+	return err
+}
+
+func ProbeTagging(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws resourcegroupstaggingapi get-resources --resources-per-page 1
+	_, err := resourcegroupstaggingapi.NewFromConfig(sdkConfig).GetResources(ctx, &resourcegroupstaggingapi.GetResourcesInput{ResourcesPerPage: aws.Int32(1)})
+	return err
+}
+
+func ProbeSQS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sqs list-queues --max-results 1
+	_, err := sqs.NewFromConfig(sdkConfig).ListQueues(ctx, &sqs.ListQueuesInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeGuardDuty(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws guardduty list-detectors --max-results 1
+	_, err := guardduty.NewFromConfig(sdkConfig).ListDetectors(ctx, &guardduty.ListDetectorsInput{MaxResults: aws.Int32(1)})
+	return err
+}
+
+func ProbeSecurityHub(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws securityhub describe-hub
+	_, err := securityhub.NewFromConfig(sdkConfig).DescribeHub(ctx, &securityhub.DescribeHubInput{})
+	return err
+}
+
+func ProbeSNS(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sns list-topics
+	_, err := sns.NewFromConfig(sdkConfig).ListTopics(ctx, &sns.ListTopicsInput{})
+	return err
+}
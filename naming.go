@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// How many resources have to share a name token before it's treated as an application or team
+const MIN_CLUSTER_SIZE = 2
+
+// Name tokens that mark an environment, keyed by the environment they're folded into
+var ENVIRONMENT_KEYWORDS = map[string][]string{
+	"prod":    {"prod", "production", "prd", "live"},
+	"staging": {"stage", "staging", "stg", "preprod", "uat"},
+	"dev":     {"dev", "development", "develop"},
+	"test":    {"test", "testing", "qa"},
+	"sandbox": {"sandbox", "sbx", "poc"},
+}
+
+// Tags that say outright what environment or application a resource belongs to, checked before its name
+var ENVIRONMENT_TAG_KEYS = []string{"environment", "env", "stage", "tier"}
+var APPLICATION_TAG_KEYS = []string{"application", "app", "project", "service", "team", "owner"}
+
+// Tokens too common in AWS resource names to say anything about who owns them
+var GENERIC_NAME_TOKENS = map[string]bool{
+	"aws": true, "role": true, "user": true, "group": true, "policy": true, "service": true,
+	"instance": true, "server": true, "ec2": true, "lambda": true, "admin": true, "default": true,
+	"iam": true, "access": true, "read": true, "readonly": true, "write": true, "execution": true,
+	"profile": true, "node": true, "worker": true, "web": true, "app": true, "the": true,
+}
+
+// A name, with its tags where the listing returns them, from the shared inventory
+type NamedResource struct {
+	Type        string            `json:"type"`
+	Name        string            `json:"name"`
+	Region      string            `json:"region,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+}
+
+type NamingClusterResult struct {
+	// Either "environment" or "application"
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	// Resource names in the cluster, i.e. role/payments-prod-deploy
+	Resources []string `json:"resources"`
+	// For applications, the environments their resources were seen in
+	Environments []string `json:"environments,omitempty"`
+}
+
+func RunNamingModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// list-users and list-roles don't return tags, so IAM principals are only grouped by name
+	// i.e. aws iam list-users, aws iam list-groups, aws iam list-roles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Inferring environments and applications from resource names and tags...")
+	fmt.Println(MAJOR_SEPARATOR)
+	var resources []NamedResource
+	if users, err := CachedUsers(ctx, iamClient); err == nil {
+		for _, user := range users {
+			resources = append(resources, NamedResource{Type: "user", Name: aws.ToString(user.UserName), Tags: IAMTags(user.Tags)})
+		}
+	}
+	if groups, err := CachedGroups(ctx, iamClient); err == nil {
+		for _, group := range groups {
+			resources = append(resources, NamedResource{Type: "group", Name: aws.ToString(group.GroupName)})
+		}
+	}
+	if roles, err := CachedRoles(ctx, iamClient); err == nil {
+		for _, role := range roles {
+			// Service-linked roles are named by AWS, not by the account's owners
+			if strings.HasPrefix(aws.ToString(role.Path), "/aws-service-role/") {
+				continue
+			}
+			resources = append(resources, NamedResource{Type: "role", Name: aws.ToString(role.RoleName), Tags: IAMTags(role.Tags)})
+		}
+	}
+
+	// i.e. aws ec2 describe-instances
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+		for _, instance := range instances {
+			name := InstanceName(instance.Tags)
+			if name == "" {
+				name = aws.ToString(instance.InstanceId)
+			}
+			resources = append(resources, NamedResource{Type: "instance", Name: name, Region: regionalConfig.Region, Tags: EC2Tags(instance.Tags)})
+		}
+
+		return nil
+	})
+
+	environments := map[string][]string{}
+	unassigned := 0
+	for i := range resources {
+		resource := &resources[i]
+		resource.Environment = InferEnvironment(resource.Name, resource.Tags)
+		if resource.Environment == "" {
+			unassigned++
+			continue
+		}
+		environments[resource.Environment] = append(environments[resource.Environment], resource.Type+"/"+resource.Name)
+	}
+
+	fmt.Printf("\tResources: %v\n", len(resources))
+	fmt.Printf("\tWithout an inferred environment: %v\n", unassigned)
+	fmt.Println(MINOR_SEPARATOR)
+	for _, environment := range SortedKeys(environments) {
+		cluster := NamingClusterResult{Kind: "environment", Value: environment, Resources: environments[environment]}
+		fmt.Printf("\tEnvironment: %v (%v resources)\n", environment, len(cluster.Resources))
+		PrintClusterResources(cluster.Resources)
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("naming-cluster", "", cluster)
+	}
+
+	for _, cluster := range ApplicationClusters(resources) {
+		fmt.Printf("\tApplication or team: %v (%v resources)\n", cluster.Value, len(cluster.Resources))
+		if len(cluster.Environments) > 0 {
+			fmt.Printf("\tEnvironments: %v\n", strings.Join(cluster.Environments, ", "))
+		}
+		PrintClusterResources(cluster.Resources)
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("naming-cluster", "", cluster)
+	}
+	if len(environments) > 0 && EnvironmentFlag == "" {
+		fmt.Printf("\tRe-run with --environment %v to only enumerate one of them\n", SortedKeys(environments)[0])
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func PrintClusterResources(resources []string) {
+	// Long clusters are cut short in the text output, the structured results have every name
+	const shown = 10
+	for _, resource := range resources[:min(shown, len(resources))] {
+		fmt.Printf("\t\t%v\n", resource)
+	}
+	if len(resources) > shown {
+		fmt.Printf("\t\t... and %v more\n", len(resources)-shown)
+	}
+}
+
+func ApplicationClusters(resources []NamedResource) []NamingClusterResult {
+	// A tagged application wins, otherwise every distinctive name token shared by enough resources is a cluster
+	members := map[string][]string{}
+	environments := map[string][]string{}
+	for _, resource := range resources {
+		var applications []string
+		if application := TagValue(resource.Tags, APPLICATION_TAG_KEYS); application != "" {
+			applications = []string{strings.ToLower(application)}
+		} else {
+			for _, token := range NameTokens(resource.Name) {
+				if IsDistinctiveToken(token) {
+					applications = AppendMissing(applications, token)
+				}
+			}
+		}
+		for _, application := range applications {
+			members[application] = append(members[application], resource.Type+"/"+resource.Name)
+			if resource.Environment != "" {
+				environments[application] = AppendMissing(environments[application], resource.Environment)
+			}
+		}
+	}
+
+	var clusters []NamingClusterResult
+	for _, application := range SortedKeys(members) {
+		if len(members[application]) < MIN_CLUSTER_SIZE {
+			continue
+		}
+		clusters = append(clusters, NamingClusterResult{
+			Kind:         "application",
+			Value:        application,
+			Resources:    members[application],
+			Environments: environments[application],
+		})
+	}
+	// The biggest clusters first, they're the ones most likely to be real naming conventions
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Resources) > len(clusters[j].Resources)
+	})
+
+	return clusters
+}
+
+func InferEnvironment(name string, tags map[string]string) string {
+	// i.e. Environment=Production, or the prd in payments-prd-api
+	if value := TagValue(tags, ENVIRONMENT_TAG_KEYS); value != "" {
+		if environment := EnvironmentForToken(strings.ToLower(value)); environment != "" {
+			return environment
+		}
+		return strings.ToLower(value)
+	}
+	for _, token := range NameTokens(name) {
+		if environment := EnvironmentForToken(token); environment != "" {
+			return environment
+		}
+	}
+
+	return ""
+}
+
+func EnvironmentForToken(token string) string {
+	for environment, keywords := range ENVIRONMENT_KEYWORDS {
+		for _, keyword := range keywords {
+			if token == keyword {
+				return environment
+			}
+		}
+	}
+
+	return ""
+}
+
+func IsDistinctiveToken(token string) bool {
+	if len(token) < 3 || GENERIC_NAME_TOKENS[token] || EnvironmentForToken(token) != "" {
+		return false
+	}
+	// Numbers and IDs, i.e. the 01 in web-01 or the 0abc123 of an unnamed instance's i-0abc123
+	return unicode.IsLetter([]rune(token)[0])
+}
+
+func NameTokens(name string) []string {
+	// Split on punctuation and camel case, i.e. PaymentsProd-api_v2 is payments, prod, api, v2
+	var tokens []string
+	var current []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(current) > 0 {
+				tokens = append(tokens, strings.ToLower(string(current)))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			tokens = append(tokens, strings.ToLower(string(current)))
+			current = nil
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		tokens = append(tokens, strings.ToLower(string(current)))
+	}
+
+	return tokens
+}
+
+func TagValue(tags map[string]string, keys []string) string {
+	// Tag keys are matched whatever their case, i.e. Environment, environment or ENV
+	for key, value := range tags {
+		for _, wanted := range keys {
+			if strings.EqualFold(key, wanted) && value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+func IAMTags(tags []iamtypes.Tag) map[string]string {
+	converted := map[string]string{}
+	for _, tag := range tags {
+		converted[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return converted
+}
+
+func EC2Tags(tags []ec2types.Tag) map[string]string {
+	converted := map[string]string{}
+	for _, tag := range tags {
+		converted[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return converted
+}
+
+func InEnvironment(name string, tags map[string]string) bool {
+	// Everything is in scope unless --environment was given
+	if EnvironmentFlag == "" {
+		return true
+	}
+	wanted := strings.ToLower(EnvironmentFlag)
+	if environment := EnvironmentForToken(wanted); environment != "" {
+		wanted = environment
+	}
+
+	return InferEnvironment(name, tags) == wanted
+}
+
+func ScopeToEnvironment[T any](items []T, describe func(item T) (string, map[string]string)) []T {
+	// Applied as the shared inventory is filled, so every module working from it is scoped the same way
+	if EnvironmentFlag == "" {
+		return items
+	}
+	var scoped []T
+	for _, item := range items {
+		if InEnvironment(describe(item)) {
+			scoped = append(scoped, item)
+		}
+	}
+
+	return scoped
+}
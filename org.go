@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+)
+
+// Role assumed into every member account by org-scan, set with --audit-role. Empty outside of org-scan
+var OrgAuditRole = ""
+
+// How many findings were emitted for each account, the empty string being the account the credentials are in
+var FindingCounts = map[string]int{}
+
+type FindingRollup struct {
+	Accounts map[string]int `json:"accounts"`
+	Total    int            `json:"total"`
+}
+
+func NewOrgScanCommand() *cobra.Command {
+	var modulesFlag string
+	var auditRole string
+	orgScanCommand := &cobra.Command{
+		Use:   "org-scan",
+		Short: "Run every module, or the ones given with --modules, in every account of the organization",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selectedModules := MODULES
+			if modulesFlag != "" {
+				var err error
+				selectedModules, err = SelectModules(modulesFlag)
+				if err != nil {
+					return err
+				}
+			}
+			OrgAuditRole = auditRole
+
+			return RunModules(cmd.Context(), selectedModules)
+		},
+	}
+
+	orgScanCommand.Flags().StringVar(&modulesFlag, "modules", "", "Comma-separated list of modules to run (default is every module)")
+	orgScanCommand.Flags().StringVar(&auditRole, "audit-role", "OrganizationAccountAccessRole", "Name of the role to assume in each member account")
+	AddRunFlags(orgScanCommand)
+
+	return orgScanCommand
+}
+
+func OrgAccountTargets(ctx context.Context, auditRole string) ([]ScanTarget, error) {
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account the credentials are already in is scanned with them as they are
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the list-accounts API, which only works from the management account or a delegated administrator
+	// i.e. aws organizations list-accounts
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the accounts in the organization...")
+	fmt.Println(MAJOR_SEPARATOR)
+	orgClient := organizations.NewFromConfig(sdkConfig)
+	accounts, err := ListOrganizationAccounts(ctx, orgClient)
+	if err != nil {
+		fmt.Println("Couldn't list the accounts in the organization. Are these credentials in the management or a delegated administrator account?")
+		return nil, err
+	}
+
+	// SCPs never apply to the management account
+	// i.e. aws organizations describe-organization
+	organization, err := orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		fmt.Printf("Couldn't describe the organization. Here's why: %v\n", err)
+		return nil, err
+	}
+	managementAccount := aws.ToString(organization.Organization.MasterAccountId)
+	scpCache := map[string]*PolicyDocument{}
+
+	var targets []ScanTarget
+	for _, account := range accounts {
+		fmt.Printf("\tAccount: %v (%v, %v)\n", *account.Id, aws.ToString(account.Name), account.State)
+		if account.State != orgtypes.AccountStateActive {
+			continue
+		}
+
+		accountId := *account.Id
+		target := ScanTarget{
+			Name:    accountId,
+			Account: accountId,
+			LoadConfig: func(ctx context.Context) (aws.Config, error) {
+				// Kept in the store so the analyses can take the account's SCPs into account
+				if accountId != managementAccount {
+					layers, err := GetAccountSCPs(ctx, orgClient, scpCache, accountId)
+					if err == nil {
+						Store.Put("org:scps", layers)
+						fmt.Printf("\tSCP levels above the account: %v\n", len(layers))
+					}
+				}
+
+				if accountId == *callerIdentity.Account {
+					return sdkConfig, nil
+				}
+
+				// i.e. aws sts assume-role --role-arn arn:aws:iam::<account>:role/<audit-role>
+				roleArn := fmt.Sprintf("arn:%v:iam::%v:role/%v", CallerPartition(*callerIdentity.Arn), accountId, auditRole)
+				accountConfig := AssumeRoleConfig(sdkConfig, roleArn, "", SessionNameFlag)
+				if _, err := GetCallerIdentity(ctx, sts.NewFromConfig(accountConfig)); err != nil {
+					fmt.Printf("Couldn't assume %v, skipping account %v\n", roleArn, accountId)
+					return aws.Config{}, err
+				}
+
+				return accountConfig, nil
+			},
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func ListOrganizationAccounts(ctx context.Context, orgClient *organizations.Client) ([]orgtypes.Account, error) {
+	var accounts []orgtypes.Account
+	paginator := organizations.NewListAccountsPaginator(orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(accounts)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the accounts. Here's why: %v\n", err)
+			return nil, err
+		}
+		accounts = append(accounts, page.Accounts...)
+	}
+
+	return LimitItems(accounts), nil
+}
+
+func AssumeRoleConfig(sdkConfig aws.Config, roleArn string, externalId string, sessionName string) aws.Config {
+	// The credentials are fetched on the first call and refreshed before they expire
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(sdkConfig), roleArn, func(options *stscreds.AssumeRoleOptions) {
+		options.RoleSessionName = "aws-enumerator"
+		if sessionName != "" {
+			options.RoleSessionName = sessionName
+		}
+		if externalId != "" {
+			options.ExternalID = aws.String(externalId)
+		}
+	})
+
+	assumedConfig := sdkConfig.Copy()
+	assumedConfig.Credentials = aws.NewCredentialsCache(provider)
+	return assumedConfig
+}
+
+func PrintFindingRollup() {
+	rollup := FindingRollup{Accounts: map[string]int{}}
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Findings by account:")
+	for _, account := range SortedKeys(FindingCounts) {
+		fmt.Printf("\t%v: %v\n", account, FindingCounts[account])
+		rollup.Accounts[account] = FindingCounts[account]
+		rollup.Total += FindingCounts[account]
+	}
+	fmt.Printf("\tOrganization total: %v\n", rollup.Total)
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("finding-rollup", "", rollup)
+}
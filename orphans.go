@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// A role with its trust policy decoded, in place of the URL-encoded string from the API
+type RoleResult struct {
+	Role        iamtypes.Role    `json:"role"`
+	TrustPolicy *PolicyDocument  `json:"trustPolicy,omitempty"`
+	Permissions *RolePermissions `json:"permissions,omitempty"`
+	// list-roles leaves the permission boundary out, so it's only filled in by the roles module
+	Boundary         string          `json:"boundary,omitempty"`
+	BoundaryDocument *PolicyDocument `json:"boundaryDocument,omitempty"`
+}
+
+func RunOrphansModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-roles API and check each trust policy for principals that no longer exist
+	// i.e. aws iam list-roles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking role trust policies for deleted principals...")
+	fmt.Println(MAJOR_SEPARATOR)
+	roles, err := CachedRoles(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list roles. Exiting...")
+		return err
+	}
+
+	found := 0
+	for _, role := range roles {
+		if role.AssumeRolePolicyDocument == nil {
+			continue
+		}
+		trustPolicy, err := ParsePolicyDocument(*role.AssumeRolePolicyDocument)
+		if err != nil {
+			fmt.Printf("Couldn't parse the trust policy for %v. Here's why: %v\n", *role.RoleName, err)
+			continue
+		}
+
+		unresolved := FindUnresolvedPrincipals(trustPolicy)
+		if len(unresolved) == 0 {
+			continue
+		}
+
+		found++
+		fmt.Printf("\tRole name: %v\n", *role.RoleName)
+		fmt.Printf("\tRole ARN: %v\n", *role.Arn)
+		for _, principal := range unresolved {
+			fmt.Printf("\t[!] Trusts deleted principal %v\n", principal)
+			EmitFinding("", *role.Arn, fmt.Sprintf("Trusts deleted principal %v", principal))
+		}
+		Emit("role", "", RoleResult{Role: role, TrustPolicy: trustPolicy})
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	// A recreated user or role with the same name gets a new ID, so these entries
+	// can't be reused directly, but they show the trust was never cleaned up and
+	// whoever fixes it by re-adding the name may grant access to someone new
+	if found == 0 {
+		fmt.Println("\tNo trust policies reference deleted principals")
+	} else {
+		fmt.Printf("%v role(s) trust deleted principals. Re-adding them by name would trust whoever now owns that name.\n", found)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func ListAllRoles(ctx context.Context, iamClient *iam.Client) ([]iamtypes.Role, error) {
+	// Get every role in the account
+	var roles []iamtypes.Role
+	paginator := iam.NewListRolesPaginator(iamClient, &iam.ListRolesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(roles)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the roles. Here's why: %v\n", err)
+			return nil, err
+		}
+		roles = append(roles, page.Roles...)
+	}
+
+	return LimitItems(roles), nil
+}
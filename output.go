@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// How results are written, set with --output
+var OutputFormat = "text"
+
+// Where structured results are written. Text output moves to stderr when this is stdout
+var ResultsOutput io.Writer = os.Stdout
+
+// Whether ndjson results are written as soon as they're emitted instead of at the end, set with --stream
+var StreamResults = false
+
+// The module currently running, recorded against everything it emits. This and the profile
+// and account below belong to the run loop, which only changes them between modules, so the
+// goroutines of a running module can read them without a lock
+var CurrentModule = ""
+
+// The profile currently being scanned with --all-profiles, recorded against everything emitted for it
+var CurrentProfile = ""
+
+// The member account currently being scanned with org-scan, recorded against everything emitted for it
+var CurrentAccount = ""
+
+// A single enumerated object, i.e. a user, a group or a policy document
+type Result struct {
+	// The output version and the build that wrote it, only on ndjson lines from version 2 on
+	Version string      `json:"version,omitempty"`
+	Build   *BuildStamp `json:"build,omitempty"`
+	Profile string      `json:"profile,omitempty"`
+	Account string      `json:"account,omitempty"`
+	Module  string      `json:"module"`
+	Region  string      `json:"region,omitempty"`
+	Type    string      `json:"type"`
+	Data    any         `json:"data"`
+}
+
+// Something worth a closer look, the [!] and [-] lines of the text output
+type Finding struct {
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+	// Who can reach it, internet-facing, cross-account or internal
+	Exposure string `json:"exposure"`
+	// The CLI command and Terraform that fix it, for findings the rule catalog knows
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+type Report struct {
+	// The output version and the build that wrote it, from version 2 on
+	Version string      `json:"version,omitempty"`
+	Build   *BuildStamp `json:"build,omitempty"`
+	Results []Result    `json:"results"`
+}
+
+// Results waiting to be written at the end of the run. They're spooled to a temporary
+// file as newline-delimited JSON rather than kept in memory, so large accounts don't
+// hold every result at once
+var ResultsSpool *os.File
+var resultsSpoolWriter *bufio.Writer
+
+// Guards the spool, streamed result lines, FindingCounts and AttackSurface, so modules can
+// emit from any goroutine
+var resultsMutex sync.Mutex
+
+func SetOutputFormat(format string, stream bool) error {
+	switch format {
+	case "text":
+	case "json", "ndjson":
+		// Keep stdout for the results alone so they can be piped straight into jq
+		ResultsOutput = os.Stdout
+		os.Stdout = os.Stderr
+	default:
+		return fmt.Errorf("unknown output format %q, expected text, json or ndjson", format)
+	}
+
+	// A single JSON document can't be written until everything is in it
+	if stream && format != "ndjson" {
+		return fmt.Errorf("--stream needs --output ndjson")
+	}
+
+	OutputFormat = format
+	StreamResults = stream
+	return nil
+}
+
+func StructuredOutput() bool {
+	return OutputFormat != "text"
+}
+
+func Emit(resultType string, region string, data any) {
+	// Results are only collected when they're going to be written out
+	if !StructuredOutput() {
+		return
+	}
+
+	result := VersionedResult(Result{
+		Profile: CurrentProfile,
+		Account: CurrentAccount,
+		Module:  CurrentModule,
+		Region:  region,
+		Type:    resultType,
+		Data:    data,
+	})
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+	if StreamResults {
+		WriteResultLine(result)
+		return
+	}
+	SpoolResult(result)
+}
+
+func SpoolResult(result Result) error {
+	// Called with resultsMutex held
+	if ResultsSpool == nil {
+		spool, err := os.CreateTemp("", "aws-enumerator-results-*.ndjson")
+		if err != nil {
+			fmt.Printf("Couldn't create the results spool file. Here's why: %v\n", err)
+			return err
+		}
+		ResultsSpool = spool
+		resultsSpoolWriter = bufio.NewWriter(spool)
+	}
+
+	if err := json.NewEncoder(resultsSpoolWriter).Encode(result); err != nil {
+		fmt.Printf("Couldn't spool the result. Here's why: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func ForEachSpooledResult(handle func(line []byte) error) error {
+	// Read the spooled results back one line at a time, in the order they were emitted
+	if ResultsSpool == nil {
+		return nil
+	}
+	if err := resultsSpoolWriter.Flush(); err != nil {
+		return err
+	}
+	if _, err := ResultsSpool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(ResultsSpool)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := handle(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func RemoveResultsSpool() {
+	if ResultsSpool == nil {
+		return
+	}
+	ResultsSpool.Close()
+	os.Remove(ResultsSpool.Name())
+	ResultsSpool = nil
+	resultsSpoolWriter = nil
+}
+
+func WriteResultLine(result Result) error {
+	// One result per line, i.e. newline-delimited JSON. Called with resultsMutex held
+	if err := json.NewEncoder(ResultsOutput).Encode(result); err != nil {
+		fmt.Printf("Couldn't write the result. Here's why: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func EmitFinding(region string, resource string, message string) {
+	// Most findings need a foothold in the account first
+	EmitExposedFinding(region, resource, EXPOSURE_INTERNAL, message)
+}
+
+func EmitExposedFinding(region string, resource string, exposure string, message string) {
+	finding := Finding{Resource: resource, Message: message, Exposure: exposure}
+	finding.Remediation = FindRemediation(region, resource, message)
+	PrintRemediation(finding.Remediation)
+	resultsMutex.Lock()
+	FindingCounts[CurrentAccount]++
+	RecordFinding(Result{
+		Profile: CurrentProfile,
+		Account: CurrentAccount,
+		Module:  CurrentModule,
+		Region:  region,
+		Type:    "finding",
+		Data:    finding,
+	})
+	resultsMutex.Unlock()
+	Emit("finding", region, finding)
+}
+
+func WriteResults() error {
+	if !StructuredOutput() || StreamResults {
+		return nil
+	}
+
+	// Everything's been emitted by now, the lock only keeps a straggling goroutine from writing mid-report
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+	defer RemoveResultsSpool()
+
+	// The spooled lines are already ndjson, so they're copied across as they are
+	if OutputFormat == "ndjson" {
+		err := ForEachSpooledResult(func(line []byte) error {
+			_, err := ResultsOutput.Write(line)
+			return err
+		})
+		if err != nil {
+			fmt.Printf("Couldn't write the results. Here's why: %v\n", err)
+		}
+		return err
+	}
+
+	// For json the Report is written a result at a time, laid out as if it had been
+	// encoded whole
+	output := bufio.NewWriter(ResultsOutput)
+	output.WriteString("{\n")
+	if OutputVersion != "1" {
+		fmt.Fprintf(output, "  \"version\": %q,\n", OutputVersion)
+		build, _ := json.MarshalIndent(CurrentBuildStamp(), "  ", "  ")
+		fmt.Fprintf(output, "  \"build\": %s,\n", build)
+	}
+	output.WriteString("  \"results\": [")
+	first := true
+	err := ForEachSpooledResult(func(line []byte) error {
+		if first {
+			output.WriteString("\n    ")
+			first = false
+		} else {
+			output.WriteString(",\n    ")
+		}
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, bytes.TrimSpace(line), "    ", "  "); err != nil {
+			return err
+		}
+		_, err := output.Write(indented.Bytes())
+		return err
+	})
+	if err == nil {
+		if !first {
+			output.WriteString("\n  ")
+		}
+		output.WriteString("]\n}\n")
+		err = output.Flush()
+	}
+	if err != nil {
+		fmt.Printf("Couldn't write the results. Here's why: %v\n", err)
+		return err
+	}
+
+	return nil
+}
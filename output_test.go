@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func useStructuredOutput(t testing.TB, format string) *bytes.Buffer {
+	var output bytes.Buffer
+	previousFormat, previousOutput := OutputFormat, ResultsOutput
+	OutputFormat, ResultsOutput = format, &output
+	t.Cleanup(func() {
+		RemoveResultsSpool()
+		OutputFormat, ResultsOutput = previousFormat, previousOutput
+	})
+
+	return &output
+}
+
+func useFindingTallies(t testing.TB) {
+	previousCounts, previousSurface := FindingCounts, AttackSurface
+	FindingCounts, AttackSurface = map[string]int{}, map[string][]Result{}
+	t.Cleanup(func() {
+		FindingCounts, AttackSurface = previousCounts, previousSurface
+	})
+}
+
+func useOutputVersion(t testing.TB, version string) {
+	previousVersion := OutputVersion
+	if err := SetOutputVersion(version); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		OutputVersion = previousVersion
+	})
+}
+
+func TestWriteResultsMatchesReport(t *testing.T) {
+	output := useStructuredOutput(t, "json")
+
+	CurrentModule = "users"
+	var expected []Result
+	for i := 0; i < 3; i++ {
+		result := Result{Module: "users", Type: "user", Data: map[string]any{"name": fmt.Sprintf("user-%v", i)}}
+		expected = append(expected, result)
+		Emit(result.Type, result.Region, result.Data)
+	}
+	CurrentModule = ""
+
+	var want bytes.Buffer
+	encoder := json.NewEncoder(&want)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(Report{Version: OutputVersion, Build: CurrentBuildStamp(), Results: expected})
+
+	if err := WriteResults(); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != want.String() {
+		t.Errorf("got\n%v\nwant\n%v", output.String(), want.String())
+	}
+}
+
+func TestWriteResultsEmpty(t *testing.T) {
+	build := CurrentBuildStamp()
+	for version, want := range map[string]string{
+		"1": "{\n  \"results\": []\n}\n",
+		"2": fmt.Sprintf("{\n  \"version\": \"2\",\n  \"build\": {\n    \"version\": %q,\n    \"commit\": %q,\n    \"ruleCatalogVersion\": %q\n  },\n  \"results\": []\n}\n",
+			build.Version, build.Commit, build.RuleCatalogVersion),
+	} {
+		output := useStructuredOutput(t, "json")
+		useOutputVersion(t, version)
+
+		if err := WriteResults(); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != want {
+			t.Errorf("version %v: got %q", version, output.String())
+		}
+	}
+}
+
+func TestEmitOlderOutputVersion(t *testing.T) {
+	// Automation built against version 1 never sees the version or build fields on an ndjson line
+	output := useStructuredOutput(t, "ndjson")
+	useOutputVersion(t, "1")
+
+	Emit("user", "", map[string]any{"name": "user-0"})
+	if err := WriteResults(); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != `{"module":"","type":"user","data":{"name":"user-0"}}`+"\n" {
+		t.Errorf("got %q", output.String())
+	}
+}
+
+func TestOutputSchemaCoversFindings(t *testing.T) {
+	content, err := LoadOutputSchema(OUTPUT_SCHEMA_VERSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Version string `json:"version"`
+		Defs    map[string]struct {
+			Required   []string       `json:"required"`
+			Properties map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Version != OUTPUT_SCHEMA_VERSION {
+		t.Errorf("schema is version %v, want %v", schema.Version, OUTPUT_SCHEMA_VERSION)
+	}
+
+	// Every field the result envelope, its build and a finding are written with has to be in the schema
+	output := useStructuredOutput(t, "ndjson")
+	CurrentModule = "roles"
+	EmitExposedFinding("us-east-1", "arn:aws:iam::123456789012:role/example", EXPOSURE_INTERNET, "Trust policy allows anyone to assume the role")
+	CurrentModule = ""
+	if err := WriteResults(); err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(output.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	build, ok := result["build"].(map[string]any)
+	if !ok {
+		t.Fatalf("ndjson line %v has no build", result)
+	}
+	for definition, fields := range map[string]map[string]any{"result": result, "finding": result["data"].(map[string]any), "build": build} {
+		for field := range fields {
+			if _, ok := schema.Defs[definition].Properties[field]; !ok {
+				t.Errorf("%v field %q isn't in the schema", definition, field)
+			}
+		}
+		for _, field := range schema.Defs[definition].Required {
+			if _, ok := fields[field]; !ok {
+				t.Errorf("required %v field %q wasn't written", definition, field)
+			}
+		}
+	}
+}
+
+func TestEmitConcurrently(t *testing.T) {
+	// Modules emitting from every region at once have to end up with every result and
+	// finding exactly once, spooled or streamed, i.e. go test -race
+	for _, stream := range []bool{false, true} {
+		output := useStructuredOutput(t, "ndjson")
+		useFindingTallies(t)
+		StreamResults = stream
+		CurrentAccount = "123456789012"
+		t.Cleanup(func() {
+			StreamResults = false
+			CurrentAccount = ""
+		})
+
+		regions := []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-2"}
+		const modules, resultsPerRegion = 4, 50
+		var wg sync.WaitGroup
+		for module := 0; module < modules; module++ {
+			for _, region := range regions {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < resultsPerRegion; i++ {
+						Emit("role", region, map[string]any{"module": module, "name": fmt.Sprintf("role-%v", i)})
+					}
+					EmitExposedFinding(region, fmt.Sprintf("arn:aws:iam::123456789012:role/module-%v", module), EXPOSURE_CROSS_ACCOUNT, "Trust policy allows another account to assume the role")
+				}()
+			}
+		}
+		wg.Wait()
+		if err := WriteResults(); err != nil {
+			t.Fatal(err)
+		}
+
+		findings := modules * len(regions)
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		if len(lines) != modules*len(regions)*resultsPerRegion+findings {
+			t.Errorf("stream %v: got %v results, want %v", stream, len(lines), modules*len(regions)*resultsPerRegion+findings)
+		}
+		for _, line := range lines {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("stream %v: interleaved result %q", stream, line)
+				break
+			}
+		}
+		if FindingCounts["123456789012"] != findings || len(AttackSurface[EXPOSURE_CROSS_ACCOUNT]) != findings {
+			t.Errorf("stream %v: counted %v findings and recorded %v, want %v", stream, FindingCounts["123456789012"], len(AttackSurface[EXPOSURE_CROSS_ACCOUNT]), findings)
+		}
+	}
+}
+
+// Emits a run's worth of results and reports how much heap is still in use once they've
+// all been emitted. It should stay flat as the number of results grows, i.e.
+//
+//	go test -run ^$ -bench BenchmarkEmit
+func BenchmarkEmit(b *testing.B) {
+	for _, count := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("results=%v", count), func(b *testing.B) {
+			useStructuredOutput(b, "json")
+			data := map[string]any{"arn": "arn:aws:iam::123456789012:role/example", "tags": []string{"a", "b", "c"}}
+
+			var heapInUse uint64
+			for n := 0; n < b.N; n++ {
+				for i := 0; i < count; i++ {
+					Emit("role", "us-east-1", data)
+				}
+
+				var stats runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&stats)
+				heapInUse = stats.HeapInuse
+				RemoveResultsSpool()
+			}
+			b.ReportMetric(float64(heapInUse)/1024, "heap-KiB")
+		})
+	}
+}
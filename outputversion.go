@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Version of the structured output to write, set with --output-version. Older versions are
+// written by undoing, result by result, what changed after them
+var OutputVersion = OUTPUT_SCHEMA_VERSION
+
+func SetOutputVersion(version string) error {
+	version = strings.TrimPrefix(version, "v")
+	if !slices.Contains(ListSchemaVersions(), version) {
+		return fmt.Errorf("unknown output version %q, available versions are %v", version, strings.Join(ListSchemaVersions(), ", "))
+	}
+
+	OutputVersion = version
+	return nil
+}
+
+func VersionedResult(result Result) Result {
+	// Version 1 had no version or build fields, everything else is the same
+	if OutputVersion == "1" {
+		return result
+	}
+
+	// ndjson lines are read on their own, so each one says which version it follows and which
+	// build wrote it. The json report says it once at the top instead
+	if OutputFormat == "ndjson" {
+		result.Version = OutputVersion
+		result.Build = CurrentBuildStamp()
+	}
+
+	return result
+}
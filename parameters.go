@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+)
+
+// Parameter names that usually hold a credential, matched against the last part of the path
+var SENSITIVE_PARAMETER_PATTERN = regexp.MustCompile(`(?i)(password|passwd|pwd|secret|token|credential|api_?key|access_?key|private_?key|(^|[-_.])key$)`)
+
+// Whether the parameters module reads the value of each parameter it can, set with --with-decryption
+var DecryptParameters = false
+
+type ParameterResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// String, StringList or SecureString
+	Type string `json:"type"`
+	Tier string `json:"tier,omitempty"`
+	// Only SecureString parameters have one, aws/ssm when none was given
+	KmsKey           string     `json:"kmsKey,omitempty"`
+	DataType         string     `json:"dataType,omitempty"`
+	Version          int64      `json:"version"`
+	LastModified     *time.Time `json:"lastModified,omitempty"`
+	LastModifiedUser string     `json:"lastModifiedUser,omitempty"`
+	// Whether the name suggests a password, token or key
+	SensitiveName bool `json:"sensitiveName"`
+	// Only with --with-decryption
+	Value *ParameterValueResult `json:"value,omitempty"`
+}
+
+type ParameterValueResult struct {
+	Readable bool `json:"readable"`
+	// Why it couldn't be read, i.e. AccessDeniedException
+	Error        string          `json:"error,omitempty"`
+	SavedTo      string          `json:"savedTo,omitempty"`
+	SecretsFound []SecretFinding `json:"secretsFound"`
+}
+
+func RunParametersModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ssmClient := ssm.NewFromConfig(regionalConfig)
+
+		// describe-parameters returns the names and KMS keys of SecureString parameters without decrypting
+		// anything, values are only read with --with-decryption
+		// i.e. aws ssm describe-parameters, aws ssm get-parameter --name <name> --with-decryption
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting SSM parameters in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		parameters, err := ListParameters(ctx, ssmClient)
+		if err != nil {
+			return err
+		}
+		results := make([]ParameterResult, len(parameters))
+		ForEachConcurrently(ctx, len(parameters), func(ctx context.Context, i int) {
+			results[i] = GetParameterResult(ctx, ssmClient, regionalConfig.Region, parameters[i])
+		}, func(i int) {
+			PrintParameterResult(regionalConfig.Region, results[i])
+		})
+		if len(parameters) == 0 {
+			fmt.Println("\tNo parameters in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetParameterResult(ctx context.Context, ssmClient *ssm.Client, region string, parameter ssmtypes.ParameterMetadata) ParameterResult {
+	name := aws.ToString(parameter.Name)
+	result := ParameterResult{
+		Name:             name,
+		Description:      aws.ToString(parameter.Description),
+		Type:             string(parameter.Type),
+		Tier:             string(parameter.Tier),
+		KmsKey:           aws.ToString(parameter.KeyId),
+		DataType:         aws.ToString(parameter.DataType),
+		Version:          parameter.Version,
+		LastModified:     parameter.LastModifiedDate,
+		LastModifiedUser: aws.ToString(parameter.LastModifiedUser),
+		SensitiveName:    SENSITIVE_PARAMETER_PATTERN.MatchString(name[strings.LastIndex(name, "/")+1:]),
+	}
+	if parameter.Type == ssmtypes.ParameterTypeSecureString && result.KmsKey == "" {
+		result.KmsKey = "alias/aws/ssm"
+	}
+
+	if DecryptParameters {
+		result.Value = GetParameterValue(ctx, ssmClient, region, name)
+	}
+
+	return result
+}
+
+func GetParameterValue(ctx context.Context, ssmClient *ssm.Client, region string, name string) *ParameterValueResult {
+	// i.e. aws ssm get-parameter --name <name> --with-decryption
+	output, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+	if err != nil {
+		// Denied by IAM or the KMS key policy, which is expected for most SecureString parameters
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) {
+			return &ParameterValueResult{Error: apiError.ErrorCode()}
+		}
+		return &ParameterValueResult{Error: err.Error()}
+	}
+
+	// Names are paths, i.e. /prod/db/password, so they're flattened into one file name
+	value := &ParameterValueResult{Readable: true}
+	content := []byte(aws.ToString(output.Parameter.Value))
+	source := name
+	lootPath, err := SaveLoot(filepath.Join("ssm", region, strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", "_")+".txt"), content)
+	if err == nil {
+		value.SavedTo = lootPath
+		source = lootPath
+	}
+	value.SecretsFound = ScanForSecrets(source, content)
+
+	return value
+}
+
+func PrintParameterResult(region string, result ParameterResult) {
+	fmt.Printf("\tParameter name: %v (%v)\n", result.Name, result.Type)
+	if result.Description != "" {
+		fmt.Printf("\tDescription: %v\n", result.Description)
+	}
+	if result.KmsKey != "" {
+		fmt.Printf("\tKMS key: %v\n", result.KmsKey)
+	}
+	if result.Tier != "" && result.Tier != string(ssmtypes.ParameterTierStandard) {
+		fmt.Printf("\tTier: %v\n", result.Tier)
+	}
+	if result.DataType != "" && result.DataType != "text" {
+		fmt.Printf("\tData type: %v\n", result.DataType)
+	}
+	if result.LastModified != nil {
+		fmt.Printf("\tVersion %v, last changed %v by %v\n", result.Version, result.LastModified.UTC().Format(time.RFC3339), result.LastModifiedUser)
+	}
+
+	// Plain String values come back from get-parameter to anyone allowed ssm:GetParameter, no KMS key involved
+	if result.SensitiveName && result.Type != string(ssmtypes.ParameterTypeSecureString) {
+		fmt.Println("\t[!] Name suggests a credential, but it's stored unencrypted")
+		EmitFinding(region, result.Name, fmt.Sprintf("Parameter %v looks like a credential but isn't a SecureString", result.Name))
+	} else if result.SensitiveName {
+		fmt.Println("\t[-] Name suggests a credential")
+	}
+
+	if result.Value != nil && result.Value.Readable {
+		if result.Type == string(ssmtypes.ParameterTypeSecureString) {
+			fmt.Println("\t[!] The current principal can decrypt the value")
+			EmitFinding(region, result.Name, fmt.Sprintf("The current principal can decrypt parameter %v", result.Name))
+		}
+		if result.Value.SavedTo != "" {
+			fmt.Printf("\tValue saved to: %v\n", result.Value.SavedTo)
+		}
+		PrintSecretFindings(result.Value.SecretsFound)
+	} else if result.Value != nil {
+		fmt.Printf("\t[-] Couldn't read the value: %v\n", result.Value.Error)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("ssm-parameter", region, result)
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// IAM policy documents allow most fields to be either a single value or a list,
+// these types normalise them so the rest of the code only deals with lists
+type PolicyDocument struct {
+	Version   string            `json:"Version,omitempty"`
+	Id        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+type PolicyStatement struct {
+	Sid          string                    `json:"Sid,omitempty"`
+	Effect       string                    `json:"Effect"`
+	Principal    PolicyPrincipal           `json:"Principal,omitempty"`
+	NotPrincipal PolicyPrincipal           `json:"NotPrincipal,omitempty"`
+	Action       StringOrSlice             `json:"Action,omitempty"`
+	NotAction    StringOrSlice             `json:"NotAction,omitempty"`
+	Resource     StringOrSlice             `json:"Resource,omitempty"`
+	NotResource  StringOrSlice             `json:"NotResource,omitempty"`
+	Condition    map[string]map[string]any `json:"Condition,omitempty"`
+}
+
+type StringOrSlice []string
+
+// A principal of "*" is stored as {"AWS": ["*"]}, which is what it means
+type PolicyPrincipal map[string]StringOrSlice
+
+// Raw principal IDs show up in place of ARNs once the user or role they pointed at is deleted
+var UNRESOLVED_PRINCIPAL_PATTERN = regexp.MustCompile(`^(AIDA|AROA)[A-Z0-9]{12,}$`)
+
+func (s *StringOrSlice) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringOrSlice{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single != "*" {
+			return fmt.Errorf("unexpected principal %q", single)
+		}
+		*p = PolicyPrincipal{"AWS": {"*"}}
+		return nil
+	}
+
+	var principals map[string]StringOrSlice
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	*p = principals
+	return nil
+}
+
+func (d *PolicyDocument) UnmarshalJSON(data []byte) error {
+	// Statement may be a single object rather than a list
+	var raw struct {
+		Version   string          `json:"Version"`
+		Id        string          `json:"Id"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	d.Version = raw.Version
+	d.Id = raw.Id
+	d.Statement = nil
+
+	if len(raw.Statement) == 0 {
+		return nil
+	}
+	if raw.Statement[0] == '{' {
+		var statement PolicyStatement
+		if err := json.Unmarshal(raw.Statement, &statement); err != nil {
+			return err
+		}
+		d.Statement = []PolicyStatement{statement}
+		return nil
+	}
+	return json.Unmarshal(raw.Statement, &d.Statement)
+}
+
+func ParsePolicyDocument(document string) (*PolicyDocument, error) {
+	// Documents returned by IAM are URL-encoded, resource policies from other services are not and
+	// unescaping them would mangle any + or %xx in their ARNs and conditions, so they're only
+	// unescaped when they aren't JSON already
+	var policy PolicyDocument
+	err := json.Unmarshal([]byte(document), &policy)
+	if err == nil {
+		return &policy, nil
+	}
+	decodedDocument, unescapeErr := url.QueryUnescape(document)
+	if unescapeErr != nil || decodedDocument == document {
+		return nil, fmt.Errorf("couldn't parse the policy document: %w", err)
+	}
+
+	policy = PolicyDocument{}
+	if err := json.Unmarshal([]byte(decodedDocument), &policy); err != nil {
+		return nil, fmt.Errorf("couldn't parse the policy document: %w", err)
+	}
+
+	return &policy, nil
+}
+
+func FindUnresolvedPrincipals(policy *PolicyDocument) []string {
+	// Collect any principals that are raw user or role IDs rather than ARNs
+	var unresolved []string
+	for _, statement := range policy.Statement {
+		for _, principals := range []PolicyPrincipal{statement.Principal, statement.NotPrincipal} {
+			for _, principal := range principals["AWS"] {
+				if UNRESOLVED_PRINCIPAL_PATTERN.MatchString(principal) {
+					unresolved = append(unresolved, principal)
+				}
+			}
+		}
+	}
+
+	return unresolved
+}
+
+func PrintUnresolvedPrincipals(region string, resource string, policy *PolicyDocument) {
+	// Resource policies keep a deleted principal's ID just like trust policies do
+	if policy == nil {
+		return
+	}
+	for _, principal := range FindUnresolvedPrincipals(policy) {
+		fmt.Printf("\t[!] Policy names deleted principal %v\n", principal)
+		EmitFinding(region, resource, fmt.Sprintf("Resource policy names deleted principal %v", principal))
+	}
+}
+
+func AnalyseResourcePolicy(policy *PolicyDocument, actions []string, account string, restricted func(statement PolicyStatement) bool) (bool, []string) {
+	// Whether a statement restricted doesn't narrow lets any AWS principal take one of the actions,
+	// and which other accounts are let in to take one, less what an unconditional Deny takes back
+	if policy == nil {
+		return false, nil
+	}
+	public := false
+	var accounts []string
+	for _, statement := range policy.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		// Allowing everyone but the NotPrincipal lets in anyone else
+		principals := statement.Principal["AWS"]
+		if len(statement.NotPrincipal) > 0 {
+			principals = []string{"*"}
+		}
+		for _, action := range actions {
+			if !StatementCoversAction(statement, action) {
+				continue
+			}
+			for _, principal := range principals {
+				if principal == "*" {
+					public = public || (!restricted(statement) && !DeniedTo(policy, statement, action, "*"))
+					continue
+				}
+				principalAccount := PrincipalAccount(principal)
+				if principalAccount == "" || principalAccount == account || slices.Contains(accounts, principalAccount) || DeniedTo(policy, statement, action, principalAccount) {
+					continue
+				}
+				accounts = append(accounts, principalAccount)
+			}
+		}
+	}
+
+	return public, accounts
+}
+
+func ConditionRestricted(statement PolicyStatement) bool {
+	// Conditions usually pin a wildcard principal to an organization or account, i.e. aws:PrincipalOrgID
+	return len(statement.Condition) > 0
+}
+
+func DeniedTo(policy *PolicyDocument, allow PolicyStatement, action string, principalAccount string) bool {
+	// Whether a Deny takes the action back from the whole of an account, or from anyone when the
+	// account is "*". Conditional denies may not apply to the caller, so only unconditional ones count
+	for _, statement := range policy.Statement {
+		if statement.Effect != "Deny" || len(statement.Condition) > 0 || !StatementCoversAction(statement, action) || !DenyCoversResources(statement, allow) {
+			continue
+		}
+		if len(statement.NotPrincipal) > 0 {
+			// Everyone but the NotPrincipal is denied, which is anyone at all, and an account unless
+			// some principal in it is left out
+			exempt := slices.ContainsFunc(statement.NotPrincipal["AWS"], func(principal string) bool {
+				return principal == "*" || PrincipalAccount(principal) == principalAccount
+			})
+			if !exempt {
+				return true
+			}
+			continue
+		}
+		for _, principal := range statement.Principal["AWS"] {
+			// Denying one role or user in an account leaves the rest of it
+			if principal == "*" || (principalAccount != "*" && (principal == principalAccount || principal == "arn:aws:iam::"+principalAccount+":root")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func DenyCoversResources(deny PolicyStatement, allow PolicyStatement) bool {
+	// A deny on part of what's allowed, i.e. one prefix of a bucket, leaves the rest. Statements
+	// without a resource apply to the resource the policy is attached to
+	if len(deny.NotResource) > 0 {
+		return false
+	}
+	if len(deny.Resource) == 0 || ResourceCoveredBy("*", deny.Resource) {
+		return true
+	}
+	if len(allow.Resource) == 0 || len(allow.NotResource) > 0 {
+		return false
+	}
+	for _, resource := range allow.Resource {
+		if !ResourceCoveredBy(resource, deny.Resource) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Permissions worth calling out wherever a policy is summarised
+var NOTABLE_ACTIONS = []string{
+	"iam:PassRole",
+	"iam:CreatePolicyVersion",
+	"iam:SetDefaultPolicyVersion",
+	"iam:AttachUserPolicy",
+	"iam:AttachRolePolicy",
+	"iam:AttachGroupPolicy",
+	"iam:PutUserPolicy",
+	"iam:PutRolePolicy",
+	"iam:PutGroupPolicy",
+	"iam:AddUserToGroup",
+	"iam:CreateAccessKey",
+	"iam:CreateLoginProfile",
+	"iam:UpdateLoginProfile",
+	"iam:UpdateAssumeRolePolicy",
+	"sts:AssumeRole",
+	"s3:GetObject",
+	"secretsmanager:GetSecretValue",
+	"ssm:GetParameter",
+	"ssm:SendCommand",
+	"kms:Decrypt",
+	"lambda:UpdateFunctionCode",
+	"ec2:RunInstances",
+}
+
+func NotablePermissions(policies []*PolicyDocument) []string {
+	// Find the notable actions granted by Allow statements across all the policies
+	allowed := map[string]bool{}
+	for _, policy := range policies {
+		for action := range AllowedActions(policy) {
+			allowed[action] = true
+		}
+	}
+
+	// A full wildcard covers everything else on the list
+	if allowed["*"] {
+		return []string{"*"}
+	}
+
+	var notable []string
+	for _, action := range NOTABLE_ACTIONS {
+		if ActionCoveredBy(strings.ToLower(action), allowed) {
+			notable = append(notable, action)
+		}
+	}
+
+	return notable
+}
+
+// Actions that hand over secrets or credentials, worth flagging when they're granted on every resource
+var CREDENTIAL_ACTIONS = []string{
+	"secretsmanager:GetSecretValue",
+	"secretsmanager:BatchGetSecretValue",
+	"ssm:GetParameter",
+	"ssm:GetParameters",
+	"ssm:GetParametersByPath",
+	"kms:Decrypt",
+	"s3:GetObject",
+	"ec2:GetPasswordData",
+	"lambda:GetFunction",
+	"iam:CreateAccessKey",
+	"sts:GetFederationToken",
+	"ecr:GetAuthorizationToken",
+	"codecommit:GitPull",
+}
+
+// IAM actions starting with one of these change identities or their permissions
+var IAM_WRITE_PREFIXES = []string{
+	"add", "attach", "change", "create", "deactivate", "delete", "detach", "enable", "pass",
+	"put", "remove", "reset", "resync", "set", "tag", "untag", "update", "upload",
+}
+
+type FlaggedStatement struct {
+	Index    int      `json:"index"`
+	Sid      string   `json:"sid,omitempty"`
+	Severity string   `json:"severity"`
+	Reasons  []string `json:"reasons"`
+}
+
+func FlagDangerousStatements(policy *PolicyDocument) []FlaggedStatement {
+	// [!] for statements granting everything, IAM writes or credentials on every resource,
+	// [-] for the merely broad ones, i.e. read-only actions on every resource
+	var flagged []FlaggedStatement
+	for i, statement := range policy.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		var high, low []string
+		allActions := len(statement.NotAction) > 0
+		for _, action := range statement.Action {
+			if action == "*" {
+				allActions = true
+			}
+		}
+		allResources := len(statement.NotResource) > 0
+		for _, resource := range statement.Resource {
+			if resource == "*" {
+				allResources = true
+			}
+		}
+
+		switch {
+		case len(statement.NotAction) > 0:
+			high = append(high, "NotAction grants every action but the listed ones")
+		case allActions:
+			high = append(high, "Action: *")
+		}
+		if allResources {
+			low = append(low, "Resource: *")
+		}
+
+		// A full wildcard already covers the specific actions below
+		if !allActions {
+			for _, action := range statement.Action {
+				if IsIAMWriteAction(action) {
+					high = append(high, "IAM write action "+action)
+				}
+			}
+			if allResources {
+				for _, action := range CREDENTIAL_ACTIONS {
+					if StatementCoversAction(statement, action) {
+						high = append(high, fmt.Sprintf("Credential access %v on *", action))
+					}
+				}
+			}
+		}
+
+		if len(high) == 0 && len(low) == 0 {
+			continue
+		}
+		severity := "[-]"
+		if len(high) > 0 {
+			severity = "[!]"
+		}
+		flagged = append(flagged, FlaggedStatement{
+			Index:    i,
+			Sid:      statement.Sid,
+			Severity: severity,
+			Reasons:  append(high, low...),
+		})
+	}
+
+	return flagged
+}
+
+func IsIAMWriteAction(action string) bool {
+	// Wildcards count if they could match a write action, i.e. iam:* or iam:Put*
+	name, ok := strings.CutPrefix(strings.ToLower(action), "iam:")
+	if !ok {
+		return false
+	}
+	literal := name
+	wildcard := false
+	if i := strings.IndexAny(name, "*?"); i >= 0 {
+		literal = name[:i]
+		wildcard = true
+	}
+	for _, prefix := range IAM_WRITE_PREFIXES {
+		if strings.HasPrefix(literal, prefix) || (wildcard && strings.HasPrefix(prefix, literal)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func PrintDangerousStatements(policy *PolicyDocument) {
+	for _, statement := range FlagDangerousStatements(policy) {
+		label := fmt.Sprintf("Statement %v", statement.Index+1)
+		if statement.Sid != "" {
+			label += fmt.Sprintf(" (%v)", statement.Sid)
+		}
+		fmt.Printf("\t%v %v: %v\n", statement.Severity, label, strings.Join(statement.Reasons, ", "))
+	}
+}
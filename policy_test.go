@@ -0,0 +1,210 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePolicyDocument(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		document  string
+		wantValue string
+	}{
+		{
+			// What IAM returns for trust and managed policies
+			name:      "URL-encoded",
+			document:  "%7B%22Statement%22%3A%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22s3%3AGetObject%22%2C%22Resource%22%3A%22arn%3Aaws%3As3%3A%3A%3Abucket%2Fa%2Bb%22%7D%7D",
+			wantValue: "arn:aws:s3:::bucket/a+b",
+		},
+		{
+			name:      "plus sign",
+			document:  `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/a+b"}}`,
+			wantValue: "arn:aws:s3:::bucket/a+b",
+		},
+		{
+			name:      "percent escape",
+			document:  `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/100%25/*"}}`,
+			wantValue: "arn:aws:s3:::bucket/100%25/*",
+		},
+		{
+			name:      "lone percent",
+			document:  `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/100%/*"}}`,
+			wantValue: "arn:aws:s3:::bucket/100%/*",
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.document)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if len(policy.Statement) != 1 || !slices.Equal(policy.Statement[0].Resource, []string{test.wantValue}) {
+			t.Errorf("%v: got statements %+v, want one on %v", test.name, policy.Statement, test.wantValue)
+		}
+	}
+
+	if _, err := ParsePolicyDocument("not a policy"); err == nil {
+		t.Errorf("got no error for a document that isn't JSON")
+	}
+}
+
+func TestFindUnresolvedPrincipals(t *testing.T) {
+	policy, err := ParsePolicyDocument(`{"Statement":[
+		{"Effect":"Allow","Principal":{"AWS":["AROAJ6WGFYXIRNZBUCPRY","arn:aws:iam::123456789012:role/current"]},"Action":"sts:AssumeRole"},
+		{"Effect":"Deny","NotPrincipal":{"AWS":"AIDAJQABLZS4A3QDU576Q"},"Action":"sts:AssumeRole"},
+		{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AROAJ6WGFYXIRNZBUCPRY", "AIDAJQABLZS4A3QDU576Q"}
+	if got := FindUnresolvedPrincipals(policy); !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAnalyseResourcePolicy(t *testing.T) {
+	const allowAnyone = `{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}`
+	const allowAccount = `{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}`
+	for _, test := range []struct {
+		name         string
+		statements   string
+		wantPublic   bool
+		wantAccounts []string
+	}{
+		{"anyone", allowAnyone, true, nil},
+		{"anyone in the organization", `{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abcdefghij"}}}`, false, nil},
+		{"anyone but the owner", `{"Effect":"Allow","NotPrincipal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}`, true, nil},
+		{"anyone, then denied to anyone", allowAnyone + `,{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::bucket/*"}`, false, nil},
+		{"anyone, then denied without TLS", allowAnyone + `,{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::bucket/*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}`, true, nil},
+		{"anyone, then denied another action", allowAnyone + `,{"Effect":"Deny","Principal":"*","Action":"s3:PutObject","Resource":"arn:aws:s3:::bucket/*"}`, true, nil},
+		{"anyone, then denied one prefix", allowAnyone + `,{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/private/*"}`, true, nil},
+		{"anyone, then denied to all but the owner", allowAnyone + `,{"Effect":"Deny","NotPrincipal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:GetObject","Resource":"*"}`, false, nil},
+		{"another account", allowAccount, false, []string{"210987654321"}},
+		{"another account, then denied to it", allowAccount + `,{"Effect":"Deny","Principal":{"AWS":"210987654321"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}`, false, nil},
+		{"another account, then denied to one role in it", allowAccount + `,{"Effect":"Deny","Principal":{"AWS":"arn:aws:iam::210987654321:role/app"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}`, false, []string{"210987654321"}},
+		{"another account, then denied to all but the owner", allowAccount + `,{"Effect":"Deny","NotPrincipal":{"AWS":"123456789012"},"Action":"s3:*","Resource":"arn:aws:s3:::bucket/*"}`, false, nil},
+		{"another account, then denied to all but a role in it", allowAccount + `,{"Effect":"Deny","NotPrincipal":{"AWS":["123456789012","arn:aws:iam::210987654321:role/reader"]},"Action":"s3:*","Resource":"arn:aws:s3:::bucket/*"}`, false, []string{"210987654321"}},
+	} {
+		policy, err := ParsePolicyDocument(`{"Version":"2012-10-17","Statement":[` + test.statements + `]}`)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		public, accounts := AnalyseResourcePolicy(policy, []string{"s3:GetObject"}, "123456789012", ConditionRestricted)
+		if public != test.wantPublic {
+			t.Errorf("%v: public is %v, want %v", test.name, public, test.wantPublic)
+		}
+		if !slices.Equal(accounts, test.wantAccounts) {
+			t.Errorf("%v: accounts %v, want %v", test.name, accounts, test.wantAccounts)
+		}
+	}
+}
+
+func TestFlagDangerousStatements(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy string
+		// Empty when nothing is flagged
+		wantSeverity string
+		wantReasons  []string
+	}{
+		{
+			name:         "everything",
+			policy:       `{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`,
+			wantSeverity: "[!]",
+			wantReasons:  []string{"Action: *", "Resource: *"},
+		},
+		{
+			name:         "NotAction",
+			policy:       `{"Statement":{"Effect":"Allow","NotAction":"iam:*","Resource":"arn:aws:s3:::bucket/*"}}`,
+			wantSeverity: "[!]",
+			wantReasons:  []string{"NotAction grants every action but the listed ones"},
+		},
+		{
+			name:         "IAM write on one role",
+			policy:       `{"Statement":{"Effect":"Allow","Action":["iam:PassRole","iam:GetRole"],"Resource":"arn:aws:iam::123456789012:role/app"}}`,
+			wantSeverity: "[!]",
+			wantReasons:  []string{"IAM write action iam:PassRole"},
+		},
+		{
+			name:         "IAM prefix wildcard",
+			policy:       `{"Statement":{"Effect":"Allow","Action":"iam:Put*","Resource":"arn:aws:iam::123456789012:user/*"}}`,
+			wantSeverity: "[!]",
+			wantReasons:  []string{"IAM write action iam:Put*"},
+		},
+		{
+			name:   "IAM reads",
+			policy: `{"Statement":{"Effect":"Allow","Action":["iam:Get*","iam:List*"],"Resource":"arn:aws:iam::123456789012:user/*"}}`,
+		},
+		{
+			name:         "credentials through a wildcard",
+			policy:       `{"Statement":{"Effect":"Allow","Action":"ssm:GetParameter*","Resource":"*"}}`,
+			wantSeverity: "[!]",
+			wantReasons:  []string{"Credential access ssm:GetParameter on *", "Credential access ssm:GetParameters on *", "Credential access ssm:GetParametersByPath on *", "Resource: *"},
+		},
+		{
+			name:   "credentials on one secret",
+			policy: `{"Statement":{"Effect":"Allow","Action":"secretsmanager:GetSecretValue","Resource":"arn:aws:secretsmanager:us-east-1:123456789012:secret:app-*"}}`,
+		},
+		{
+			name:         "reads on everything",
+			policy:       `{"Statement":{"Effect":"Allow","Action":["ec2:Describe*","s3:ListAllMyBuckets"],"Resource":"*"}}`,
+			wantSeverity: "[-]",
+			wantReasons:  []string{"Resource: *"},
+		},
+		{
+			name:         "NotResource",
+			policy:       `{"Statement":{"Effect":"Allow","Action":"ec2:DescribeInstances","NotResource":"arn:aws:ec2:*:*:instance/i-0123456789abcdef0"}}`,
+			wantSeverity: "[-]",
+			wantReasons:  []string{"Resource: *"},
+		},
+		{
+			name:   "deny everything",
+			policy: `{"Statement":{"Effect":"Deny","Action":"*","Resource":"*"}}`,
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		flagged := FlagDangerousStatements(policy)
+		if test.wantSeverity == "" {
+			if len(flagged) > 0 {
+				t.Errorf("%v: got %+v, want nothing flagged", test.name, flagged)
+			}
+			continue
+		}
+		if len(flagged) != 1 || flagged[0].Severity != test.wantSeverity || !slices.Equal(flagged[0].Reasons, test.wantReasons) {
+			t.Errorf("%v: got %+v, want %v %q", test.name, flagged, test.wantSeverity, test.wantReasons)
+		}
+	}
+
+	// Statements are flagged by their place in the policy
+	policy, err := ParsePolicyDocument(`{"Statement":[
+		{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"},
+		{"Sid":"Admin","Effect":"Allow","Action":"*","Resource":"*"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flagged := FlagDangerousStatements(policy); len(flagged) != 1 || flagged[0].Index != 1 || flagged[0].Sid != "Admin" {
+		t.Errorf("got %+v, want only the second statement flagged", flagged)
+	}
+}
+
+func TestIsIAMWriteAction(t *testing.T) {
+	for action, want := range map[string]bool{
+		"iam:PassRole":                 true,
+		"IAM:createaccesskey":          true,
+		"iam:*":                        true,
+		"iam:*Policy":                  true,
+		"iam:GetRole":                  false,
+		"iam:List*":                    false,
+		"s3:PutObject":                 false,
+		"iam:GenerateCredentialReport": false,
+	} {
+		if got := IsIAMWriteAction(action); got != want {
+			t.Errorf("IsIAMWriteAction(%q) = %v, want %v", action, got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// IAM keeps at most five versions of a managed policy
+const MAX_POLICY_VERSIONS = 5
+
+func RunPolicyVersionsModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-policies API to get every customer managed policy
+	// i.e. aws iam list-policies --scope Local
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Comparing customer managed policy versions...")
+	fmt.Println(MAJOR_SEPARATOR)
+	policies, err := ListCustomerManagedPolicies(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list customer managed policies. Exiting...")
+		return err
+	}
+
+	// The versions of several policies are fetched at once, then compared in order
+	allVersions := make([][]iamtypes.PolicyVersion, len(policies))
+	allDocuments := make([]map[string]*PolicyDocument, len(policies))
+	ForEachConcurrently(ctx, len(policies), func(ctx context.Context, i int) {
+		allVersions[i], allDocuments[i] = GetPolicyVersionDocuments(ctx, iamClient, policies[i])
+	}, func(i int) {
+		policy := policies[i]
+		versions := allVersions[i]
+		documents := allDocuments[i]
+		// Nothing to compare if the policy only has its default version
+		if len(versions) < 2 {
+			return
+		}
+
+		fmt.Printf("\tPolicy name: %v\n", *policy.PolicyName)
+		fmt.Printf("\tPolicy ARN: %v\n", *policy.Arn)
+		fmt.Printf("\tVersions: %v of %v\n", len(versions), MAX_POLICY_VERSIONS)
+		fmt.Printf("\tDefault version: %v\n", *policy.DefaultVersionId)
+		defaultDocument, ok := documents[*policy.DefaultVersionId]
+		if !ok {
+			fmt.Println(MINOR_SEPARATOR)
+			return
+		}
+		defaultActions := AllowedActions(defaultDocument)
+
+		for _, version := range versions {
+			versionDocument, ok := documents[*version.VersionId]
+			if version.IsDefaultVersion || !ok {
+				continue
+			}
+
+			// Anything this version allows that the default doesn't is one SetDefaultPolicyVersion call away
+			extraActions := ActionsNotCoveredBy(AllowedActions(versionDocument), defaultActions)
+			if len(extraActions) == 0 {
+				fmt.Printf("\tVersion %v: no permissions beyond the default\n", *version.VersionId)
+				continue
+			}
+
+			marker := "[-]"
+			for _, action := range extraActions {
+				if strings.Contains(action, "*") {
+					marker = "[!]"
+					break
+				}
+			}
+			fmt.Printf("\t%v Version %v (created %v) allows more than the default:\n", marker, *version.VersionId, *version.CreateDate)
+			for _, action := range extraActions {
+				fmt.Printf("\t\t%v\n", action)
+			}
+			Emit("policy-version", "", PolicyVersionResult{
+				PolicyArn:  *policy.Arn,
+				VersionId:  *version.VersionId,
+				CreateDate: *version.CreateDate,
+				Document:   versionDocument,
+			})
+			EmitFinding("", *policy.Arn, fmt.Sprintf("Version %v allows more than the default: %v", *version.VersionId, strings.Join(extraActions, ", ")))
+		}
+		fmt.Println(MINOR_SEPARATOR)
+	})
+
+	return nil
+}
+
+func AllowedActions(policy *PolicyDocument) map[string]bool {
+	// Collect the actions granted by Allow statements, lowercased since actions are case-insensitive
+	actions := map[string]bool{}
+	for _, statement := range policy.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		for _, action := range statement.Action {
+			actions[strings.ToLower(action)] = true
+		}
+		// NotAction in an Allow statement grants almost everything
+		if len(statement.NotAction) > 0 {
+			actions["*"] = true
+		}
+	}
+
+	return actions
+}
+
+func ActionsNotCoveredBy(actions map[string]bool, baseline map[string]bool) []string {
+	var extra []string
+	for action := range actions {
+		if !ActionCoveredBy(action, baseline) {
+			extra = append(extra, action)
+		}
+	}
+	sort.Strings(extra)
+
+	return extra
+}
+
+func ActionCoveredBy(action string, baseline map[string]bool) bool {
+	// An action is covered if the baseline has it exactly or through a wildcard pattern
+	if baseline[action] {
+		return true
+	}
+	for pattern := range baseline {
+		if strings.Contains(pattern, "*") && WildcardMatch(pattern, action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func WildcardMatch(pattern string, value string) bool {
+	// IAM wildcards: * matches any run of characters and ? matches exactly one
+	if pattern == "" {
+		return value == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(value); i++ {
+			if WildcardMatch(pattern[1:], value[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return value != "" && WildcardMatch(pattern[1:], value[1:])
+	default:
+		return value != "" && pattern[0] == value[0] && WildcardMatch(pattern[1:], value[1:])
+	}
+}
+
+func GetPolicyVersionDocuments(ctx context.Context, iamClient *iam.Client, policy iamtypes.Policy) ([]iamtypes.PolicyVersion, map[string]*PolicyDocument) {
+	// Get every version of the policy and its document, keyed by version ID. Versions whose
+	// document can't be fetched or parsed are left out of the map
+	// i.e. aws iam list-policy-versions --policy-arn <policy-arn>
+	versions, err := ListLatestPolicyVersions(ctx, iamClient, *policy.Arn)
+	if err != nil || len(versions) < 2 {
+		return versions, nil
+	}
+
+	documents := map[string]*PolicyDocument{}
+	for _, version := range versions {
+		// i.e. aws iam get-policy-version --policy-arn <policy-arn> --version-id <version-id>
+		versionDetails, err := GetPolicyVersionDetails(ctx, iamClient, *policy.Arn, *version.VersionId)
+		if err != nil {
+			continue
+		}
+		document, err := ParsePolicyDocument(*versionDetails.PolicyVersion.Document)
+		if err != nil {
+			fmt.Printf("Couldn't parse version %v of %v. Here's why: %v\n", *version.VersionId, *policy.PolicyName, err)
+			continue
+		}
+		documents[*version.VersionId] = document
+	}
+
+	return versions, documents
+}
+
+func ListCustomerManagedPolicies(ctx context.Context, iamClient *iam.Client) ([]iamtypes.Policy, error) {
+	// Get every customer managed policy in the account
+	var policies []iamtypes.Policy
+	paginator := iam.NewListPoliciesPaginator(iamClient, &iam.ListPoliciesInput{
+		Scope: iamtypes.PolicyScopeTypeLocal,
+	})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(policies)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the customer managed policies. Here's why: %v\n", err)
+			return nil, err
+		}
+		policies = append(policies, page.Policies...)
+	}
+
+	return LimitItems(policies), nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// A known way to escalate privileges, usable when every one of its actions is allowed
+type PrivescPath struct {
+	Name        string
+	Description string
+	Actions     []string
+	// For the PassRole paths, the service a passed role has to trust
+	PassRoleTo string
+	// The action has to be allowed on every resource, not just some, i.e. sts:AssumeRole on *
+	AnyResource bool
+}
+
+var PRIVESC_PATHS = []PrivescPath{
+	{Name: "New policy version", Description: "Create a new default version of a customer managed policy granting anything", Actions: []string{"iam:CreatePolicyVersion"}},
+	{Name: "Old policy version", Description: "Switch a customer managed policy back to a version that allows more", Actions: []string{"iam:SetDefaultPolicyVersion"}},
+	{Name: "Attach policy to a user", Description: "Attach AdministratorAccess to yourself or another user", Actions: []string{"iam:AttachUserPolicy"}},
+	{Name: "Attach policy to a group", Description: "Attach AdministratorAccess to a group you're in", Actions: []string{"iam:AttachGroupPolicy"}},
+	{Name: "Attach policy to a role", Description: "Attach AdministratorAccess to a role, then assume it", Actions: []string{"iam:AttachRolePolicy", "sts:AssumeRole"}},
+	{Name: "Inline policy on a user", Description: "Add an inline policy granting anything to yourself or another user", Actions: []string{"iam:PutUserPolicy"}},
+	{Name: "Inline policy on a group", Description: "Add an inline policy granting anything to a group you're in", Actions: []string{"iam:PutGroupPolicy"}},
+	{Name: "Inline policy on a role", Description: "Add an inline policy granting anything to a role, then assume it", Actions: []string{"iam:PutRolePolicy", "sts:AssumeRole"}},
+	{Name: "Add user to group", Description: "Add yourself to a more privileged group", Actions: []string{"iam:AddUserToGroup"}},
+	{Name: "Access key for another user", Description: "Create an access key for a more privileged user", Actions: []string{"iam:CreateAccessKey"}},
+	{Name: "Console password for another user", Description: "Create a console password for a more privileged user", Actions: []string{"iam:CreateLoginProfile"}},
+	{Name: "Reset another user's console password", Description: "Change the console password of a more privileged user", Actions: []string{"iam:UpdateLoginProfile"}},
+	{Name: "Rewrite a role trust policy", Description: "Let yourself assume a more privileged role, then assume it", Actions: []string{"iam:UpdateAssumeRolePolicy", "sts:AssumeRole"}},
+	{Name: "Assume any role", Description: "Assume any role in the account that trusts it", Actions: []string{"sts:AssumeRole"}, AnyResource: true},
+	{Name: "Pass a role to a new EC2 instance", Description: "Launch an instance with a more privileged role and take its credentials", Actions: []string{"iam:PassRole", "ec2:RunInstances"}, PassRoleTo: "ec2.amazonaws.com"},
+	{Name: "Pass a role to a new Lambda function", Description: "Create a function with a more privileged role and invoke it", Actions: []string{"iam:PassRole", "lambda:CreateFunction", "lambda:InvokeFunction"}, PassRoleTo: "lambda.amazonaws.com"},
+	{Name: "Pass a role to a Lambda function run by an event source", Description: "Create a function with a more privileged role and have a stream or queue trigger it", Actions: []string{"iam:PassRole", "lambda:CreateFunction", "lambda:CreateEventSourceMapping"}, PassRoleTo: "lambda.amazonaws.com"},
+	{Name: "Update Lambda function code", Description: "Replace the code of a function that runs with a more privileged role", Actions: []string{"lambda:UpdateFunctionCode"}},
+	{Name: "Pass a role to a Glue dev endpoint", Description: "Create a dev endpoint with a more privileged role and SSH in", Actions: []string{"iam:PassRole", "glue:CreateDevEndpoint"}, PassRoleTo: "glue.amazonaws.com"},
+	{Name: "Update a Glue dev endpoint", Description: "Add your SSH key to a dev endpoint that runs with a more privileged role", Actions: []string{"glue:UpdateDevEndpoint"}},
+	{Name: "Pass a role to a CloudFormation stack", Description: "Create a stack with a more privileged role that creates whatever you ask it to", Actions: []string{"iam:PassRole", "cloudformation:CreateStack"}, PassRoleTo: "cloudformation.amazonaws.com"},
+	{Name: "Pass a role to a Data Pipeline", Description: "Create a pipeline with a more privileged role that runs your commands", Actions: []string{"iam:PassRole", "datapipeline:CreatePipeline", "datapipeline:PutPipelineDefinition"}, PassRoleTo: "datapipeline.amazonaws.com"},
+	{Name: "Pass a role to an ECS task", Description: "Run a task with a more privileged role and take its credentials", Actions: []string{"iam:PassRole", "ecs:RegisterTaskDefinition", "ecs:RunTask"}, PassRoleTo: "ecs-tasks.amazonaws.com"},
+	{Name: "Pass a role to a CodeBuild project", Description: "Create a build with a more privileged role that runs your commands", Actions: []string{"iam:PassRole", "codebuild:CreateProject", "codebuild:StartBuild"}, PassRoleTo: "codebuild.amazonaws.com"},
+	{Name: "Pass a role to a SageMaker notebook", Description: "Create a notebook with a more privileged role and open it", Actions: []string{"iam:PassRole", "sagemaker:CreateNotebookInstance", "sagemaker:CreatePresignedNotebookInstanceUrl"}, PassRoleTo: "sagemaker.amazonaws.com"},
+}
+
+type PrivescResult struct {
+	Principal   string `json:"principal"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	// Each action mapped to the resources it's allowed on
+	Actions map[string][]string `json:"actions"`
+	// Roles trusting the path's service that the principal can pass, for the PassRole paths
+	PassableRoles []string `json:"passableRoles,omitempty"`
+	// Actions the account's SCPs take away, which stop the path working, only known in org-scan
+	BlockedBySCP []string `json:"blockedBySCP,omitempty"`
+}
+
+func RunPrivescModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Gather every policy that applies to the current principal and check each path against them
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking privilege escalation paths for the current principal...")
+	fmt.Println(MAJOR_SEPARATOR)
+	principalArn, policies, err := GetCallerPolicies(ctx, sdkConfig, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't get the policies for the current principal. Exiting...")
+		return err
+	}
+	fmt.Printf("\tPrincipal: %v\n", principalArn)
+	fmt.Printf("\tPolicies analysed: %v\n", len(policies))
+	fmt.Println(MINOR_SEPARATOR)
+
+	var documents []*PolicyDocument
+	for _, document := range policies {
+		documents = append(documents, document)
+	}
+
+	// The PassRole paths only lead somewhere if a role trusting the service can be passed
+	// i.e. aws iam list-roles
+	roles, err := CachedRoles(ctx, iamClient)
+	if err != nil {
+		roles = nil
+	}
+
+	found := 0
+	for _, path := range PRIVESC_PATHS {
+		result := PrivescResult{
+			Principal:   principalArn,
+			Path:        path.Name,
+			Description: path.Description,
+			Actions:     map[string][]string{},
+		}
+		usable := true
+		for _, action := range path.Actions {
+			resources := AllowedResources(documents, action)
+			if len(resources) == 0 || (path.AnyResource && !ResourceCoveredBy("*", resources)) {
+				usable = false
+				break
+			}
+			result.Actions[action] = resources
+		}
+		if !usable {
+			continue
+		}
+
+		if path.PassRoleTo != "" {
+			for _, role := range roles {
+				if ResourceCoveredBy(*role.Arn, result.Actions["iam:PassRole"]) && RoleTrustsService(role, path.PassRoleTo) {
+					result.PassableRoles = append(result.PassableRoles, *role.RoleName)
+				}
+			}
+			if len(result.PassableRoles) == 0 {
+				continue
+			}
+		}
+
+		// A path the SCPs break is still shown, but not as a finding
+		_, result.BlockedBySCP = ApplySCPs(path.Actions)
+		if len(result.BlockedBySCP) > 0 {
+			fmt.Printf("\t[-] %v, blocked by SCP\n", path.Name)
+			for _, action := range result.BlockedBySCP {
+				fmt.Printf("\t\t%v\n", action)
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("privesc", "", result)
+			continue
+		}
+
+		found++
+		fmt.Printf("\t[!] %v: %v\n", path.Name, path.Description)
+		for _, action := range path.Actions {
+			fmt.Printf("\t\t%v on %v\n", action, strings.Join(result.Actions[action], ", "))
+		}
+		for _, roleName := range result.PassableRoles {
+			fmt.Printf("\t\tPassable role: %v\n", roleName)
+		}
+		EmitFinding("", principalArn, fmt.Sprintf("Can escalate privileges: %v", path.Name))
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("privesc", "", result)
+	}
+	if found == 0 {
+		fmt.Println("\tNo escalation paths found in the principal's policies")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func GetCallerPolicies(ctx context.Context, sdkConfig aws.Config, iamClient *iam.Client) (string, map[string]*PolicyDocument, error) {
+	// Every policy document that applies to the caller, keyed by ARN for managed policies and by
+	// name for inline ones, including what the user's groups pass on
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return "", nil, err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+
+	if roleName, ok := CallerRoleName(*callerIdentity.Arn); ok {
+		// i.e. aws iam get-role --role-name <role-name>
+		role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get details for the role %v. Here's why: %v\n", roleName, err)
+			return "", nil, err
+		}
+		permissions, err := GetRolePermissions(ctx, iamClient, *role.Role)
+		if err != nil {
+			return "", nil, err
+		}
+		return principalArn, permissions.Policies, nil
+	}
+
+	parsedArn, err := arn.Parse(principalArn)
+	if err != nil || !strings.HasPrefix(parsedArn.Resource, "user/") {
+		return "", nil, fmt.Errorf("only IAM users and roles have policies to analyse, not %v", principalArn)
+	}
+	username := parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+	policies := map[string]*PolicyDocument{}
+
+	// i.e. aws iam list-attached-user-policies --user-name <username>
+	attachedPolicies, err := ListAttachedUserPolicies(ctx, iamClient, username)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, policy := range attachedPolicies {
+		document, err := GetManagedPolicyDocument(ctx, iamClient, *policy.PolicyArn)
+		if err == nil {
+			policies[*policy.PolicyArn] = document
+		}
+	}
+
+	// i.e. aws iam list-user-policies --user-name <username>
+	inlinePolicies, err := ListInlineUserPolicies(ctx, iamClient, username)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, policyName := range inlinePolicies {
+		document, err := GetInlineUserPolicyDocument(ctx, iamClient, username, policyName)
+		if err == nil {
+			policies[PolicyDocumentKey("user/"+username, policyName)] = document
+		}
+	}
+
+	// i.e. aws iam list-groups-for-user --user-name <username>
+	groups, err := ListUserGroups(ctx, iamClient, username)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, group := range groups {
+		for policyName, document := range GetGroupResult(ctx, iamClient, group).Policies {
+			policies[PolicyDocumentKey("group/"+*group.GroupName, policyName)] = document
+		}
+	}
+
+	return principalArn, policies, nil
+}
+
+func AllowedResources(documents []*PolicyDocument, action string) []string {
+	// The resource patterns an action is allowed on. Conditions aren't evaluated, so a conditional
+	// Allow counts and only a Deny on every resource without conditions takes the action away
+	action = strings.ToLower(action)
+	resources := map[string]bool{}
+	for _, document := range documents {
+		for _, statement := range document.Statement {
+			if statement.Effect == "Deny" && len(statement.Condition) == 0 && StatementCoversAction(statement, action) && ResourceCoveredBy("*", statement.Resource) {
+				return nil
+			}
+			if statement.Effect != "Allow" || !StatementCoversAction(statement, action) {
+				continue
+			}
+			for _, resource := range statement.Resource {
+				resources[resource] = true
+			}
+			// NotResource allows everything but the listed resources, which is near enough to everything
+			if len(statement.NotResource) > 0 {
+				resources["*"] = true
+			}
+		}
+	}
+
+	var allowed []string
+	for resource := range resources {
+		allowed = append(allowed, resource)
+	}
+	sort.Strings(allowed)
+
+	return allowed
+}
+
+func StatementCoversAction(statement PolicyStatement, action string) bool {
+	// The statement's actions are lowercased, so the action has to be too
+	action = strings.ToLower(action)
+	if len(statement.NotAction) > 0 {
+		return !ActionCoveredBy(action, ToActionSet(statement.NotAction))
+	}
+
+	return ActionCoveredBy(action, ToActionSet(statement.Action))
+}
+
+func RoleTrustsService(role iamtypes.Role, service string) bool {
+	// The trust policy comes back URL-encoded in the list-roles response
+	if role.AssumeRolePolicyDocument == nil {
+		return false
+	}
+	trustPolicy, err := ParsePolicyDocument(*role.AssumeRolePolicyDocument)
+	if err != nil {
+		return false
+	}
+	for _, statement := range trustPolicy.Statement {
+		if statement.Effect != "Allow" || !StatementCoversAction(statement, "sts:assumerole") {
+			continue
+		}
+		for _, principal := range statement.Principal["Service"] {
+			if principal == service {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStatementCoversAction(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		statement PolicyStatement
+		action    string
+		want      bool
+	}{
+		{"exact", PolicyStatement{Action: StringOrSlice{"s3:GetObject"}}, "s3:GetObject", true},
+		{"exact lowercase", PolicyStatement{Action: StringOrSlice{"s3:GetObject"}}, "s3:getobject", true},
+		{"statement lowercase", PolicyStatement{Action: StringOrSlice{"s3:getobject"}}, "s3:GetObject", true},
+		{"other action", PolicyStatement{Action: StringOrSlice{"s3:GetObject"}}, "s3:PutObject", false},
+		{"prefix wildcard", PolicyStatement{Action: StringOrSlice{"s3:Get*"}}, "s3:GetObject", true},
+		{"prefix wildcard miss", PolicyStatement{Action: StringOrSlice{"s3:Get*"}}, "s3:PutObject", false},
+		{"service wildcard", PolicyStatement{Action: StringOrSlice{"s3:*"}}, "s3:PutObject", true},
+		{"service wildcard other service", PolicyStatement{Action: StringOrSlice{"s3:*"}}, "iam:PassRole", false},
+		{"full wildcard", PolicyStatement{Action: StringOrSlice{"*"}}, "iam:PassRole", true},
+		{"NotAction listed", PolicyStatement{NotAction: StringOrSlice{"iam:*"}}, "iam:PassRole", false},
+		{"NotAction unlisted", PolicyStatement{NotAction: StringOrSlice{"iam:*"}}, "s3:GetObject", true},
+		{"NotAction mixed case", PolicyStatement{NotAction: StringOrSlice{"IAM:PassRole"}}, "iam:passrole", false},
+		{"no actions", PolicyStatement{}, "s3:GetObject", false},
+	} {
+		if got := StatementCoversAction(test.statement, test.action); got != test.want {
+			t.Errorf("%v: StatementCoversAction(%v, %q) = %v, want %v", test.name, test.statement.Action, test.action, got, test.want)
+		}
+	}
+}
+
+func TestAllowedResources(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		policies []string
+		action   string
+		want     []string
+	}{
+		{
+			"allowed on one bucket",
+			[]string{`{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`},
+			"s3:GetObject",
+			[]string{"arn:aws:s3:::bucket/*"},
+		},
+		{
+			"merged across policies",
+			[]string{
+				`{"Statement":{"Effect":"Allow","Action":"iam:PassRole","Resource":"arn:aws:iam::123456789012:role/b"}}`,
+				`{"Statement":{"Effect":"Allow","Action":"iam:Pass*","Resource":"arn:aws:iam::123456789012:role/a"}}`,
+			},
+			"iam:PassRole",
+			[]string{"arn:aws:iam::123456789012:role/a", "arn:aws:iam::123456789012:role/b"},
+		},
+		{
+			"NotResource",
+			[]string{`{"Statement":{"Effect":"Allow","Action":"iam:PassRole","NotResource":"arn:aws:iam::123456789012:role/admin"}}`},
+			"iam:PassRole",
+			[]string{"*"},
+		},
+		{
+			"denied everywhere",
+			[]string{
+				`{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`,
+				`{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"*"}}`,
+			},
+			"iam:PassRole",
+			nil,
+		},
+		{
+			"conditional deny doesn't count",
+			[]string{`{"Statement":[
+				{"Effect":"Allow","Action":"*","Resource":"*"},
+				{"Effect":"Deny","Action":"iam:*","Resource":"*","Condition":{"Bool":{"aws:MultiFactorAuthPresent":"false"}}}
+			]}`},
+			"iam:PassRole",
+			[]string{"*"},
+		},
+		{
+			"not allowed",
+			[]string{`{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`},
+			"iam:PassRole",
+			nil,
+		},
+	} {
+		if got := AllowedResources(parsePolicies(t, test.policies...), test.action); !slices.Equal(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
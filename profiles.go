@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Named profile from the shared config files to use, set with --profile
+var ProfileFlag = ""
+
+// Whether every configured profile is scanned one after another, set with --all-profiles
+var AllProfiles = false
+
+func ListProfiles() ([]string, error) {
+	// Profiles are sections of ~/.aws/config, written [profile <name>] apart from [default],
+	// and of ~/.aws/credentials, written [<name>]
+	found := map[string]bool{}
+	files := map[string]bool{
+		SharedConfigFile():      true,
+		SharedCredentialsFile(): false,
+	}
+	for path, isConfigFile := range files {
+		sections, err := ReadIniSections(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			fmt.Printf("Couldn't read %v. Here's why: %v\n", path, err)
+			return nil, err
+		}
+		for _, section := range sections {
+			if isConfigFile && section != "default" {
+				// Other sections of the config file, i.e. [sso-session <name>], aren't profiles
+				if !strings.HasPrefix(section, "profile ") {
+					continue
+				}
+				section = strings.TrimSpace(strings.TrimPrefix(section, "profile "))
+			}
+			found[section] = true
+		}
+	}
+
+	var profiles []string
+	for profile := range found {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	return profiles, nil
+}
+
+func SharedConfigFile() string {
+	// Either file can be moved with an environment variable, same as for the SDK itself
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+
+	return config.DefaultSharedConfigFilename()
+}
+
+func SharedCredentialsFile() string {
+	if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+		return path
+	}
+
+	return config.DefaultSharedCredentialsFilename()
+}
+
+func ReadIniSections(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sections []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, strings.TrimSpace(line[1:len(line)-1]))
+		}
+	}
+
+	return sections, scanner.Err()
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// An owned image or snapshot and who besides the account can use it
+type ImageExposureResult struct {
+	// AMI, EBS snapshot, DB snapshot or DB cluster snapshot
+	Kind string `json:"kind"`
+	Id   string `json:"id"`
+	Arn  string `json:"arn"`
+	// The instance, volume, database or cluster it was taken of, or the AMI's name
+	Source     string   `json:"source,omitempty"`
+	Encrypted  bool     `json:"encrypted"`
+	Public     bool     `json:"public"`
+	SharedWith []string `json:"sharedWith"`
+}
+
+// The region's block public access settings for AMIs and EBS snapshots
+type BlockPublicAccessResult struct {
+	// block-new-sharing or unblocked
+	Images string `json:"images,omitempty"`
+	// block-all-sharing, block-new-sharing or unblocked
+	Snapshots string `json:"snapshots,omitempty"`
+}
+
+func RunPublicSnapshotsModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	partition := CallerPartition(aws.ToString(callerIdentity.Arn))
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ec2Client := ec2.NewFromConfig(regionalConfig)
+		rdsClient := rds.NewFromConfig(regionalConfig)
+
+		// Block public access stops new public shares, and for snapshots can hide existing ones too
+		// i.e. aws ec2 get-image-block-public-access-state, aws ec2 get-snapshot-block-public-access-state
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking owned AMIs and snapshots for public and cross-account access in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		blockPublicAccess := BlockPublicAccessResult{}
+		if imageState, err := ec2Client.GetImageBlockPublicAccessState(ctx, &ec2.GetImageBlockPublicAccessStateInput{}); err == nil {
+			blockPublicAccess.Images = aws.ToString(imageState.ImageBlockPublicAccessState)
+			fmt.Printf("\tAMI block public access: %v\n", blockPublicAccess.Images)
+		}
+		if snapshotState, err := ec2Client.GetSnapshotBlockPublicAccessState(ctx, &ec2.GetSnapshotBlockPublicAccessStateInput{}); err == nil {
+			blockPublicAccess.Snapshots = string(snapshotState.State)
+			fmt.Printf("\tEBS snapshot block public access: %v\n", blockPublicAccess.Snapshots)
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("block-public-access", regionalConfig.Region, blockPublicAccess)
+
+		// i.e. aws ec2 describe-images --owners self
+		var results []ImageExposureResult
+		images, err := ListOwnedImages(ctx, ec2Client)
+		if err == nil {
+			for _, image := range images {
+				results = append(results, ImageExposureResult{
+					Kind:   "AMI",
+					Id:     aws.ToString(image.ImageId),
+					Arn:    fmt.Sprintf("arn:%v:ec2:%v::image/%v", partition, regionalConfig.Region, aws.ToString(image.ImageId)),
+					Source: aws.ToString(image.Name),
+					Public: aws.ToBool(image.Public),
+				})
+			}
+		}
+		// i.e. aws ec2 describe-snapshots --owner-ids self
+		ebsSnapshots, err := ListOwnedSnapshots(ctx, ec2Client)
+		if err == nil {
+			for _, snapshot := range ebsSnapshots {
+				results = append(results, ImageExposureResult{
+					Kind:      "EBS snapshot",
+					Id:        aws.ToString(snapshot.SnapshotId),
+					Arn:       fmt.Sprintf("arn:%v:ec2:%v::snapshot/%v", partition, regionalConfig.Region, aws.ToString(snapshot.SnapshotId)),
+					Source:    aws.ToString(snapshot.VolumeId),
+					Encrypted: aws.ToBool(snapshot.Encrypted),
+				})
+			}
+		}
+		// Only manual snapshots can be shared
+		// i.e. aws rds describe-db-snapshots --snapshot-type manual
+		dbSnapshots, err := ListDBSnapshots(ctx, rdsClient)
+		if err == nil {
+			for _, snapshot := range dbSnapshots {
+				results = append(results, ImageExposureResult{
+					Kind:      "DB snapshot",
+					Id:        aws.ToString(snapshot.DBSnapshotIdentifier),
+					Arn:       aws.ToString(snapshot.DBSnapshotArn),
+					Source:    aws.ToString(snapshot.DBInstanceIdentifier),
+					Encrypted: aws.ToBool(snapshot.Encrypted),
+				})
+			}
+		}
+		// i.e. aws rds describe-db-cluster-snapshots --snapshot-type manual
+		clusterSnapshots, err := ListDBClusterSnapshots(ctx, rdsClient)
+		if err == nil {
+			for _, snapshot := range clusterSnapshots {
+				results = append(results, ImageExposureResult{
+					Kind:      "DB cluster snapshot",
+					Id:        aws.ToString(snapshot.DBClusterSnapshotIdentifier),
+					Arn:       aws.ToString(snapshot.DBClusterSnapshotArn),
+					Source:    aws.ToString(snapshot.DBClusterIdentifier),
+					Encrypted: aws.ToBool(snapshot.StorageEncrypted),
+				})
+			}
+		}
+
+		// "all" in the permissions means anyone, anything else is an account, organization or OU
+		exposed := 0
+		ForEachConcurrently(ctx, len(results), func(ctx context.Context, i int) {
+			GetImageExposure(ctx, ec2Client, rdsClient, &results[i])
+		}, func(i int) {
+			result := results[i]
+			if !result.Public && len(result.SharedWith) == 0 {
+				return
+			}
+			exposed++
+			fmt.Printf("\t%v: %v\n", result.Kind, result.Id)
+			if result.Source != "" {
+				fmt.Printf("\tSource: %v\n", result.Source)
+			}
+			fmt.Printf("\tEncrypted: %v\n", result.Encrypted)
+			if result.Public {
+				if result.Kind == "EBS snapshot" && blockPublicAccess.Snapshots == string(ec2types.SnapshotBlockPublicAccessStateBlockAllSharing) {
+					fmt.Println("\t[-] Shared publicly, but block public access hides it")
+				} else {
+					fmt.Println("\t[!] Anyone can use it")
+					EmitExposedFinding(regionalConfig.Region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("%v %v is public", result.Kind, result.Id))
+				}
+			}
+			for _, sharedWith := range result.SharedWith {
+				fmt.Printf("\t[-] Shared with %v\n", sharedWith)
+				EmitExposedFinding(regionalConfig.Region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("%v %v is shared with %v", result.Kind, result.Id, sharedWith))
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("image-exposure", regionalConfig.Region, result)
+		})
+		fmt.Printf("\t%v of %v AMIs and snapshots are public or shared\n", exposed, len(results))
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetImageExposure(ctx context.Context, ec2Client *ec2.Client, rdsClient *rds.Client, result *ImageExposureResult) {
+	addPermission := func(value string) {
+		if value == "all" {
+			result.Public = true
+		} else if value != "" {
+			result.SharedWith = append(result.SharedWith, value)
+		}
+	}
+
+	switch result.Kind {
+	case "AMI":
+		// i.e. aws ec2 describe-image-attribute --image-id <image-id> --attribute launchPermission
+		attribute, err := ec2Client.DescribeImageAttribute(ctx, &ec2.DescribeImageAttributeInput{
+			ImageId:   aws.String(result.Id),
+			Attribute: ec2types.ImageAttributeNameLaunchPermission,
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the launch permissions for %v. Here's why: %v\n", result.Id, err)
+			return
+		}
+		for _, permission := range attribute.LaunchPermissions {
+			addPermission(string(permission.Group))
+			addPermission(aws.ToString(permission.UserId))
+			addPermission(aws.ToString(permission.OrganizationArn))
+			addPermission(aws.ToString(permission.OrganizationalUnitArn))
+		}
+	case "EBS snapshot":
+		// i.e. aws ec2 describe-snapshot-attribute --snapshot-id <snapshot-id> --attribute createVolumePermission
+		attribute, err := ec2Client.DescribeSnapshotAttribute(ctx, &ec2.DescribeSnapshotAttributeInput{
+			SnapshotId: aws.String(result.Id),
+			Attribute:  ec2types.SnapshotAttributeNameCreateVolumePermission,
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the volume permissions for %v. Here's why: %v\n", result.Id, err)
+			return
+		}
+		for _, permission := range attribute.CreateVolumePermissions {
+			addPermission(string(permission.Group))
+			addPermission(aws.ToString(permission.UserId))
+		}
+	case "DB snapshot":
+		// i.e. aws rds describe-db-snapshot-attributes --db-snapshot-identifier <snapshot-id>
+		attributes, err := rdsClient.DescribeDBSnapshotAttributes(ctx, &rds.DescribeDBSnapshotAttributesInput{DBSnapshotIdentifier: aws.String(result.Id)})
+		if err != nil {
+			fmt.Printf("Couldn't get the attributes for %v. Here's why: %v\n", result.Id, err)
+			return
+		}
+		if attributes.DBSnapshotAttributesResult == nil {
+			return
+		}
+		for _, attribute := range attributes.DBSnapshotAttributesResult.DBSnapshotAttributes {
+			if aws.ToString(attribute.AttributeName) == "restore" {
+				for _, value := range attribute.AttributeValues {
+					addPermission(value)
+				}
+			}
+		}
+	case "DB cluster snapshot":
+		// i.e. aws rds describe-db-cluster-snapshot-attributes --db-cluster-snapshot-identifier <snapshot-id>
+		attributes, err := rdsClient.DescribeDBClusterSnapshotAttributes(ctx, &rds.DescribeDBClusterSnapshotAttributesInput{DBClusterSnapshotIdentifier: aws.String(result.Id)})
+		if err != nil {
+			fmt.Printf("Couldn't get the attributes for %v. Here's why: %v\n", result.Id, err)
+			return
+		}
+		if attributes.DBClusterSnapshotAttributesResult == nil {
+			return
+		}
+		for _, attribute := range attributes.DBClusterSnapshotAttributesResult.DBClusterSnapshotAttributes {
+			if aws.ToString(attribute.AttributeName) == "restore" {
+				for _, value := range attribute.AttributeValues {
+					addPermission(value)
+				}
+			}
+		}
+	}
+}
+
+func ListOwnedImages(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.Image, error) {
+	// Without an owner every public AMI in the region would be listed too
+	var images []ec2types.Image
+	paginator := ec2.NewDescribeImagesPaginator(ec2Client, &ec2.DescribeImagesInput{
+		Owners: []string{"self"},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the AMIs. Here's why: %v\n", err)
+			return nil, err
+		}
+		images = append(images, page.Images...)
+	}
+
+	return images, nil
+}
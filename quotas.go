@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+)
+
+// Utilization at or above this fraction of a quota is flagged
+const QUOTA_WARNING_THRESHOLD = 0.8
+
+// Each of these has a matching <name>Quota entry in the account summary
+var IAM_QUOTA_ENTITIES = []string{
+	"Users",
+	"Groups",
+	"Roles",
+	"Policies",
+	"InstanceProfiles",
+	"ServerCertificates",
+}
+
+// Running On-Demand Standard (A, C, D, H, I, M, R, T, Z) instances, measured in vCPUs
+const EC2_STANDARD_VCPU_QUOTA_CODE = "L-1216C47A"
+const EC2_STANDARD_INSTANCE_FAMILIES = "acdhimrtz"
+
+type QuotaResult struct {
+	Name        string  `json:"name"`
+	Used        float64 `json:"used"`
+	Limit       float64 `json:"limit"`
+	Utilization float64 `json:"utilization"`
+	NearLimit   bool    `json:"nearLimit"`
+}
+
+func RunQuotasModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the get-account-summary API to compare IAM entity counts against their quotas
+	// i.e. aws iam get-account-summary
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting IAM quota utilization for the account...")
+	fmt.Println(MAJOR_SEPARATOR)
+	accountSummary, err := GetAccountSummary(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't get the account summary. Exiting...")
+		return err
+	}
+
+	for _, entity := range IAM_QUOTA_ENTITIES {
+		used, hasUsed := accountSummary.SummaryMap[entity]
+		limit, hasLimit := accountSummary.SummaryMap[entity+"Quota"]
+		if !hasUsed || !hasLimit {
+			continue
+		}
+		PrintQuotaUtilization("", entity, float64(used), float64(limit))
+	}
+
+	// Compare running instance vCPUs against the standard On-Demand quota in each region
+	// i.e. aws service-quotas get-service-quota --service-code ec2 --quota-code L-1216C47A
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting EC2 instance quota utilization for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		return PrintEC2InstanceQuota(ctx, regionalConfig)
+	})
+
+	return nil
+}
+
+func PrintQuotaUtilization(region string, name string, used float64, limit float64) {
+	result := QuotaResult{Name: name, Used: used, Limit: limit}
+	fmt.Printf("\t%v: %v / %v", name, used, limit)
+	if limit > 0 {
+		result.Utilization = used / limit
+		result.NearLimit = result.Utilization >= QUOTA_WARNING_THRESHOLD
+		fmt.Printf(" (%.0f%%)", result.Utilization*100)
+		if result.NearLimit {
+			fmt.Print(" [!] close to exhaustion, new resources of this type may be blocked")
+		}
+	}
+	fmt.Println()
+	Emit("quota", region, result)
+}
+
+func PrintEC2InstanceQuota(ctx context.Context, sdkConfig aws.Config) error {
+	quota, err := GetServiceQuotaValue(ctx, servicequotas.NewFromConfig(sdkConfig), "ec2", EC2_STANDARD_VCPU_QUOTA_CODE)
+	if err != nil {
+		return err
+	}
+
+	instances, err := CachedInstances(ctx, sdkConfig)
+	if err != nil {
+		return err
+	}
+	runningInstances := RunningInstances(instances)
+
+	// Only standard instance families count towards this quota
+	var standardVCPUs int32
+	for _, instance := range runningInstances {
+		family := strings.ToLower(string(instance.InstanceType))
+		if family == "" || !strings.ContainsRune(EC2_STANDARD_INSTANCE_FAMILIES, rune(family[0])) {
+			continue
+		}
+		if instance.CpuOptions != nil && instance.CpuOptions.CoreCount != nil && instance.CpuOptions.ThreadsPerCore != nil {
+			standardVCPUs += *instance.CpuOptions.CoreCount * *instance.CpuOptions.ThreadsPerCore
+		}
+	}
+
+	fmt.Printf("\tRunning instances: %v\n", len(runningInstances))
+	PrintQuotaUtilization(sdkConfig.Region, "Standard On-Demand vCPUs", float64(standardVCPUs), quota)
+	return nil
+}
+
+func GetAccountSummary(ctx context.Context, iamClient *iam.Client) (*iam.GetAccountSummaryOutput, error) {
+	// Get the entity usage and quotas for the account
+	accountSummary, err := iamClient.GetAccountSummary(ctx, &iam.GetAccountSummaryInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the account summary. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return accountSummary, nil
+}
+
+func GetServiceQuotaValue(ctx context.Context, quotasClient *servicequotas.Client, serviceCode string, quotaCode string) (float64, error) {
+	// Get the applied value of the quota, falling back to the AWS default if it has never been changed
+	appliedQuota, err := quotasClient.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(serviceCode),
+		QuotaCode:   aws.String(quotaCode),
+	})
+	if err == nil && appliedQuota.Quota != nil && appliedQuota.Quota.Value != nil {
+		return *appliedQuota.Quota.Value, nil
+	}
+
+	defaultQuota, err := quotasClient.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+		ServiceCode: aws.String(serviceCode),
+		QuotaCode:   aws.String(quotaCode),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the %v quota %v. Here's why: %v\n", serviceCode, quotaCode, err)
+		return 0, err
+	}
+	if defaultQuota.Quota == nil || defaultQuota.Quota.Value == nil {
+		return 0, fmt.Errorf("quota %v has no value", quotaCode)
+	}
+
+	return *defaultQuota.Quota.Value, nil
+}
+
+func ListInstances(ctx context.Context, ec2Client *ec2.Client, filters []ec2types.Filter) ([]ec2types.Instance, error) {
+	// Get every instance in the region matching the filters
+	var instances []ec2types.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(ec2Client, &ec2.DescribeInstancesInput{
+		Filters: filters,
+	})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(instances)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the instances. Here's why: %v\n", err)
+			return nil, err
+		}
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+	}
+
+	return LimitItems(instances), nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// A database that accepts IAM authentication, either an Aurora cluster or a standalone instance
+type RDSAuthTarget struct {
+	Identifier string `json:"identifier"`
+	Engine     string `json:"engine"`
+	Endpoint   string `json:"endpoint"`
+	Port       int32  `json:"port"`
+	// rds-db:connect is granted on arn:aws:rds-db:<region>:<account>:dbuser:<resource-id>/<db-user>
+	ResourceId     string `json:"resourceId"`
+	MasterUsername string `json:"masterUsername"`
+	DatabaseName   string `json:"databaseName,omitempty"`
+}
+
+type RDSAuthResult struct {
+	Target RDSAuthTarget `json:"target"`
+	// The database users the current principal can connect as, with a token and command for each
+	Connections []RDSConnection `json:"connections"`
+}
+
+type RDSConnection struct {
+	DBUser string `json:"dbUser"`
+	// Tokens are valid for 15 minutes after they're generated
+	Token   string `json:"token"`
+	Command string `json:"command"`
+}
+
+func RunRDSAuthModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+	partition := "aws"
+	if parsedArn, err := arn.Parse(principalArn); err == nil {
+		partition = parsedArn.Partition
+	}
+
+	// Database users named in the principal's own policies are tried as well as each master user,
+	// i.e. the admin in arn:aws:rds-db:us-east-1:123456789012:dbuser:db-ABC/admin
+	var connectPatterns []string
+	if _, policies, err := GetCallerPolicies(ctx, sdkConfig, iamClient); err == nil {
+		var documents []*PolicyDocument
+		for _, document := range policies {
+			documents = append(documents, document)
+		}
+		connectPatterns = AllowedResources(documents, "rds-db:connect")
+	}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		rdsClient := rds.NewFromConfig(regionalConfig)
+
+		// i.e. aws rds describe-db-clusters, aws rds describe-db-instances
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking databases with IAM authentication in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		targets, err := ListRDSAuthTargets(ctx, rdsClient)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			fmt.Println("\tNo databases with IAM authentication enabled in this region")
+			return nil
+		}
+
+		for _, target := range targets {
+			fmt.Printf("\tDatabase: %v (%v)\n", target.Identifier, target.Engine)
+			fmt.Printf("\tEndpoint: %v:%v\n", target.Endpoint, target.Port)
+			fmt.Printf("\tMaster user: %v\n", target.MasterUsername)
+
+			userArn := fmt.Sprintf("arn:%v:rds-db:%v:%v:dbuser:%v/", partition, regionalConfig.Region, *callerIdentity.Account, target.ResourceId)
+			var userArns []string
+			for _, dbUser := range RDSCandidateUsers(target, userArn, connectPatterns) {
+				userArns = append(userArns, userArn+dbUser)
+			}
+
+			// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names rds-db:connect --resource-arns <dbuser-arn>
+			results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, []string{"rds-db:connect"}, userArns)
+			if err != nil {
+				return err
+			}
+			result := RDSAuthResult{Target: target}
+			for _, evaluation := range results {
+				for _, dbUserArn := range AllowedResourceNames(evaluation) {
+					dbUser := strings.TrimPrefix(dbUserArn, userArn)
+					// i.e. aws rds generate-db-auth-token --hostname <endpoint> --port <port> --username <db-user>
+					token, err := auth.BuildAuthToken(ctx, fmt.Sprintf("%v:%v", target.Endpoint, target.Port), regionalConfig.Region, dbUser, regionalConfig.Credentials)
+					if err != nil {
+						fmt.Printf("Couldn't generate an auth token for %v on %v. Here's why: %v\n", dbUser, target.Identifier, err)
+						continue
+					}
+					connection := RDSConnection{DBUser: dbUser, Token: token, Command: RDSConnectCommand(target, dbUser, token)}
+					result.Connections = append(result.Connections, connection)
+					fmt.Printf("\t[!] Can connect as %v with an IAM auth token\n", dbUser)
+					fmt.Printf("\t\t%v\n", connection.Command)
+					EmitFinding(regionalConfig.Region, target.Identifier, fmt.Sprintf("Current principal can connect to the database as %v with rds-db:connect", dbUser))
+				}
+			}
+			if len(result.Connections) == 0 {
+				fmt.Println("\tNo rds-db:connect for any known database user")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("rds-iam-auth", regionalConfig.Region, result)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListRDSAuthTargets(ctx context.Context, rdsClient *rds.Client) ([]RDSAuthTarget, error) {
+	// Aurora instances are connected to through their cluster, which has its own resource ID
+	var targets []RDSAuthTarget
+	clusters, err := ListDBClusters(ctx, rdsClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		if !aws.ToBool(cluster.IAMDatabaseAuthenticationEnabled) {
+			continue
+		}
+		targets = append(targets, RDSAuthTarget{
+			Identifier:     aws.ToString(cluster.DBClusterIdentifier),
+			Engine:         aws.ToString(cluster.Engine),
+			Endpoint:       aws.ToString(cluster.Endpoint),
+			Port:           aws.ToInt32(cluster.Port),
+			ResourceId:     aws.ToString(cluster.DbClusterResourceId),
+			MasterUsername: aws.ToString(cluster.MasterUsername),
+			DatabaseName:   aws.ToString(cluster.DatabaseName),
+		})
+	}
+
+	instances, err := ListDBInstances(ctx, rdsClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		if instance.DBClusterIdentifier != nil || !aws.ToBool(instance.IAMDatabaseAuthenticationEnabled) || instance.Endpoint == nil {
+			continue
+		}
+		targets = append(targets, RDSAuthTarget{
+			Identifier:     aws.ToString(instance.DBInstanceIdentifier),
+			Engine:         aws.ToString(instance.Engine),
+			Endpoint:       aws.ToString(instance.Endpoint.Address),
+			Port:           aws.ToInt32(instance.Endpoint.Port),
+			ResourceId:     aws.ToString(instance.DbiResourceId),
+			MasterUsername: aws.ToString(instance.MasterUsername),
+			DatabaseName:   aws.ToString(instance.DBName),
+		})
+	}
+
+	return targets, nil
+}
+
+func RDSCandidateUsers(target RDSAuthTarget, userArn string, connectPatterns []string) []string {
+	// The master user, plus any specific user a pattern for this database names. Wildcard
+	// patterns don't name anyone, so only the master user is tried for those
+	users := []string{target.MasterUsername}
+	for _, pattern := range connectPatterns {
+		dbUser, ok := strings.CutPrefix(pattern, userArn)
+		if ok && dbUser != "" && !strings.ContainsAny(dbUser, "*?") {
+			users = AppendMissing(users, dbUser)
+		}
+	}
+
+	return users
+}
+
+func AllowedResourceNames(evaluation iamtypes.EvaluationResult) []string {
+	// With several resources the decision for each is in the resource-specific results
+	if len(evaluation.ResourceSpecificResults) == 0 {
+		if evaluation.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			return []string{aws.ToString(evaluation.EvalResourceName)}
+		}
+		return nil
+	}
+
+	var allowed []string
+	for _, resourceResult := range evaluation.ResourceSpecificResults {
+		if resourceResult.EvalResourceDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			allowed = append(allowed, aws.ToString(resourceResult.EvalResourceName))
+		}
+	}
+
+	return allowed
+}
+
+func RDSConnectCommand(target RDSAuthTarget, dbUser string, token string) string {
+	// IAM authentication needs TLS, and the token is sent as a cleartext password over it
+	if strings.Contains(target.Engine, "postgres") {
+		database := target.DatabaseName
+		if database == "" {
+			database = "postgres"
+		}
+		return fmt.Sprintf("PGPASSWORD='%v' psql \"host=%v port=%v user=%v dbname=%v sslmode=require\"", token, target.Endpoint, target.Port, dbUser, database)
+	}
+
+	return fmt.Sprintf("mysql -h %v -P %v -u %v --ssl-mode=REQUIRED --enable-cleartext-plugin --password='%v'", target.Endpoint, target.Port, dbUser, token)
+}
+
+func ListDBClusters(ctx context.Context, rdsClient *rds.Client) ([]rdstypes.DBCluster, error) {
+	var clusters []rdstypes.DBCluster
+	paginator := rds.NewDescribeDBClustersPaginator(rdsClient, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the DB clusters. Here's why: %v\n", err)
+			return nil, err
+		}
+		clusters = append(clusters, page.DBClusters...)
+	}
+
+	return clusters, nil
+}
+
+func ListDBInstances(ctx context.Context, rdsClient *rds.Client) ([]rdstypes.DBInstance, error) {
+	var instances []rdstypes.DBInstance
+	paginator := rds.NewDescribeDBInstancesPaginator(rdsClient, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the DB instances. Here's why: %v\n", err)
+			return nil, err
+		}
+		instances = append(instances, page.DBInstances...)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	accounttypes "github.com/aws/aws-sdk-go-v2/service/account/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Regions chosen with --regions, resolved once at startup so disabled regions are never called
+var SelectedRegions []string
+
+type RegionStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func ResolveRegions(ctx context.Context, sdkConfig aws.Config, regionsFlag string) ([]string, error) {
+	// Default to the region from the loaded configuration
+	if regionsFlag == "" {
+		if RegionExcluded(sdkConfig.Region) {
+			return nil, fmt.Errorf("the configured region %v is excluded with --exclude-regions", sdkConfig.Region)
+		}
+		return []string{sdkConfig.Region}, nil
+	}
+
+	regionStatuses, err := ListRegionStatuses(ctx, sdkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := map[string]bool{}
+	var allEnabled []string
+	for _, region := range regionStatuses {
+		if IsRegionEnabled(region.Status) {
+			enabled[region.Name] = true
+			allEnabled = append(allEnabled, region.Name)
+		}
+	}
+
+	if regionsFlag == "all" {
+		var selected []string
+		for _, name := range allEnabled {
+			if !RegionExcluded(name) {
+				selected = append(selected, name)
+			}
+		}
+		return selected, nil
+	}
+
+	var selected []string
+	for _, name := range strings.Split(regionsFlag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !enabled[name] {
+			fmt.Printf("Skipping region %v, it isn't enabled for this account\n", name)
+			continue
+		}
+		if RegionExcluded(name) {
+			fmt.Printf("Skipping region %v, it's excluded with --exclude-regions\n", name)
+			continue
+		}
+		selected = append(selected, name)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of the selected regions are enabled for this account, or they're all excluded")
+	}
+
+	return selected, nil
+}
+
+func ForEachRegion(ctx context.Context, sdkConfig aws.Config, fn func(ctx context.Context, regionalConfig aws.Config) error) {
+	// Run fn once per selected region, with the configuration pointed at that region and
+	// a context carrying the region's span
+	regions := SelectedRegions
+	if len(regions) == 0 {
+		regions = []string{sdkConfig.Region}
+	}
+
+	for _, region := range regions {
+		// Don't start on another region once the run's been interrupted
+		if ctx.Err() != nil {
+			return
+		}
+		regionalConfig := sdkConfig.Copy()
+		regionalConfig.Region = region
+		regionCtx, span := StartSpan(ctx, "region "+region, attribute.String("aws.region", region))
+		err := fn(regionCtx, regionalConfig)
+		if err != nil {
+			fmt.Printf("Couldn't finish enumerating %v. Here's why: %v\n", region, err)
+		}
+		EndSpan(span, err)
+	}
+}
+
+func IsRegionEnabled(status string) bool {
+	return status == string(accounttypes.RegionOptStatusEnabled) || status == string(accounttypes.RegionOptStatusEnabledByDefault)
+}
+
+func ListRegionStatuses(ctx context.Context, sdkConfig aws.Config) ([]RegionStatus, error) {
+	// Get the opt-in status of every region
+	// i.e. aws account list-regions
+	var regions []RegionStatus
+	paginator := account.NewListRegionsPaginator(account.NewFromConfig(sdkConfig), &account.ListRegionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			// Fall back to EC2, which only returns the regions that are enabled
+			fmt.Printf("Couldn't list region opt-in status, falling back to ec2:DescribeRegions. Here's why: %v\n", err)
+			return ListEnabledRegionsFromEC2(ctx, sdkConfig)
+		}
+		for _, region := range page.Regions {
+			regions = append(regions, RegionStatus{
+				Name:   aws.ToString(region.RegionName),
+				Status: string(region.RegionOptStatus),
+			})
+		}
+	}
+
+	return regions, nil
+}
+
+func ListEnabledRegionsFromEC2(ctx context.Context, sdkConfig aws.Config) ([]RegionStatus, error) {
+	// i.e. aws ec2 describe-regions --all-regions
+	output, err := ec2.NewFromConfig(sdkConfig).DescribeRegions(ctx, &ec2.DescribeRegionsInput{
+		AllRegions: aws.Bool(true),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't list the regions. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	var regions []RegionStatus
+	for _, region := range output.Regions {
+		// EC2 reports opt-in-not-required, opted-in and not-opted-in
+		status := string(accounttypes.RegionOptStatusDisabled)
+		switch aws.ToString(region.OptInStatus) {
+		case "opt-in-not-required":
+			status = string(accounttypes.RegionOptStatusEnabledByDefault)
+		case "opted-in":
+			status = string(accounttypes.RegionOptStatusEnabled)
+		}
+		regions = append(regions, RegionStatus{Name: aws.ToString(region.RegionName), Status: status})
+	}
+
+	return regions, nil
+}
+
+func RunRegionsModule(ctx context.Context, sdkConfig aws.Config) error {
+	// Call the list-regions API and report which regions are enabled
+	// i.e. aws account list-regions
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting region opt-in status for the account...")
+	fmt.Println(MAJOR_SEPARATOR)
+	regionStatuses, err := ListRegionStatuses(ctx, sdkConfig)
+	if err != nil {
+		fmt.Println("Couldn't get region opt-in status. Exiting...")
+		return err
+	}
+
+	var optedIn []string
+	for _, region := range regionStatuses {
+		fmt.Printf("\t%v: %v\n", region.Name, region.Status)
+		Emit("region", region.Name, region)
+		if region.Status == string(accounttypes.RegionOptStatusEnabled) {
+			optedIn = append(optedIn, region.Name)
+		}
+	}
+
+	// Opt-in regions are easy to leave out of monitoring since they aren't on by default
+	if len(optedIn) > 0 {
+		fmt.Println(MINOR_SEPARATOR)
+		fmt.Println("[!] Opted-in regions (check these are covered by monitoring):")
+		for _, region := range optedIn {
+			fmt.Printf("\t%v\n", region)
+		}
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
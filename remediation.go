@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A fix for the findings whose message matches, kept in the rule catalog. The commands and
+// Terraform can use {resource}, {name} (the last part of the resource's ARN), {region}
+// and {1}, {2}, ... for what the match captured
+type RemediationRule struct {
+	Match string `json:"match"`
+	// Only used for findings on resources containing this, i.e. ":role/"
+	Resource  string `json:"resource,omitempty"`
+	CLI       string `json:"cli"`
+	Terraform string `json:"terraform,omitempty"`
+}
+
+type Remediation struct {
+	CLI       string `json:"cli"`
+	Terraform string `json:"terraform,omitempty"`
+}
+
+type compiledRemediationRule struct {
+	RemediationRule
+	pattern *regexp.Regexp
+}
+
+var loadRemediationRules = sync.OnceValue(func() []compiledRemediationRule {
+	// A broken catalog only costs the remediations, the findings are still reported
+	catalog, err := LoadRuleCatalog()
+	if err != nil {
+		fmt.Printf("Couldn't load the remediations. Here's why: %v\n", err)
+		return nil
+	}
+
+	var rules []compiledRemediationRule
+	for _, rule := range catalog.Remediations {
+		pattern, err := regexp.Compile(rule.Match)
+		if err != nil {
+			fmt.Printf("Couldn't compile the remediation pattern %q. Here's why: %v\n", rule.Match, err)
+			continue
+		}
+		rules = append(rules, compiledRemediationRule{RemediationRule: rule, pattern: pattern})
+	}
+
+	return rules
+})
+
+func FindRemediation(region string, resource string, message string) *Remediation {
+	// The first matching rule wins
+	for _, rule := range loadRemediationRules() {
+		if rule.Resource != "" && !strings.Contains(resource, rule.Resource) {
+			continue
+		}
+		captures := rule.pattern.FindStringSubmatch(message)
+		if captures == nil {
+			continue
+		}
+
+		replacements := []string{
+			"{resource}", resource,
+			"{name}", resource[strings.LastIndex(resource, "/")+1:],
+			"{region}", region,
+		}
+		for i, capture := range captures[1:] {
+			replacements = append(replacements, fmt.Sprintf("{%v}", i+1), capture)
+		}
+		replacer := strings.NewReplacer(replacements...)
+
+		return &Remediation{CLI: replacer.Replace(rule.CLI), Terraform: replacer.Replace(rule.Terraform)}
+	}
+
+	return nil
+}
+
+func PrintRemediation(remediation *Remediation) {
+	// Only the command goes in the text output, the Terraform is in the structured results
+	if remediation == nil {
+		return
+	}
+	fmt.Printf("\t\tFix: %v\n", remediation.CLI)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const REPL_HELP = `Commands:
+	show role <name>               Trust policy, policies and notable permissions of a role
+	show user <name>               Groups and policies of a user
+	can-i <action> [resource-arn]  Simulate an action for the current principal (resource defaults to *)
+	expand policy <name|arn>       Print the default version of a managed policy
+	who-has <name|arn>             Users, groups and roles a managed policy is attached to
+	help                           Show this message
+	exit                           Leave the prompt`
+
+// Everything looked up during the session is kept so repeat questions don't call AWS again
+type ReplSession struct {
+	iamClient    *iam.Client
+	principalArn string
+	roles        map[string]*RolePermissions
+	trustPolicy  map[string]*PolicyDocument
+	policies     map[string]iamtypes.Policy
+}
+
+func RunRepl(ctx context.Context, sdkConfig aws.Config) {
+	session := &ReplSession{
+		iamClient:   iam.NewFromConfig(sdkConfig),
+		roles:       map[string]*RolePermissions{},
+		trustPolicy: map[string]*PolicyDocument{},
+		policies:    map[string]iamtypes.Policy{},
+	}
+
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err == nil {
+		session.principalArn = SimulationPrincipalArn(*callerIdentity.Arn)
+	}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Entering interactive mode, type 'help' for commands")
+	fmt.Println(MAJOR_SEPARATOR)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("aws-enumerator> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+
+		switch {
+		case args[0] == "exit" || args[0] == "quit":
+			return
+		case args[0] == "help":
+			fmt.Println(REPL_HELP)
+		case args[0] == "show" && len(args) == 3 && args[1] == "role":
+			session.ShowRole(ctx, args[2])
+		case args[0] == "show" && len(args) == 3 && args[1] == "user":
+			session.ShowUser(ctx, args[2])
+		case args[0] == "can-i" && (len(args) == 2 || len(args) == 3):
+			resource := "*"
+			if len(args) == 3 {
+				resource = args[2]
+			}
+			session.CanI(ctx, args[1], resource)
+		case args[0] == "expand" && len(args) == 3 && args[1] == "policy":
+			session.ExpandPolicy(ctx, args[2])
+		case args[0] == "who-has" && len(args) == 2:
+			session.WhoHas(ctx, args[1])
+		default:
+			fmt.Println("Unknown command, type 'help' for commands")
+		}
+	}
+}
+
+func (s *ReplSession) ShowRole(ctx context.Context, roleName string) {
+	permissions, ok := s.roles[roleName]
+	if !ok {
+		// i.e. aws iam get-role --role-name <role-name>
+		role, err := s.iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			fmt.Printf("Couldn't get the role %v. Here's why: %v\n", roleName, err)
+			return
+		}
+		permissions, err = GetRolePermissions(ctx, s.iamClient, *role.Role)
+		if err != nil {
+			return
+		}
+		s.roles[roleName] = permissions
+		if role.Role.AssumeRolePolicyDocument != nil {
+			if trustPolicy, err := ParsePolicyDocument(*role.Role.AssumeRolePolicyDocument); err == nil {
+				s.trustPolicy[roleName] = trustPolicy
+			}
+		}
+	}
+
+	fmt.Printf("\tRole ARN: %v\n", permissions.RoleArn)
+	if trustPolicy, ok := s.trustPolicy[roleName]; ok {
+		fmt.Printf("\tTrust policy:\n%v\n", FormatPolicyDocument(trustPolicy))
+	}
+	var policyNames []string
+	for policyName := range permissions.Policies {
+		policyNames = append(policyNames, policyName)
+	}
+	sort.Strings(policyNames)
+	for _, policyName := range policyNames {
+		fmt.Printf("\tPolicy: %v\n", policyName)
+	}
+	for _, action := range permissions.Notable {
+		fmt.Printf("\t[!] Grants %v\n", action)
+	}
+}
+
+func (s *ReplSession) ShowUser(ctx context.Context, username string) {
+	userGroups, err := ListUserGroups(ctx, s.iamClient, username)
+	if err == nil {
+		for _, group := range userGroups {
+			fmt.Printf("\tGroup: %v\n", *group.GroupName)
+		}
+	}
+
+	userPolicies, err := ListAttachedUserPolicies(ctx, s.iamClient, username)
+	if err == nil {
+		for _, policy := range userPolicies {
+			fmt.Printf("\tAttached policy: %v\n", *policy.PolicyArn)
+		}
+	}
+
+	userInlinePolicies, err := ListInlineUserPolicies(ctx, s.iamClient, username)
+	if err == nil {
+		for _, policy := range userInlinePolicies {
+			fmt.Printf("\tInline policy: %v\n", policy)
+		}
+	}
+}
+
+func (s *ReplSession) CanI(ctx context.Context, action string, resource string) {
+	if s.principalArn == "" {
+		fmt.Println("The current principal is unknown, can't simulate")
+		return
+	}
+
+	// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names <action> --resource-arns <resource>
+	results, err := SimulatePrincipalActions(ctx, s.iamClient, s.principalArn, []string{action}, []string{resource})
+	if err != nil {
+		return
+	}
+	for _, result := range results {
+		PrintEvaluationResult(result)
+	}
+}
+
+func (s *ReplSession) ExpandPolicy(ctx context.Context, nameOrArn string) {
+	policyArn, ok := s.PolicyArn(ctx, nameOrArn)
+	if !ok {
+		return
+	}
+
+	document, err := GetManagedPolicyDocument(ctx, s.iamClient, policyArn)
+	if err != nil {
+		return
+	}
+	fmt.Println(FormatPolicyDocument(document))
+	PrintDangerousStatements(document)
+}
+
+func (s *ReplSession) WhoHas(ctx context.Context, nameOrArn string) {
+	policyArn, ok := s.PolicyArn(ctx, nameOrArn)
+	if !ok {
+		return
+	}
+
+	// i.e. aws iam list-entities-for-policy --policy-arn <policy-arn> --policy-usage-filter PermissionsPolicy
+	result, err := GetPolicyEntities(ctx, s.iamClient, policyArn)
+	if err != nil {
+		return
+	}
+	PrintPolicyEntities(result)
+}
+
+func (s *ReplSession) PolicyArn(ctx context.Context, nameOrArn string) (string, bool) {
+	if strings.HasPrefix(nameOrArn, "arn:") {
+		return nameOrArn, true
+	}
+
+	// Look the name up among every managed policy, AWS managed ones included
+	// i.e. aws iam list-policies
+	if len(s.policies) == 0 {
+		paginator := iam.NewListPoliciesPaginator(s.iamClient, &iam.ListPoliciesInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				fmt.Printf("Couldn't list the policies. Here's why: %v\n", err)
+				return "", false
+			}
+			for _, policy := range page.Policies {
+				s.policies[*policy.PolicyName] = policy
+			}
+		}
+	}
+	policy, ok := s.policies[nameOrArn]
+	if !ok {
+		fmt.Printf("No managed policy named %v\n", nameOrArn)
+		return "", false
+	}
+
+	return *policy.Arn, true
+}
+
+func FormatPolicyDocument(policy *PolicyDocument) string {
+	formatted, err := json.MarshalIndent(policy, "\t", "  ")
+	if err != nil {
+		return fmt.Sprintf("\t%v", err)
+	}
+
+	return "\t" + string(formatted)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	resolvertypes "github.com/aws/aws-sdk-go-v2/service/route53resolver/types"
+)
+
+type ResolverEndpointResult struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	VpcId     string `json:"vpcId"`
+	Status    string `json:"status"`
+}
+
+type ResolverRuleResult struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	DomainName  string `json:"domainName"`
+	RuleType    string `json:"ruleType"`
+	ShareStatus string `json:"shareStatus"`
+	// Where forwarded queries go, i.e. 10.0.0.2:53, only set for FORWARD rules
+	Targets    []string `json:"targets,omitempty"`
+	EndpointId string   `json:"endpointId,omitempty"`
+	VpcIds     []string `json:"vpcIds"`
+}
+
+type ResolverQueryLogResult struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Destination string   `json:"destination"`
+	VpcIds      []string `json:"vpcIds"`
+}
+
+type ResolverResult struct {
+	Endpoints    []ResolverEndpointResult `json:"endpoints"`
+	Rules        []ResolverRuleResult     `json:"rules"`
+	QueryLogs    []ResolverQueryLogResult `json:"queryLogs"`
+	UnloggedVPCs []string                 `json:"unloggedVpcs"`
+}
+
+func RunResolverModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		resolverClient := route53resolver.NewFromConfig(regionalConfig)
+		result := ResolverResult{}
+
+		// Outbound endpoints are how queries leave the VPCs for DNS servers elsewhere
+		// i.e. aws route53resolver list-resolver-endpoints
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Route 53 Resolver endpoints, rules and query logging in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		endpoints, err := ListResolverEndpoints(ctx, resolverClient)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range endpoints {
+			endpointResult := ResolverEndpointResult{
+				Id:        aws.ToString(endpoint.Id),
+				Name:      aws.ToString(endpoint.Name),
+				Direction: string(endpoint.Direction),
+				VpcId:     aws.ToString(endpoint.HostVPCId),
+				Status:    string(endpoint.Status),
+			}
+			result.Endpoints = append(result.Endpoints, endpointResult)
+			fmt.Printf("\tEndpoint: %v (%v)\n", endpointResult.Name, endpointResult.Id)
+			fmt.Printf("\t\tDirection: %v\n", endpointResult.Direction)
+			fmt.Printf("\t\tVPC: %v\n", endpointResult.VpcId)
+			fmt.Printf("\t\tIP addresses: %v\n", aws.ToInt32(endpoint.IpAddressCount))
+		}
+		if len(endpoints) == 0 {
+			fmt.Println("\tNo Resolver endpoints in this region")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws route53resolver list-resolver-rules, aws route53resolver list-resolver-rule-associations
+		rules, err := ListResolverRules(ctx, resolverClient)
+		if err != nil {
+			return err
+		}
+		ruleVPCs, err := ListResolverRuleVPCs(ctx, resolverClient)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			ruleResult := ResolverRuleResult{
+				Id:          aws.ToString(rule.Id),
+				Name:        aws.ToString(rule.Name),
+				DomainName:  aws.ToString(rule.DomainName),
+				RuleType:    string(rule.RuleType),
+				ShareStatus: string(rule.ShareStatus),
+				EndpointId:  aws.ToString(rule.ResolverEndpointId),
+				VpcIds:      ruleVPCs[aws.ToString(rule.Id)],
+			}
+			for _, target := range rule.TargetIps {
+				ip := aws.ToString(target.Ip)
+				if ip == "" {
+					ip = aws.ToString(target.Ipv6)
+				}
+				ruleResult.Targets = append(ruleResult.Targets, fmt.Sprintf("%v:%v", ip, aws.ToInt32(target.Port)))
+			}
+			result.Rules = append(result.Rules, ruleResult)
+
+			fmt.Printf("\tRule: %v (%v)\n", ruleResult.Name, ruleResult.Id)
+			fmt.Printf("\t\tDomain: %v\n", ruleResult.DomainName)
+			fmt.Printf("\t\tType: %v\n", ruleResult.RuleType)
+			if rule.ShareStatus == resolvertypes.ShareStatusSharedWithMe {
+				fmt.Printf("\t\tShared from: %v\n", aws.ToString(rule.OwnerId))
+			}
+			if len(ruleResult.VpcIds) > 0 {
+				fmt.Printf("\t\tAssociated VPCs: %v\n", strings.Join(ruleResult.VpcIds, ", "))
+			}
+			if rule.RuleType != resolvertypes.RuleTypeOptionForward {
+				continue
+			}
+			fmt.Printf("\t\tForwards to: %v via %v\n", strings.Join(ruleResult.Targets, ", "), ruleResult.EndpointId)
+			// Forwarding the root domain sends every lookup from the associated VPCs to the targets
+			if strings.TrimSuffix(ruleResult.DomainName, ".") == "" && len(ruleResult.VpcIds) > 0 {
+				fmt.Println("\t\t[-] Every query from the associated VPCs is forwarded")
+			}
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws route53resolver list-resolver-query-log-configs
+		queryLogs, err := ListResolverQueryLogConfigs(ctx, resolverClient)
+		if err != nil {
+			return err
+		}
+		// i.e. aws route53resolver list-resolver-query-log-config-associations
+		queryLogVPCs, err := ListResolverQueryLogVPCs(ctx, resolverClient)
+		if err != nil {
+			return err
+		}
+		for _, queryLog := range queryLogs {
+			queryLogResult := ResolverQueryLogResult{
+				Id:          aws.ToString(queryLog.Id),
+				Name:        aws.ToString(queryLog.Name),
+				Destination: aws.ToString(queryLog.DestinationArn),
+				VpcIds:      queryLogVPCs[aws.ToString(queryLog.Id)],
+			}
+			result.QueryLogs = append(result.QueryLogs, queryLogResult)
+			fmt.Printf("\tQuery logging: %v (%v)\n", queryLogResult.Name, queryLogResult.Id)
+			fmt.Printf("\t\tDelivered to: %v\n", queryLogResult.Destination)
+			fmt.Printf("\t\tVPCs: %v\n", strings.Join(queryLogResult.VpcIds, ", "))
+		}
+
+		// DNS query logs are one of the few places C2 and exfiltration over DNS shows up
+		// i.e. aws ec2 describe-vpcs
+		logged := map[string]bool{}
+		for _, vpcIds := range queryLogVPCs {
+			for _, vpcId := range vpcIds {
+				logged[vpcId] = true
+			}
+		}
+		vpcs, err := ListVPCs(ctx, ec2.NewFromConfig(regionalConfig))
+		if err == nil {
+			for _, vpc := range vpcs {
+				if !logged[*vpc.VpcId] {
+					result.UnloggedVPCs = append(result.UnloggedVPCs, *vpc.VpcId)
+					fmt.Printf("\t[!] VPC %v has no DNS query logging\n", *vpc.VpcId)
+					EmitFinding(regionalConfig.Region, *vpc.VpcId, "VPC has no Route 53 Resolver query logging, so its DNS lookups aren't recorded")
+				}
+			}
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+		Emit("resolver", regionalConfig.Region, result)
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListResolverEndpoints(ctx context.Context, resolverClient *route53resolver.Client) ([]resolvertypes.ResolverEndpoint, error) {
+	var endpoints []resolvertypes.ResolverEndpoint
+	paginator := route53resolver.NewListResolverEndpointsPaginator(resolverClient, &route53resolver.ListResolverEndpointsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Resolver endpoints. Here's why: %v\n", err)
+			return nil, err
+		}
+		endpoints = append(endpoints, page.ResolverEndpoints...)
+	}
+
+	return endpoints, nil
+}
+
+func ListResolverRules(ctx context.Context, resolverClient *route53resolver.Client) ([]resolvertypes.ResolverRule, error) {
+	var rules []resolvertypes.ResolverRule
+	paginator := route53resolver.NewListResolverRulesPaginator(resolverClient, &route53resolver.ListResolverRulesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Resolver rules. Here's why: %v\n", err)
+			return nil, err
+		}
+		rules = append(rules, page.ResolverRules...)
+	}
+
+	return rules, nil
+}
+
+func ListResolverRuleVPCs(ctx context.Context, resolverClient *route53resolver.Client) (map[string][]string, error) {
+	// The VPCs each rule is associated with, keyed by rule ID
+	vpcIds := map[string][]string{}
+	paginator := route53resolver.NewListResolverRuleAssociationsPaginator(resolverClient, &route53resolver.ListResolverRuleAssociationsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Resolver rule associations. Here's why: %v\n", err)
+			return nil, err
+		}
+		for _, association := range page.ResolverRuleAssociations {
+			ruleId := aws.ToString(association.ResolverRuleId)
+			vpcIds[ruleId] = append(vpcIds[ruleId], aws.ToString(association.VPCId))
+		}
+	}
+
+	return vpcIds, nil
+}
+
+func ListResolverQueryLogConfigs(ctx context.Context, resolverClient *route53resolver.Client) ([]resolvertypes.ResolverQueryLogConfig, error) {
+	var queryLogs []resolvertypes.ResolverQueryLogConfig
+	paginator := route53resolver.NewListResolverQueryLogConfigsPaginator(resolverClient, &route53resolver.ListResolverQueryLogConfigsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Resolver query logging configs. Here's why: %v\n", err)
+			return nil, err
+		}
+		queryLogs = append(queryLogs, page.ResolverQueryLogConfigs...)
+	}
+
+	return queryLogs, nil
+}
+
+func ListResolverQueryLogVPCs(ctx context.Context, resolverClient *route53resolver.Client) (map[string][]string, error) {
+	// The VPCs each query logging config is actively logging, keyed by config ID
+	vpcIds := map[string][]string{}
+	paginator := route53resolver.NewListResolverQueryLogConfigAssociationsPaginator(resolverClient, &route53resolver.ListResolverQueryLogConfigAssociationsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Resolver query logging associations. Here's why: %v\n", err)
+			return nil, err
+		}
+		for _, association := range page.ResolverQueryLogConfigAssociations {
+			if association.Status != resolvertypes.ResolverQueryLogConfigAssociationStatusActive {
+				continue
+			}
+			configId := aws.ToString(association.ResolverQueryLogConfigId)
+			vpcIds[configId] = append(vpcIds[configId], aws.ToString(association.ResourceId))
+		}
+	}
+
+	return vpcIds, nil
+}
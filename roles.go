@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+func RunRolesModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-roles API and decode who can assume each role, then what the role can do
+	// i.e. aws iam list-roles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting trust policies and permissions for every role...")
+	fmt.Println(MAJOR_SEPARATOR)
+	roles, err := CachedRoles(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list roles. Exiting...")
+		return err
+	}
+
+	// The permissions of several roles are fetched at once, then printed in order
+	allPermissions := make([]*RolePermissions, len(roles))
+	boundaryArns := make([]string, len(roles))
+	boundaries := make([]*PolicyDocument, len(roles))
+	ForEachConcurrently(ctx, len(roles), func(ctx context.Context, i int) {
+		// i.e. aws iam list-attached-role-policies, aws iam list-role-policies
+		allPermissions[i], _ = GetRolePermissions(ctx, iamClient, roles[i])
+		// i.e. aws iam get-role --role-name <role-name>, aws iam get-policy-version for the boundary
+		boundaryArns[i], boundaries[i], _ = GetPermissionsBoundary(ctx, iamClient, *roles[i].Arn)
+	}, func(i int) {
+		PrintRoleResult(roles[i], allPermissions[i], boundaryArns[i], boundaries[i])
+	})
+
+	return nil
+}
+
+func PrintRoleResult(role iamtypes.Role, permissions *RolePermissions, boundaryArn string, boundary *PolicyDocument) {
+	result := RoleResult{Role: role, Boundary: boundaryArn, BoundaryDocument: boundary}
+	fmt.Printf("\tRole name: %v\n", *role.RoleName)
+	fmt.Printf("\tRole ARN: %v\n", *role.Arn)
+
+	// The trust policy comes back URL-encoded in the list-roles response
+	if role.AssumeRolePolicyDocument != nil {
+		trustPolicy, err := ParsePolicyDocument(*role.AssumeRolePolicyDocument)
+		if err != nil {
+			fmt.Printf("Couldn't parse the trust policy for %v. Here's why: %v\n", *role.RoleName, err)
+		} else {
+			result.TrustPolicy = trustPolicy
+			PrintTrustPolicy(*role.Arn, trustPolicy)
+		}
+	}
+
+	PrintPermissionsBoundary(result.Boundary, result.BoundaryDocument)
+	if permissions != nil {
+		result.Permissions = permissions
+		var policyNames []string
+		for policyName := range permissions.Policies {
+			policyNames = append(policyNames, policyName)
+		}
+		sort.Strings(policyNames)
+		for _, policyName := range policyNames {
+			fmt.Printf("\tPolicy: %v\n", policyName)
+			if ResolveDocuments {
+				ShowPolicyDocument(PolicyDocumentKey("role/"+*role.RoleName, policyName), permissions.Policies[policyName])
+			}
+		}
+		for _, action := range permissions.Notable {
+			// The policies grant it, but the boundary doesn't let all of it through
+			bounded := result.BoundaryDocument != nil && !BoundaryAllowsAction(result.BoundaryDocument, action)
+			if action == "*" && bounded {
+				fmt.Println("\t[-] Full administrative access in its policies, narrowed by the permission boundary")
+				continue
+			}
+			if bounded {
+				fmt.Printf("\t[-] Outside the permission boundary: %v\n", action)
+				continue
+			}
+			if action == "*" {
+				fmt.Println("\t[!] Role has full administrative access")
+				continue
+			}
+			fmt.Printf("\t[!] Grants %v\n", action)
+		}
+		for _, action := range permissions.BlockedBySCP {
+			fmt.Printf("\t[-] Blocked by SCP: %v\n", action)
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("role", "", result)
+}
+
+func PrintTrustPolicy(roleArn string, trustPolicy *PolicyDocument) {
+	// Anything outside the role's own account is worth calling out
+	var roleAccount string
+	if parsedArn, err := arn.Parse(roleArn); err == nil {
+		roleAccount = parsedArn.AccountID
+	}
+
+	for _, statement := range trustPolicy.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		var principalTypes []string
+		for principalType := range statement.Principal {
+			principalTypes = append(principalTypes, principalType)
+		}
+		sort.Strings(principalTypes)
+		for _, principalType := range principalTypes {
+			for _, principal := range statement.Principal[principalType] {
+				fmt.Printf("\tTrusted %v: %v (%v)\n", principalType, principal, strings.Join(statement.Action, ", "))
+
+				if principalType != "AWS" {
+					continue
+				}
+				if principal == "*" && len(statement.Condition) == 0 {
+					fmt.Println("\t[!] Anyone can assume this role")
+					EmitExposedFinding("", roleArn, EXPOSURE_INTERNET, "Trust policy allows anyone to assume the role")
+					continue
+				}
+				if account := PrincipalAccount(principal); account != "" && account != roleAccount {
+					fmt.Printf("\t[-] Trusts account %v\n", account)
+					EmitExposedFinding("", roleArn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Trust policy allows account %v to assume the role", account))
+				}
+			}
+		}
+		if len(statement.Condition) > 0 {
+			fmt.Printf("\tConditions: %v\n", statement.Condition)
+		}
+	}
+}
+
+func PrincipalAccount(principal string) string {
+	// AWS principals are either a bare account ID or an ARN in some account
+	if parsedArn, err := arn.Parse(principal); err == nil {
+		return parsedArn.AccountID
+	}
+	if len(principal) == 12 && strings.Trim(principal, "0123456789") == "" {
+		return principal
+	}
+
+	return ""
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/rolesanywhere"
+	rolesanywheretypes "github.com/aws/aws-sdk-go-v2/service/rolesanywhere/types"
+)
+
+const ROLES_ANYWHERE_SERVICE = "rolesanywhere.amazonaws.com"
+
+type TrustAnchorResult struct {
+	Name    string `json:"name"`
+	Arn     string `json:"arn"`
+	Enabled bool   `json:"enabled"`
+	// AWS_ACM_PCA, CERTIFICATE_BUNDLE or SELF_SIGNED_REPOSITORY
+	SourceType string `json:"sourceType"`
+	// The private CA's ARN, or the subject of an uploaded CA certificate
+	CA         string `json:"ca"`
+	Expiration string `json:"expiration,omitempty"`
+}
+
+type RolesAnywhereProfileResult struct {
+	Name     string   `json:"name"`
+	Arn      string   `json:"arn"`
+	Enabled  bool     `json:"enabled"`
+	RoleArns []string `json:"roleArns"`
+	// Both cut down what the session can do, whatever the role allows
+	ManagedPolicyArns []string `json:"managedPolicyArns"`
+	SessionPolicy     bool     `json:"sessionPolicy"`
+	DurationSeconds   int32    `json:"durationSeconds"`
+}
+
+// A trust anchor whose certificates can be exchanged for a role's credentials through a profile
+type RolesAnywherePathResult struct {
+	TrustAnchor string `json:"trustAnchor"`
+	Profile     string `json:"profile"`
+	RoleArn     string `json:"roleArn"`
+	// Certificate subject and SAN conditions in the role's trust policy, empty when any certificate will do
+	SubjectConditions []string `json:"subjectConditions"`
+}
+
+func RunRolesAnywhereModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	// Roles can be shared by profiles in several regions, so each trust policy is only fetched once
+	trustPolicies := map[string]*PolicyDocument{}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		rolesanywhereClient := rolesanywhere.NewFromConfig(regionalConfig)
+
+		// Each trust anchor is a CA whose certificates IAM accepts in place of access keys
+		// i.e. aws rolesanywhere list-trust-anchors
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting IAM Roles Anywhere trust anchors and profiles in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		trustAnchors, err := ListTrustAnchors(ctx, rolesanywhereClient)
+		if err != nil {
+			return err
+		}
+		var anchors []TrustAnchorResult
+		anchorEnabled := map[string]bool{}
+		for _, trustAnchor := range trustAnchors {
+			result := NewTrustAnchorResult(trustAnchor)
+			anchors = append(anchors, result)
+			anchorEnabled[result.Name] = result.Enabled
+			fmt.Printf("\tTrust anchor: %v\n", result.Name)
+			fmt.Printf("\tEnabled: %v\n", result.Enabled)
+			fmt.Printf("\tSource: %v\n", result.SourceType)
+			fmt.Printf("\tCA: %v\n", result.CA)
+			if result.Expiration != "" {
+				fmt.Printf("\tCA certificate expires: %v\n", result.Expiration)
+			}
+			// A CA outside AWS is run by whoever uploaded it, its issuance isn't visible from the account
+			if result.SourceType != string(rolesanywheretypes.TrustAnchorTypeAwsAcmPca) {
+				fmt.Println("\t[-] External CA, certificates it issues can't be audited from AWS")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("trust-anchor", regionalConfig.Region, result)
+		}
+		if len(trustAnchors) == 0 {
+			fmt.Println("\tNo trust anchors in this region")
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+
+		// i.e. aws rolesanywhere list-profiles
+		profiles, err := ListRolesAnywhereProfiles(ctx, rolesanywhereClient)
+		if err != nil {
+			return err
+		}
+		for _, profile := range profiles {
+			result := RolesAnywhereProfileResult{
+				Name:              aws.ToString(profile.Name),
+				Arn:               aws.ToString(profile.ProfileArn),
+				Enabled:           aws.ToBool(profile.Enabled),
+				RoleArns:          profile.RoleArns,
+				ManagedPolicyArns: profile.ManagedPolicyArns,
+				SessionPolicy:     aws.ToString(profile.SessionPolicy) != "",
+				DurationSeconds:   aws.ToInt32(profile.DurationSeconds),
+			}
+			fmt.Printf("\tProfile: %v\n", result.Name)
+			fmt.Printf("\tEnabled: %v\n", result.Enabled)
+			fmt.Printf("\tSession duration: %vs\n", result.DurationSeconds)
+			for _, policyArn := range result.ManagedPolicyArns {
+				fmt.Printf("\tSession limited to: %v\n", policyArn)
+			}
+			if result.SessionPolicy {
+				fmt.Println("\tSession limited by an inline session policy")
+			}
+
+			// The profile names the roles, and each role's trust policy names the trust anchors
+			for _, roleArn := range result.RoleArns {
+				fmt.Printf("\tRole: %v\n", roleArn)
+				trustPolicy, ok := trustPolicies[roleArn]
+				if !ok {
+					// i.e. aws iam get-role --role-name <role-name>
+					trustPolicy = GetRoleTrustPolicy(ctx, iamClient, roleArn[strings.LastIndex(roleArn, "/")+1:])
+					trustPolicies[roleArn] = trustPolicy
+				}
+				if trustPolicy == nil {
+					continue
+				}
+				for _, path := range RolesAnywherePaths(trustPolicy, anchors, result.Name, roleArn) {
+					fmt.Printf("\t\tCertificates from %v can assume it\n", path.TrustAnchor)
+					for _, condition := range path.SubjectConditions {
+						fmt.Printf("\t\t\tCondition: %v\n", condition)
+					}
+					// Only a live profile with a live anchor actually hands out credentials
+					if result.Enabled && anchorEnabled[path.TrustAnchor] {
+						if len(path.SubjectConditions) == 0 {
+							fmt.Printf("\t\t[!] Any certificate %v issues can be exchanged for the role's credentials\n", path.TrustAnchor)
+							EmitFinding(regionalConfig.Region, roleArn, fmt.Sprintf("Any certificate trust anchor %v issues can be exchanged for the role's credentials through profile %v", path.TrustAnchor, result.Name))
+						} else {
+							EmitFinding(regionalConfig.Region, roleArn, fmt.Sprintf("Certificates from trust anchor %v can be exchanged for the role's credentials through profile %v", path.TrustAnchor, result.Name))
+						}
+					}
+					Emit("roles-anywhere-path", regionalConfig.Region, path)
+				}
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("roles-anywhere-profile", regionalConfig.Region, result)
+		}
+		if len(profiles) == 0 {
+			fmt.Println("\tNo profiles in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func NewTrustAnchorResult(trustAnchor rolesanywheretypes.TrustAnchorDetail) TrustAnchorResult {
+	result := TrustAnchorResult{
+		Name:    aws.ToString(trustAnchor.Name),
+		Arn:     aws.ToString(trustAnchor.TrustAnchorArn),
+		Enabled: aws.ToBool(trustAnchor.Enabled),
+	}
+	if trustAnchor.Source == nil {
+		return result
+	}
+	result.SourceType = string(trustAnchor.Source.SourceType)
+
+	// The source is either a private CA in ACM or the PEM of an uploaded CA certificate
+	switch source := trustAnchor.Source.SourceData.(type) {
+	case *rolesanywheretypes.SourceDataMemberAcmPcaArn:
+		result.CA = source.Value
+	case *rolesanywheretypes.SourceDataMemberX509CertificateData:
+		result.CA = "unreadable certificate"
+		block, _ := pem.Decode([]byte(source.Value))
+		if block == nil {
+			break
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			break
+		}
+		result.CA = certificate.Subject.String()
+		result.Expiration = certificate.NotAfter.String()
+	}
+
+	return result
+}
+
+func RolesAnywherePaths(trustPolicy *PolicyDocument, anchors []TrustAnchorResult, profile string, roleArn string) []RolesAnywherePathResult {
+	var paths []RolesAnywherePathResult
+	for _, statement := range trustPolicy.Statement {
+		if statement.Effect != "Allow" || !slices.Contains(statement.Principal["Service"], ROLES_ANYWHERE_SERVICE) {
+			continue
+		}
+
+		// aws:SourceArn narrows the statement to some trust anchors, without it any anchor in the account works.
+		// Conditions on the certificate's subject or SANs narrow it to some certificates
+		var sourceArns, subjectConditions []string
+		for operator, conditions := range statement.Condition {
+			for key, value := range conditions {
+				values := ConditionValues(value)
+				switch {
+				case strings.EqualFold(key, "aws:SourceArn"):
+					sourceArns = append(sourceArns, values...)
+				case strings.HasPrefix(key, "aws:PrincipalTag/x509"):
+					subjectConditions = append(subjectConditions, fmt.Sprintf("%v %v %v", key, operator, strings.Join(values, ", ")))
+				}
+			}
+		}
+		slices.Sort(subjectConditions)
+
+		for _, anchor := range anchors {
+			if len(sourceArns) > 0 && !ResourceCoveredBy(anchor.Arn, sourceArns) {
+				continue
+			}
+			paths = append(paths, RolesAnywherePathResult{TrustAnchor: anchor.Name, Profile: profile, RoleArn: roleArn, SubjectConditions: subjectConditions})
+		}
+	}
+
+	return paths
+}
+
+func ConditionValues(value any) []string {
+	// Condition values are a single string or a list of them
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []any:
+		var values []string
+		for _, item := range typed {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	}
+
+	return []string{fmt.Sprint(value)}
+}
+
+func GetRoleTrustPolicy(ctx context.Context, iamClient *iam.Client, roleName string) *PolicyDocument {
+	role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		fmt.Printf("Couldn't get the role %v. Here's why: %v\n", roleName, err)
+		return nil
+	}
+	if role.Role.AssumeRolePolicyDocument == nil {
+		return nil
+	}
+	trustPolicy, err := ParsePolicyDocument(*role.Role.AssumeRolePolicyDocument)
+	if err != nil {
+		fmt.Printf("Couldn't parse the trust policy for %v. Here's why: %v\n", roleName, err)
+		return nil
+	}
+
+	return trustPolicy
+}
+
+func ListTrustAnchors(ctx context.Context, rolesanywhereClient *rolesanywhere.Client) ([]rolesanywheretypes.TrustAnchorDetail, error) {
+	var trustAnchors []rolesanywheretypes.TrustAnchorDetail
+	paginator := rolesanywhere.NewListTrustAnchorsPaginator(rolesanywhereClient, &rolesanywhere.ListTrustAnchorsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(trustAnchors)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the trust anchors. Here's why: %v\n", err)
+			return nil, err
+		}
+		trustAnchors = append(trustAnchors, page.TrustAnchors...)
+	}
+
+	return LimitItems(trustAnchors), nil
+}
+
+func ListRolesAnywhereProfiles(ctx context.Context, rolesanywhereClient *rolesanywhere.Client) ([]rolesanywheretypes.ProfileDetail, error) {
+	var profiles []rolesanywheretypes.ProfileDetail
+	paginator := rolesanywhere.NewListProfilesPaginator(rolesanywhereClient, &rolesanywhere.ListProfilesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(profiles)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Roles Anywhere profiles. Here's why: %v\n", err)
+			return nil, err
+		}
+		profiles = append(profiles, page.Profiles...)
+	}
+
+	return LimitItems(profiles), nil
+}
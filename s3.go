@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// What a public principal needs to read a bucket's objects or change them
+var BUCKET_READ_ACTIONS = []string{"s3:GetObject", "s3:ListBucket"}
+var BUCKET_WRITE_ACTIONS = []string{"s3:PutObject", "s3:DeleteObject", "s3:PutBucketPolicy", "s3:PutBucketAcl"}
+
+// ACL grantees standing for everyone and for anyone with an AWS account
+const ALL_USERS_GRANTEE = "http://acs.amazonaws.com/groups/global/AllUsers"
+const AUTHENTICATED_USERS_GRANTEE = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+
+type BucketGrantResult struct {
+	Grantee    string `json:"grantee"`
+	Permission string `json:"permission"`
+}
+
+type BucketResult struct {
+	Name   string          `json:"name"`
+	Policy *PolicyDocument `json:"policy,omitempty"`
+	// AWS's own verdict on the policy, which takes its conditions into account
+	PolicyPublic bool                `json:"policyPublic"`
+	Grants       []BucketGrantResult `json:"grants"`
+	// Bucket settings combined with the account's, either one turning a setting on is enough
+	PublicAccessBlock *s3types.PublicAccessBlockConfiguration `json:"publicAccessBlock,omitempty"`
+	// i.e. aws:kms with its key, or AES256
+	Encryption  string `json:"encryption,omitempty"`
+	Versioning  string `json:"versioning,omitempty"`
+	MFADelete   string `json:"mfaDelete,omitempty"`
+	WebsiteHost bool   `json:"websiteHost"`
+	// How anyone can reach the bucket once the public access block is taken into account, i.e. policy or ACL
+	PublicRead  []string `json:"publicRead"`
+	PublicWrite []string `json:"publicWrite"`
+	// Public access the bucket's policy or ACL grants but the public access block stops
+	Blocked []string `json:"blocked"`
+	// Other accounts named in the policy
+	TrustedAccounts []string `json:"trustedAccounts"`
+	// With --sample-objects, whether the caller could list the bucket, how many objects were looked at
+	// and those whose names suggest they're sensitive
+	ObjectsListable    bool                      `json:"objectsListable"`
+	ObjectsSampled     int                       `json:"objectsSampled"`
+	InterestingObjects []InterestingObjectResult `json:"interestingObjects"`
+}
+
+func RunS3Module(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	// The account-wide block applies on top of each bucket's own
+	// i.e. aws s3control get-public-access-block --account-id <account-id>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the account's S3 public access block...")
+	fmt.Println(MAJOR_SEPARATOR)
+	accountBlock, err := GetAccountPublicAccessBlock(ctx, s3control.NewFromConfig(sdkConfig), account)
+	if err == nil && accountBlock == nil {
+		fmt.Println("\t[-] The account has no S3 public access block, each bucket relies on its own")
+	} else if accountBlock != nil {
+		fmt.Printf("\tBlock ACLs %v, ignore ACLs %v, block policy %v, restrict policy %v\n", aws.ToBool(accountBlock.BlockPublicAcls), aws.ToBool(accountBlock.IgnorePublicAcls), aws.ToBool(accountBlock.BlockPublicPolicy), aws.ToBool(accountBlock.RestrictPublicBuckets))
+	}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		s3Client := s3.NewFromConfig(regionalConfig)
+
+		// i.e. aws s3api list-buckets --bucket-region <region>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting S3 buckets in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		buckets, err := ListBuckets(ctx, s3Client, regionalConfig.Region)
+		if err != nil {
+			return err
+		}
+
+		results := make([]BucketResult, len(buckets))
+		bucketErrors := make([]error, len(buckets))
+		ForEachConcurrently(ctx, len(buckets), func(ctx context.Context, i int) {
+			results[i], bucketErrors[i] = GetBucketResult(ctx, s3Client, aws.ToString(buckets[i].Name), account, accountBlock)
+		}, func(i int) {
+			if bucketErrors[i] == nil {
+				PrintBucketResult(regionalConfig.Region, results[i])
+			}
+		})
+		if len(buckets) == 0 {
+			fmt.Println("\tNo buckets in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func PrintBucketResult(region string, result BucketResult) {
+	bucketArn := "arn:aws:s3:::" + result.Name
+	fmt.Printf("\tBucket: %v\n", result.Name)
+	if result.Policy != nil {
+		fmt.Printf("\tPolicy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	PrintUnresolvedPrincipals(region, bucketArn, result.Policy)
+	for _, grant := range result.Grants {
+		fmt.Printf("\tACL grant: %v to %v\n", grant.Permission, grant.Grantee)
+	}
+	if block := result.PublicAccessBlock; block != nil {
+		fmt.Printf("\tPublic access block: block ACLs %v, ignore ACLs %v, block policy %v, restrict policy %v\n", aws.ToBool(block.BlockPublicAcls), aws.ToBool(block.IgnorePublicAcls), aws.ToBool(block.BlockPublicPolicy), aws.ToBool(block.RestrictPublicBuckets))
+	} else {
+		fmt.Println("\tPublic access block: none")
+	}
+	fmt.Printf("\tEncryption: %v\n", result.Encryption)
+	fmt.Printf("\tVersioning: %v\n", result.Versioning)
+	if result.MFADelete != "" {
+		fmt.Printf("\tMFA delete: %v\n", result.MFADelete)
+	}
+	if result.WebsiteHost {
+		fmt.Println("\tStatic website hosting: on")
+	}
+
+	for _, source := range result.PublicRead {
+		fmt.Printf("\t[!] Anyone can read objects through the bucket %v\n", source)
+		EmitExposedFinding(region, bucketArn, EXPOSURE_INTERNET, fmt.Sprintf("Bucket %v is readable by anyone through its %v", result.Name, source))
+	}
+	for _, source := range result.PublicWrite {
+		fmt.Printf("\t[!] Anyone can write or delete objects through the bucket %v\n", source)
+		EmitExposedFinding(region, bucketArn, EXPOSURE_INTERNET, fmt.Sprintf("Bucket %v is writable by anyone through its %v", result.Name, source))
+	}
+	for _, blocked := range result.Blocked {
+		fmt.Printf("\t[-] The %v grants public access, but the public access block stops it\n", blocked)
+	}
+	for _, trustedAccount := range result.TrustedAccounts {
+		fmt.Printf("\t[-] Policy grants access to account %v\n", trustedAccount)
+		EmitExposedFinding(region, bucketArn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Bucket policy grants access to account %v", trustedAccount))
+	}
+	if SampleObjects > 0 && !result.ObjectsListable {
+		fmt.Println("\t[-] Objects can't be listed with the current credentials")
+	} else if SampleObjects > 0 {
+		fmt.Printf("\tObjects sampled: %v\n", result.ObjectsSampled)
+	}
+	for _, object := range result.InterestingObjects {
+		fmt.Printf("\t[!] Interesting object: %v (%v bytes, modified %v, matches %v)\n", object.Key, object.Size, object.LastModified.UTC().Format(time.RFC3339), object.Pattern)
+		EmitFinding(region, bucketArn, fmt.Sprintf("Bucket %v holds %v, which looks sensitive", result.Name, object.Key))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("bucket", region, result)
+}
+
+func GetBucketResult(ctx context.Context, s3Client *s3.Client, bucket string, account string, accountBlock *s3types.PublicAccessBlockConfiguration) (BucketResult, error) {
+	result := BucketResult{Name: bucket}
+
+	// Settings that were never configured come back as errors, which just mean they're off
+	// i.e. aws s3api get-bucket-policy --bucket <bucket>
+	policy, err := s3Client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+	if err != nil && S3ErrorCode(err) != "NoSuchBucketPolicy" {
+		fmt.Printf("Couldn't get the policy for %v. Here's why: %v\n", bucket, err)
+	} else if err == nil {
+		result.Policy, err = ParsePolicyDocument(aws.ToString(policy.Policy))
+		if err != nil {
+			fmt.Printf("Couldn't parse the policy for %v. Here's why: %v\n", bucket, err)
+		}
+	}
+	policyStatusKnown := false
+	if result.Policy != nil {
+		// i.e. aws s3api get-bucket-policy-status --bucket <bucket>
+		status, err := s3Client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: aws.String(bucket)})
+		if err == nil && status.PolicyStatus != nil {
+			result.PolicyPublic = aws.ToBool(status.PolicyStatus.IsPublic)
+			policyStatusKnown = true
+		}
+	}
+
+	// i.e. aws s3api get-bucket-acl --bucket <bucket>
+	acl, err := s3Client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		fmt.Printf("Couldn't get the ACL for %v. Here's why: %v\n", bucket, err)
+		return result, err
+	}
+	for _, grant := range acl.Grants {
+		if grant.Grantee == nil {
+			continue
+		}
+		grantee := aws.ToString(grant.Grantee.URI)
+		if grantee == "" {
+			grantee = aws.ToString(grant.Grantee.DisplayName)
+		}
+		if grantee == "" {
+			grantee = aws.ToString(grant.Grantee.ID)
+		}
+		result.Grants = append(result.Grants, BucketGrantResult{Grantee: grantee, Permission: string(grant.Permission)})
+	}
+
+	// i.e. aws s3api get-public-access-block --bucket <bucket>
+	block, err := s3Client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucket)})
+	if err != nil && S3ErrorCode(err) != "NoSuchPublicAccessBlockConfiguration" {
+		fmt.Printf("Couldn't get the public access block for %v. Here's why: %v\n", bucket, err)
+	}
+	var bucketBlock *s3types.PublicAccessBlockConfiguration
+	if err == nil {
+		bucketBlock = block.PublicAccessBlockConfiguration
+	}
+	result.PublicAccessBlock = CombinePublicAccessBlocks(bucketBlock, accountBlock)
+
+	// i.e. aws s3api get-bucket-encryption --bucket <bucket>
+	encryption, err := s3Client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucket)})
+	if err == nil && encryption.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault == nil {
+				continue
+			}
+			result.Encryption = string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+			if keyId := aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID); keyId != "" {
+				result.Encryption += " with " + keyId
+			}
+		}
+	} else if err != nil && S3ErrorCode(err) != "ServerSideEncryptionConfigurationNotFoundError" {
+		fmt.Printf("Couldn't get the encryption for %v. Here's why: %v\n", bucket, err)
+	}
+	if result.Encryption == "" {
+		result.Encryption = "none"
+	}
+
+	// i.e. aws s3api get-bucket-versioning --bucket <bucket>
+	versioning, err := s3Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		result.Versioning = string(versioning.Status)
+		result.MFADelete = string(versioning.MFADelete)
+	}
+	// A bucket that never had versioning turned on has no status at all
+	if result.Versioning == "" {
+		result.Versioning = "Disabled"
+	}
+
+	// i.e. aws s3api get-bucket-website --bucket <bucket>
+	_, err = s3Client.GetBucketWebsite(ctx, &s3.GetBucketWebsiteInput{Bucket: aws.String(bucket)})
+	result.WebsiteHost = err == nil
+
+	AnalyseBucketAccess(&result, account, policyStatusKnown)
+
+	// Only names and sizes are listed, nothing is downloaded
+	if SampleObjects > 0 {
+		result.ObjectsSampled, result.InterestingObjects, err = SampleBucketObjects(ctx, s3Client, bucket)
+		if err != nil && S3ErrorCode(err) != "AccessDenied" {
+			fmt.Printf("Couldn't list the objects in %v. Here's why: %v\n", bucket, err)
+		}
+		result.ObjectsListable = err == nil || result.ObjectsSampled > 0
+	}
+
+	return result, nil
+}
+
+func AnalyseBucketAccess(result *BucketResult, account string, policyStatusKnown bool) {
+	block := result.PublicAccessBlock
+	if block == nil {
+		block = &s3types.PublicAccessBlockConfiguration{}
+	}
+
+	// Policy statements open to everyone, unconditional ones only when AWS's verdict is unknown.
+	// RestrictPublicBuckets is what stops an existing public policy, BlockPublicPolicy only stops new ones
+	if result.Policy != nil {
+		read, write := false, false
+		for _, statement := range result.Policy.Statement {
+			if statement.Effect != "Allow" {
+				continue
+			}
+			for _, principal := range statement.Principal["AWS"] {
+				if principal == "*" {
+					continue
+				}
+				if trustedAccount := PrincipalAccount(principal); trustedAccount != "" && trustedAccount != account && !slices.Contains(result.TrustedAccounts, trustedAccount) {
+					result.TrustedAccounts = append(result.TrustedAccounts, trustedAccount)
+				}
+			}
+			if !slices.Contains(statement.Principal["AWS"], "*") {
+				continue
+			}
+			if (policyStatusKnown && !result.PolicyPublic) || (!policyStatusKnown && len(statement.Condition) > 0) {
+				continue
+			}
+			read = read || slices.ContainsFunc(BUCKET_READ_ACTIONS, func(action string) bool { return StatementCoversAction(statement, action) })
+			write = write || slices.ContainsFunc(BUCKET_WRITE_ACTIONS, func(action string) bool { return StatementCoversAction(statement, action) })
+		}
+		if (read || write) && aws.ToBool(block.RestrictPublicBuckets) {
+			result.Blocked = append(result.Blocked, "policy")
+		} else {
+			if read {
+				result.PublicRead = append(result.PublicRead, "policy")
+			}
+			if write {
+				result.PublicWrite = append(result.PublicWrite, "policy")
+			}
+		}
+	}
+
+	// Anyone with an AWS account is as good as anyone at all
+	read, write := false, false
+	for _, grant := range result.Grants {
+		if grant.Grantee != ALL_USERS_GRANTEE && grant.Grantee != AUTHENTICATED_USERS_GRANTEE {
+			continue
+		}
+		switch s3types.Permission(grant.Permission) {
+		case s3types.PermissionRead:
+			read = true
+		case s3types.PermissionWrite, s3types.PermissionWriteAcp:
+			write = true
+		case s3types.PermissionFullControl:
+			read, write = true, true
+		}
+	}
+	if (read || write) && aws.ToBool(block.IgnorePublicAcls) {
+		result.Blocked = append(result.Blocked, "ACL")
+	} else {
+		if read {
+			result.PublicRead = append(result.PublicRead, "ACL")
+		}
+		if write {
+			result.PublicWrite = append(result.PublicWrite, "ACL")
+		}
+	}
+}
+
+func CombinePublicAccessBlocks(bucketBlock *s3types.PublicAccessBlockConfiguration, accountBlock *s3types.PublicAccessBlockConfiguration) *s3types.PublicAccessBlockConfiguration {
+	if bucketBlock == nil && accountBlock == nil {
+		return nil
+	}
+	combined := &s3types.PublicAccessBlockConfiguration{}
+	for _, block := range []*s3types.PublicAccessBlockConfiguration{bucketBlock, accountBlock} {
+		if block == nil {
+			continue
+		}
+		combined.BlockPublicAcls = aws.Bool(aws.ToBool(combined.BlockPublicAcls) || aws.ToBool(block.BlockPublicAcls))
+		combined.IgnorePublicAcls = aws.Bool(aws.ToBool(combined.IgnorePublicAcls) || aws.ToBool(block.IgnorePublicAcls))
+		combined.BlockPublicPolicy = aws.Bool(aws.ToBool(combined.BlockPublicPolicy) || aws.ToBool(block.BlockPublicPolicy))
+		combined.RestrictPublicBuckets = aws.Bool(aws.ToBool(combined.RestrictPublicBuckets) || aws.ToBool(block.RestrictPublicBuckets))
+	}
+
+	return combined
+}
+
+func GetAccountPublicAccessBlock(ctx context.Context, s3controlClient *s3control.Client, account string) (*s3types.PublicAccessBlockConfiguration, error) {
+	// An account that never set one comes back as not found, which is returned as nil
+	output, err := s3controlClient.GetPublicAccessBlock(ctx, &s3control.GetPublicAccessBlockInput{AccountId: aws.String(account)})
+	if err != nil {
+		if S3ErrorCode(err) == "NoSuchPublicAccessBlockConfiguration" {
+			return nil, nil
+		}
+		fmt.Printf("Couldn't get the account's S3 public access block. Here's why: %v\n", err)
+		return nil, err
+	}
+	if output.PublicAccessBlockConfiguration == nil {
+		return nil, nil
+	}
+
+	// The s3control type has the same settings as the s3 one
+	block := output.PublicAccessBlockConfiguration
+	return &s3types.PublicAccessBlockConfiguration{
+		BlockPublicAcls:       block.BlockPublicAcls,
+		IgnorePublicAcls:      block.IgnorePublicAcls,
+		BlockPublicPolicy:     block.BlockPublicPolicy,
+		RestrictPublicBuckets: block.RestrictPublicBuckets,
+	}, nil
+}
+
+func S3ErrorCode(err error) string {
+	// S3 reports missing configuration with error codes that have no type of their own, i.e. NoSuchBucketPolicy
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		return apiError.ErrorCode()
+	}
+
+	return ""
+}
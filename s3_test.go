@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestAnalyseBucketAccess(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy string
+		block  *s3types.PublicAccessBlockConfiguration
+		// AWS's verdict on the policy, nil when it isn't known
+		policyPublic *bool
+		wantRead     []string
+		wantWrite    []string
+		wantBlocked  []string
+		wantTrusted  []string
+	}{
+		{
+			name:     "public read",
+			policy:   `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			wantRead: []string{"policy"},
+		},
+		{
+			name:     "public list in the AWS form",
+			policy:   `{"Statement":{"Effect":"Allow","Principal":{"AWS":"*"},"Action":["s3:ListBucket"],"Resource":"arn:aws:s3:::bucket"}}`,
+			wantRead: []string{"policy"},
+		},
+		{
+			name:      "public write",
+			policy:    `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:PutObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			wantWrite: []string{"policy"},
+		},
+		{
+			name:      "public everything",
+			policy:    `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:*","Resource":["arn:aws:s3:::bucket","arn:aws:s3:::bucket/*"]}}`,
+			wantRead:  []string{"policy"},
+			wantWrite: []string{"policy"},
+		},
+		{
+			name:   "public but something else",
+			policy: `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetBucketLocation","Resource":"arn:aws:s3:::bucket"}}`,
+		},
+		{
+			name:   "public with a condition",
+			policy: `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"StringEquals":{"aws:SourceVpce":"vpce-1a2b3c4d"}}}}`,
+		},
+		{
+			name:         "public with a condition AWS says is public",
+			policy:       `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"Bool":{"aws:SecureTransport":"true"}}}}`,
+			policyPublic: aws.Bool(true),
+			wantRead:     []string{"policy"},
+		},
+		{
+			name:         "public AWS says isn't",
+			policy:       `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			policyPublic: aws.Bool(false),
+		},
+		{
+			name:        "public but restricted",
+			policy:      `{"Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			block:       &s3types.PublicAccessBlockConfiguration{RestrictPublicBuckets: aws.Bool(true)},
+			wantBlocked: []string{"policy"},
+		},
+		{
+			name:   "denied to everyone",
+			policy: `{"Statement":{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::bucket/*"}}`,
+		},
+		{
+			name:        "other account",
+			policy:      `{"Statement":{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::210987654321:root","arn:aws:iam::123456789012:role/own"]},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			wantTrusted: []string{"210987654321"},
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		result := &BucketResult{Name: "bucket", Policy: policy, PublicAccessBlock: test.block, PolicyPublic: aws.ToBool(test.policyPublic)}
+		AnalyseBucketAccess(result, "123456789012", test.policyPublic != nil)
+		if !slices.Equal(result.PublicRead, test.wantRead) {
+			t.Errorf("%v: public read through %v, want %v", test.name, result.PublicRead, test.wantRead)
+		}
+		if !slices.Equal(result.PublicWrite, test.wantWrite) {
+			t.Errorf("%v: public write through %v, want %v", test.name, result.PublicWrite, test.wantWrite)
+		}
+		if !slices.Equal(result.Blocked, test.wantBlocked) {
+			t.Errorf("%v: blocked %v, want %v", test.name, result.Blocked, test.wantBlocked)
+		}
+		if !slices.Equal(result.TrustedAccounts, test.wantTrusted) {
+			t.Errorf("%v: trusted accounts %v, want %v", test.name, result.TrustedAccounts, test.wantTrusted)
+		}
+	}
+}
+
+func TestAnalyseBucketAccessGrants(t *testing.T) {
+	result := &BucketResult{
+		Name: "bucket",
+		Grants: []BucketGrantResult{
+			{Grantee: ALL_USERS_GRANTEE, Permission: string(s3types.PermissionRead)},
+			{Grantee: AUTHENTICATED_USERS_GRANTEE, Permission: string(s3types.PermissionWrite)},
+			{Grantee: "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be", Permission: string(s3types.PermissionFullControl)},
+		},
+	}
+	AnalyseBucketAccess(result, "123456789012", false)
+	if !slices.Equal(result.PublicRead, []string{"ACL"}) || !slices.Equal(result.PublicWrite, []string{"ACL"}) {
+		t.Fatalf("public read through %v and write through %v, want the ACL for both", result.PublicRead, result.PublicWrite)
+	}
+
+	result = &BucketResult{
+		Name:              "bucket",
+		Grants:            []BucketGrantResult{{Grantee: ALL_USERS_GRANTEE, Permission: string(s3types.PermissionFullControl)}},
+		PublicAccessBlock: &s3types.PublicAccessBlockConfiguration{IgnorePublicAcls: aws.Bool(true)},
+	}
+	AnalyseBucketAccess(result, "123456789012", false)
+	if len(result.PublicRead) > 0 || !slices.Equal(result.Blocked, []string{"ACL"}) {
+		t.Fatalf("public read through %v and blocked %v, want only the ACL blocked", result.PublicRead, result.Blocked)
+	}
+}
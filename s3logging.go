@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// A trail logging S3 data events for a bucket, and which of them
+type DataEventTrailResult struct {
+	Trail  string `json:"trail"`
+	Reads  bool   `json:"reads"`
+	Writes bool   `json:"writes"`
+}
+
+type BucketLoggingResult struct {
+	Bucket string `json:"bucket"`
+	// Where server access logs go, i.e. logs-bucket/s3/my-bucket/, empty when they're off
+	AccessLogTarget string                 `json:"accessLogTarget,omitempty"`
+	DataEventTrails []DataEventTrailResult `json:"dataEventTrails"`
+	// Whether either source would record someone reading or writing objects
+	ReadsDetected  bool `json:"readsDetected"`
+	WritesDetected bool `json:"writesDetected"`
+}
+
+// A trail with the event selectors that decide which data events it records
+type TrailSelectors struct {
+	Trail     cloudtrailtypes.Trail
+	IsLogging bool
+	Selectors *cloudtrail.GetEventSelectorsOutput
+}
+
+func RunS3LoggingModule(ctx context.Context, sdkConfig aws.Config) error {
+	// Multi-region trails show up in every region, so each is only looked up once
+	selectorsByTrail := map[string]*TrailSelectors{}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		s3Client := s3.NewFromConfig(regionalConfig)
+
+		// i.e. aws s3api list-buckets --bucket-region <region>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking S3 access logging and CloudTrail data events in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		buckets, err := ListBuckets(ctx, s3Client, regionalConfig.Region)
+		if err != nil {
+			return err
+		}
+		if len(buckets) == 0 {
+			fmt.Println("\tNo buckets in this region")
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+
+		// The trails recording this region, its own and multi-region ones from elsewhere
+		// i.e. aws cloudtrail describe-trails --include-shadow-trails
+		trails, err := ListTrails(ctx, cloudtrail.NewFromConfig(regionalConfig))
+		if err != nil {
+			return err
+		}
+		var regionTrails []*TrailSelectors
+		for _, trail := range trails {
+			trailArn := aws.ToString(trail.TrailARN)
+			if _, ok := selectorsByTrail[trailArn]; !ok {
+				selectorsByTrail[trailArn] = GetTrailSelectors(ctx, sdkConfig, trail)
+			}
+			regionTrails = append(regionTrails, selectorsByTrail[trailArn])
+		}
+		for _, trail := range regionTrails {
+			fmt.Printf("\tTrail: %v\n", aws.ToString(trail.Trail.Name))
+			if !trail.IsLogging {
+				fmt.Println("\t\t[-] Not logging, so it records nothing")
+			}
+		}
+		if len(regionTrails) == 0 {
+			fmt.Println("\tNo trails record this region")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+
+		// i.e. aws s3api get-bucket-logging --bucket <bucket>
+		results := make([]BucketLoggingResult, len(buckets))
+		loggingErrors := make([]error, len(buckets))
+		ForEachConcurrently(ctx, len(buckets), func(ctx context.Context, i int) {
+			results[i], loggingErrors[i] = GetBucketLoggingResult(ctx, s3Client, aws.ToString(buckets[i].Name), regionTrails)
+		}, func(i int) {
+			result := results[i]
+			if loggingErrors[i] != nil {
+				return
+			}
+			fmt.Printf("\tBucket: %v\n", result.Bucket)
+			if result.AccessLogTarget != "" {
+				fmt.Printf("\tServer access logs: %v\n", result.AccessLogTarget)
+			} else {
+				fmt.Println("\tServer access logs: off")
+			}
+			for _, trail := range result.DataEventTrails {
+				fmt.Printf("\tData events: %v (reads %v, writes %v)\n", trail.Trail, trail.Reads, trail.Writes)
+			}
+			// Management events never include GetObject or PutObject, so without either source
+			// objects can be read or replaced without a trace
+			if !result.ReadsDetected && !result.WritesDetected {
+				fmt.Println("\t[!] Object reads and writes aren't logged anywhere")
+				EmitFinding(regionalConfig.Region, "arn:aws:s3:::"+result.Bucket, fmt.Sprintf("Object reads and writes in bucket %v aren't logged by server access logging or CloudTrail data events", result.Bucket))
+			} else if !result.ReadsDetected {
+				fmt.Println("\t[!] Object reads aren't logged anywhere")
+				EmitFinding(regionalConfig.Region, "arn:aws:s3:::"+result.Bucket, fmt.Sprintf("Object reads in bucket %v aren't logged by server access logging or CloudTrail data events", result.Bucket))
+			} else if !result.WritesDetected {
+				fmt.Println("\t[-] Object writes aren't logged anywhere")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("bucket-logging", regionalConfig.Region, result)
+		})
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetBucketLoggingResult(ctx context.Context, s3Client *s3.Client, bucket string, trails []*TrailSelectors) (BucketLoggingResult, error) {
+	result := BucketLoggingResult{Bucket: bucket}
+
+	logging, err := s3Client.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		fmt.Printf("Couldn't get the logging configuration for %v. Here's why: %v\n", bucket, err)
+		return result, err
+	}
+	// Server access logs record reads and writes alike, if hours late and best effort
+	if logging.LoggingEnabled != nil {
+		result.AccessLogTarget = aws.ToString(logging.LoggingEnabled.TargetBucket) + "/" + aws.ToString(logging.LoggingEnabled.TargetPrefix)
+		result.ReadsDetected = true
+		result.WritesDetected = true
+	}
+
+	for _, trail := range trails {
+		if !trail.IsLogging || trail.Selectors == nil {
+			continue
+		}
+		reads, writes := TrailCoversBucket(trail.Selectors, bucket)
+		if !reads && !writes {
+			continue
+		}
+		result.DataEventTrails = append(result.DataEventTrails, DataEventTrailResult{Trail: aws.ToString(trail.Trail.Name), Reads: reads, Writes: writes})
+		result.ReadsDetected = result.ReadsDetected || reads
+		result.WritesDetected = result.WritesDetected || writes
+	}
+
+	return result, nil
+}
+
+func TrailCoversBucket(selectors *cloudtrail.GetEventSelectorsOutput, bucket string) (bool, bool) {
+	// Only selectors covering every object in the bucket count, a prefix or a handful of
+	// object ARNs leaves the rest unrecorded
+	bucketPrefix := "arn:aws:s3:::" + bucket + "/"
+	reads, writes := false, false
+
+	// Basic selectors, i.e. Type AWS::S3::Object with Values arn:aws:s3 for every bucket
+	for _, selector := range selectors.EventSelectors {
+		for _, resource := range selector.DataResources {
+			if aws.ToString(resource.Type) != "AWS::S3::Object" {
+				continue
+			}
+			covered := slices.ContainsFunc(resource.Values, func(value string) bool {
+				return value == "arn:aws:s3" || strings.HasPrefix(bucketPrefix, value)
+			})
+			if !covered {
+				continue
+			}
+			reads = reads || selector.ReadWriteType != cloudtrailtypes.ReadWriteTypeWriteOnly
+			writes = writes || selector.ReadWriteType != cloudtrailtypes.ReadWriteTypeReadOnly
+		}
+	}
+
+	// Advanced selectors, i.e. eventCategory = Data, resources.type = AWS::S3::Object
+	for _, selector := range selectors.AdvancedEventSelectors {
+		data, objects, covered := false, false, true
+		selectorReads, selectorWrites := true, true
+		for _, field := range selector.FieldSelectors {
+			switch aws.ToString(field.Field) {
+			case "eventCategory":
+				data = slices.Contains(field.Equals, "Data")
+			case "resources.type":
+				objects = slices.Contains(field.Equals, "AWS::S3::Object")
+			case "resources.ARN":
+				covered = covered && len(field.Equals) == 0 && len(field.EndsWith) == 0 && len(field.NotEquals) == 0 && len(field.NotEndsWith) == 0
+				if len(field.StartsWith) > 0 {
+					covered = covered && slices.ContainsFunc(field.StartsWith, func(value string) bool {
+						return strings.HasPrefix(bucketPrefix, value)
+					})
+				}
+				if slices.ContainsFunc(field.NotStartsWith, func(value string) bool {
+					return strings.HasPrefix(bucketPrefix, value) || strings.HasPrefix(value, bucketPrefix)
+				}) {
+					covered = false
+				}
+			case "readOnly":
+				selectorReads = !slices.Contains(field.Equals, "false")
+				selectorWrites = !slices.Contains(field.Equals, "true")
+			case "eventName":
+				// Picking out individual calls, i.e. only DeleteObject, isn't coverage
+				covered = false
+			}
+		}
+		if !data || !objects || !covered {
+			continue
+		}
+		reads = reads || selectorReads
+		writes = writes || selectorWrites
+	}
+
+	return reads, writes
+}
+
+func GetTrailSelectors(ctx context.Context, sdkConfig aws.Config, trail cloudtrailtypes.Trail) *TrailSelectors {
+	// Shadow copies of multi-region trails are looked up in the trail's home region
+	homeConfig := sdkConfig.Copy()
+	homeConfig.Region = aws.ToString(trail.HomeRegion)
+	cloudtrailClient := cloudtrail.NewFromConfig(homeConfig)
+	result := &TrailSelectors{Trail: trail}
+
+	// i.e. aws cloudtrail get-trail-status --name <trail-arn>
+	status, err := cloudtrailClient.GetTrailStatus(ctx, &cloudtrail.GetTrailStatusInput{Name: trail.TrailARN})
+	if err != nil {
+		fmt.Printf("Couldn't get the status of %v. Here's why: %v\n", aws.ToString(trail.Name), err)
+	} else {
+		result.IsLogging = aws.ToBool(status.IsLogging)
+	}
+
+	// i.e. aws cloudtrail get-event-selectors --trail-name <trail-arn>
+	selectors, err := cloudtrailClient.GetEventSelectors(ctx, &cloudtrail.GetEventSelectorsInput{TrailName: trail.TrailARN})
+	if err != nil {
+		fmt.Printf("Couldn't get the event selectors for %v. Here's why: %v\n", aws.ToString(trail.Name), err)
+	} else {
+		result.Selectors = selectors
+	}
+
+	return result
+}
+
+func ListBuckets(ctx context.Context, s3Client *s3.Client, region string) ([]s3types.Bucket, error) {
+	// The bucket listing is global, filtered down to the buckets in one region unless it's empty
+	input := &s3.ListBucketsInput{}
+	if region != "" {
+		input.BucketRegion = aws.String(region)
+	}
+	var buckets []s3types.Bucket
+	paginator := s3.NewListBucketsPaginator(s3Client, input)
+	for paginator.HasMorePages() && !ReachedMaxItems(len(buckets)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the buckets. Here's why: %v\n", err)
+			return nil, err
+		}
+		buckets = append(buckets, page.Buckets...)
+	}
+
+	return LimitItems(buckets), nil
+}
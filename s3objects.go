@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Object names that usually hold keys, credentials, infrastructure state or data dumps
+var INTERESTING_OBJECT_PATTERNS = []string{
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.ppk", "id_rsa*", "id_ed25519*", "*.kdbx",
+	".env", "*.env", ".env.*", "credentials", "credentials.*", "*.htpasswd", ".npmrc", ".pypirc", ".git-credentials",
+	"terraform.tfstate", "*.tfstate", "*.tfstate.backup", "*.tfvars",
+	"*.sql", "*.sql.gz", "*.dump", "*.bak", "*.backup",
+}
+
+// Most objects listed in each bucket the caller can read, set with --sample-objects. 0 turns sampling off
+var SampleObjects = 0
+
+// How many folders below the bucket root sampling goes, set with --sample-depth
+var SampleDepth = 2
+
+// Object name patterns sampling flags, set with --sample-patterns
+var SamplePatterns = INTERESTING_OBJECT_PATTERNS
+
+type InterestingObjectResult struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	// The pattern from --sample-patterns it matched
+	Pattern string `json:"pattern"`
+}
+
+func SampleBucketObjects(ctx context.Context, s3Client *s3.Client, bucket string) (int, []InterestingObjectResult, error) {
+	// Walk the bucket one folder level at a time so a single deep prefix can't use up the whole sample
+	var sampled int
+	var interesting []InterestingObjectResult
+	prefixes := []string{""}
+	for depth := 0; depth <= SampleDepth && len(prefixes) > 0 && sampled < SampleObjects; depth++ {
+		var nextPrefixes []string
+		for _, prefix := range prefixes {
+			// i.e. aws s3api list-objects-v2 --bucket <bucket> --prefix <prefix> --delimiter /
+			paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+				Bucket:    aws.String(bucket),
+				Prefix:    aws.String(prefix),
+				Delimiter: aws.String("/"),
+			})
+			for paginator.HasMorePages() && sampled < SampleObjects {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return sampled, interesting, err
+				}
+				for _, object := range page.Contents {
+					if sampled >= SampleObjects {
+						break
+					}
+					sampled++
+					if pattern, ok := MatchInterestingObject(aws.ToString(object.Key)); ok {
+						interesting = append(interesting, InterestingObjectResult{
+							Key:          aws.ToString(object.Key),
+							Size:         aws.ToInt64(object.Size),
+							LastModified: aws.ToTime(object.LastModified),
+							Pattern:      pattern,
+						})
+					}
+				}
+				for _, commonPrefix := range page.CommonPrefixes {
+					nextPrefixes = append(nextPrefixes, aws.ToString(commonPrefix.Prefix))
+				}
+			}
+		}
+		prefixes = nextPrefixes
+	}
+
+	return sampled, interesting, nil
+}
+
+func MatchInterestingObject(key string) (string, bool) {
+	// Patterns with a slash match the whole key, the rest just the object's name, ignoring case
+	lowerKey := strings.ToLower(key)
+	for _, pattern := range SamplePatterns {
+		lowerPattern := strings.ToLower(pattern)
+		name := path.Base(lowerKey)
+		if strings.Contains(lowerPattern, "/") {
+			name = lowerKey
+		}
+		if matched, _ := path.Match(lowerPattern, name); matched {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+)
+
+// Targets that run arbitrary code or commands with whatever permissions they're given
+var PRIVILEGED_TARGET_SERVICES = map[string]bool{
+	"lambda":    true,
+	"ssm":       true,
+	"codebuild": true,
+	"ecs":       true,
+	"states":    true,
+	"batch":     true,
+	"sagemaker": true,
+	"iam":       true,
+	"scheduler": true,
+}
+
+// Managed policies that make a schedule's role worth flagging on its own
+var PRIVILEGED_MANAGED_POLICIES = map[string]bool{
+	"arn:aws:iam::aws:policy/AdministratorAccess": true,
+	"arn:aws:iam::aws:policy/IAMFullAccess":       true,
+	"arn:aws:iam::aws:policy/PowerUserAccess":     true,
+}
+
+// Schedules and scheduled rules share a shape in the results, rules just have more than one target
+type ScheduleResult struct {
+	Name       string           `json:"name"`
+	Arn        string           `json:"arn"`
+	Group      string           `json:"group,omitempty"`
+	Expression string           `json:"expression"`
+	State      string           `json:"state"`
+	RoleArn    string           `json:"roleArn,omitempty"`
+	Targets    []ScheduleTarget `json:"targets"`
+}
+
+type ScheduleTarget struct {
+	Arn     string `json:"arn"`
+	RoleArn string `json:"roleArn,omitempty"`
+}
+
+func RunSchedulesModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	privilegedRoles := map[string]bool{}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		schedulerClient := scheduler.NewFromConfig(regionalConfig)
+		eventbridgeClient := eventbridge.NewFromConfig(regionalConfig)
+
+		// Call the list-schedules API to get every EventBridge Scheduler schedule
+		// i.e. aws scheduler list-schedules
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting EventBridge Scheduler schedules for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		schedules, err := ListAllSchedules(ctx, schedulerClient)
+		if err == nil {
+			for _, schedule := range schedules {
+				// i.e. aws scheduler get-schedule --name <name> --group-name <group>
+				scheduleDetails, err := GetScheduleDetails(ctx, schedulerClient, schedule)
+				if err != nil || scheduleDetails.Target == nil {
+					continue
+				}
+
+				fmt.Printf("\tSchedule name: %v\n", *scheduleDetails.Name)
+				fmt.Printf("\tGroup: %v\n", aws.ToString(scheduleDetails.GroupName))
+				fmt.Printf("\tExpression: %v\n", aws.ToString(scheduleDetails.ScheduleExpression))
+				fmt.Printf("\tState: %v\n", scheduleDetails.State)
+				PrintScheduledTarget(ctx, iamClient, privilegedRoles, regionalConfig.Region, aws.ToString(scheduleDetails.Arn), *scheduleDetails.Target.Arn, aws.ToString(scheduleDetails.Target.RoleArn))
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("schedule", regionalConfig.Region, ScheduleResult{
+					Name:       *scheduleDetails.Name,
+					Arn:        aws.ToString(scheduleDetails.Arn),
+					Group:      aws.ToString(scheduleDetails.GroupName),
+					Expression: aws.ToString(scheduleDetails.ScheduleExpression),
+					State:      string(scheduleDetails.State),
+					Targets:    []ScheduleTarget{{Arn: *scheduleDetails.Target.Arn, RoleArn: aws.ToString(scheduleDetails.Target.RoleArn)}},
+				})
+			}
+		}
+
+		// Call the list-rules API to get the classic EventBridge rules that run on a schedule
+		// i.e. aws events list-rules
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting scheduled EventBridge rules for %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		rules, err := ListAllRules(ctx, eventbridgeClient)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if rule.ScheduleExpression == nil {
+				continue
+			}
+
+			fmt.Printf("\tRule name: %v\n", *rule.Name)
+			fmt.Printf("\tExpression: %v\n", *rule.ScheduleExpression)
+			fmt.Printf("\tState: %v\n", rule.State)
+			if rule.RoleArn != nil {
+				fmt.Printf("\tRule role: %v\n", *rule.RoleArn)
+			}
+
+			// i.e. aws events list-targets-by-rule --rule <name>
+			result := ScheduleResult{
+				Name:       *rule.Name,
+				Arn:        aws.ToString(rule.Arn),
+				Expression: *rule.ScheduleExpression,
+				State:      string(rule.State),
+				RoleArn:    aws.ToString(rule.RoleArn),
+			}
+			targets, err := ListRuleTargets(ctx, eventbridgeClient, rule)
+			if err == nil {
+				for _, target := range targets {
+					PrintScheduledTarget(ctx, iamClient, privilegedRoles, regionalConfig.Region, aws.ToString(rule.Arn), *target.Arn, aws.ToString(target.RoleArn))
+					result.Targets = append(result.Targets, ScheduleTarget{Arn: *target.Arn, RoleArn: aws.ToString(target.RoleArn)})
+				}
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("scheduled-rule", regionalConfig.Region, result)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func PrintScheduledTarget(ctx context.Context, iamClient *iam.Client, privilegedRoles map[string]bool, region string, sourceArn string, targetArn string, roleArn string) {
+	fmt.Printf("\tTarget: %v\n", targetArn)
+	if roleArn != "" {
+		fmt.Printf("\tTarget role: %v\n", roleArn)
+	}
+
+	// Universal targets look like arn:aws:scheduler:::aws-sdk:<service>:<action>
+	service := ""
+	if parsedArn, err := arn.Parse(targetArn); err == nil {
+		service = parsedArn.Service
+		if service == "scheduler" && strings.HasPrefix(parsedArn.Resource, "aws-sdk:") {
+			service = strings.SplitN(strings.TrimPrefix(parsedArn.Resource, "aws-sdk:"), ":", 2)[0]
+		}
+	}
+	if PRIVILEGED_TARGET_SERVICES[service] {
+		fmt.Printf("\t[-] Target runs code or commands (%v), a candidate for scheduled persistence\n", service)
+		EmitFinding(region, sourceArn, fmt.Sprintf("Target %v runs code or commands (%v), a candidate for scheduled persistence", targetArn, service))
+	}
+
+	if roleArn != "" && IsPrivilegedRole(ctx, iamClient, privilegedRoles, roleArn) {
+		fmt.Println("\t[!] Target role has administrative IAM permissions")
+		EmitFinding(region, sourceArn, fmt.Sprintf("Target role %v has administrative IAM permissions", roleArn))
+	}
+}
+
+func IsPrivilegedRole(ctx context.Context, iamClient *iam.Client, cache map[string]bool, roleArn string) bool {
+	// Check the role's attached managed policies, caching the answer since roles are often shared
+	if privileged, ok := cache[roleArn]; ok {
+		return privileged
+	}
+
+	privileged := false
+	parsedArn, err := arn.Parse(roleArn)
+	if err == nil {
+		roleName := parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+		// i.e. aws iam list-attached-role-policies --role-name <role-name>
+		paginator := iam.NewListAttachedRolePoliciesPaginator(iamClient, &iam.ListAttachedRolePoliciesInput{
+			RoleName: aws.String(roleName),
+		})
+		for paginator.HasMorePages() && !privileged {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				break
+			}
+			for _, policy := range page.AttachedPolicies {
+				if PRIVILEGED_MANAGED_POLICIES[*policy.PolicyArn] {
+					privileged = true
+				}
+			}
+		}
+	}
+
+	cache[roleArn] = privileged
+	return privileged
+}
+
+func ListAllSchedules(ctx context.Context, schedulerClient *scheduler.Client) ([]schedulertypes.ScheduleSummary, error) {
+	// Get every schedule in every schedule group
+	var schedules []schedulertypes.ScheduleSummary
+	paginator := scheduler.NewListSchedulesPaginator(schedulerClient, &scheduler.ListSchedulesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(schedules)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the schedules. Here's why: %v\n", err)
+			return nil, err
+		}
+		schedules = append(schedules, page.Schedules...)
+	}
+
+	return LimitItems(schedules), nil
+}
+
+func GetScheduleDetails(ctx context.Context, schedulerClient *scheduler.Client, schedule schedulertypes.ScheduleSummary) (*scheduler.GetScheduleOutput, error) {
+	// Get the expression and target of the schedule
+	scheduleDetails, err := schedulerClient.GetSchedule(ctx, &scheduler.GetScheduleInput{
+		Name:      schedule.Name,
+		GroupName: schedule.GroupName,
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get details for the schedule %v. Here's why: %v\n", *schedule.Name, err)
+		return nil, err
+	}
+
+	return scheduleDetails, nil
+}
+
+func ListAllRules(ctx context.Context, eventbridgeClient *eventbridge.Client) ([]eventbridgetypes.Rule, error) {
+	// Get every rule on the default event bus, following NextToken since the API has no paginator
+	var rules []eventbridgetypes.Rule
+	input := &eventbridge.ListRulesInput{}
+	for {
+		page, err := eventbridgeClient.ListRules(ctx, input)
+		if err != nil {
+			fmt.Printf("Couldn't list the EventBridge rules. Here's why: %v\n", err)
+			return nil, err
+		}
+		rules = append(rules, page.Rules...)
+		if page.NextToken == nil || ReachedMaxItems(len(rules)) {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+
+	return LimitItems(rules), nil
+}
+
+func ListRuleTargets(ctx context.Context, eventbridgeClient *eventbridge.Client, rule eventbridgetypes.Rule) ([]eventbridgetypes.Target, error) {
+	// Get the targets the rule invokes
+	var targets []eventbridgetypes.Target
+	input := &eventbridge.ListTargetsByRuleInput{
+		Rule:         rule.Name,
+		EventBusName: rule.EventBusName,
+	}
+	for {
+		page, err := eventbridgeClient.ListTargetsByRule(ctx, input)
+		if err != nil {
+			fmt.Printf("Couldn't list the targets for %v. Here's why: %v\n", *rule.Name, err)
+			return nil, err
+		}
+		targets = append(targets, page.Targets...)
+		if page.NextToken == nil {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+
+	return targets, nil
+}
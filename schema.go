@@ -0,0 +1,64 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Version of the structured output written by this build. The report and result envelopes
+// don't allow fields their schema doesn't list, so it's bumped whenever an envelope field is
+// added or removed, or any field changes meaning. New fields inside data and new result types
+// don't need a new version. Version 2 added the version and build envelope fields
+const OUTPUT_SCHEMA_VERSION = "2"
+
+// One JSON Schema per output version, i.e. schemas/output-v1.json
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+func LoadOutputSchema(version string) ([]byte, error) {
+	content, err := schemaFiles.ReadFile("schemas/output-v" + version + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown output schema version %q, available versions are %v", version, strings.Join(ListSchemaVersions(), ", "))
+	}
+
+	return content, nil
+}
+
+func ListSchemaVersions() []string {
+	var versions []string
+	entries, _ := schemaFiles.ReadDir("schemas")
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(strings.TrimPrefix(entry.Name(), "output-v"), ".json"))
+	}
+	sort.Strings(versions)
+
+	return versions
+}
+
+func NewSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema [version]",
+		Short: "Print the JSON Schema of the json and ndjson output",
+		Long:  "Print the JSON Schema of the json and ndjson output, for --output-version (default " + OUTPUT_SCHEMA_VERSION + ") or the version given",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := OutputVersion
+			if len(args) > 0 {
+				version = strings.TrimPrefix(args[0], "v")
+			}
+			content, err := LoadOutputSchema(version)
+			if err != nil {
+				return err
+			}
+
+			// Straight to stdout even with --output json, so it can be redirected into a file
+			_, err = ResultsOutput.Write(content)
+			return err
+		},
+	}
+}
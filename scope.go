@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Regions and services no request may be sent to, set with --exclude-regions and --exclude-services
+var ExcludeRegions []string
+var ExcludeServices []string
+
+// Returned for every request the exclusions stop before it leaves the process
+type OutOfScopeError struct {
+	Service   string
+	Operation string
+	Region    string
+	Reason    string
+}
+
+func (e *OutOfScopeError) Error() string {
+	return fmt.Sprintf("%v %v in %v wasn't sent, %v", e.Service, e.Operation, e.Region, e.Reason)
+}
+
+func AddScopeMiddleware(sdkConfig *aws.Config) {
+	// Every client is built from this configuration, so checking each request here covers every module,
+	// including ones added later that never look at the exclusions. Added last and placed first in the
+	// initialize step, so an excluded request is refused before anything else sees it
+	if len(ExcludeRegions) == 0 && len(ExcludeServices) == 0 {
+		return
+	}
+	sdkConfig.APIOptions = append(sdkConfig.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Scope", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			service := awsmiddleware.GetServiceID(ctx)
+			region := awsmiddleware.GetRegion(ctx)
+			reason := ""
+			switch {
+			case RegionExcluded(region):
+				reason = "the region is excluded with --exclude-regions"
+			case ServiceExcluded(service):
+				reason = "the service is excluded with --exclude-services"
+			}
+			if reason != "" {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, &OutOfScopeError{
+					Service:   service,
+					Operation: awsmiddleware.GetOperationName(ctx),
+					Region:    region,
+					Reason:    reason,
+				}
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+	})
+}
+
+func RegionExcluded(region string) bool {
+	return slices.Contains(ExcludeRegions, strings.ToLower(strings.TrimSpace(region)))
+}
+
+func ServiceExcluded(serviceId string) bool {
+	// Service IDs are written like "Secrets Manager" or "S3 Control", which normalise to the names
+	// of the SDK packages and CLI commands people pass, i.e. secretsmanager or s3control
+	return slices.Contains(ExcludeServices, NormaliseServiceName(serviceId))
+}
+
+func NormaliseServiceName(name string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+func NormaliseExclusions() {
+	for i, region := range ExcludeRegions {
+		ExcludeRegions[i] = strings.ToLower(strings.TrimSpace(region))
+	}
+	for i, service := range ExcludeServices {
+		ExcludeServices[i] = NormaliseServiceName(service)
+	}
+}
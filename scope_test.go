@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+func useExclusions(t testing.TB, regions []string, services []string) {
+	previousRegions, previousServices := ExcludeRegions, ExcludeServices
+	ExcludeRegions, ExcludeServices = regions, services
+	NormaliseExclusions()
+	t.Cleanup(func() {
+		ExcludeRegions, ExcludeServices = previousRegions, previousServices
+	})
+}
+
+func TestScopeRefusesExcludedRequests(t *testing.T) {
+	useExclusions(t, []string{" EU-West-1"}, []string{"EC2"})
+	account := &mockAccount{Roles: 1, InstancesPerRegion: 1}
+	ctx := context.Background()
+
+	allowed := account.Config("us-east-1")
+	AddScopeMiddleware(&allowed)
+	if _, err := iam.NewFromConfig(allowed).ListRoles(ctx, &iam.ListRolesInput{}); err != nil {
+		t.Fatalf("ListRoles in us-east-1 failed: %v", err)
+	}
+	if account.calls.Load() != 1 {
+		t.Fatalf("got %v calls, want 1", account.calls.Load())
+	}
+
+	// Neither request may reach the account, not even to be rejected there
+	var scopeError *OutOfScopeError
+	_, err := ec2.NewFromConfig(allowed).DescribeInstances(ctx, &ec2.DescribeInstancesInput{})
+	if !errors.As(err, &scopeError) || scopeError.Service != "EC2" {
+		t.Fatalf("DescribeInstances got %v, want an out of scope error for EC2", err)
+	}
+	excludedRegion := account.Config("eu-west-1")
+	AddScopeMiddleware(&excludedRegion)
+	_, err = iam.NewFromConfig(excludedRegion).ListRoles(ctx, &iam.ListRolesInput{})
+	if !errors.As(err, &scopeError) || scopeError.Region != "eu-west-1" {
+		t.Fatalf("ListRoles in eu-west-1 got %v, want an out of scope error for the region", err)
+	}
+	if account.calls.Load() != 1 {
+		t.Fatalf("got %v calls, want excluded requests never sent", account.calls.Load())
+	}
+}
+
+func TestScopeOffWithoutExclusions(t *testing.T) {
+	useExclusions(t, nil, nil)
+	sdkConfig := largeAccount(0).Config("us-east-1")
+	AddScopeMiddleware(&sdkConfig)
+	if len(sdkConfig.APIOptions) != 0 {
+		t.Fatalf("got %v API options, want none when nothing is excluded", len(sdkConfig.APIOptions))
+	}
+}
+
+func TestNormaliseServiceName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Secrets Manager": "secretsmanager",
+		"S3 Control":      "s3control",
+		"secrets-manager": "secretsmanager",
+		" IAM ":           "iam",
+	} {
+		if got := NormaliseServiceName(name); got != want {
+			t.Errorf("NormaliseServiceName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
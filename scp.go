@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// The SCPs attached at one level of an account's path, the account itself, an OU or the root
+type SCPLayer struct {
+	TargetId string            `json:"targetId"`
+	Policies []*PolicyDocument `json:"policies"`
+	// Some of the level's SCPs couldn't be fetched, so what it allows isn't known
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+func GetAccountSCPs(ctx context.Context, orgClient *organizations.Client, cache map[string]*PolicyDocument, accountId string) ([]SCPLayer, error) {
+	// Walk up from the account to the root, an action has to be allowed at every level
+	// i.e. aws organizations list-parents --child-id <id>
+	var layers []SCPLayer
+	targetId := accountId
+	for {
+		layer, err := GetSCPLayer(ctx, orgClient, cache, targetId)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+
+		parents, err := orgClient.ListParents(ctx, &organizations.ListParentsInput{
+			ChildId: aws.String(targetId),
+		})
+		if err != nil {
+			fmt.Printf("Couldn't get the parent of %v. Here's why: %v\n", targetId, err)
+			return nil, err
+		}
+		if len(parents.Parents) == 0 {
+			return layers, nil
+		}
+		targetId = *parents.Parents[0].Id
+		if parents.Parents[0].Type == orgtypes.ParentTypeRoot {
+			layer, err := GetSCPLayer(ctx, orgClient, cache, targetId)
+			if err != nil {
+				return nil, err
+			}
+			return append(layers, layer), nil
+		}
+	}
+}
+
+func GetSCPLayer(ctx context.Context, orgClient *organizations.Client, cache map[string]*PolicyDocument, targetId string) (SCPLayer, error) {
+	layer := SCPLayer{TargetId: targetId}
+	policies, missing, err := ListSCPsForTarget(ctx, orgClient, cache, targetId)
+	if err != nil {
+		return layer, err
+	}
+	layer.Policies = policies
+	if missing > 0 {
+		// Without every SCP the level's allow list can't be known, so only its denies are counted
+		fmt.Printf("	[!] Couldn't get %v of the SCPs attached to %v, only the denies that could be read are applied there\n", missing, targetId)
+		layer.Unknown = true
+	}
+
+	return layer, nil
+}
+
+func ListSCPsForTarget(ctx context.Context, orgClient *organizations.Client, cache map[string]*PolicyDocument, targetId string) ([]*PolicyDocument, int, error) {
+	// Also returns how many of the attached SCPs couldn't be fetched or parsed
+	// i.e. aws organizations list-policies-for-target --target-id <id> --filter SERVICE_CONTROL_POLICY
+	var policies []*PolicyDocument
+	missing := 0
+	paginator := organizations.NewListPoliciesForTargetPaginator(orgClient, &organizations.ListPoliciesForTargetInput{
+		TargetId: aws.String(targetId),
+		Filter:   orgtypes.PolicyTypeServiceControlPolicy,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the SCPs attached to %v. Here's why: %v\n", targetId, err)
+			return nil, 0, err
+		}
+		for _, summary := range page.Policies {
+			// The same SCP is usually attached in many places, so each is only downloaded once
+			document, ok := cache[*summary.Id]
+			if !ok {
+				// i.e. aws organizations describe-policy --policy-id <id>
+				policy, err := orgClient.DescribePolicy(ctx, &organizations.DescribePolicyInput{
+					PolicyId: summary.Id,
+				})
+				if err != nil {
+					fmt.Printf("Couldn't get the SCP %v. Here's why: %v\n", *summary.Id, err)
+					missing++
+					continue
+				}
+				document, err = ParsePolicyDocument(*policy.Policy.Content)
+				if err != nil {
+					missing++
+					continue
+				}
+				cache[*summary.Id] = document
+			}
+			policies = append(policies, document)
+		}
+	}
+
+	return policies, missing, nil
+}
+
+func SCPBlocks(layers []SCPLayer, action string) (bool, string) {
+	// Resources and conditions aren't evaluated, so only Deny statements that apply to
+	// every resource unconditionally are counted, and an action counts as allowed at a
+	// level if any Allow statement there covers it. A level whose SCPs couldn't all be
+	// fetched can still deny, but isn't taken to leave anything out
+	action = strings.ToLower(action)
+	for _, layer := range layers {
+		allowed := map[string]bool{}
+		for _, policy := range layer.Policies {
+			for pattern := range AllowedActions(policy) {
+				allowed[pattern] = true
+			}
+			for _, statement := range policy.Statement {
+				if statement.Effect != "Deny" || len(statement.Condition) > 0 || len(statement.NotResource) > 0 || !ResourceCoveredBy("*", statement.Resource) {
+					continue
+				}
+				if len(statement.Action) > 0 && ActionCoveredBy(action, ToActionSet(statement.Action)) {
+					return true, fmt.Sprintf("denied at %v", layer.TargetId)
+				}
+				if len(statement.NotAction) > 0 && !ActionCoveredBy(action, ToActionSet(statement.NotAction)) {
+					return true, fmt.Sprintf("denied at %v", layer.TargetId)
+				}
+			}
+		}
+		if !layer.Unknown && !ActionCoveredBy(action, allowed) {
+			return true, fmt.Sprintf("not allowed at %v", layer.TargetId)
+		}
+	}
+
+	return false, ""
+}
+
+func ToActionSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+
+	return set
+}
+
+func ResourceCoveredBy(resource string, patterns []string) bool {
+	// ARNs are case-sensitive, unlike actions
+	for _, pattern := range patterns {
+		if WildcardMatch(pattern, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ApplySCPs(notable []string) ([]string, []string) {
+	// Only org-scan knows the SCPs for the account being scanned, everywhere else this does nothing
+	value, ok := Store.Get("org:scps")
+	if !ok {
+		return notable, nil
+	}
+	layers := value.([]SCPLayer)
+
+	// Full access under SCPs is only full access to what the SCPs leave, so it's broken
+	// back out into the notable actions if any of them are blocked
+	candidates := notable
+	if len(notable) == 1 && notable[0] == "*" {
+		candidates = NOTABLE_ACTIONS
+	}
+
+	var allowed, blocked []string
+	for _, action := range candidates {
+		if isBlocked, reason := SCPBlocks(layers, action); isBlocked {
+			blocked = append(blocked, fmt.Sprintf("%v (%v)", action, reason))
+			continue
+		}
+		allowed = append(allowed, action)
+	}
+	if len(blocked) == 0 {
+		return notable, nil
+	}
+
+	return allowed, blocked
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// The SCP every level of an organization starts with
+const FULL_AWS_ACCESS_SCP = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`
+
+func parsePolicies(t testing.TB, documents ...string) []*PolicyDocument {
+	t.Helper()
+	var policies []*PolicyDocument
+	for _, document := range documents {
+		policy, err := ParsePolicyDocument(document)
+		if err != nil {
+			t.Fatalf("couldn't parse %v: %v", document, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies
+}
+
+func TestSCPBlocks(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		action string
+		// From the account up to the root
+		layers     [][]string
+		wantReason string
+	}{
+		{
+			name:   "full access everywhere",
+			action: "iam:PassRole",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP}, {FULL_AWS_ACCESS_SCP}, {FULL_AWS_ACCESS_SCP}},
+		},
+		{
+			name:       "denied at the root",
+			action:     "iam:PassRole",
+			layers:     [][]string{{FULL_AWS_ACCESS_SCP}, {FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"*"}}`}},
+			wantReason: "denied at r-root",
+		},
+		{
+			name:       "lowercase action denied",
+			action:     "iam:passrole",
+			layers:     [][]string{{FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"IAM:PassRole","Resource":"*"}}`}, {FULL_AWS_ACCESS_SCP}},
+			wantReason: "denied at 123456789012",
+		},
+		{
+			name:       "outside a NotAction deny",
+			action:     "iam:PassRole",
+			layers:     [][]string{{FULL_AWS_ACCESS_SCP}, {FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","NotAction":["S3:*","CloudWatch:*"],"Resource":"*"}}`}},
+			wantReason: "denied at r-root",
+		},
+		{
+			name:   "inside a NotAction deny",
+			action: "s3:GetObject",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP}, {FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","NotAction":["S3:*","CloudWatch:*"],"Resource":"*"}}`}},
+		},
+		{
+			name:   "conditional deny",
+			action: "iam:PassRole",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"*","Resource":"*","Condition":{"StringNotEquals":{"aws:RequestedRegion":"us-east-1"}}}}`}, {FULL_AWS_ACCESS_SCP}},
+		},
+		{
+			name:   "deny on one resource",
+			action: "iam:PassRole",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"arn:aws:iam::*:role/admin"}}`}, {FULL_AWS_ACCESS_SCP}},
+		},
+		{
+			name:   "deny on everything but one resource",
+			action: "iam:PassRole",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"iam:*","NotResource":"arn:aws:iam::*:role/Deploy"}}`}, {FULL_AWS_ACCESS_SCP}},
+		},
+		{
+			// An allow list at one level caps every level below it, whatever they allow
+			name:       "not in an allow list",
+			action:     "iam:PassRole",
+			layers:     [][]string{{FULL_AWS_ACCESS_SCP}, {`{"Statement":{"Effect":"Allow","Action":["s3:*","ec2:*"],"Resource":"*"}}`}, {FULL_AWS_ACCESS_SCP}},
+			wantReason: "not allowed at ou-1",
+		},
+		{
+			name:   "in an allow list",
+			action: "ec2:RunInstances",
+			layers: [][]string{{FULL_AWS_ACCESS_SCP}, {`{"Statement":{"Effect":"Allow","Action":["s3:*","ec2:*"],"Resource":"*"}}`}, {FULL_AWS_ACCESS_SCP}},
+		},
+		{
+			name:       "nothing attached to the account",
+			action:     "s3:GetObject",
+			layers:     [][]string{nil, {FULL_AWS_ACCESS_SCP}},
+			wantReason: "not allowed at 123456789012",
+		},
+	} {
+		var layers []SCPLayer
+		for i, policies := range test.layers {
+			targetId := fmt.Sprintf("ou-%v", i)
+			switch i {
+			case 0:
+				targetId = "123456789012"
+			case len(test.layers) - 1:
+				targetId = "r-root"
+			}
+			layers = append(layers, SCPLayer{TargetId: targetId, Policies: parsePolicies(t, policies...)})
+		}
+		blocked, reason := SCPBlocks(layers, test.action)
+		if blocked != (test.wantReason != "") || reason != test.wantReason {
+			t.Errorf("%v: got %v %q, want %q", test.name, blocked, reason, test.wantReason)
+		}
+	}
+}
+
+func TestSCPBlocksUnknownLayer(t *testing.T) {
+	// None of the OU's SCPs could be fetched, so it can't be said to leave the action out
+	layers := []SCPLayer{
+		{TargetId: "123456789012", Policies: parsePolicies(t, FULL_AWS_ACCESS_SCP)},
+		{TargetId: "ou-1", Unknown: true},
+		{TargetId: "r-root", Policies: parsePolicies(t, FULL_AWS_ACCESS_SCP)},
+	}
+	if blocked, reason := SCPBlocks(layers, "iam:PassRole"); blocked {
+		t.Errorf("got blocked %q under a level whose SCPs are unknown", reason)
+	}
+
+	// The denies that could be read still count
+	layers[1].Policies = parsePolicies(t, `{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"*"}}`)
+	if blocked, reason := SCPBlocks(layers, "iam:PassRole"); !blocked || reason != "denied at ou-1" {
+		t.Errorf("got %v %q, want denied at ou-1", blocked, reason)
+	}
+}
+
+func TestApplySCPs(t *testing.T) {
+	useDataStore(t)
+	notable := []string{"iam:PassRole", "s3:GetObject"}
+	if allowed, blocked := ApplySCPs(notable); !slices.Equal(allowed, notable) || blocked != nil {
+		t.Fatalf("without SCPs got %v allowed and %v blocked, want everything allowed", allowed, blocked)
+	}
+
+	Store.Put("org:scps", []SCPLayer{
+		{TargetId: "123456789012", Policies: parsePolicies(t, FULL_AWS_ACCESS_SCP)},
+		{TargetId: "r-root", Policies: parsePolicies(t, FULL_AWS_ACCESS_SCP, `{"Statement":{"Effect":"Deny","Action":"iam:*","Resource":"*"}}`)},
+	})
+	allowed, blocked := ApplySCPs(notable)
+	if !slices.Equal(allowed, []string{"s3:GetObject"}) || !slices.Equal(blocked, []string{"iam:PassRole (denied at r-root)"}) {
+		t.Errorf("got %v allowed and %v blocked", allowed, blocked)
+	}
+
+	// Full access is broken back out into the notable actions the SCPs leave
+	allowed, _ = ApplySCPs([]string{"*"})
+	if slices.Contains(allowed, "*") || slices.ContainsFunc(allowed, func(action string) bool { return ActionCoveredBy(action, map[string]bool{"iam:*": true}) }) {
+		t.Errorf("got %v allowed under full access, want everything but iam", allowed)
+	}
+	if !slices.Contains(allowed, "s3:GetObject") {
+		t.Errorf("got %v allowed under full access, want s3:GetObject in it", allowed)
+	}
+}
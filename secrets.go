@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Files larger than this are skipped by the scanner, they're almost always binaries or bundles
+const MAX_SCAN_FILE_SIZE = 5 * 1024 * 1024
+
+type SecretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+type SecretFinding struct {
+	Rule   string `json:"rule"`
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Match  string `json:"match"`
+}
+
+var SECRET_RULES = []SecretRule{
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws.{0,20}(secret|private).{0,20}['"=:\s][0-9a-zA-Z/+]{40}\b`)},
+	{"Private key", regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|PGP) )?PRIVATE KEY`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[baprs]-[0-9A-Za-z-]{10,}`)},
+	{"Stripe live key", regexp.MustCompile(`\b[rs]k_live_[0-9a-zA-Z]{24,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"Credentials in connection string", regexp.MustCompile(`(?i)\b(postgres(ql)?|mysql|mongodb(\+srv)?|redis|amqps?)://[^:/\s]+:[^@\s]+@`)},
+	{"Password assignment", regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|api_?key|access_?token)\b\s*[:=]\s*['"]?[^\s'"]{6,}`)},
+}
+
+func ScanForSecrets(source string, content []byte) []SecretFinding {
+	// Check each line of the content against every rule
+	var findings []SecretFinding
+	if len(content) > MAX_SCAN_FILE_SIZE {
+		return findings
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), MAX_SCAN_FILE_SIZE)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		for _, rule := range SECRET_RULES {
+			if match := rule.Pattern.FindString(line); match != "" {
+				findings = append(findings, SecretFinding{
+					Rule:   rule.Name,
+					Source: source,
+					Line:   lineNumber,
+					Match:  match,
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+func PrintSecretFindings(findings []SecretFinding) {
+	for _, finding := range findings {
+		fmt.Printf("\t[!] %v in %v:%v\n", finding.Rule, finding.Source, finding.Line)
+		fmt.Printf("\t\t%v\n", finding.Match)
+		Emit("secret", "", finding)
+	}
+}
+
+func ScanZipForSecrets(source string, zipped []byte) ([]SecretFinding, error) {
+	// Scan every file in the archive, reporting findings as <archive>/<file>
+	reader, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []SecretFinding
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || file.UncompressedSize64 > MAX_SCAN_FILE_SIZE {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			continue
+		}
+		// The header's size can be forged, so the entry is read up to one byte past the limit
+		// to tell an oversized file from one that's exactly at it
+		content, err := io.ReadAll(io.LimitReader(fileReader, MAX_SCAN_FILE_SIZE+1))
+		fileReader.Close()
+		if err != nil || len(content) > MAX_SCAN_FILE_SIZE {
+			continue
+		}
+
+		findings = append(findings, ScanForSecrets(source+"/"+file.Name, content)...)
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsmanagertypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// Secrets not rotated or changed in this many days are flagged
+const STALE_SECRET_DAYS = 90
+
+// Actions that hand over a secret's value
+var SECRET_READ_ACTIONS = []string{"secretsmanager:GetSecretValue", "secretsmanager:BatchGetSecretValue"}
+
+// Whether the secrets module reads the value of each secret it can, set with --retrieve-values
+var RetrieveSecretValues = false
+
+type SecretResult struct {
+	Name          string     `json:"name"`
+	Arn           string     `json:"arn"`
+	Description   string     `json:"description,omitempty"`
+	KmsKey        string     `json:"kmsKey,omitempty"`
+	OwningService string     `json:"owningService,omitempty"`
+	PrimaryRegion string     `json:"primaryRegion,omitempty"`
+	LastChanged   *time.Time `json:"lastChanged,omitempty"`
+	LastAccessed  *time.Time `json:"lastAccessed,omitempty"`
+	// Rotation is only scheduled when enabled, by a number of days or a schedule expression
+	RotationEnabled  bool            `json:"rotationEnabled"`
+	RotationLambda   string          `json:"rotationLambda,omitempty"`
+	RotationSchedule string          `json:"rotationSchedule,omitempty"`
+	LastRotated      *time.Time      `json:"lastRotated,omitempty"`
+	Policy           *PolicyDocument `json:"policy,omitempty"`
+	PublicRead       bool            `json:"publicRead"`
+	ReadAccounts     []string        `json:"readAccounts"`
+	// Only with --retrieve-values
+	Value *SecretValueResult `json:"value,omitempty"`
+}
+
+type SecretValueResult struct {
+	Readable bool `json:"readable"`
+	// Why it couldn't be read, i.e. AccessDeniedException
+	Error   string `json:"error,omitempty"`
+	SavedTo string `json:"savedTo,omitempty"`
+	// The top-level keys when the value is a JSON object, i.e. username and password
+	Keys         []string        `json:"keys,omitempty"`
+	SecretsFound []SecretFinding `json:"secretsFound"`
+}
+
+func RunSecretsManagerModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		secretsClient := secretsmanager.NewFromConfig(regionalConfig)
+
+		// Values are only read with --retrieve-values, every read is a GetSecretValue event in CloudTrail
+		// i.e. aws secretsmanager list-secrets, aws secretsmanager get-resource-policy --secret-id <secret-arn>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Secrets Manager secrets in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		secrets, err := ListSecrets(ctx, secretsClient)
+		if err != nil {
+			return err
+		}
+		results := make([]SecretResult, len(secrets))
+		ForEachConcurrently(ctx, len(secrets), func(ctx context.Context, i int) {
+			results[i] = GetSecretResult(ctx, secretsClient, regionalConfig.Region, secrets[i], accountId)
+		}, func(i int) {
+			PrintSecretResult(regionalConfig.Region, results[i])
+		})
+		if len(secrets) == 0 {
+			fmt.Println("\tNo secrets in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetSecretResult(ctx context.Context, secretsClient *secretsmanager.Client, region string, secret secretsmanagertypes.SecretListEntry, accountId string) SecretResult {
+	result := SecretResult{
+		Name:            aws.ToString(secret.Name),
+		Arn:             aws.ToString(secret.ARN),
+		Description:     aws.ToString(secret.Description),
+		KmsKey:          aws.ToString(secret.KmsKeyId),
+		OwningService:   aws.ToString(secret.OwningService),
+		PrimaryRegion:   aws.ToString(secret.PrimaryRegion),
+		LastChanged:     secret.LastChangedDate,
+		LastAccessed:    secret.LastAccessedDate,
+		RotationEnabled: aws.ToBool(secret.RotationEnabled),
+		RotationLambda:  aws.ToString(secret.RotationLambdaARN),
+		LastRotated:     secret.LastRotatedDate,
+	}
+	if secret.RotationRules != nil && secret.RotationRules.ScheduleExpression != nil {
+		result.RotationSchedule = aws.ToString(secret.RotationRules.ScheduleExpression)
+	} else if secret.RotationRules != nil && secret.RotationRules.AutomaticallyAfterDays != nil {
+		result.RotationSchedule = fmt.Sprintf("every %v days", aws.ToInt64(secret.RotationRules.AutomaticallyAfterDays))
+	}
+
+	// i.e. aws secretsmanager get-resource-policy --secret-id <secret-arn>
+	output, err := secretsClient.GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: secret.ARN})
+	if err != nil {
+		fmt.Printf("Couldn't get the resource policy for %v. Here's why: %v\n", result.Name, err)
+	} else if output.ResourcePolicy != nil {
+		policy, err := ParsePolicyDocument(aws.ToString(output.ResourcePolicy))
+		if err == nil {
+			result.Policy = policy
+			result.PublicRead, result.ReadAccounts = AnalyseResourcePolicy(policy, SECRET_READ_ACTIONS, accountId, ConditionRestricted)
+		}
+	}
+
+	if RetrieveSecretValues {
+		result.Value = GetSecretValue(ctx, secretsClient, region, secret)
+	}
+
+	return result
+}
+
+func GetSecretValue(ctx context.Context, secretsClient *secretsmanager.Client, region string, secret secretsmanagertypes.SecretListEntry) *SecretValueResult {
+	// i.e. aws secretsmanager get-secret-value --secret-id <secret-arn>
+	output, err := secretsClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: secret.ARN})
+	if err != nil {
+		// Denied by IAM, the resource policy or the KMS key, which is expected for most of them
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) {
+			return &SecretValueResult{Error: apiError.ErrorCode()}
+		}
+		return &SecretValueResult{Error: err.Error()}
+	}
+
+	value := &SecretValueResult{Readable: true}
+	content, extension := output.SecretBinary, ".bin"
+	if output.SecretString != nil {
+		content, extension = []byte(aws.ToString(output.SecretString)), ".txt"
+		var fields map[string]any
+		if json.Unmarshal(content, &fields) == nil {
+			for key := range fields {
+				value.Keys = append(value.Keys, key)
+			}
+			sort.Strings(value.Keys)
+			extension = ".json"
+		}
+	}
+
+	// Names can hold slashes, i.e. prod/db/password, so they're flattened into one file name
+	source := aws.ToString(secret.ARN)
+	lootPath, err := SaveLoot(filepath.Join("secretsmanager", region, strings.ReplaceAll(aws.ToString(secret.Name), "/", "_")+extension), content)
+	if err == nil {
+		value.SavedTo = lootPath
+		source = lootPath
+	}
+	value.SecretsFound = ScanForSecrets(source, content)
+
+	return value
+}
+
+func PrintSecretResult(region string, result SecretResult) {
+	fmt.Printf("\tSecret name: %v\n", result.Name)
+	if result.Description != "" {
+		fmt.Printf("\tDescription: %v\n", result.Description)
+	}
+	if result.OwningService != "" {
+		fmt.Printf("\tManaged by: %v\n", result.OwningService)
+	}
+	if result.KmsKey != "" {
+		fmt.Printf("\tKMS key: %v\n", result.KmsKey)
+	} else {
+		fmt.Println("\tKMS key: aws/secretsmanager")
+	}
+	if result.PrimaryRegion != "" && result.PrimaryRegion != region {
+		fmt.Printf("\tReplica of: %v\n", result.PrimaryRegion)
+	}
+	if result.LastChanged != nil {
+		fmt.Printf("\tLast changed: %v\n", result.LastChanged.UTC().Format(time.RFC3339))
+	}
+	if result.LastAccessed != nil {
+		fmt.Printf("\tLast accessed: %v\n", result.LastAccessed.UTC().Format("2006-01-02"))
+	}
+
+	// Rotation is judged by when the value last changed, whether or not rotation did it
+	lastChanged := result.LastChanged
+	if result.LastRotated != nil && (lastChanged == nil || result.LastRotated.After(*lastChanged)) {
+		lastChanged = result.LastRotated
+	}
+	if result.RotationEnabled {
+		fmt.Printf("\tRotation: %v with %v\n", result.RotationSchedule, result.RotationLambda)
+		if result.LastRotated != nil {
+			fmt.Printf("\tLast rotated: %v\n", result.LastRotated.UTC().Format(time.RFC3339))
+		}
+	} else {
+		fmt.Println("\tRotation: disabled")
+	}
+	if lastChanged != nil && time.Since(*lastChanged) > STALE_SECRET_DAYS*24*time.Hour {
+		days := int(time.Since(*lastChanged).Hours() / 24)
+		fmt.Printf("\t[!] Not rotated or changed in %v days\n", days)
+		EmitFinding(region, result.Arn, fmt.Sprintf("Secret %v hasn't been rotated or changed in %v days", result.Name, days))
+	}
+
+	if result.Policy != nil {
+		fmt.Printf("\tResource policy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	if result.PublicRead {
+		fmt.Println("\t[!] Any AWS principal can read the value")
+		EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Any AWS principal can read secret %v", result.Name))
+	}
+	for _, readAccount := range result.ReadAccounts {
+		fmt.Printf("\t[!] Account %v can read the value\n", readAccount)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Account %v can read secret %v", readAccount, result.Name))
+	}
+
+	if result.Value != nil && result.Value.Readable {
+		fmt.Println("\t[!] The current principal can read the value")
+		EmitFinding(region, result.Arn, fmt.Sprintf("The current principal can read secret %v", result.Name))
+		if len(result.Value.Keys) > 0 {
+			fmt.Printf("\tKeys: %v\n", strings.Join(result.Value.Keys, ", "))
+		}
+		if result.Value.SavedTo != "" {
+			fmt.Printf("\tValue saved to: %v\n", result.Value.SavedTo)
+		}
+		PrintSecretFindings(result.Value.SecretsFound)
+	} else if result.Value != nil {
+		fmt.Printf("\t[-] Couldn't read the value: %v\n", result.Value.Error)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("secretsmanager-secret", region, result)
+}
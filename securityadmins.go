@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	guarddutytypes "github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityhubtypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Security services that can hand running them for the whole organization to a member account
+var SECURITY_SERVICE_PRINCIPALS = []string{
+	"guardduty.amazonaws.com",
+	"securityhub.amazonaws.com",
+	"inspector2.amazonaws.com",
+	"macie.amazonaws.com",
+	"detective.amazonaws.com",
+	"access-analyzer.amazonaws.com",
+	"config.amazonaws.com",
+	"cloudtrail.amazonaws.com",
+	"fms.amazonaws.com",
+}
+
+type SecurityAdminsResult struct {
+	OrganizationId    string `json:"organizationId"`
+	ManagementAccount string `json:"managementAccount"`
+	// Delegated administrators by service principal, only listed from the management account or
+	// a delegated administrator for Organizations
+	DelegatedAdministrators map[string][]DelegatedAdministratorResult `json:"delegatedAdministrators"`
+}
+
+type DelegatedAdministratorResult struct {
+	AccountId string     `json:"accountId"`
+	Name      string     `json:"name,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+}
+
+// Where GuardDuty or Security Hub findings from one region end up
+type SecurityServiceAdminResult struct {
+	// guardduty or securityhub
+	Service string `json:"service"`
+	Enabled bool   `json:"enabled"`
+	// The account findings aggregate in, empty when there's none and they stay in each account
+	AdminAccount string `json:"adminAccount,omitempty"`
+	// organization when listed as the organization's admin, member when this account's own administrator
+	Source string `json:"source,omitempty"`
+	// Whether the admin relationship is in effect, i.e. ENABLED or Enabled
+	Status              string `json:"status,omitempty"`
+	IsCurrentAccount    bool   `json:"isCurrentAccount"`
+	IsManagementAccount bool   `json:"isManagementAccount"`
+}
+
+func RunSecurityAdminsModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+	orgClient := organizations.NewFromConfig(sdkConfig)
+
+	// Any member can describe its organization, only the management account or a delegated
+	// administrator for Organizations can list who the other services are delegated to
+	// i.e. aws organizations describe-organization,
+	// aws organizations list-delegated-administrators --service-principal <service-principal>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the delegated administrators for security services across the organization...")
+	fmt.Println(MAJOR_SEPARATOR)
+	result := SecurityAdminsResult{DelegatedAdministrators: map[string][]DelegatedAdministratorResult{}}
+	organization, err := orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		fmt.Printf("Couldn't describe the organization. Here's why: %v\n", err)
+	} else {
+		result.OrganizationId = aws.ToString(organization.Organization.Id)
+		result.ManagementAccount = aws.ToString(organization.Organization.MasterAccountId)
+		fmt.Printf("\tOrganization: %v\n", result.OrganizationId)
+		fmt.Printf("\tManagement account: %v\n", result.ManagementAccount)
+		listed := true
+		for _, servicePrincipal := range SECURITY_SERVICE_PRINCIPALS {
+			administrators, err := ListDelegatedAdministrators(ctx, orgClient, servicePrincipal)
+			if err != nil {
+				fmt.Println("\t[-] Only the management account or a delegated administrator can list who the services are delegated to")
+				listed = false
+				break
+			}
+			for _, administrator := range administrators {
+				result.DelegatedAdministrators[servicePrincipal] = append(result.DelegatedAdministrators[servicePrincipal], DelegatedAdministratorResult{
+					AccountId: aws.ToString(administrator.Id),
+					Name:      aws.ToString(administrator.Name),
+					Since:     administrator.DelegationEnabledDate,
+				})
+			}
+		}
+		for _, servicePrincipal := range SECURITY_SERVICE_PRINCIPALS {
+			for _, administrator := range result.DelegatedAdministrators[servicePrincipal] {
+				fmt.Printf("\t%v: %v (%v)\n", servicePrincipal, administrator.AccountId, administrator.Name)
+				if administrator.AccountId == accountId {
+					fmt.Printf("\t[-] The current account is the delegated administrator for %v\n", servicePrincipal)
+				}
+			}
+		}
+		if listed && len(result.DelegatedAdministrators) == 0 {
+			fmt.Println("\tNo security services are delegated, they're run from the management account if at all")
+		}
+		Emit("security-admins", "", result)
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	// GuardDuty and Security Hub admins are set region by region, and can differ between them
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting where GuardDuty and Security Hub findings aggregate in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		for _, admin := range []SecurityServiceAdminResult{
+			GetGuardDutyAdmin(ctx, guardduty.NewFromConfig(regionalConfig)),
+			GetSecurityHubAdmin(ctx, securityhub.NewFromConfig(regionalConfig)),
+		} {
+			admin.IsCurrentAccount = admin.AdminAccount == accountId
+			admin.IsManagementAccount = admin.AdminAccount != "" && admin.AdminAccount == result.ManagementAccount
+			PrintSecurityServiceAdmin(regionalConfig.Region, admin)
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetGuardDutyAdmin(ctx context.Context, guarddutyClient *guardduty.Client) SecurityServiceAdminResult {
+	result := SecurityServiceAdminResult{Service: "guardduty"}
+
+	// From the management account the organization's admin is listed, anywhere else the account's own
+	// i.e. aws guardduty list-organization-admin-accounts
+	admins, err := ListGuardDutyAdminAccounts(ctx, guarddutyClient)
+	if err == nil && len(admins) > 0 {
+		result.AdminAccount = aws.ToString(admins[0].AdminAccountId)
+		result.Status = string(admins[0].AdminStatus)
+		result.Source = "organization"
+	}
+
+	// i.e. aws guardduty list-detectors, aws guardduty get-administrator-account --detector-id <detector-id>
+	detectors, err := guarddutyClient.ListDetectors(ctx, &guardduty.ListDetectorsInput{})
+	if err != nil {
+		fmt.Printf("Couldn't list the GuardDuty detectors. Here's why: %v\n", err)
+		return result
+	}
+	if len(detectors.DetectorIds) == 0 {
+		return result
+	}
+	result.Enabled = true
+	if result.AdminAccount != "" {
+		return result
+	}
+	administrator, err := guarddutyClient.GetAdministratorAccount(ctx, &guardduty.GetAdministratorAccountInput{DetectorId: aws.String(detectors.DetectorIds[0])})
+	if err != nil {
+		fmt.Printf("Couldn't get the GuardDuty administrator. Here's why: %v\n", err)
+		return result
+	}
+	if administrator.Administrator != nil {
+		result.AdminAccount = aws.ToString(administrator.Administrator.AccountId)
+		result.Status = aws.ToString(administrator.Administrator.RelationshipStatus)
+		result.Source = "member"
+	}
+
+	return result
+}
+
+func GetSecurityHubAdmin(ctx context.Context, securityhubClient *securityhub.Client) SecurityServiceAdminResult {
+	result := SecurityServiceAdminResult{Service: "securityhub"}
+
+	// i.e. aws securityhub list-organization-admin-accounts
+	admins, err := ListSecurityHubAdminAccounts(ctx, securityhubClient)
+	if err == nil && len(admins) > 0 {
+		result.AdminAccount = aws.ToString(admins[0].AccountId)
+		result.Status = string(admins[0].Status)
+		result.Source = "organization"
+	}
+
+	// Every other Security Hub call fails when it isn't enabled in the region
+	// i.e. aws securityhub describe-hub, aws securityhub get-administrator-account
+	if _, err := securityhubClient.DescribeHub(ctx, &securityhub.DescribeHubInput{}); err != nil {
+		return result
+	}
+	result.Enabled = true
+	if result.AdminAccount != "" {
+		return result
+	}
+	administrator, err := securityhubClient.GetAdministratorAccount(ctx, &securityhub.GetAdministratorAccountInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the Security Hub administrator. Here's why: %v\n", err)
+		return result
+	}
+	if administrator.Administrator != nil {
+		result.AdminAccount = aws.ToString(administrator.Administrator.AccountId)
+		result.Status = aws.ToString(administrator.Administrator.MemberStatus)
+		result.Source = "member"
+	}
+
+	return result
+}
+
+func PrintSecurityServiceAdmin(region string, result SecurityServiceAdminResult) {
+	name := map[string]string{"guardduty": "GuardDuty", "securityhub": "Security Hub"}[result.Service]
+	fmt.Printf("\t%v enabled: %v\n", name, result.Enabled)
+	switch {
+	case result.AdminAccount == "" && result.Enabled:
+		fmt.Println("\t[-] No administrator account, findings stay in this account")
+	case result.AdminAccount != "":
+		fmt.Printf("\t%v administrator: %v (%v, %v)\n", name, result.AdminAccount, result.Source, result.Status)
+	}
+	if result.IsCurrentAccount {
+		fmt.Println("\t[-] Findings from across the organization aggregate in the current account")
+	}
+	// AWS advises against it, since the management account is then also where detections are managed
+	// and can't be held back by SCPs
+	if result.IsManagementAccount {
+		fmt.Printf("\t[!] The %v administrator is the organization's management account\n", name)
+		EmitFinding(region, result.AdminAccount, fmt.Sprintf("The %v administrator is the organization's management account", name))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("security-service-admin", region, result)
+}
+
+func ListDelegatedAdministrators(ctx context.Context, orgClient *organizations.Client, servicePrincipal string) ([]orgtypes.DelegatedAdministrator, error) {
+	var administrators []orgtypes.DelegatedAdministrator
+	paginator := organizations.NewListDelegatedAdministratorsPaginator(orgClient, &organizations.ListDelegatedAdministratorsInput{ServicePrincipal: aws.String(servicePrincipal)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the delegated administrators for %v. Here's why: %v\n", servicePrincipal, err)
+			return nil, err
+		}
+		administrators = append(administrators, page.DelegatedAdministrators...)
+	}
+
+	return administrators, nil
+}
+
+func ListGuardDutyAdminAccounts(ctx context.Context, guarddutyClient *guardduty.Client) ([]guarddutytypes.AdminAccount, error) {
+	var admins []guarddutytypes.AdminAccount
+	paginator := guardduty.NewListOrganizationAdminAccountsPaginator(guarddutyClient, &guardduty.ListOrganizationAdminAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			// Anything but the management account is refused, which isn't worth printing
+			return nil, err
+		}
+		admins = append(admins, page.AdminAccounts...)
+	}
+
+	return admins, nil
+}
+
+func ListSecurityHubAdminAccounts(ctx context.Context, securityhubClient *securityhub.Client) ([]securityhubtypes.AdminAccount, error) {
+	var admins []securityhubtypes.AdminAccount
+	paginator := securityhub.NewListOrganizationAdminAccountsPaginator(securityhubClient, &securityhub.ListOrganizationAdminAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		admins = append(admins, page.AdminAccounts...)
+	}
+
+	return admins, nil
+}
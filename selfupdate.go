@@ -0,0 +1,253 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Set at build time, i.e.
+// go build -ldflags "-X main.UpdatePublicKey=<base64 ed25519 public key>"
+var UpdatePublicKey = ""
+
+const RELEASES_URL = "https://api.github.com/repos/imflikk/go-aws-enumerator/releases/latest"
+
+// Release binaries are around 100MB, anything much bigger isn't one of ours. The release
+// listing and signatures are far smaller
+const (
+	MAX_RELEASE_BINARY_SIZE   = 512 * 1024 * 1024
+	MAX_RELEASE_METADATA_SIZE = 1024 * 1024
+)
+
+// Set by --allow-downgrade to install a release that isn't newer than the running one
+var AllowDowngrade bool
+
+type GithubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []GithubAsset `json:"assets"`
+}
+
+type GithubAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+func RunSelfUpdate(ctx context.Context) error {
+	// Refuse to update if this binary wasn't built with a key to check the new one against
+	if UpdatePublicKey == "" {
+		return fmt.Errorf("this build has no update signing key, download a release build to use self-update")
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(UpdatePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("the embedded update signing key is invalid")
+	}
+
+	fmt.Println("Checking for the latest release...")
+	release, err := GetLatestRelease(ctx)
+	if err != nil {
+		fmt.Printf("Couldn't get the latest release. Here's why: %v\n", err)
+		return err
+	}
+
+	// A signed but older release is still a rollback, i.e. to one with a known vulnerability,
+	// so only newer releases are installed unless that's asked for
+	comparison, err := CompareVersions(release.TagName, Version)
+	switch {
+	case err != nil && !AllowDowngrade:
+		return fmt.Errorf("couldn't compare release %v with this build (%v): %w, use --allow-downgrade to install it anyway", release.TagName, Version, err)
+	case err == nil && comparison == 0:
+		fmt.Printf("Already running the latest version (%v)\n", Version)
+		return nil
+	case err == nil && comparison < 0 && !AllowDowngrade:
+		return fmt.Errorf("the latest release %v is older than this build (%v), use --allow-downgrade to install it anyway", release.TagName, Version)
+	}
+
+	// Release binaries are named aws-enumerator_<os>_<arch>, with a detached
+	// base64-encoded signature alongside in aws-enumerator_<os>_<arch>.sig
+	binaryName := ReleaseAssetName(runtime.GOOS, runtime.GOARCH)
+	var binaryURL, signatureURL string
+	for _, asset := range release.Assets {
+		switch asset.Name {
+		case binaryName:
+			binaryURL = asset.BrowserDownloadURL
+		case binaryName + ".sig":
+			signatureURL = asset.BrowserDownloadURL
+		}
+	}
+	if binaryURL == "" || signatureURL == "" {
+		return fmt.Errorf("release %v has no signed build for %v/%v", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+
+	fmt.Printf("Downloading %v %v...\n", binaryName, release.TagName)
+	binary, err := DownloadAsset(ctx, binaryURL, MAX_RELEASE_BINARY_SIZE)
+	if err != nil {
+		fmt.Printf("Couldn't download the release binary. Here's why: %v\n", err)
+		return err
+	}
+	encodedSignature, err := DownloadAsset(ctx, signatureURL, MAX_RELEASE_METADATA_SIZE)
+	if err != nil {
+		fmt.Printf("Couldn't download the release signature. Here's why: %v\n", err)
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedSignature)))
+	if err != nil {
+		return fmt.Errorf("couldn't decode the release signature: %w", err)
+	}
+
+	if !ed25519.Verify(publicKey, binary, signature) {
+		return fmt.Errorf("signature verification failed for %v, not updating", binaryName)
+	}
+	fmt.Println("Signature verified.")
+
+	if err := ReplaceExecutable(binary); err != nil {
+		fmt.Printf("Couldn't replace the current binary. Here's why: %v\n", err)
+		return err
+	}
+
+	fmt.Printf("Updated from %v to %v\n", Version, release.TagName)
+	return nil
+}
+
+func ReleaseAssetName(goos string, goarch string) string {
+	name := fmt.Sprintf("aws-enumerator_%v_%v", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func CompareVersions(a string, b string) (int, error) {
+	// Semantic versions, i.e. v1.4.0 or v1.5.0-rc.1, a pre-release sorting before its release
+	aVersion, aPrerelease, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bVersion, bPrerelease, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range aVersion {
+		if aVersion[i] != bVersion[i] {
+			return cmp.Compare(aVersion[i], bVersion[i]), nil
+		}
+	}
+	switch {
+	case aPrerelease == bPrerelease:
+		return 0, nil
+	case aPrerelease == "":
+		return 1, nil
+	case bPrerelease == "":
+		return -1, nil
+	}
+	return strings.Compare(aPrerelease, bPrerelease), nil
+}
+
+func ParseVersion(version string) ([3]int, string, error) {
+	var parts [3]int
+	core, prerelease, _ := strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	// Build metadata, i.e. +linux.amd64, doesn't affect the order
+	core, _, _ = strings.Cut(core, "+")
+	prerelease, _, _ = strings.Cut(prerelease, "+")
+	fields := strings.Split(core, ".")
+	if len(fields) != 3 {
+		return parts, "", fmt.Errorf("%q isn't a semantic version", version)
+	}
+	for i, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 0 {
+			return parts, "", fmt.Errorf("%q isn't a semantic version", version)
+		}
+		parts[i] = number
+	}
+
+	return parts, prerelease, nil
+}
+
+func GetLatestRelease(ctx context.Context) (*GithubRelease, error) {
+	// i.e. curl https://api.github.com/repos/imflikk/go-aws-enumerator/releases/latest
+	body, err := DownloadAsset(ctx, RELEASES_URL, MAX_RELEASE_METADATA_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	var release GithubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, err
+	}
+
+	return &release, nil
+}
+
+func DownloadAsset(ctx context.Context, assetURL string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, assetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v from %v", resp.Status, assetURL)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%v is %v bytes, more than the %v allowed", assetURL, resp.ContentLength, maxSize)
+	}
+
+	// The length header can be missing or wrong, so the read is capped as well
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("%v is more than the %v bytes allowed", assetURL, maxSize)
+	}
+
+	return body, nil
+}
+
+func ReplaceExecutable(binary []byte) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+
+	// Write the new binary next to the current one so the final rename stays on the same filesystem
+	newPath := executable + ".new"
+	if err := os.WriteFile(newPath, binary, 0755); err != nil {
+		return err
+	}
+
+	// Windows won't let a running executable be overwritten, but it can be renamed out of the way
+	oldPath := executable + ".old"
+	os.Remove(oldPath)
+	if err := os.Rename(executable, oldPath); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	if err := os.Rename(newPath, executable); err != nil {
+		os.Rename(oldPath, executable)
+		return err
+	}
+
+	// This fails on Windows while the old binary is still running, it's cleaned up on the next update
+	os.Remove(oldPath)
+	return nil
+}
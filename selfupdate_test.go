@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.4.0", "v1.4.0", 0},
+		{"v1.5.0", "v1.4.9", 1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v1.4.0", "v2.0.0", -1},
+		{"v1.5.0-rc.1", "v1.5.0", -1},
+		{"v1.5.0-rc.2", "v1.5.0-rc.1", 1},
+		{"1.4.0", "v1.4.0+linux.amd64", 0},
+	} {
+		got, err := CompareVersions(test.a, test.b)
+		if err != nil {
+			t.Errorf("%v vs %v: %v", test.a, test.b, err)
+		} else if got != test.want {
+			t.Errorf("%v vs %v: got %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+
+	// Builds from source aren't versioned, so nothing can be said to be newer than them
+	for _, version := range []string{"dev", "v1.4", "v1.x.0"} {
+		if _, err := CompareVersions("v1.4.0", version); err == nil {
+			t.Errorf("%v: compared without an error", version)
+		}
+	}
+}
+
+func TestDownloadAssetCapsSize(t *testing.T) {
+	const maxSize = 1024
+	for _, test := range []struct {
+		name          string
+		contentLength string
+		size          int
+		wantErr       bool
+	}{
+		{"within the limit", "", maxSize, false},
+		{"declared too large", strconv.Itoa(maxSize + 1), 0, true},
+		// Chunked, so the length isn't known until the body has been read
+		{"undeclared and too large", "", maxSize + 1, true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.contentLength != "" {
+				w.Header().Set("Content-Length", test.contentLength)
+			} else {
+				w.Header().Set("Transfer-Encoding", "chunked")
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(make([]byte, test.size))
+		}))
+		body, err := DownloadAsset(context.Background(), server.URL, maxSize)
+		server.Close()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: got error %v, want error %v", test.name, err, test.wantErr)
+		} else if err == nil && len(body) != test.size {
+			t.Errorf("%v: got %v bytes, want %v", test.name, len(body), test.size)
+		}
+	}
+}
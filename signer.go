@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/signer"
+	signertypes "github.com/aws/aws-sdk-go-v2/service/signer/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type SigningProfileResult struct {
+	Name       string `json:"name"`
+	VersionArn string `json:"versionArn"`
+	Platform   string `json:"platform"`
+	// Active, Canceled or Revoked
+	Status string `json:"status"`
+	// Principals outside the account allowed to sign with or manage the profile, i.e. 123456789012 (signer:StartSigningJob)
+	CrossAccount []string `json:"crossAccount"`
+}
+
+type CodeSigningConfigResult struct {
+	Arn               string   `json:"arn"`
+	Description       string   `json:"description,omitempty"`
+	AllowedPublishers []string `json:"allowedPublishers"`
+	// Enforce blocks unsigned or untrusted code, Warn only logs it
+	UntrustedArtifactOnDeployment string   `json:"untrustedArtifactOnDeployment"`
+	Functions                     []string `json:"functions"`
+}
+
+// Whether a function's code has to be signed before it's deployed
+type FunctionSigningResult struct {
+	FunctionName      string `json:"functionName"`
+	CodeSigningConfig string `json:"codeSigningConfig,omitempty"`
+	// enforced, warn, none, or unsupported for container images
+	Signing string `json:"signing"`
+}
+
+func RunSignerModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		signerClient := signer.NewFromConfig(regionalConfig)
+		lambdaClient := lambda.NewFromConfig(regionalConfig)
+
+		// i.e. aws signer list-signing-profiles
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting Signer profiles and Lambda code signing configurations in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		profiles, err := ListSigningProfiles(ctx, signerClient)
+		if err == nil {
+			for _, profile := range profiles {
+				result := SigningProfileResult{
+					Name:       aws.ToString(profile.ProfileName),
+					VersionArn: aws.ToString(profile.ProfileVersionArn),
+					Platform:   aws.ToString(profile.PlatformDisplayName),
+					Status:     string(profile.Status),
+				}
+				// Anyone allowed to sign with the profile can produce code every function trusting it will run
+				// i.e. aws signer list-profile-permissions --profile-name <profile-name>
+				permissions, err := ListProfilePermissions(ctx, signerClient, result.Name)
+				if err == nil {
+					for _, permission := range permissions {
+						if principalAccount := PrincipalAccount(aws.ToString(permission.Principal)); principalAccount != account {
+							result.CrossAccount = append(result.CrossAccount, fmt.Sprintf("%v (%v)", aws.ToString(permission.Principal), aws.ToString(permission.Action)))
+						}
+					}
+				}
+
+				fmt.Printf("\tSigning profile: %v (%v)\n", result.Name, result.Status)
+				fmt.Printf("\tVersion: %v\n", result.VersionArn)
+				fmt.Printf("\tPlatform: %v\n", result.Platform)
+				for _, principal := range result.CrossAccount {
+					fmt.Printf("\t[!] Usable from outside the account by %v\n", principal)
+					EmitExposedFinding(regionalConfig.Region, aws.ToString(profile.Arn), EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Signing profile %v can be used by %v", result.Name, principal))
+				}
+				fmt.Println(MINOR_SEPARATOR)
+				Emit("signing-profile", regionalConfig.Region, result)
+			}
+			if len(profiles) == 0 {
+				fmt.Println("\tNo signing profiles in this region")
+				fmt.Println(MINOR_SEPARATOR)
+			}
+		}
+
+		// i.e. aws lambda list-code-signing-configs
+		configs, err := ListCodeSigningConfigs(ctx, lambdaClient)
+		if err != nil {
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+		functionConfigs := map[string]CodeSigningConfigResult{}
+		for _, config := range configs {
+			result := CodeSigningConfigResult{
+				Arn:         aws.ToString(config.CodeSigningConfigArn),
+				Description: aws.ToString(config.Description),
+			}
+			if config.AllowedPublishers != nil {
+				result.AllowedPublishers = config.AllowedPublishers.SigningProfileVersionArns
+			}
+			if config.CodeSigningPolicies != nil {
+				result.UntrustedArtifactOnDeployment = string(config.CodeSigningPolicies.UntrustedArtifactOnDeployment)
+			}
+			// i.e. aws lambda list-functions-by-code-signing-config --code-signing-config-arn <arn>
+			result.Functions, _ = ListFunctionsByCodeSigningConfig(ctx, lambdaClient, result.Arn)
+			for _, functionArn := range result.Functions {
+				functionConfigs[functionArn] = result
+			}
+
+			fmt.Printf("\tCode signing config: %v\n", result.Arn)
+			if result.Description != "" {
+				fmt.Printf("\tDescription: %v\n", result.Description)
+			}
+			for _, publisher := range result.AllowedPublishers {
+				fmt.Printf("\tAllowed publisher: %v\n", publisher)
+			}
+			fmt.Printf("\tUntrusted code on deployment: %v\n", result.UntrustedArtifactOnDeployment)
+			for _, functionArn := range result.Functions {
+				fmt.Printf("\tFunction: %v\n", functionArn)
+			}
+			// Warn looks like protection but deploys unsigned code anyway
+			if result.UntrustedArtifactOnDeployment == string(lambdatypes.CodeSigningPolicyWarn) && len(result.Functions) > 0 {
+				fmt.Println("\t[!] Only warns, unsigned or tampered code is still deployed")
+				EmitFinding(regionalConfig.Region, result.Arn, "Code signing config only warns about untrusted code, it's still deployed")
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("code-signing-config", regionalConfig.Region, result)
+		}
+
+		// Functions without an enforcing config run whatever code anyone allowed lambda:UpdateFunctionCode uploads
+		// i.e. aws lambda list-functions
+		functions, err := ListAllFunctions(ctx, lambdaClient)
+		if err != nil {
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+		enforced := 0
+		for _, function := range functions {
+			result := FunctionSigningResult{FunctionName: aws.ToString(function.FunctionName), Signing: "none"}
+			if config, ok := functionConfigs[aws.ToString(function.FunctionArn)]; ok {
+				result.CodeSigningConfig = config.Arn
+				result.Signing = "warn"
+				if config.UntrustedArtifactOnDeployment == string(lambdatypes.CodeSigningPolicyEnforce) {
+					result.Signing = "enforced"
+					enforced++
+				}
+			} else if function.PackageType == lambdatypes.PackageTypeImage {
+				result.Signing = "unsupported"
+			}
+			fmt.Printf("\tFunction: %v (signing %v)\n", result.FunctionName, result.Signing)
+			Emit("function-signing", regionalConfig.Region, result)
+		}
+		if len(functions) > 0 {
+			fmt.Printf("\t%v of %v functions enforce code signing\n", enforced, len(functions))
+		} else {
+			fmt.Println("\tNo functions in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListSigningProfiles(ctx context.Context, signerClient *signer.Client) ([]signertypes.SigningProfile, error) {
+	var profiles []signertypes.SigningProfile
+	paginator := signer.NewListSigningProfilesPaginator(signerClient, &signer.ListSigningProfilesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(profiles)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the signing profiles. Here's why: %v\n", err)
+			return nil, err
+		}
+		profiles = append(profiles, page.Profiles...)
+	}
+
+	return LimitItems(profiles), nil
+}
+
+func ListProfilePermissions(ctx context.Context, signerClient *signer.Client, profileName string) ([]signertypes.Permission, error) {
+	var permissions []signertypes.Permission
+	input := &signer.ListProfilePermissionsInput{ProfileName: aws.String(profileName)}
+	for {
+		output, err := signerClient.ListProfilePermissions(ctx, input)
+		if err != nil {
+			fmt.Printf("Couldn't list the permissions for %v. Here's why: %v\n", profileName, err)
+			return nil, err
+		}
+		permissions = append(permissions, output.Permissions...)
+		if aws.ToString(output.NextToken) == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	return permissions, nil
+}
+
+func ListCodeSigningConfigs(ctx context.Context, lambdaClient *lambda.Client) ([]lambdatypes.CodeSigningConfig, error) {
+	var configs []lambdatypes.CodeSigningConfig
+	paginator := lambda.NewListCodeSigningConfigsPaginator(lambdaClient, &lambda.ListCodeSigningConfigsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(configs)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the code signing configs. Here's why: %v\n", err)
+			return nil, err
+		}
+		configs = append(configs, page.CodeSigningConfigs...)
+	}
+
+	return LimitItems(configs), nil
+}
+
+func ListFunctionsByCodeSigningConfig(ctx context.Context, lambdaClient *lambda.Client, configArn string) ([]string, error) {
+	var functionArns []string
+	paginator := lambda.NewListFunctionsByCodeSigningConfigPaginator(lambdaClient, &lambda.ListFunctionsByCodeSigningConfigInput{CodeSigningConfigArn: aws.String(configArn)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the functions using %v. Here's why: %v\n", configArn, err)
+			return nil, err
+		}
+		functionArns = append(functionArns, page.FunctionArns...)
+	}
+
+	return functionArns, nil
+}
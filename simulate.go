@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func RunSimulate(ctx context.Context, actions []string, resources []string, principalArn string) error {
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Without a principal, ask about the caller, using the role rather than the session for roles
+	// i.e. aws sts get-caller-identity
+	if principalArn == "" {
+		callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+		if err != nil {
+			return err
+		}
+		principalArn = SimulationPrincipalArn(*callerIdentity.Arn)
+	}
+
+	// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names <action> --resource-arns <resource>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Simulating %v on %v for %v...\n", strings.Join(actions, ", "), strings.Join(resources, ", "), principalArn)
+	fmt.Println(MAJOR_SEPARATOR)
+	results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, actions, resources)
+	if err != nil {
+		return err
+	}
+	CurrentModule = "simulate"
+	for _, result := range results {
+		PrintEvaluationResult(result)
+		fmt.Println(MINOR_SEPARATOR)
+		simulation := NewSimulationResult(result)
+		simulation.Principal = principalArn
+		Emit("simulation", "", simulation)
+	}
+
+	return WriteResults()
+}
+
+func PrintEvaluationResult(result iamtypes.EvaluationResult) {
+	// Each resource gets its own decision when more than one was asked about
+	fmt.Printf("\t%v on %v: %v\n", aws.ToString(result.EvalActionName), aws.ToString(result.EvalResourceName), result.EvalDecision)
+	PrintDecisionDetails(result.MatchedStatements, result.MissingContextValues, result.PermissionsBoundaryDecisionDetail)
+	if result.OrganizationsDecisionDetail != nil && !result.OrganizationsDecisionDetail.AllowedByOrganizations {
+		fmt.Println("\t\tDenied by an SCP")
+	}
+	for _, resourceResult := range result.ResourceSpecificResults {
+		if len(result.ResourceSpecificResults) == 1 && aws.ToString(resourceResult.EvalResourceName) == aws.ToString(result.EvalResourceName) {
+			continue
+		}
+		fmt.Printf("\t%v on %v: %v\n", aws.ToString(result.EvalActionName), aws.ToString(resourceResult.EvalResourceName), resourceResult.EvalResourceDecision)
+		PrintDecisionDetails(resourceResult.MatchedStatements, resourceResult.MissingContextValues, resourceResult.PermissionsBoundaryDecisionDetail)
+	}
+}
+
+func PrintDecisionDetails(statements []iamtypes.Statement, missingContext []string, boundary *iamtypes.PermissionsBoundaryDecisionDetail) {
+	for _, statement := range statements {
+		fmt.Printf("\t\tMatched statement in %v (%v)%v\n", aws.ToString(statement.SourcePolicyId), statement.SourcePolicyType, StatementPosition(statement))
+	}
+	if boundary != nil && !boundary.AllowedByPermissionsBoundary {
+		fmt.Println("\t\tDenied by the permission boundary")
+	}
+	// Conditions on these keys couldn't be evaluated, so the decision may differ for a real request
+	if len(missingContext) > 0 {
+		fmt.Printf("\t\tMissing context keys: %v\n", strings.Join(missingContext, ", "))
+	}
+}
+
+func StatementPosition(statement iamtypes.Statement) string {
+	if statement.StartPosition == nil || statement.EndPosition == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(", lines %v-%v", statement.StartPosition.Line, statement.EndPosition.Line)
+}
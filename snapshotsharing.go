@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// How many snapshot ARNs are simulated in a single call
+const SNAPSHOT_SIMULATION_BATCH = 50
+
+// A snapshot and the action that would share it with another account or make it public
+type ShareableSnapshot struct {
+	SnapshotId string `json:"snapshotId"`
+	Arn        string `json:"arn"`
+	// The database, cluster or volume the snapshot was taken of
+	Source    string `json:"source"`
+	Encrypted bool   `json:"encrypted"`
+	Action    string `json:"action"`
+}
+
+func RunSnapshotSharingModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+	partition := "aws"
+	if parsedArn, err := arn.Parse(principalArn); err == nil {
+		partition = parsedArn.Partition
+	}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Only manual snapshots can be shared, automated ones have to be copied first
+		// i.e. aws rds describe-db-snapshots --snapshot-type manual
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking which snapshots could be shared out of the account in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		var snapshots []ShareableSnapshot
+		rdsClient := rds.NewFromConfig(regionalConfig)
+		dbSnapshots, err := ListDBSnapshots(ctx, rdsClient)
+		if err == nil {
+			for _, snapshot := range dbSnapshots {
+				snapshots = append(snapshots, ShareableSnapshot{
+					SnapshotId: aws.ToString(snapshot.DBSnapshotIdentifier),
+					Arn:        aws.ToString(snapshot.DBSnapshotArn),
+					Source:     aws.ToString(snapshot.DBInstanceIdentifier),
+					Encrypted:  aws.ToBool(snapshot.Encrypted),
+					Action:     "rds:ModifyDBSnapshotAttribute",
+				})
+			}
+		}
+
+		// i.e. aws rds describe-db-cluster-snapshots --snapshot-type manual
+		clusterSnapshots, err := ListDBClusterSnapshots(ctx, rdsClient)
+		if err == nil {
+			for _, snapshot := range clusterSnapshots {
+				snapshots = append(snapshots, ShareableSnapshot{
+					SnapshotId: aws.ToString(snapshot.DBClusterSnapshotIdentifier),
+					Arn:        aws.ToString(snapshot.DBClusterSnapshotArn),
+					Source:     aws.ToString(snapshot.DBClusterIdentifier),
+					Encrypted:  aws.ToBool(snapshot.StorageEncrypted),
+					Action:     "rds:ModifyDBClusterSnapshotAttribute",
+				})
+			}
+		}
+
+		// i.e. aws ec2 describe-snapshots --owner-ids self
+		ebsSnapshots, err := ListOwnedSnapshots(ctx, ec2.NewFromConfig(regionalConfig))
+		if err == nil {
+			for _, snapshot := range ebsSnapshots {
+				source := aws.ToString(snapshot.VolumeId)
+				if name := InstanceName(snapshot.Tags); name != "" {
+					source = fmt.Sprintf("%v (%v)", name, source)
+				}
+				snapshots = append(snapshots, ShareableSnapshot{
+					SnapshotId: aws.ToString(snapshot.SnapshotId),
+					Arn:        fmt.Sprintf("arn:%v:ec2:%v::snapshot/%v", partition, regionalConfig.Region, aws.ToString(snapshot.SnapshotId)),
+					Source:     source,
+					Encrypted:  aws.ToBool(snapshot.Encrypted),
+					Action:     "ec2:ModifySnapshotAttribute",
+				})
+			}
+		}
+		fmt.Printf("\tSnapshots: %v\n", len(snapshots))
+		fmt.Println(MINOR_SEPARATOR)
+
+		// Each kind of snapshot has its own action, so they're simulated in batches of the same kind
+		// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names <action> --resource-arns <snapshot-arn> ...
+		byAction := map[string][]ShareableSnapshot{}
+		for _, snapshot := range snapshots {
+			byAction[snapshot.Action] = append(byAction[snapshot.Action], snapshot)
+		}
+		shareable := 0
+		for _, action := range SortedKeys(byAction) {
+			actionSnapshots := byAction[action]
+			for start := 0; start < len(actionSnapshots); start += SNAPSHOT_SIMULATION_BATCH {
+				batch := actionSnapshots[start:min(start+SNAPSHOT_SIMULATION_BATCH, len(actionSnapshots))]
+				var snapshotArns []string
+				for _, snapshot := range batch {
+					snapshotArns = append(snapshotArns, snapshot.Arn)
+				}
+				results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, []string{action}, snapshotArns)
+				if err != nil {
+					return err
+				}
+				allowed := map[string]bool{}
+				for _, result := range results {
+					for _, snapshotArn := range AllowedResourceNames(result) {
+						allowed[snapshotArn] = true
+					}
+				}
+
+				for _, snapshot := range batch {
+					if !allowed[snapshot.Arn] {
+						continue
+					}
+					shareable++
+					fmt.Printf("\tSnapshot: %v\n", snapshot.SnapshotId)
+					fmt.Printf("\tSnapshot of: %v\n", snapshot.Source)
+					fmt.Printf("\t[!] Can be shared with another account or made public via %v\n", snapshot.Action)
+					// Snapshots encrypted with a customer managed key are only usable if the key is shared too
+					if snapshot.Encrypted {
+						fmt.Println("\t\tEncrypted, the other account also needs access to the KMS key")
+					}
+					EmitExposedFinding(regionalConfig.Region, snapshot.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Snapshot of %v can be shared out of the account via %v", snapshot.Source, snapshot.Action))
+					fmt.Println(MINOR_SEPARATOR)
+					Emit("shareable-snapshot", regionalConfig.Region, snapshot)
+				}
+			}
+		}
+		if shareable == 0 {
+			fmt.Println("\tNo snapshots the current principal can share")
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func ListDBSnapshots(ctx context.Context, rdsClient *rds.Client) ([]rdstypes.DBSnapshot, error) {
+	var snapshots []rdstypes.DBSnapshot
+	paginator := rds.NewDescribeDBSnapshotsPaginator(rdsClient, &rds.DescribeDBSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the DB snapshots. Here's why: %v\n", err)
+			return nil, err
+		}
+		snapshots = append(snapshots, page.DBSnapshots...)
+	}
+
+	return snapshots, nil
+}
+
+func ListDBClusterSnapshots(ctx context.Context, rdsClient *rds.Client) ([]rdstypes.DBClusterSnapshot, error) {
+	var snapshots []rdstypes.DBClusterSnapshot
+	paginator := rds.NewDescribeDBClusterSnapshotsPaginator(rdsClient, &rds.DescribeDBClusterSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the DB cluster snapshots. Here's why: %v\n", err)
+			return nil, err
+		}
+		snapshots = append(snapshots, page.DBClusterSnapshots...)
+	}
+
+	return snapshots, nil
+}
+
+func ListOwnedSnapshots(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.Snapshot, error) {
+	// Without an owner every public snapshot in the region would be listed too
+	var snapshots []ec2types.Snapshot
+	paginator := ec2.NewDescribeSnapshotsPaginator(ec2Client, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the EBS snapshots. Here's why: %v\n", err)
+			return nil, err
+		}
+		snapshots = append(snapshots, page.Snapshots...)
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// What a topic policy can hand out, by what it lets someone do with the topic
+var TOPIC_ACCESS_ACTIONS = map[string][]string{
+	// Push messages to every subscriber, i.e. phishing email or SMS from a trusted sender
+	"publish": {"sns:Publish"},
+	// Add an endpoint of their own and get a copy of every message
+	"subscribe": {"sns:Subscribe", "sns:Receive"},
+	// Rewrite the policy or delivery settings, or delete the topic
+	"manage": {"sns:SetTopicAttributes", "sns:AddPermission", "sns:RemovePermission", "sns:DeleteTopic"},
+}
+
+type TopicResult struct {
+	Arn         string `json:"arn"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	Fifo        bool   `json:"fifo"`
+	// Empty when messages aren't encrypted at rest
+	KmsKey               string          `json:"kmsKey,omitempty"`
+	ConfirmedSubscribers int64           `json:"confirmedSubscribers"`
+	PendingSubscribers   int64           `json:"pendingSubscribers"`
+	Policy               *PolicyDocument `json:"policy,omitempty"`
+	// Kinds of access, publish, subscribe or manage, any AWS principal has without a condition
+	PublicAccess []string `json:"publicAccess"`
+	// Other accounts by the kinds of access the policy gives them
+	AccountAccess map[string][]string `json:"accountAccess"`
+	// Any AWS principal is allowed something, narrowed down by conditions such as aws:SourceOwner
+	ConditionalWildcard bool                 `json:"conditionalWildcard"`
+	Subscriptions       []SubscriptionResult `json:"subscriptions"`
+}
+
+type SubscriptionResult struct {
+	// PendingConfirmation until the endpoint confirms it
+	Arn      string `json:"arn"`
+	Owner    string `json:"owner"`
+	Protocol string `json:"protocol"`
+	Endpoint string `json:"endpoint"`
+	// The account an SQS, Lambda or Firehose endpoint is in, when it isn't the topic's
+	ExternalAccount string `json:"externalAccount,omitempty"`
+}
+
+func RunSNSModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		snsClient := sns.NewFromConfig(regionalConfig)
+
+		// Topic policies are read like queue policies, and subscriptions show where every message goes
+		// i.e. aws sns list-topics, aws sns get-topic-attributes --topic-arn <topic-arn>,
+		// aws sns list-subscriptions-by-topic --topic-arn <topic-arn>
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting SNS topics in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		topics, err := ListTopics(ctx, snsClient)
+		if err != nil {
+			return err
+		}
+		results := make([]*TopicResult, len(topics))
+		ForEachConcurrently(ctx, len(topics), func(ctx context.Context, i int) {
+			results[i] = GetTopicResult(ctx, snsClient, aws.ToString(topics[i].TopicArn), accountId)
+		}, func(i int) {
+			if results[i] != nil {
+				PrintTopicResult(regionalConfig.Region, *results[i])
+			}
+		})
+		if len(topics) == 0 {
+			fmt.Println("\tNo topics in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetTopicResult(ctx context.Context, snsClient *sns.Client, topicArn string, accountId string) *TopicResult {
+	// i.e. aws sns get-topic-attributes --topic-arn <topic-arn>
+	output, err := snsClient.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(topicArn)})
+	if err != nil {
+		fmt.Printf("Couldn't get the attributes of %v. Here's why: %v\n", topicArn, err)
+		return nil
+	}
+	attributes := output.Attributes
+	result := &TopicResult{
+		Arn:           topicArn,
+		Name:          topicArn[strings.LastIndex(topicArn, ":")+1:],
+		DisplayName:   attributes["DisplayName"],
+		Fifo:          attributes["FifoTopic"] == "true",
+		KmsKey:        attributes["KmsMasterKeyId"],
+		AccountAccess: map[string][]string{},
+	}
+	result.ConfirmedSubscribers, _ = strconv.ParseInt(attributes["SubscriptionsConfirmed"], 10, 64)
+	result.PendingSubscribers, _ = strconv.ParseInt(attributes["SubscriptionsPending"], 10, 64)
+
+	// Every topic has a policy, the default one only lets in the owning account
+	if policy := attributes["Policy"]; policy != "" {
+		document, err := ParsePolicyDocument(policy)
+		if err != nil {
+			fmt.Printf("Couldn't parse the policy of %v. Here's why: %v\n", result.Name, err)
+		} else {
+			result.Policy = document
+			result.PublicAccess, result.AccountAccess, result.ConditionalWildcard = AnalyseAccessPolicy(document, []string{"publish", "subscribe", "manage"}, TOPIC_ACCESS_ACTIONS, accountId)
+		}
+	}
+
+	// i.e. aws sns list-subscriptions-by-topic --topic-arn <topic-arn>
+	subscriptions, err := ListTopicSubscriptions(ctx, snsClient, topicArn)
+	if err == nil {
+		for _, subscription := range subscriptions {
+			subscriptionResult := SubscriptionResult{
+				Arn:      aws.ToString(subscription.SubscriptionArn),
+				Owner:    aws.ToString(subscription.Owner),
+				Protocol: aws.ToString(subscription.Protocol),
+				Endpoint: aws.ToString(subscription.Endpoint),
+			}
+			if endpointArn, err := arn.Parse(subscriptionResult.Endpoint); err == nil && endpointArn.AccountID != "" && endpointArn.AccountID != accountId {
+				subscriptionResult.ExternalAccount = endpointArn.AccountID
+			}
+			result.Subscriptions = append(result.Subscriptions, subscriptionResult)
+		}
+	}
+
+	return result
+}
+
+func PrintTopicResult(region string, result TopicResult) {
+	fmt.Printf("\tTopic: %v\n", result.Name)
+	fmt.Printf("\tARN: %v\n", result.Arn)
+	if result.DisplayName != "" {
+		fmt.Printf("\tDisplay name: %v\n", result.DisplayName)
+	}
+	if result.Fifo {
+		fmt.Println("\tFIFO: yes")
+	}
+	if result.KmsKey != "" {
+		fmt.Printf("\tKMS key: %v\n", result.KmsKey)
+	} else {
+		fmt.Println("\t[-] Messages aren't encrypted at rest")
+	}
+	fmt.Printf("\tSubscriptions: %v confirmed, %v pending\n", result.ConfirmedSubscribers, result.PendingSubscribers)
+
+	// AWS writes the same default policy on every topic, only the account's own changes are worth reading
+	if result.Policy != nil && (len(result.PublicAccess) > 0 || len(result.AccountAccess) > 0 || result.ConditionalWildcard) {
+		fmt.Printf("\tTopic policy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	PrintUnresolvedPrincipals(region, result.Arn, result.Policy)
+	if len(result.PublicAccess) > 0 {
+		access := strings.Join(result.PublicAccess, ", ")
+		fmt.Printf("\t[!] Any AWS principal has %v access\n", access)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Topic policy gives any AWS principal %v access to SNS topic %v", access, result.Name))
+	}
+	for _, account := range SortedKeys(result.AccountAccess) {
+		access := strings.Join(result.AccountAccess[account], ", ")
+		fmt.Printf("\t[!] Account %v has %v access\n", account, access)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Topic policy gives account %v %v access to SNS topic %v", account, access, result.Name))
+	}
+	if result.ConditionalWildcard && len(result.PublicAccess) == 0 {
+		fmt.Println("\t[-] Any AWS principal is allowed in, narrowed down by conditions")
+	}
+
+	for _, subscription := range result.Subscriptions {
+		fmt.Printf("\tSubscription: %v to %v\n", subscription.Protocol, subscription.Endpoint)
+		if subscription.Arn == "PendingConfirmation" {
+			fmt.Println("\t[-] Waiting for the endpoint to confirm it")
+		}
+		// Messages go to the endpoint unencrypted, to whoever is on the path or behind the address
+		if subscription.Protocol == "http" {
+			fmt.Println("\t[!] Messages are delivered over plain HTTP")
+			EmitFinding(region, result.Arn, fmt.Sprintf("SNS topic %v delivers messages over plain HTTP to %v", result.Name, subscription.Endpoint))
+		}
+		if subscription.ExternalAccount != "" {
+			fmt.Printf("\t[!] Messages go to account %v\n", subscription.ExternalAccount)
+			EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("SNS topic %v delivers messages to account %v", result.Name, subscription.ExternalAccount))
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("sns-topic", region, result)
+}
+
+func ListTopics(ctx context.Context, snsClient *sns.Client) ([]snstypes.Topic, error) {
+	var topics []snstypes.Topic
+	paginator := sns.NewListTopicsPaginator(snsClient, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(topics)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the SNS topics. Here's why: %v\n", err)
+			return nil, err
+		}
+		topics = append(topics, page.Topics...)
+	}
+
+	return LimitItems(topics), nil
+}
+
+func ListTopicSubscriptions(ctx context.Context, snsClient *sns.Client, topicArn string) ([]snstypes.Subscription, error) {
+	var subscriptions []snstypes.Subscription
+	paginator := sns.NewListSubscriptionsByTopicPaginator(snsClient, &sns.ListSubscriptionsByTopicInput{TopicArn: aws.String(topicArn)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the subscriptions to %v. Here's why: %v\n", topicArn, err)
+			return nil, err
+		}
+		subscriptions = append(subscriptions, page.Subscriptions...)
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyseTopicPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		policy          string
+		wantPublic      []string
+		wantAccounts    map[string][]string
+		wantConditional bool
+	}{
+		{
+			// What sns create-topic writes, pinned to the owning account
+			name: "default policy",
+			policy: `{"Version":"2008-10-17","Id":"__default_policy_ID","Statement":[{"Sid":"__default_statement_ID","Effect":"Allow","Principal":{"AWS":"*"},` +
+				`"Action":["SNS:GetTopicAttributes","SNS:SetTopicAttributes","SNS:AddPermission","SNS:RemovePermission","SNS:DeleteTopic","SNS:Subscribe","SNS:ListSubscriptionsByTopic","SNS:Publish"],` +
+				`"Resource":"arn:aws:sns:us-east-1:123456789012:topic","Condition":{"StringEquals":{"AWS:SourceOwner":"123456789012"}}}]}`,
+		},
+		{
+			name:       "anyone can publish",
+			policy:     `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"sns:Publish","Resource":"arn:aws:sns:us-east-1:123456789012:topic"}]}`,
+			wantPublic: []string{"publish"},
+		},
+		{
+			name:       "anyone can subscribe",
+			policy:     `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"sns:Subscribe","Resource":"arn:aws:sns:us-east-1:123456789012:topic"}]}`,
+			wantPublic: []string{"subscribe"},
+		},
+		{
+			name:       "anyone can publish and subscribe",
+			policy:     `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":["SNS:Publish","SNS:Subscribe"],"Resource":"arn:aws:sns:us-east-1:123456789012:topic"}]}`,
+			wantPublic: []string{"publish", "subscribe"},
+		},
+		{
+			name: "another account can subscribe",
+			policy: `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},` +
+				`"Action":"sns:Subscribe","Resource":"arn:aws:sns:us-east-1:123456789012:topic"}]}`,
+			wantAccounts: map[string][]string{"210987654321": {"subscribe"}},
+		},
+		{
+			// What letting S3 event notifications publish to the topic writes
+			name: "bucket pinned by source ARN",
+			policy: `{"Version":"2008-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"sns:Publish","Resource":"arn:aws:sns:us-east-1:123456789012:topic",` +
+				`"Condition":{"ArnLike":{"aws:SourceArn":"arn:aws:s3:::bucket"}}}]}`,
+			wantConditional: true,
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		public, accounts, conditional := AnalyseAccessPolicy(policy, []string{"publish", "subscribe", "manage"}, TOPIC_ACCESS_ACTIONS, "123456789012")
+		if !slices.Equal(public, test.wantPublic) {
+			t.Errorf("%v: public access %v, want %v", test.name, public, test.wantPublic)
+		}
+		if len(accounts) != len(test.wantAccounts) {
+			t.Errorf("%v: account access %v, want %v", test.name, accounts, test.wantAccounts)
+		}
+		for account, access := range test.wantAccounts {
+			if !slices.Equal(accounts[account], access) {
+				t.Errorf("%v: account %v has %v access, want %v", test.name, account, accounts[account], access)
+			}
+		}
+		if conditional != test.wantConditional {
+			t.Errorf("%v: conditional wildcard is %v, want %v", test.name, conditional, test.wantConditional)
+		}
+	}
+}
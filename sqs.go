@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// What a queue policy can hand out, by what it lets someone do with the queue
+var QUEUE_ACCESS_ACTIONS = map[string][]string{
+	// Inject messages into whatever consumes the queue
+	"send": {"sqs:SendMessage"},
+	// Read or throw away the messages meant for the consumer
+	"consume": {"sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:PurgeQueue", "sqs:ChangeMessageVisibility"},
+	// Rewrite the policy, redrive or encryption, and with them everything else
+	"manage": {"sqs:SetQueueAttributes", "sqs:AddPermission", "sqs:RemovePermission", "sqs:DeleteQueue"},
+}
+
+type QueueResult struct {
+	Url  string `json:"url"`
+	Arn  string `json:"arn"`
+	Name string `json:"name"`
+	Fifo bool   `json:"fifo"`
+	// sqs-managed, kms or none
+	Encryption string `json:"encryption"`
+	KmsKey     string `json:"kmsKey,omitempty"`
+	// The queue failed messages are moved to
+	DeadLetterTarget string          `json:"deadLetterTarget,omitempty"`
+	Messages         int64           `json:"messages"`
+	Policy           *PolicyDocument `json:"policy,omitempty"`
+	// Kinds of access, send, consume or manage, any AWS principal has without a condition
+	PublicAccess []string `json:"publicAccess"`
+	// Other accounts by the kinds of access the policy gives them
+	AccountAccess map[string][]string `json:"accountAccess"`
+	// Any AWS principal is allowed something, narrowed down by conditions such as aws:SourceArn
+	ConditionalWildcard bool `json:"conditionalWildcard"`
+}
+
+func RunSQSModule(ctx context.Context, sdkConfig aws.Config) error {
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the current account ID. Exiting...")
+		return err
+	}
+	accountId := aws.ToString(callerIdentity.Account)
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		sqsClient := sqs.NewFromConfig(regionalConfig)
+
+		// Queue policies are resource policies, IAM never sees who they let in, so each one is read
+		// for wildcard and other-account principals
+		// i.e. aws sqs list-queues, aws sqs get-queue-attributes --queue-url <queue-url> --attribute-names All
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting SQS queues in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		queueUrls, err := ListQueues(ctx, sqsClient)
+		if err != nil {
+			return err
+		}
+		results := make([]*QueueResult, len(queueUrls))
+		ForEachConcurrently(ctx, len(queueUrls), func(ctx context.Context, i int) {
+			results[i] = GetQueueResult(ctx, sqsClient, queueUrls[i], accountId)
+		}, func(i int) {
+			if results[i] != nil {
+				PrintQueueResult(regionalConfig.Region, *results[i])
+			}
+		})
+		if len(queueUrls) == 0 {
+			fmt.Println("\tNo queues in this region")
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetQueueResult(ctx context.Context, sqsClient *sqs.Client, queueUrl string, accountId string) *QueueResult {
+	// i.e. aws sqs get-queue-attributes --queue-url <queue-url> --attribute-names All
+	output, err := sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueUrl),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameAll},
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the attributes of %v. Here's why: %v\n", queueUrl, err)
+		return nil
+	}
+	attributes := output.Attributes
+	result := &QueueResult{
+		Url:           queueUrl,
+		Arn:           attributes["QueueArn"],
+		Name:          queueUrl[strings.LastIndex(queueUrl, "/")+1:],
+		Fifo:          attributes["FifoQueue"] == "true",
+		Encryption:    "none",
+		KmsKey:        attributes["KmsMasterKeyId"],
+		AccountAccess: map[string][]string{},
+	}
+	result.Messages, _ = strconv.ParseInt(attributes["ApproximateNumberOfMessages"], 10, 64)
+	switch {
+	case result.KmsKey != "":
+		result.Encryption = "kms"
+	case attributes["SqsManagedSseEnabled"] == "true":
+		result.Encryption = "sqs-managed"
+	}
+	// i.e. {"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:failed","maxReceiveCount":5}
+	if redrivePolicy := attributes["RedrivePolicy"]; redrivePolicy != "" {
+		var redrive struct {
+			DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+		}
+		if err := json.Unmarshal([]byte(redrivePolicy), &redrive); err == nil {
+			result.DeadLetterTarget = redrive.DeadLetterTargetArn
+		}
+	}
+
+	// Queues without a policy are only reachable through IAM in the owning account
+	if policy := attributes["Policy"]; policy != "" {
+		document, err := ParsePolicyDocument(policy)
+		if err != nil {
+			fmt.Printf("Couldn't parse the policy of %v. Here's why: %v\n", result.Name, err)
+			return result
+		}
+		result.Policy = document
+		result.PublicAccess, result.AccountAccess, result.ConditionalWildcard = AnalyseAccessPolicy(document, []string{"send", "consume", "manage"}, QUEUE_ACCESS_ACTIONS, accountId)
+	}
+
+	return result
+}
+
+func AnalyseAccessPolicy(policy *PolicyDocument, kinds []string, actions map[string][]string, account string) ([]string, map[string][]string, bool) {
+	// Which kinds of access any AWS principal has, which other accounts get which kinds, and whether
+	// a wildcard principal is let in behind conditions
+	var public []string
+	accountAccess := map[string][]string{}
+	var allActions []string
+	for _, kind := range kinds {
+		allActions = append(allActions, actions[kind]...)
+		isPublic, accounts := AnalyseResourcePolicy(policy, actions[kind], account, ConditionRestricted)
+		if isPublic {
+			public = append(public, kind)
+		}
+		for _, otherAccount := range accounts {
+			accountAccess[otherAccount] = append(accountAccess[otherAccount], kind)
+		}
+	}
+
+	return public, accountAccess, HasConditionalWildcard(policy, allActions, account)
+}
+
+func HasConditionalWildcard(policy *PolicyDocument, actions []string, account string) bool {
+	// Service integrations, i.e. an SNS topic or S3 event notification, are usually let in this way,
+	// pinned to their source with aws:SourceArn or aws:SourceAccount. Statements only letting in
+	// the owning account, like the one every SNS topic starts with, aren't counted
+	for _, statement := range policy.Statement {
+		if statement.Effect != "Allow" || len(statement.Condition) == 0 || !slices.Contains(statement.Principal["AWS"], "*") || PinnedToAccount(statement, account) {
+			continue
+		}
+		if slices.ContainsFunc(actions, func(action string) bool { return StatementCoversAction(statement, action) }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func PinnedToAccount(statement PolicyStatement, account string) bool {
+	// i.e. "Condition": {"StringEquals": {"AWS:SourceOwner": "123456789012"}}
+	for operator, keys := range statement.Condition {
+		if !strings.HasPrefix(operator, "StringEquals") {
+			continue
+		}
+		for key, value := range keys {
+			if !slices.Contains([]string{"aws:sourceowner", "aws:sourceaccount", "aws:principalaccount"}, strings.ToLower(key)) {
+				continue
+			}
+			switch value := value.(type) {
+			case string:
+				return value == account
+			case []any:
+				return len(value) == 1 && value[0] == account
+			}
+		}
+	}
+
+	return false
+}
+
+func PrintQueueResult(region string, result QueueResult) {
+	fmt.Printf("\tQueue: %v\n", result.Name)
+	fmt.Printf("\tURL: %v\n", result.Url)
+	if result.Fifo {
+		fmt.Println("\tFIFO: yes")
+	}
+	fmt.Printf("\tEncryption: %v\n", result.Encryption)
+	if result.KmsKey != "" {
+		fmt.Printf("\tKMS key: %v\n", result.KmsKey)
+	}
+	if result.DeadLetterTarget != "" {
+		fmt.Printf("\tDead-letter queue: %v\n", result.DeadLetterTarget)
+	}
+	fmt.Printf("\tMessages waiting: %v\n", result.Messages)
+	if result.Encryption == "none" {
+		fmt.Println("\t[-] Messages aren't encrypted at rest")
+	}
+
+	if result.Policy != nil {
+		fmt.Printf("\tQueue policy:\n%v\n", FormatPolicyDocument(result.Policy))
+	}
+	PrintUnresolvedPrincipals(region, result.Arn, result.Policy)
+	if len(result.PublicAccess) > 0 {
+		access := strings.Join(result.PublicAccess, ", ")
+		fmt.Printf("\t[!] Any AWS principal has %v access\n", access)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_INTERNET, fmt.Sprintf("Queue policy gives any AWS principal %v access to SQS queue %v", access, result.Name))
+	}
+	for _, account := range SortedKeys(result.AccountAccess) {
+		access := strings.Join(result.AccountAccess[account], ", ")
+		fmt.Printf("\t[!] Account %v has %v access\n", account, access)
+		EmitExposedFinding(region, result.Arn, EXPOSURE_CROSS_ACCOUNT, fmt.Sprintf("Queue policy gives account %v %v access to SQS queue %v", account, access, result.Name))
+	}
+	if result.ConditionalWildcard && len(result.PublicAccess) == 0 {
+		fmt.Println("\t[-] Any AWS principal is allowed in, narrowed down by conditions")
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("sqs-queue", region, result)
+}
+
+func ListQueues(ctx context.Context, sqsClient *sqs.Client) ([]string, error) {
+	var queueUrls []string
+	paginator := sqs.NewListQueuesPaginator(sqsClient, &sqs.ListQueuesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(queueUrls)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the SQS queues. Here's why: %v\n", err)
+			return nil, err
+		}
+		queueUrls = append(queueUrls, page.QueueUrls...)
+	}
+
+	return LimitItems(queueUrls), nil
+}
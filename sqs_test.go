@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyseQueuePolicy(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		policy          string
+		wantPublic      []string
+		wantAccounts    map[string][]string
+		wantConditional bool
+	}{
+		{
+			name:       "anyone can send",
+			policy:     `{"Statement":{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+			wantPublic: []string{"send"},
+		},
+		{
+			name:       "anyone can do anything",
+			policy:     `{"Statement":{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"sqs:*","Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+			wantPublic: []string{"send", "consume", "manage"},
+		},
+		{
+			name:       "anyone can receive",
+			policy:     `{"Statement":{"Effect":"Allow","Principal":"*","Action":["sqs:ReceiveMessage","sqs:GetQueueAttributes"],"Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+			wantPublic: []string{"consume"},
+		},
+		{
+			name: "another account can consume",
+			policy: `{"Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},` +
+				`"Action":["sqs:ReceiveMessage","sqs:DeleteMessage"],"Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+			wantAccounts: map[string][]string{"210987654321": {"consume"}},
+		},
+		{
+			// What subscribing a queue to a topic writes
+			name: "topic pinned by source ARN",
+			policy: `{"Statement":{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"SQS:SendMessage","Resource":"arn:aws:sqs:us-east-1:123456789012:queue",` +
+				`"Condition":{"ArnEquals":{"aws:SourceArn":"arn:aws:sns:us-east-1:123456789012:topic"}}}}`,
+			wantConditional: true,
+		},
+		{
+			name: "pinned to the owning account",
+			policy: `{"Statement":{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"arn:aws:sqs:us-east-1:123456789012:queue",` +
+				`"Condition":{"StringEquals":{"aws:SourceAccount":"123456789012"}}}}`,
+		},
+		{
+			name:   "anyone can read the attributes",
+			policy: `{"Statement":{"Effect":"Allow","Principal":"*","Action":"sqs:GetQueueAttributes","Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+		},
+		{
+			name:   "denied to anyone",
+			policy: `{"Statement":{"Effect":"Deny","Principal":"*","Action":"sqs:*","Resource":"arn:aws:sqs:us-east-1:123456789012:queue"}}`,
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		public, accounts, conditional := AnalyseAccessPolicy(policy, []string{"send", "consume", "manage"}, QUEUE_ACCESS_ACTIONS, "123456789012")
+		if !slices.Equal(public, test.wantPublic) {
+			t.Errorf("%v: public access %v, want %v", test.name, public, test.wantPublic)
+		}
+		if len(accounts) != len(test.wantAccounts) {
+			t.Errorf("%v: account access %v, want %v", test.name, accounts, test.wantAccounts)
+		}
+		for account, access := range test.wantAccounts {
+			if !slices.Equal(accounts[account], access) {
+				t.Errorf("%v: account %v has %v access, want %v", test.name, account, accounts[account], access)
+			}
+		}
+		if conditional != test.wantConditional {
+			t.Errorf("%v: conditional wildcard is %v, want %v", test.name, conditional, test.wantConditional)
+		}
+	}
+}
+
+func TestPinnedToAccount(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		condition map[string]map[string]any
+		want      bool
+	}{
+		{"source owner", map[string]map[string]any{"StringEquals": {"AWS:SourceOwner": "123456789012"}}, true},
+		{"source account list", map[string]map[string]any{"StringEquals": {"aws:SourceAccount": []any{"123456789012"}}}, true},
+		{"other account", map[string]map[string]any{"StringEquals": {"aws:SourceAccount": "210987654321"}}, false},
+		{"several accounts", map[string]map[string]any{"StringEquals": {"aws:SourceAccount": []any{"123456789012", "210987654321"}}}, false},
+		{"pattern", map[string]map[string]any{"StringLike": {"aws:SourceAccount": "123456789012"}}, false},
+		{"source ARN", map[string]map[string]any{"ArnEquals": {"aws:SourceArn": "arn:aws:sns:us-east-1:123456789012:topic"}}, false},
+	} {
+		if got := PinnedToAccount(PolicyStatement{Condition: test.condition}, "123456789012"); got != test.want {
+			t.Errorf("%v: PinnedToAccount is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
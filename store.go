@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// DataStore holds what one module has fetched so the modules after it can reuse it,
+// keyed by what the data is, i.e. "iam:users" or "ec2:instances:us-east-1". It's safe
+// to use from any goroutine, and the run loop swaps in a new one for each target
+type DataStore struct {
+	mutex sync.Mutex
+	data  map[string]any
+	// Held while a key's value is fetched, so goroutines after the same data wait for
+	// the first fetch instead of repeating it
+	fetching map[string]*sync.Mutex
+}
+
+var Store = NewDataStore()
+
+func NewDataStore() *DataStore {
+	return &DataStore{data: map[string]any{}, fetching: map[string]*sync.Mutex{}}
+}
+
+func (s *DataStore) Put(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = value
+}
+
+func (s *DataStore) Get(key string) (any, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func (s *DataStore) fetchMutex(key string) *sync.Mutex {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.fetching[key]; !ok {
+		s.fetching[key] = &sync.Mutex{}
+	}
+	return s.fetching[key]
+}
+
+func Cached[T any](key string, fetch func() (T, error)) (T, error) {
+	// Use what's already in the store, otherwise fetch it and keep it for the next module.
+	// Failed fetches aren't kept, so the next caller tries again
+	store := Store
+	fetchMutex := store.fetchMutex(key)
+	fetchMutex.Lock()
+	defer fetchMutex.Unlock()
+	if value, ok := store.Get(key); ok {
+		return value.(T), nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	store.Put(key, value)
+
+	return value, nil
+}
+
+func CachedUsers(ctx context.Context, iamClient *iam.Client) ([]iamtypes.User, error) {
+	return Cached("iam:users", func() ([]iamtypes.User, error) {
+		users, err := ListAllUsers(ctx, iamClient)
+		return ScopeToEnvironment(users, func(user iamtypes.User) (string, map[string]string) {
+			return aws.ToString(user.UserName), IAMTags(user.Tags)
+		}), err
+	})
+}
+
+func CachedGroups(ctx context.Context, iamClient *iam.Client) ([]iamtypes.Group, error) {
+	return Cached("iam:groups", func() ([]iamtypes.Group, error) {
+		groups, err := ListAllGroups(ctx, iamClient)
+		return ScopeToEnvironment(groups, func(group iamtypes.Group) (string, map[string]string) {
+			return aws.ToString(group.GroupName), nil
+		}), err
+	})
+}
+
+func CachedRoles(ctx context.Context, iamClient *iam.Client) ([]iamtypes.Role, error) {
+	return Cached("iam:roles", func() ([]iamtypes.Role, error) {
+		roles, err := ListAllRoles(ctx, iamClient)
+		return ScopeToEnvironment(roles, func(role iamtypes.Role) (string, map[string]string) {
+			return aws.ToString(role.RoleName), IAMTags(role.Tags)
+		}), err
+	})
+}
+
+func CachedInstanceProfiles(ctx context.Context, iamClient *iam.Client) ([]iamtypes.InstanceProfile, error) {
+	return Cached("iam:instance-profiles", func() ([]iamtypes.InstanceProfile, error) {
+		return ListInstanceProfiles(ctx, iamClient)
+	})
+}
+
+func CachedInstances(ctx context.Context, regionalConfig aws.Config) ([]ec2types.Instance, error) {
+	// Every instance in the region, or in --environment, is kept, modules that only want some of them filter it themselves
+	return Cached("ec2:instances:"+regionalConfig.Region, func() ([]ec2types.Instance, error) {
+		instances, err := ListInstances(ctx, ec2.NewFromConfig(regionalConfig), nil)
+		return ScopeToEnvironment(instances, func(instance ec2types.Instance) (string, map[string]string) {
+			return InstanceName(instance.Tags), EC2Tags(instance.Tags)
+		}), err
+	})
+}
+
+func RunningInstances(instances []ec2types.Instance) []ec2types.Instance {
+	var running []ec2types.Instance
+	for _, instance := range instances {
+		if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning {
+			running = append(running, instance)
+		}
+	}
+
+	return running
+}
+
+func ReachedMaxItems(count int) bool {
+	// Checked before fetching each page, so no more pages are requested than --max-items needs
+	return MaxItemsFlag > 0 && count >= MaxItemsFlag
+}
+
+func LimitItems[T any](items []T) []T {
+	// The last page can go past --max-items, so it's trimmed back
+	if MaxItemsFlag > 0 && len(items) > MaxItemsFlag {
+		return items[:MaxItemsFlag]
+	}
+
+	return items
+}
+
+func OrderModules(selected []Module) ([]Module, error) {
+	// Put each module after the ones it depends on, pulling in any that weren't selected
+	var ordered []Module
+	state := map[string]int{}
+	const visiting, done = 1, 2
+
+	var visit func(module Module) error
+	visit = func(module Module) error {
+		switch state[module.Name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("module %q depends on itself", module.Name)
+		}
+
+		state[module.Name] = visiting
+		for _, dependency := range module.DependsOn {
+			dependencyModule, err := SelectModules(dependency)
+			if err != nil {
+				return fmt.Errorf("module %q depends on %w", module.Name, err)
+			}
+			if err := visit(dependencyModule[0]); err != nil {
+				return err
+			}
+		}
+		state[module.Name] = done
+		ordered = append(ordered, module)
+
+		return nil
+	}
+
+	for _, module := range selected {
+		if err := visit(module); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
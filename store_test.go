@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func useDataStore(t testing.TB) {
+	previousStore := Store
+	Store = NewDataStore()
+	t.Cleanup(func() {
+		Store = previousStore
+	})
+}
+
+func TestCachedFetchesOncePerKey(t *testing.T) {
+	// Modules working from the same inventory in every region at once, i.e. go test -race
+	useDataStore(t)
+	regions := []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-2"}
+	fetches := map[string]*atomic.Int32{}
+	for _, region := range regions {
+		fetches[region] = &atomic.Int32{}
+	}
+
+	var wg sync.WaitGroup
+	for module := 0; module < 8; module++ {
+		for _, region := range regions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				instances, err := Cached("ec2:instances:"+region, func() ([]string, error) {
+					fetches[region].Add(1)
+					return []string{"i-" + region}, nil
+				})
+				if err != nil || len(instances) != 1 || instances[0] != "i-"+region {
+					t.Errorf("module %v got %v, %v for %v", module, instances, err, region)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, region := range regions {
+		if count := fetches[region].Load(); count != 1 {
+			t.Errorf("%v fetched %v times, want 1", region, count)
+		}
+	}
+}
+
+func TestCachedRetriesFailedFetch(t *testing.T) {
+	useDataStore(t)
+	var fetches atomic.Int32
+	fetch := func() (int, error) {
+		if fetches.Add(1) == 1 {
+			return 0, errors.New("throttled")
+		}
+		return 42, nil
+	}
+
+	if _, err := Cached("iam:users", fetch); err == nil {
+		t.Fatal("first fetch didn't fail")
+	}
+	for i := 0; i < 3; i++ {
+		if value, err := Cached("iam:users", fetch); err != nil || value != 42 {
+			t.Errorf("got %v, %v", value, fmt.Sprint(err))
+		}
+	}
+	if count := fetches.Load(); count != 2 {
+		t.Errorf("fetched %v times, want 2", count)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// A takeover technique works on an instance when every one of its actions is allowed against it,
+// and for the side channels, when there's also a way to reach the instance
+type TakeoverTechnique struct {
+	Name      string
+	Actions   []string
+	Reachable func(channels SideChannels, instance ec2types.Instance) bool
+}
+
+var TAKEOVER_TECHNIQUES = []TakeoverTechnique{
+	{"User data swap (stop, replace user data, start)", []string{"ec2:StopInstances", "ec2:ModifyInstanceAttribute", "ec2:StartInstances"}, nil},
+	{"SSM Run Command", []string{"ssm:SendCommand"}, nil},
+	{"SSM Session Manager", []string{"ssm:StartSession"}, nil},
+	{"EC2 serial console", []string{"ec2-instance-connect:SendSerialConsoleSSHPublicKey"}, func(channels SideChannels, instance ec2types.Instance) bool {
+		return channels.SerialConsole
+	}},
+	{"EC2 Instance Connect", []string{"ec2-instance-connect:SendSSHPublicKey"}, func(channels SideChannels, instance ec2types.Instance) bool {
+		return instance.PublicIpAddress != nil
+	}},
+	{"EC2 Instance Connect Endpoint", []string{"ec2-instance-connect:SendSSHPublicKey", "ec2-instance-connect:OpenTunnel"}, func(channels SideChannels, instance ec2types.Instance) bool {
+		return channels.EndpointVPCs[aws.ToString(instance.VpcId)]
+	}},
+}
+
+// The EC2 Instance Connect actions, checked against every resource to show what the principal holds
+var INSTANCE_CONNECT_ACTIONS = []string{
+	"ec2-instance-connect:SendSSHPublicKey",
+	"ec2-instance-connect:SendSerialConsoleSSHPublicKey",
+	"ec2-instance-connect:OpenTunnel",
+}
+
+// What's set up in a region for reaching instances outside of SSH and SSM
+type SideChannels struct {
+	// Serial console access is off by default and turned on for the whole account, per region
+	SerialConsole bool `json:"serialConsole"`
+	// VPCs with an EC2 Instance Connect Endpoint, which tunnels to instances without a public IP
+	EndpointVPCs map[string]bool `json:"endpointVpcs"`
+}
+
+type TakeoverResult struct {
+	InstanceId string   `json:"instanceId"`
+	Name       string   `json:"name,omitempty"`
+	Techniques []string `json:"techniques"`
+}
+
+func RunTakeoverModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't get the caller identity. Exiting...")
+		return err
+	}
+	principalArn := SimulationPrincipalArn(*callerIdentity.Arn)
+	partition := "aws"
+	if parsedArn, err := arn.Parse(principalArn); err == nil {
+		partition = parsedArn.Partition
+	}
+
+	var actions []string
+	for _, technique := range TAKEOVER_TECHNIQUES {
+		actions = append(actions, technique.Actions...)
+	}
+
+	// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names ec2-instance-connect:SendSSHPublicKey ...
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking EC2 Instance Connect permissions for the current principal...")
+	fmt.Println(MAJOR_SEPARATOR)
+	results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, INSTANCE_CONNECT_ACTIONS, []string{"*"})
+	if err == nil {
+		for _, result := range results {
+			fmt.Printf("\t%v: %v\n", *result.EvalActionName, result.EvalDecision)
+			Emit("simulation", "", NewSimulationResult(result))
+		}
+	}
+
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		// Call the describe-instances API and simulate each takeover technique against every running instance
+		// i.e. aws ec2 describe-instances
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Checking which instances could be taken over in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+
+		// i.e. aws ec2 get-serial-console-access-status, aws ec2 describe-instance-connect-endpoints
+		channels := GetSideChannels(ctx, ec2.NewFromConfig(regionalConfig))
+		fmt.Printf("\tSerial console access: %v\n", channels.SerialConsole)
+		fmt.Printf("\tVPCs with an Instance Connect Endpoint: %v\n", len(channels.EndpointVPCs))
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("side-channels", regionalConfig.Region, channels)
+
+		for _, instance := range RunningInstances(instances) {
+			instanceArn := fmt.Sprintf("arn:%v:ec2:%v:%v:instance/%v", partition, regionalConfig.Region, *callerIdentity.Account, *instance.InstanceId)
+
+			// i.e. aws iam simulate-principal-policy --policy-source-arn <principal-arn> --action-names ... --resource-arns <instance-arn>
+			results, err := SimulatePrincipalActions(ctx, iamClient, principalArn, actions, []string{instanceArn})
+			if err != nil {
+				return err
+			}
+			allowed := map[string]bool{}
+			for _, result := range results {
+				if result.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+					allowed[*result.EvalActionName] = true
+				}
+			}
+
+			var usable []string
+			for _, technique := range TAKEOVER_TECHNIQUES {
+				works := true
+				for _, action := range technique.Actions {
+					if !allowed[action] {
+						works = false
+						break
+					}
+				}
+				if works && technique.Reachable != nil {
+					works = technique.Reachable(channels, instance)
+				}
+				if works {
+					usable = append(usable, technique.Name)
+				}
+			}
+			if len(usable) == 0 {
+				continue
+			}
+
+			fmt.Printf("\tInstance ID: %v\n", *instance.InstanceId)
+			if name := InstanceName(instance.Tags); name != "" {
+				fmt.Printf("\tName: %v\n", name)
+			}
+			if instance.IamInstanceProfile != nil {
+				fmt.Printf("\tInstance profile: %v\n", *instance.IamInstanceProfile.Arn)
+			}
+			for _, technique := range usable {
+				fmt.Printf("\t[!] Can be taken over via %v\n", technique)
+				EmitFinding(regionalConfig.Region, instanceArn, fmt.Sprintf("Can be taken over via %v", technique))
+			}
+			fmt.Println(MINOR_SEPARATOR)
+			Emit("takeover", regionalConfig.Region, TakeoverResult{InstanceId: *instance.InstanceId, Name: InstanceName(instance.Tags), Techniques: usable})
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetSideChannels(ctx context.Context, ec2Client *ec2.Client) SideChannels {
+	// Anything that can't be checked is treated as not set up
+	channels := SideChannels{EndpointVPCs: map[string]bool{}}
+	serialConsole, err := ec2Client.GetSerialConsoleAccessStatus(ctx, &ec2.GetSerialConsoleAccessStatusInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the serial console access status. Here's why: %v\n", err)
+	} else {
+		channels.SerialConsole = aws.ToBool(serialConsole.SerialConsoleAccessEnabled)
+	}
+
+	paginator := ec2.NewDescribeInstanceConnectEndpointsPaginator(ec2Client, &ec2.DescribeInstanceConnectEndpointsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the Instance Connect Endpoints. Here's why: %v\n", err)
+			break
+		}
+		for _, endpoint := range page.InstanceConnectEndpoints {
+			if endpoint.State == ec2types.Ec2InstanceConnectEndpointStateCreateComplete {
+				channels.EndpointVPCs[aws.ToString(endpoint.VpcId)] = true
+			}
+		}
+	}
+
+	return channels
+}
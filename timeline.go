@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// How many of the principal's most recent CloudTrail events to pull from each region
+const TIMELINE_EVENTS_PER_REGION = 50
+
+// How often to check whether the credential report has finished generating
+const CREDENTIAL_REPORT_POLL_INTERVAL = 2 * time.Second
+
+// Something the principal or its credentials did, and where the tool learned of it
+type TimelineEvent struct {
+	Time time.Time `json:"time"`
+	// iam, credential-report, cloudtrail or cloudtrail-lake
+	Source      string `json:"source"`
+	Description string `json:"description"`
+	Region      string `json:"region,omitempty"`
+}
+
+type ActivityProfileResult struct {
+	Principal string `json:"principal"`
+	// Oldest first, from IAM, the credential report and CloudTrail event history
+	Events []TimelineEvent `json:"events"`
+}
+
+func RunTimelineModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	callerArn := aws.ToString(callerIdentity.Arn)
+	profile := ActivityProfileResult{Principal: CallerIdentityName(callerArn)}
+
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Building an activity profile for %v...\n", profile.Principal)
+	fmt.Println(MAJOR_SEPARATOR)
+
+	// What IAM itself remembers about the principal, and the name CloudTrail records its events under
+	var eventUsername string
+	if roleName, ok := CallerRoleName(callerArn); ok {
+		// Events from a role session are recorded under the session name, which may be shared
+		// by other sessions of the same role
+		eventUsername = callerArn[strings.LastIndex(callerArn, "/")+1:]
+		profile.Events = append(profile.Events, RoleTimeline(ctx, iamClient, roleName)...)
+	} else if parsedArn, err := arn.Parse(callerArn); err == nil && parsedArn.Resource == "root" {
+		eventUsername = "root"
+		profile.Events = append(profile.Events, CredentialReportTimeline(ctx, iamClient, "<root_account>")...)
+	} else if err == nil && strings.HasPrefix(parsedArn.Resource, "user/") {
+		eventUsername = parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+		profile.Events = append(profile.Events, UserTimeline(ctx, iamClient, eventUsername)...)
+		profile.Events = append(profile.Events, CredentialReportTimeline(ctx, iamClient, eventUsername)...)
+	}
+
+	// With an event data store, one Lake query covers every region, goes back further than event history
+	// and matches the exact ARN, so other sessions of a role with the same session name are left out.
+	// Event history is still used if the query fails
+	// i.e. aws cloudtrail start-query --query-statement <sql>
+	if CloudTrailLakeStore != "" {
+		events, err := QueryLakeEvents(ctx, cloudtrail.NewFromConfig(sdkConfig), CloudTrailLakeStore, LakeActivityFilter{PrincipalArn: callerArn, Days: LakeDays, Limit: TIMELINE_LAKE_EVENTS})
+		if err == nil {
+			for _, event := range events {
+				description := fmt.Sprintf("%v %v", event.EventSource, event.EventName)
+				if len(event.Resources) > 0 {
+					description += " on " + strings.Join(event.Resources, ", ")
+				}
+				if event.AccessKeyId != "" {
+					description += " with " + event.AccessKeyId
+				}
+				profile.Events = append(profile.Events, TimelineEvent{Time: event.Time, Source: "cloudtrail-lake", Description: description, Region: event.Region})
+			}
+			eventUsername = ""
+		}
+	}
+
+	// Event history is kept per region for 90 days and only covers management events
+	// i.e. aws cloudtrail lookup-events --lookup-attributes AttributeKey=Username,AttributeValue=<username>
+	if eventUsername != "" {
+		ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+			events, err := LookupUserEvents(ctx, cloudtrail.NewFromConfig(regionalConfig), eventUsername)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				profile.Events = append(profile.Events, TimelineEvent{
+					Time:        aws.ToTime(event.EventTime),
+					Source:      "cloudtrail",
+					Description: CloudTrailEventDescription(event),
+					Region:      regionalConfig.Region,
+				})
+			}
+			return nil
+		})
+	}
+
+	sort.SliceStable(profile.Events, func(i, j int) bool {
+		return profile.Events[i].Time.Before(profile.Events[j].Time)
+	})
+	for _, event := range profile.Events {
+		if event.Region != "" {
+			fmt.Printf("\t%v  %v (%v, %v)\n", event.Time.UTC().Format(time.RFC3339), event.Description, event.Source, event.Region)
+		} else {
+			fmt.Printf("\t%v  %v (%v)\n", event.Time.UTC().Format(time.RFC3339), event.Description, event.Source)
+		}
+	}
+	if len(profile.Events) == 0 {
+		fmt.Println("\tNo activity recorded for this principal")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("activity-profile", "", profile)
+
+	return nil
+}
+
+func UserTimeline(ctx context.Context, iamClient *iam.Client, username string) []TimelineEvent {
+	var events []TimelineEvent
+
+	// i.e. aws iam get-user --user-name <username>
+	user, err := iamClient.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(username)})
+	if err != nil {
+		fmt.Printf("Couldn't get details for %v. Here's why: %v\n", username, err)
+	} else {
+		events = append(events, TimelineEvent{Time: aws.ToTime(user.User.CreateDate), Source: "iam", Description: "User created"})
+		if user.User.PasswordLastUsed != nil {
+			events = append(events, TimelineEvent{Time: *user.User.PasswordLastUsed, Source: "iam", Description: "Last console sign-in"})
+		}
+	}
+
+	// i.e. aws iam list-access-keys --user-name <username>
+	accessKeys, err := ListAccessKeys(ctx, iamClient, username)
+	if err != nil {
+		return events
+	}
+	for _, key := range accessKeys {
+		events = append(events, TimelineEvent{Time: aws.ToTime(key.CreateDate), Source: "iam", Description: fmt.Sprintf("Access key %v created", aws.ToString(key.AccessKeyId))})
+		// i.e. aws iam get-access-key-last-used --access-key-id <access-key-id>
+		if lastUsed := GetAccessKeyLastUsed(ctx, iamClient, *key.AccessKeyId); lastUsed != nil {
+			events = append(events, TimelineEvent{
+				Time:        *lastUsed.LastUsedDate,
+				Source:      "iam",
+				Description: fmt.Sprintf("Access key %v last used with %v", aws.ToString(key.AccessKeyId), aws.ToString(lastUsed.ServiceName)),
+				Region:      aws.ToString(lastUsed.Region),
+			})
+		}
+	}
+
+	return events
+}
+
+func RoleTimeline(ctx context.Context, iamClient *iam.Client, roleName string) []TimelineEvent {
+	// i.e. aws iam get-role --role-name <role-name>
+	role, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		fmt.Printf("Couldn't get the role %v. Here's why: %v\n", roleName, err)
+		return nil
+	}
+	events := []TimelineEvent{{Time: aws.ToTime(role.Role.CreateDate), Source: "iam", Description: "Role created"}}
+	// IAM keeps when any session of the role last made a call, not just this one
+	if role.Role.RoleLastUsed != nil && role.Role.RoleLastUsed.LastUsedDate != nil {
+		events = append(events, TimelineEvent{
+			Time:        *role.Role.RoleLastUsed.LastUsedDate,
+			Source:      "iam",
+			Description: "Role last used",
+			Region:      aws.ToString(role.Role.RoleLastUsed.Region),
+		})
+	}
+
+	return events
+}
+
+func CredentialReportTimeline(ctx context.Context, iamClient *iam.Client, username string) []TimelineEvent {
+	// i.e. aws iam generate-credential-report, aws iam get-credential-report
+	report, err := CachedCredentialReport(ctx, iamClient)
+	if err != nil {
+		return nil
+	}
+	var row map[string]string
+	for _, reportRow := range report {
+		if reportRow["user"] == username {
+			row = reportRow
+			break
+		}
+	}
+	if row == nil {
+		return nil
+	}
+
+	// Columns hold a timestamp, or N/A, no_information or not_supported when there isn't one
+	var events []TimelineEvent
+	addEvent := func(column string, description string, regionColumn string) {
+		when, err := time.Parse(time.RFC3339, row[column])
+		if err != nil {
+			return
+		}
+		region := row[regionColumn]
+		if region == "N/A" {
+			region = ""
+		}
+		events = append(events, TimelineEvent{Time: when, Source: "credential-report", Description: description, Region: region})
+	}
+	addEvent("password_last_changed", "Password changed", "")
+	for _, key := range []string{"1", "2"} {
+		addEvent("cert_"+key+"_last_rotated", fmt.Sprintf("Signing certificate %v uploaded", key), "")
+	}
+	// For IAM users the rest comes from get-user and the access key APIs, which name the keys,
+	// but for the root user the report is the only source
+	if username == "<root_account>" {
+		addEvent("user_creation_time", "Account created", "")
+		addEvent("password_last_used", "Last console sign-in", "")
+		for _, key := range []string{"1", "2"} {
+			addEvent("access_key_"+key+"_last_rotated", fmt.Sprintf("Access key %v created", key), "")
+			addEvent("access_key_"+key+"_last_used_date", fmt.Sprintf("Access key %v last used with %v", key, row["access_key_"+key+"_last_used_service"]), "access_key_"+key+"_last_used_region")
+		}
+	}
+
+	return events
+}
+
+func CachedCredentialReport(ctx context.Context, iamClient *iam.Client) ([]map[string]string, error) {
+	return Cached("credential-report", func() ([]map[string]string, error) {
+		return GetCredentialReport(ctx, iamClient)
+	})
+}
+
+func GetCredentialReport(ctx context.Context, iamClient *iam.Client) ([]map[string]string, error) {
+	// A report is reused for four hours, generating one when it's stale takes a few seconds
+	for {
+		generated, err := iamClient.GenerateCredentialReport(ctx, &iam.GenerateCredentialReportInput{})
+		if err != nil {
+			fmt.Printf("Couldn't generate the credential report. Here's why: %v\n", err)
+			return nil, err
+		}
+		if generated.State == iamtypes.ReportStateTypeComplete {
+			break
+		}
+		select {
+		case <-time.After(CREDENTIAL_REPORT_POLL_INTERVAL):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	report, err := iamClient.GetCredentialReport(ctx, &iam.GetCredentialReportInput{})
+	if err != nil {
+		fmt.Printf("Couldn't get the credential report. Here's why: %v\n", err)
+		return nil, err
+	}
+	records, err := csv.NewReader(bytes.NewReader(report.Content)).ReadAll()
+	if err != nil {
+		fmt.Printf("Couldn't parse the credential report. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	// One row per user, keyed by the header, i.e. user, arn, password_last_used
+	var rows []map[string]string
+	for _, record := range records[min(1, len(records)):] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func LookupUserEvents(ctx context.Context, cloudtrailClient *cloudtrail.Client, username string) ([]cloudtrailtypes.Event, error) {
+	// Most recent first, stopping once there are enough
+	var events []cloudtrailtypes.Event
+	paginator := cloudtrail.NewLookupEventsPaginator(cloudtrailClient, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []cloudtrailtypes.LookupAttribute{{
+			AttributeKey:   cloudtrailtypes.LookupAttributeKeyUsername,
+			AttributeValue: aws.String(username),
+		}},
+		MaxResults: aws.Int32(TIMELINE_EVENTS_PER_REGION),
+	})
+	for paginator.HasMorePages() && len(events) < TIMELINE_EVENTS_PER_REGION {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't look up the CloudTrail events for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		events = append(events, page.Events...)
+	}
+
+	return events[:min(len(events), TIMELINE_EVENTS_PER_REGION)], nil
+}
+
+func CloudTrailEventDescription(event cloudtrailtypes.Event) string {
+	// i.e. s3.amazonaws.com CreateBucket on my-bucket with AKIA...
+	description := fmt.Sprintf("%v %v", aws.ToString(event.EventSource), aws.ToString(event.EventName))
+	var resources []string
+	for _, resource := range event.Resources {
+		resources = append(resources, aws.ToString(resource.ResourceName))
+	}
+	if len(resources) > 0 {
+		description += " on " + strings.Join(resources, ", ")
+	}
+	if event.AccessKeyId != nil {
+		description += " with " + *event.AccessKeyId
+	}
+
+	return description
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTLP/HTTP collector to send traces to, i.e. http://localhost:4318, set with --otlp-endpoint.
+// Tracing is off without it, and the spans below cost next to nothing
+var OTLPEndpoint = ""
+
+// Resolves through the global provider, so spans started before tracing is set up still go to it
+var Tracer = otel.Tracer("github.com/imflikk/aws-enumerator")
+
+func StartTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	// The rest of the exporter's settings, i.e. headers, come from the standard OTEL_EXPORTER_OTLP_* variables
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		fmt.Printf("Couldn't set up the trace exporter for %v. Here's why: %v\n", endpoint, err)
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("aws-enumerator"),
+			semconv.ServiceVersion(Version),
+		)),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func AddTracingMiddleware(sdkConfig *aws.Config) {
+	// One span per API operation, retries included, at the very start of the stack
+	sdkConfig.APIOptions = append(sdkConfig.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			service := awsmiddleware.GetServiceID(ctx)
+			operation := awsmiddleware.GetOperationName(ctx)
+			ctx, span := StartSpan(ctx, service+"."+operation,
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCService(service),
+				semconv.RPCMethod(operation),
+				attribute.String("aws.region", awsmiddleware.GetRegion(ctx)),
+			)
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if requestId, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+				span.SetAttributes(attribute.String("aws.request_id", requestId))
+			}
+			EndSpan(span, err)
+			return out, metadata, err
+		}), middleware.Before)
+	})
+}
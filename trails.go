@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type TrailResult struct {
+	Name string `json:"name"`
+	Arn  string `json:"arn"`
+	// The account and region the trail was created in, which is where it can be stopped or changed
+	HomeAccount         string `json:"homeAccount"`
+	HomeRegion          string `json:"homeRegion"`
+	IsOrganizationTrail bool   `json:"isOrganizationTrail"`
+	IsMultiRegion       bool   `json:"isMultiRegion"`
+	IsLogging           bool   `json:"isLogging"`
+	Bucket              string `json:"bucket,omitempty"`
+	BucketPrefix        string `json:"bucketPrefix,omitempty"`
+	// False when the bucket isn't one of this account's, i.e. it's in a central logging account
+	BucketInAccount bool   `json:"bucketInAccount"`
+	LogGroupArn     string `json:"logGroupArn,omitempty"`
+	KmsKeyId        string `json:"kmsKeyId,omitempty"`
+	// Other accounts the trail is managed from or delivers to
+	RemoteAccounts []string `json:"remoteAccounts"`
+}
+
+// Whether this account's logs are held somewhere it doesn't control
+type CentralizedLoggingResult struct {
+	Centralized    bool     `json:"centralized"`
+	RemoteAccounts []string `json:"remoteAccounts"`
+	RemoteBuckets  []string `json:"remoteBuckets"`
+}
+
+func RunTrailsModule(ctx context.Context, sdkConfig aws.Config) error {
+	// i.e. aws sts get-caller-identity
+	callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+	if err != nil {
+		return err
+	}
+	account := aws.ToString(callerIdentity.Account)
+
+	// Multi-region and organization trails show up in every region they record, so they're
+	// gathered first and each is reported once
+	// i.e. aws cloudtrail describe-trails --include-shadow-trails
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting the trails recording this account and where their logs go...")
+	fmt.Println(MAJOR_SEPARATOR)
+	var trails []cloudtrailtypes.Trail
+	seen := map[string]bool{}
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		regionTrails, err := ListTrails(ctx, cloudtrail.NewFromConfig(regionalConfig))
+		if err != nil {
+			return err
+		}
+		for _, trail := range regionTrails {
+			if !seen[aws.ToString(trail.TrailARN)] {
+				seen[aws.ToString(trail.TrailARN)] = true
+				trails = append(trails, trail)
+			}
+		}
+		return nil
+	})
+
+	summary := CentralizedLoggingResult{}
+	s3Client := s3.NewFromConfig(sdkConfig)
+	for _, trail := range trails {
+		result := TrailResult{
+			Name:                aws.ToString(trail.Name),
+			Arn:                 aws.ToString(trail.TrailARN),
+			HomeRegion:          aws.ToString(trail.HomeRegion),
+			IsOrganizationTrail: aws.ToBool(trail.IsOrganizationTrail),
+			IsMultiRegion:       aws.ToBool(trail.IsMultiRegionTrail),
+			Bucket:              aws.ToString(trail.S3BucketName),
+			BucketPrefix:        aws.ToString(trail.S3KeyPrefix),
+			LogGroupArn:         aws.ToString(trail.CloudWatchLogsLogGroupArn),
+			KmsKeyId:            aws.ToString(trail.KmsKeyId),
+		}
+		if parsedArn, err := arn.Parse(result.Arn); err == nil {
+			result.HomeAccount = parsedArn.AccountID
+		}
+
+		// i.e. aws cloudtrail get-trail-status --name <trail-arn>
+		homeConfig := sdkConfig.Copy()
+		homeConfig.Region = result.HomeRegion
+		status, err := cloudtrail.NewFromConfig(homeConfig).GetTrailStatus(ctx, &cloudtrail.GetTrailStatusInput{Name: trail.TrailARN})
+		if err != nil {
+			fmt.Printf("Couldn't get the status of %v. Here's why: %v\n", result.Name, err)
+		} else {
+			result.IsLogging = aws.ToBool(status.IsLogging)
+		}
+
+		// The bucket ARN has no account in it, so look for it among this account's own buckets
+		// i.e. aws s3api list-buckets --prefix <bucket>
+		var bucketErr error
+		if result.Bucket != "" {
+			result.BucketInAccount, bucketErr = BucketInAccount(ctx, s3Client, result.Bucket)
+			if bucketErr == nil && !result.BucketInAccount && !slices.Contains(summary.RemoteBuckets, result.Bucket) {
+				summary.RemoteBuckets = append(summary.RemoteBuckets, result.Bucket)
+			}
+		}
+		if result.HomeAccount != "" && result.HomeAccount != account {
+			result.RemoteAccounts = append(result.RemoteAccounts, result.HomeAccount)
+		}
+		if parsedArn, err := arn.Parse(result.LogGroupArn); err == nil && parsedArn.AccountID != account && !slices.Contains(result.RemoteAccounts, parsedArn.AccountID) {
+			result.RemoteAccounts = append(result.RemoteAccounts, parsedArn.AccountID)
+		}
+		for _, remoteAccount := range result.RemoteAccounts {
+			if !slices.Contains(summary.RemoteAccounts, remoteAccount) {
+				summary.RemoteAccounts = append(summary.RemoteAccounts, remoteAccount)
+			}
+		}
+
+		fmt.Printf("\tTrail: %v\n", result.Name)
+		fmt.Printf("\tARN: %v\n", result.Arn)
+		fmt.Printf("\tHome region: %v\n", result.HomeRegion)
+		fmt.Printf("\tMulti-region: %v\n", result.IsMultiRegion)
+		fmt.Printf("\tOrganization trail: %v\n", result.IsOrganizationTrail)
+		fmt.Printf("\tLogging: %v\n", result.IsLogging)
+		if result.Bucket != "" {
+			fmt.Printf("\tDelivered to: s3://%v/%v\n", result.Bucket, result.BucketPrefix)
+		}
+		if result.LogGroupArn != "" {
+			fmt.Printf("\tCloudWatch Logs: %v\n", result.LogGroupArn)
+		}
+		if result.KmsKeyId != "" {
+			fmt.Printf("\tEncrypted with: %v\n", result.KmsKeyId)
+		}
+		// An organization trail is created in the management account or a delegated administrator,
+		// and member accounts can see it but not stop, change or delete it
+		if result.HomeAccount != "" && result.HomeAccount != account {
+			fmt.Printf("\t[-] Managed from account %v, it can't be stopped or changed from here\n", result.HomeAccount)
+		}
+		if result.Bucket != "" && !result.BucketInAccount && bucketErr == nil {
+			fmt.Println("\t[-] Delivered to a bucket outside this account, likely a central logging account")
+		}
+		fmt.Println(MINOR_SEPARATOR)
+		Emit("trail", result.HomeRegion, result)
+	}
+	if len(trails) == 0 {
+		fmt.Println("\tNo trails record the selected regions")
+		fmt.Println(MINOR_SEPARATOR)
+	}
+
+	// Deleting or reading this account's logs, or hiding from them, then means getting into those accounts too
+	summary.Centralized = len(summary.RemoteAccounts) > 0 || len(summary.RemoteBuckets) > 0
+	if summary.Centralized {
+		fmt.Println("\t[-] Logs are held outside this account, so exfiltration and tampering analysis must include:")
+		for _, remoteAccount := range summary.RemoteAccounts {
+			fmt.Printf("\t\tAccount %v\n", remoteAccount)
+		}
+		for _, bucket := range summary.RemoteBuckets {
+			fmt.Printf("\t\tBucket %v\n", bucket)
+		}
+	} else if len(trails) > 0 {
+		fmt.Println("\tEvery trail is managed from and delivered to this account")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("centralized-logging", "", summary)
+
+	return nil
+}
+
+func ListTrails(ctx context.Context, cloudtrailClient *cloudtrail.Client) ([]cloudtrailtypes.Trail, error) {
+	// Every trail that records this region, not just the ones created in it
+	output, err := cloudtrailClient.DescribeTrails(ctx, &cloudtrail.DescribeTrailsInput{IncludeShadowTrails: aws.Bool(true)})
+	if err != nil {
+		fmt.Printf("Couldn't list the trails. Here's why: %v\n", err)
+		return nil, err
+	}
+
+	return output.TrailList, nil
+}
+
+func BucketInAccount(ctx context.Context, s3Client *s3.Client, bucket string) (bool, error) {
+	// Listing buckets only returns the caller's own, so an exact match means it's in this account
+	output, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{Prefix: aws.String(bucket)})
+	if err != nil {
+		fmt.Printf("Couldn't check whether %v is in this account. Here's why: %v\n", bucket, err)
+		return false, err
+	}
+	for _, owned := range output.Buckets {
+		if aws.ToString(owned.Name) == bucket {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	taggingtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+// Tag keys every resource is expected to carry, set with --required-tags and matched case-insensitively
+var RequiredTags = []string{"owner", "cost-center"}
+
+// A resource missing one or more of the required tags
+type UntaggedResult struct {
+	Service  string `json:"service"`
+	Region   string `json:"region,omitempty"`
+	Resource string `json:"resource"`
+	// Which of --required-tags it doesn't have
+	Missing []string `json:"missing"`
+	// Untagged when it has no tags at all
+	Untagged bool `json:"untagged"`
+}
+
+// How many resources of one service in one region were checked and how many fell short
+type UntaggedSummaryResult struct {
+	Service  string `json:"service"`
+	Region   string `json:"region,omitempty"`
+	Checked  int    `json:"checked"`
+	Missing  int    `json:"missing"`
+	Untagged int    `json:"untagged"`
+}
+
+func RunUntaggedModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+	groups := map[string]*UntaggedSummaryResult{}
+	missing := map[string][]UntaggedResult{}
+	check := func(service string, region string, resource string, tags map[string]string) {
+		key := service + " " + region
+		if _, ok := groups[key]; !ok {
+			groups[key] = &UntaggedSummaryResult{Service: service, Region: region}
+		}
+		groups[key].Checked++
+		if result := CheckRequiredTags(service, region, resource, tags); result != nil {
+			groups[key].Missing++
+			if result.Untagged {
+				groups[key].Untagged++
+			}
+			missing[key] = append(missing[key], *result)
+		}
+	}
+
+	// list-users and list-roles leave the tags out, so they're listed for each principal.
+	// Roles under the AWS-reserved paths belong to AWS and can't be tagged by the account
+	// i.e. aws iam list-user-tags --user-name <username>, aws iam list-role-tags --role-name <role-name>
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Checking resources for the required tags %v...\n", strings.Join(RequiredTags, ", "))
+	fmt.Println(MAJOR_SEPARATOR)
+	if users, err := CachedUsers(ctx, iamClient); err == nil {
+		userTags := make([]map[string]string, len(users))
+		ForEachConcurrently(ctx, len(users), func(ctx context.Context, i int) {
+			userTags[i], _ = ListUserTags(ctx, iamClient, aws.ToString(users[i].UserName))
+		}, func(i int) {
+			// Left nil when the tags couldn't be listed, which says nothing about whether there are any
+			if userTags[i] != nil {
+				check("iam", "", aws.ToString(users[i].Arn), userTags[i])
+			}
+		})
+	}
+	if roles, err := CachedRoles(ctx, iamClient); err == nil {
+		roles = slices.DeleteFunc(slices.Clone(roles), func(role iamtypes.Role) bool {
+			return strings.HasPrefix(aws.ToString(role.Path), "/aws-service-role/") || strings.HasPrefix(aws.ToString(role.Path), "/aws-reserved/")
+		})
+		roleTags := make([]map[string]string, len(roles))
+		ForEachConcurrently(ctx, len(roles), func(ctx context.Context, i int) {
+			roleTags[i], _ = ListRoleTags(ctx, iamClient, aws.ToString(roles[i].RoleName))
+		}, func(i int) {
+			if roleTags[i] != nil {
+				check("iam", "", aws.ToString(roles[i].Arn), roleTags[i])
+			}
+		})
+	}
+
+	// Instances are listed whether or not they're tagged, everything else comes from the tagging API,
+	// which only knows about resources that have been tagged at some point
+	// i.e. aws ec2 describe-instances, aws resourcegroupstaggingapi get-resources
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err == nil {
+			for _, instance := range instances {
+				check("ec2", regionalConfig.Region, aws.ToString(instance.InstanceId), EC2Tags(instance.Tags))
+			}
+		}
+		resources, err := ListTaggedResources(ctx, resourcegroupstaggingapi.NewFromConfig(regionalConfig))
+		if err != nil {
+			return nil
+		}
+		for _, resource := range resources {
+			parsedArn, err := arn.Parse(aws.ToString(resource.ResourceARN))
+			if err != nil || parsedArn.Service == "ec2" && strings.HasPrefix(parsedArn.Resource, "instance/") {
+				continue
+			}
+			tags := map[string]string{}
+			for _, tag := range resource.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			check(parsedArn.Service, regionalConfig.Region, parsedArn.String(), tags)
+		}
+
+		return nil
+	})
+
+	var keys []string
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		PrintUntaggedSummary(*groups[key], missing[key])
+	}
+	if len(keys) == 0 {
+		fmt.Println("\tNo resources found")
+	}
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func CheckRequiredTags(service string, region string, resource string, tags map[string]string) *UntaggedResult {
+	// Tag keys are case-sensitive in AWS, but Owner and owner are the same tag to whoever reads them
+	present := map[string]bool{}
+	for key, value := range tags {
+		if value != "" {
+			present[strings.ToLower(key)] = true
+		}
+	}
+	result := &UntaggedResult{Service: service, Region: region, Resource: resource, Untagged: len(tags) == 0}
+	for _, required := range RequiredTags {
+		if !present[strings.ToLower(required)] {
+			result.Missing = append(result.Missing, required)
+		}
+	}
+	if len(result.Missing) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+func PrintUntaggedSummary(summary UntaggedSummaryResult, missing []UntaggedResult) {
+	location := summary.Region
+	if location == "" {
+		location = "global"
+	}
+	fmt.Printf("\tService: %v (%v), %v of %v resources missing required tags, %v with no tags at all\n", summary.Service, location, summary.Missing, summary.Checked, summary.Untagged)
+	for _, result := range missing {
+		fmt.Printf("\t\t%v: missing %v\n", result.Resource, strings.Join(result.Missing, ", "))
+		Emit("untagged-resource", result.Region, result)
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("untagged-summary", summary.Region, summary)
+}
+
+func ListUserTags(ctx context.Context, iamClient *iam.Client, username string) (map[string]string, error) {
+	var tags []iamtypes.Tag
+	paginator := iam.NewListUserTagsPaginator(iamClient, &iam.ListUserTagsInput{UserName: aws.String(username)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the tags for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+	}
+
+	return IAMTags(tags), nil
+}
+
+func ListRoleTags(ctx context.Context, iamClient *iam.Client, roleName string) (map[string]string, error) {
+	var tags []iamtypes.Tag
+	paginator := iam.NewListRoleTagsPaginator(iamClient, &iam.ListRoleTagsInput{RoleName: aws.String(roleName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the tags for %v. Here's why: %v\n", roleName, err)
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+	}
+
+	return IAMTags(tags), nil
+}
+
+func ListTaggedResources(ctx context.Context, taggingClient *resourcegroupstaggingapi.Client) ([]taggingtypes.ResourceTagMapping, error) {
+	var resources []taggingtypes.ResourceTagMapping
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(taggingClient, &resourcegroupstaggingapi.GetResourcesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(resources)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the tagged resources. Here's why: %v\n", err)
+			return nil, err
+		}
+		resources = append(resources, page.ResourceTagMappingList...)
+	}
+
+	return LimitItems(resources), nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// The user data of an instance or of one launch template version
+type UserDataResult struct {
+	// instance or launch-template
+	Kind string `json:"kind"`
+	Id   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Launch template version, 0 for instances
+	Version      int64           `json:"version,omitempty"`
+	Size         int             `json:"size"`
+	SavedTo      string          `json:"savedTo,omitempty"`
+	SecretsFound []SecretFinding `json:"secretsFound"`
+}
+
+func RunUserDataModule(ctx context.Context, sdkConfig aws.Config) error {
+	ForEachRegion(ctx, sdkConfig, func(ctx context.Context, regionalConfig aws.Config) error {
+		ec2Client := ec2.NewFromConfig(regionalConfig)
+
+		// Bootstrap scripts are passed in user data, so passwords, tokens and keys for whatever the
+		// instance pulls down at boot tend to be written into them
+		// i.e. aws ec2 describe-instance-attribute --instance-id <instance-id> --attribute userData
+		fmt.Println(MAJOR_SEPARATOR)
+		fmt.Printf("Getting user data for instances and launch templates in %v...\n", regionalConfig.Region)
+		fmt.Println(MAJOR_SEPARATOR)
+		instances, err := CachedInstances(ctx, regionalConfig)
+		if err != nil {
+			return err
+		}
+		results := make([]*UserDataResult, len(instances))
+		ForEachConcurrently(ctx, len(instances), func(ctx context.Context, i int) {
+			results[i] = GetInstanceUserData(ctx, ec2Client, instances[i])
+		}, func(i int) {
+			if results[i] != nil {
+				PrintUserDataResult(regionalConfig.Region, *results[i])
+			}
+		})
+
+		// Every version is scanned, an old one can still hold a secret that was never rotated
+		// i.e. aws ec2 describe-launch-templates, aws ec2 describe-launch-template-versions --launch-template-id <id>
+		launchTemplates, err := ListLaunchTemplates(ctx, ec2Client)
+		if err != nil {
+			fmt.Println(MAJOR_SEPARATOR)
+			return nil
+		}
+		for _, launchTemplate := range launchTemplates {
+			versions, err := ListLaunchTemplateVersions(ctx, ec2Client, aws.ToString(launchTemplate.LaunchTemplateId))
+			if err != nil {
+				continue
+			}
+			for _, version := range versions {
+				if version.LaunchTemplateData == nil || aws.ToString(version.LaunchTemplateData.UserData) == "" {
+					continue
+				}
+				result := UserDataResult{
+					Kind:    "launch-template",
+					Id:      aws.ToString(launchTemplate.LaunchTemplateId),
+					Name:    aws.ToString(launchTemplate.LaunchTemplateName),
+					Version: aws.ToInt64(version.VersionNumber),
+				}
+				ScanUserData(&result, aws.ToString(version.LaunchTemplateData.UserData), fmt.Sprintf("%v-v%v", result.Id, result.Version))
+				PrintUserDataResult(regionalConfig.Region, result)
+			}
+		}
+		fmt.Println(MAJOR_SEPARATOR)
+
+		return nil
+	})
+
+	return nil
+}
+
+func GetInstanceUserData(ctx context.Context, ec2Client *ec2.Client, instance ec2types.Instance) *UserDataResult {
+	instanceId := aws.ToString(instance.InstanceId)
+	attribute, err := ec2Client.DescribeInstanceAttribute(ctx, &ec2.DescribeInstanceAttributeInput{
+		InstanceId: instance.InstanceId,
+		Attribute:  ec2types.InstanceAttributeNameUserData,
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get the user data for %v. Here's why: %v\n", instanceId, err)
+		return nil
+	}
+	if attribute.UserData == nil || aws.ToString(attribute.UserData.Value) == "" {
+		return nil
+	}
+
+	result := &UserDataResult{Kind: "instance", Id: instanceId, Name: InstanceName(instance.Tags)}
+	ScanUserData(result, aws.ToString(attribute.UserData.Value), instanceId)
+
+	return result
+}
+
+func ScanUserData(result *UserDataResult, encoded string, lootName string) {
+	// User data comes back base64-encoded, and cloud-init also accepts it gzipped
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		fmt.Printf("Couldn't decode the user data for %v. Here's why: %v\n", result.Id, err)
+		return
+	}
+	if bytes.HasPrefix(decoded, []byte{0x1f, 0x8b}) {
+		if reader, err := gzip.NewReader(bytes.NewReader(decoded)); err == nil {
+			if unzipped, err := io.ReadAll(io.LimitReader(reader, MAX_SCAN_FILE_SIZE)); err == nil {
+				decoded = unzipped
+			}
+		}
+	}
+	result.Size = len(decoded)
+
+	// The user data is in the response, so it's always scanned and only saved with --download-code
+	source := result.Id
+	if DownloadCode {
+		lootPath, err := SaveLoot(filepath.Join("ec2", "user-data", lootName+".txt"), decoded)
+		if err == nil {
+			result.SavedTo = lootPath
+			source = lootPath
+		}
+	}
+	result.SecretsFound = ScanForSecrets(source, decoded)
+}
+
+func PrintUserDataResult(region string, result UserDataResult) {
+	if result.Kind == "instance" {
+		fmt.Printf("\tInstance ID: %v\n", result.Id)
+		if result.Name != "" {
+			fmt.Printf("\tName: %v\n", result.Name)
+		}
+	} else {
+		fmt.Printf("\tLaunch template: %v (%v) version %v\n", result.Name, result.Id, result.Version)
+	}
+	fmt.Printf("\tUser data: %v bytes\n", result.Size)
+	if result.SavedTo != "" {
+		fmt.Printf("\tSaved to: %v\n", result.SavedTo)
+	}
+	PrintSecretFindings(result.SecretsFound)
+	if len(result.SecretsFound) > 0 && result.Kind == "instance" {
+		EmitFinding(region, result.Id, fmt.Sprintf("User data holds %v possible secrets", len(result.SecretsFound)))
+	} else if len(result.SecretsFound) > 0 {
+		EmitFinding(region, result.Id, fmt.Sprintf("User data of version %v holds %v possible secrets", result.Version, len(result.SecretsFound)))
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("user-data", region, result)
+}
+
+func ListLaunchTemplates(ctx context.Context, ec2Client *ec2.Client) ([]ec2types.LaunchTemplate, error) {
+	var launchTemplates []ec2types.LaunchTemplate
+	paginator := ec2.NewDescribeLaunchTemplatesPaginator(ec2Client, &ec2.DescribeLaunchTemplatesInput{})
+	for paginator.HasMorePages() && !ReachedMaxItems(len(launchTemplates)) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the launch templates. Here's why: %v\n", err)
+			return nil, err
+		}
+		launchTemplates = append(launchTemplates, page.LaunchTemplates...)
+	}
+
+	return LimitItems(launchTemplates), nil
+}
+
+func ListLaunchTemplateVersions(ctx context.Context, ec2Client *ec2.Client, launchTemplateId string) ([]ec2types.LaunchTemplateVersion, error) {
+	// Every version of the template, not just the default one
+	var versions []ec2types.LaunchTemplateVersion
+	paginator := ec2.NewDescribeLaunchTemplateVersionsPaginator(ec2Client, &ec2.DescribeLaunchTemplateVersionsInput{LaunchTemplateId: aws.String(launchTemplateId)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the versions of %v. Here's why: %v\n", launchTemplateId, err)
+			return nil, err
+		}
+		versions = append(versions, page.LaunchTemplateVersions...)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Active access keys unused for this long are flagged as stale
+const STALE_ACCESS_KEY_DAYS = 90
+
+// Everything the users module collects about one user
+type UserResult struct {
+	User             iamtypes.User             `json:"user"`
+	Groups           []string                  `json:"groups"`
+	AttachedPolicies []iamtypes.AttachedPolicy `json:"attachedPolicies"`
+	InlinePolicies   []string                  `json:"inlinePolicies"`
+	AccessKeys       []AccessKeyResult         `json:"accessKeys"`
+	// Keys uploaded for CodeCommit over SSH
+	SSHPublicKeys []iamtypes.SSHPublicKeyMetadata `json:"sshPublicKeys"`
+	MFADevices    []iamtypes.MFADevice            `json:"mfaDevices"`
+	// CodeCommit Git and Keyspaces credentials, which work without the access keys
+	ServiceCredentials []iamtypes.ServiceSpecificCredentialMetadata `json:"serviceCredentials"`
+	// Default version documents of the attached policies keyed by ARN, only fetched with --resolve-documents
+	PolicyDocuments map[string]*PolicyDocument `json:"policyDocuments,omitempty"`
+	// list-users leaves the permission boundary out, so it's looked up with get-user
+	Boundary         string          `json:"boundary,omitempty"`
+	BoundaryDocument *PolicyDocument `json:"boundaryDocument,omitempty"`
+}
+
+type AccessKeyResult struct {
+	iamtypes.AccessKeyMetadata
+	AgeDays int `json:"ageDays"`
+	// Empty when the key has never been used
+	LastUsed *iamtypes.AccessKeyLastUsed `json:"lastUsed,omitempty"`
+}
+
+func RunUsersModule(ctx context.Context, sdkConfig aws.Config) error {
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	// Call the list-users API to get every user in the account, not just the current one
+	// i.e. aws iam list-users
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Getting details for every user in the account...")
+	fmt.Println(MAJOR_SEPARATOR)
+	users, err := CachedUsers(ctx, iamClient)
+	if err != nil {
+		fmt.Println("Couldn't list users. Exiting...")
+		return err
+	}
+
+	// The details for several users are fetched at once, then printed in order
+	results := make([]UserResult, len(users))
+	mfaErrors := make([]error, len(users))
+	ForEachConcurrently(ctx, len(users), func(ctx context.Context, i int) {
+		results[i], mfaErrors[i] = GetUserResult(ctx, iamClient, users[i])
+	}, func(i int) {
+		PrintUserResult(results[i], mfaErrors[i])
+	})
+
+	return nil
+}
+
+// Whether the user has no MFA is only known when mfaErr is nil
+func PrintUserResult(result UserResult, mfaErr error) {
+	user := result.User
+	fmt.Printf("\tUsername: %v\n", *user.UserName)
+	fmt.Printf("\tUser ARN: %v\n", *user.Arn)
+	fmt.Printf("\tCreated on: %v\n", *user.CreateDate)
+	for _, group := range result.Groups {
+		fmt.Printf("\tGroup: %v\n", group)
+	}
+	for _, policy := range result.AttachedPolicies {
+		fmt.Printf("\tAttached policy: %v\n", *policy.PolicyArn)
+		if document := result.PolicyDocuments[*policy.PolicyArn]; document != nil {
+			ShowPolicyDocument(*policy.PolicyArn, document)
+		}
+	}
+	for _, policy := range result.InlinePolicies {
+		fmt.Printf("\tInline policy: %v\n", policy)
+	}
+	PrintPermissionsBoundary(result.Boundary, result.BoundaryDocument)
+	for _, key := range result.AccessKeys {
+		fmt.Printf("\tAccess key: %v (%v, created %v, %v days old)\n", *key.AccessKeyId, key.Status, *key.CreateDate, key.AgeDays)
+		if key.LastUsed != nil {
+			fmt.Printf("\t\tLast used: %v in %v (%v)\n", *key.LastUsed.LastUsedDate, aws.ToString(key.LastUsed.ServiceName), aws.ToString(key.LastUsed.Region))
+		} else {
+			fmt.Println("\t\tLast used: never")
+		}
+		if stale := StaleAccessKey(key); stale != "" {
+			fmt.Printf("\t[!] Access key %v is active but %v\n", *key.AccessKeyId, stale)
+			EmitFinding("", *user.Arn, fmt.Sprintf("Access key %v is active but %v", *key.AccessKeyId, stale))
+		}
+	}
+	for _, key := range result.SSHPublicKeys {
+		fmt.Printf("\tSSH public key: %v (%v, uploaded %v)\n", *key.SSHPublicKeyId, key.Status, *key.UploadDate)
+	}
+	for _, credential := range result.ServiceCredentials {
+		fmt.Printf("\tService credential: %v for %v as %v (%v, created %v)\n", *credential.ServiceSpecificCredentialId, *credential.ServiceName, *credential.ServiceUserName, credential.Status, *credential.CreateDate)
+	}
+	for _, device := range result.MFADevices {
+		fmt.Printf("\tMFA device: %v (enabled %v)\n", *device.SerialNumber, *device.EnableDate)
+	}
+	if mfaErr == nil && len(result.MFADevices) == 0 && user.PasswordLastUsed != nil {
+		fmt.Println("\t[!] Signs in to the console without MFA")
+		EmitFinding("", *user.Arn, "Signs in to the console without MFA")
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("user", "", result)
+}
+
+func GetUserResult(ctx context.Context, iamClient *iam.Client, user iamtypes.User) (UserResult, error) {
+	// Anything that can't be listed is left empty, the error from listing the MFA devices is
+	// returned since a user with no MFA is only a finding if that's known for sure
+	username := *user.UserName
+	result := UserResult{User: user}
+
+	// i.e. aws iam list-groups-for-user --user-name <username>
+	userGroups, err := ListUserGroups(ctx, iamClient, username)
+	if err == nil {
+		for _, group := range userGroups {
+			result.Groups = append(result.Groups, *group.GroupName)
+		}
+	}
+
+	// i.e. aws iam list-attached-user-policies --user-name <username>
+	userPolicies, err := ListAttachedUserPolicies(ctx, iamClient, username)
+	if err == nil {
+		result.AttachedPolicies = userPolicies
+		if ResolveDocuments {
+			result.PolicyDocuments = map[string]*PolicyDocument{}
+			for _, policy := range userPolicies {
+				// i.e. aws iam get-policy, aws iam get-policy-version
+				document, err := GetManagedPolicyDocument(ctx, iamClient, *policy.PolicyArn)
+				if err == nil {
+					result.PolicyDocuments[*policy.PolicyArn] = document
+				}
+			}
+		}
+	}
+
+	// i.e. aws iam list-user-policies --user-name <username>
+	userInlinePolicies, err := ListInlineUserPolicies(ctx, iamClient, username)
+	if err == nil {
+		result.InlinePolicies = userInlinePolicies
+	}
+
+	// i.e. aws iam get-user --user-name <username>, aws iam get-policy-version for the boundary
+	boundaryArn, boundary, err := GetPermissionsBoundary(ctx, iamClient, *user.Arn)
+	if err == nil {
+		result.Boundary = boundaryArn
+		result.BoundaryDocument = boundary
+	}
+
+	// i.e. aws iam list-access-keys --user-name <username>
+	accessKeys, err := ListAccessKeys(ctx, iamClient, username)
+	if err == nil {
+		for _, key := range accessKeys {
+			// i.e. aws iam get-access-key-last-used --access-key-id <access-key-id>
+			result.AccessKeys = append(result.AccessKeys, AccessKeyResult{
+				AccessKeyMetadata: key,
+				AgeDays:           int(time.Since(*key.CreateDate).Hours() / 24),
+				LastUsed:          GetAccessKeyLastUsed(ctx, iamClient, *key.AccessKeyId),
+			})
+		}
+	}
+
+	// i.e. aws iam list-ssh-public-keys --user-name <username>
+	sshKeys, err := ListSSHPublicKeys(ctx, iamClient, username)
+	if err == nil {
+		result.SSHPublicKeys = sshKeys
+	}
+
+	// i.e. aws iam list-service-specific-credentials --user-name <username>
+	serviceCredentials, err := ListServiceSpecificCredentials(ctx, iamClient, username)
+	if err == nil {
+		result.ServiceCredentials = serviceCredentials
+	}
+
+	// i.e. aws iam list-mfa-devices --user-name <username>
+	mfaDevices, err := ListMFADevices(ctx, iamClient, username)
+	result.MFADevices = mfaDevices
+
+	return result, err
+}
+
+func ListAccessKeys(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.AccessKeyMetadata, error) {
+	// Get the access keys belonging to the user
+	var accessKeys []iamtypes.AccessKeyMetadata
+	paginator := iam.NewListAccessKeysPaginator(iamClient, &iam.ListAccessKeysInput{
+		UserName: aws.String(username),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the access keys for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		accessKeys = append(accessKeys, page.AccessKeyMetadata...)
+	}
+
+	return accessKeys, nil
+}
+
+func GetAccessKeyLastUsed(ctx context.Context, iamClient *iam.Client, accessKeyId string) *iamtypes.AccessKeyLastUsed {
+	// A key that's never been used comes back without a date, which is returned as nil
+	lastUsed, err := iamClient.GetAccessKeyLastUsed(ctx, &iam.GetAccessKeyLastUsedInput{
+		AccessKeyId: aws.String(accessKeyId),
+	})
+	if err != nil {
+		fmt.Printf("Couldn't get when %v was last used. Here's why: %v\n", accessKeyId, err)
+		return nil
+	}
+	if lastUsed.AccessKeyLastUsed == nil || lastUsed.AccessKeyLastUsed.LastUsedDate == nil {
+		return nil
+	}
+
+	return lastUsed.AccessKeyLastUsed
+}
+
+func StaleAccessKey(key AccessKeyResult) string {
+	// Describes why an active key is stale, or returns nothing if it isn't
+	if key.Status != iamtypes.StatusTypeActive {
+		return ""
+	}
+	if key.LastUsed == nil {
+		if key.AgeDays >= STALE_ACCESS_KEY_DAYS {
+			return fmt.Sprintf("has never been used in %v days", key.AgeDays)
+		}
+		return ""
+	}
+	if unused := int(time.Since(*key.LastUsed.LastUsedDate).Hours() / 24); unused >= STALE_ACCESS_KEY_DAYS {
+		return fmt.Sprintf("hasn't been used in %v days", unused)
+	}
+
+	return ""
+}
+
+func ListMFADevices(ctx context.Context, iamClient *iam.Client, username string) ([]iamtypes.MFADevice, error) {
+	// Get the MFA devices assigned to the user, virtual and hardware
+	var mfaDevices []iamtypes.MFADevice
+	paginator := iam.NewListMFADevicesPaginator(iamClient, &iam.ListMFADevicesInput{
+		UserName: aws.String(username),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the MFA devices for %v. Here's why: %v\n", username, err)
+			return nil, err
+		}
+		mfaDevices = append(mfaDevices, page.MFADevices...)
+	}
+
+	return mfaDevices, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time, i.e.
+// go build -ldflags "-X main.Version=v1.0.0"
+var Version = "dev"
+
+// The rule catalog is embedded so findings can always be tied back to the
+// revision of the rules that produced them
+//
+//go:embed rules.json
+var rulesCatalogJSON []byte
+
+type RuleCatalog struct {
+	Remediations []RemediationRule `json:"remediations"`
+}
+
+type BuildInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	BuildTime      string `json:"build_time"`
+	GoVersion      string `json:"go_version"`
+	Platform       string `json:"platform"`
+	CatalogVersion string `json:"rule_catalog_version"`
+	SchemaVersion  string `json:"output_schema_version"`
+}
+
+// What the json report and every ndjson line say about the build that wrote them
+type BuildStamp struct {
+	Version            string `json:"version"`
+	Commit             string `json:"commit"`
+	RuleCatalogVersion string `json:"ruleCatalogVersion"`
+}
+
+// Read once, it's the same for the whole run
+var CurrentBuildStamp = sync.OnceValue(func() *BuildStamp {
+	info := GetBuildInfo()
+	return &BuildStamp{Version: info.Version, Commit: info.Commit, RuleCatalogVersion: info.CatalogVersion}
+})
+
+func LoadRuleCatalog() (*RuleCatalog, error) {
+	var catalog RuleCatalog
+	if err := json.Unmarshal(rulesCatalogJSON, &catalog); err != nil {
+		return nil, fmt.Errorf("couldn't parse the embedded rule catalog: %w", err)
+	}
+
+	return &catalog, nil
+}
+
+func RuleCatalogVersion() string {
+	// A hash of the rules findings are made and fixed by, so any change to them changes the version
+	// without anyone having to remember to bump it
+	hash := sha256.New()
+	hash.Write(rulesCatalogJSON)
+	privescPaths, _ := json.Marshal(PRIVESC_PATHS)
+	hash.Write(privescPaths)
+
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:       Version,
+		Commit:        "unknown",
+		BuildTime:     "unknown",
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: OUTPUT_SCHEMA_VERSION,
+	}
+
+	// The commit and build time come from the VCS stamp the Go toolchain embeds
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	if _, err := LoadRuleCatalog(); err == nil {
+		info.CatalogVersion = RuleCatalogVersion()
+	} else {
+		info.CatalogVersion = "invalid"
+	}
+
+	return info
+}
+
+func PrintVersion() {
+	info := GetBuildInfo()
+	fmt.Printf("aws-enumerator %v\n", info.Version)
+	fmt.Printf("\tCommit: %v\n", info.Commit)
+	fmt.Printf("\tBuilt on: %v\n", info.BuildTime)
+	fmt.Printf("\tGo version: %v\n", info.GoVersion)
+	fmt.Printf("\tPlatform: %v\n", info.Platform)
+	fmt.Printf("\tRule catalog version: %v\n", info.CatalogVersion)
+	fmt.Printf("\tOutput schema version: %v\n", info.SchemaVersion)
+}
+
+func PrintReportHeader() {
+	// Every run starts with the build and rule catalog versions so the output can be tied to them later
+	info := GetBuildInfo()
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("aws-enumerator %v (commit %v, rule catalog %v)\n", info.Version, info.Commit, info.CatalogVersion)
+	fmt.Println(MAJOR_SEPARATOR)
+}
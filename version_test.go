@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRuleCatalogVersionFollowsPrivescPaths(t *testing.T) {
+	before := RuleCatalogVersion()
+	if again := RuleCatalogVersion(); again != before {
+		t.Fatalf("version changed from %v to %v without the rules changing", before, again)
+	}
+
+	previousPaths := PRIVESC_PATHS
+	PRIVESC_PATHS = slices.Clone(PRIVESC_PATHS)
+	PRIVESC_PATHS[0].Actions = []string{"iam:CreatePolicyVersion", "iam:TagPolicy"}
+	t.Cleanup(func() {
+		PRIVESC_PATHS = previousPaths
+	})
+	if after := RuleCatalogVersion(); after == before {
+		t.Errorf("version stayed %v when a privilege escalation path changed", after)
+	}
+}
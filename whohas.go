@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Everything a managed policy is attached to, with the users that get it through a group
+type PolicyEntitiesResult struct {
+	PolicyArn string   `json:"policyArn"`
+	Users     []string `json:"users"`
+	Groups    []string `json:"groups"`
+	Roles     []string `json:"roles"`
+	// Group name to the names of its members
+	GroupMembers map[string][]string `json:"groupMembers,omitempty"`
+}
+
+func RunWhoHas(ctx context.Context, nameOrArn string) error {
+	sdkConfig, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+	iamClient := iam.NewFromConfig(sdkConfig)
+
+	policyArn := nameOrArn
+	if !strings.HasPrefix(nameOrArn, "arn:") {
+		// i.e. aws sts get-caller-identity
+		callerIdentity, err := GetCallerIdentity(ctx, sts.NewFromConfig(sdkConfig))
+		if err != nil {
+			return err
+		}
+		policyArn, err = ResolvePolicyArn(ctx, iamClient, *callerIdentity.Arn, nameOrArn)
+		if err != nil {
+			return err
+		}
+	}
+
+	// i.e. aws iam list-entities-for-policy --policy-arn <policy-arn> --policy-usage-filter PermissionsPolicy
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Printf("Getting every user, group and role %v is attached to...\n", policyArn)
+	fmt.Println(MAJOR_SEPARATOR)
+	result, err := GetPolicyEntities(ctx, iamClient, policyArn)
+	if err != nil {
+		return err
+	}
+	CurrentModule = "who-has"
+	PrintPolicyEntities(result)
+	fmt.Println(MAJOR_SEPARATOR)
+	Emit("policy-entities", "", result)
+
+	return WriteResults()
+}
+
+func PrintPolicyEntities(result PolicyEntitiesResult) {
+	for _, user := range result.Users {
+		fmt.Printf("\tUser: %v\n", user)
+	}
+	for _, group := range result.Groups {
+		fmt.Printf("\tGroup: %v\n", group)
+		for _, member := range result.GroupMembers[group] {
+			fmt.Printf("\t\tMember: %v\n", member)
+		}
+	}
+	for _, role := range result.Roles {
+		fmt.Printf("\tRole: %v\n", role)
+	}
+	if len(result.Users)+len(result.Groups)+len(result.Roles) == 0 {
+		fmt.Println("\tNot attached to anything")
+	}
+}
+
+func ResolvePolicyArn(ctx context.Context, iamClient *iam.Client, callerArn string, name string) (string, error) {
+	// A policy created in the account wins over an AWS managed one with the same name
+	parsedArn, err := arn.Parse(callerArn)
+	if err != nil {
+		return "", err
+	}
+	for _, policyArn := range []string{
+		fmt.Sprintf("arn:%v:iam::%v:policy/%v", parsedArn.Partition, parsedArn.AccountID, name),
+		fmt.Sprintf("arn:%v:iam::aws:policy/%v", parsedArn.Partition, name),
+	} {
+		// i.e. aws iam get-policy --policy-arn <policy-arn>
+		if _, err := iamClient.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(policyArn)}); err == nil {
+			return policyArn, nil
+		}
+	}
+	// AWS managed policies outside the root path, i.e. service-role/AWSLambdaRole, need the full ARN
+	fmt.Printf("No managed policy named %v in the account or managed by AWS, pass its ARN instead\n", name)
+
+	return "", fmt.Errorf("no managed policy named %v", name)
+}
+
+func GetPolicyEntities(ctx context.Context, iamClient *iam.Client, policyArn string) (PolicyEntitiesResult, error) {
+	result := PolicyEntitiesResult{PolicyArn: policyArn, GroupMembers: map[string][]string{}}
+
+	// Without the filter, entities only using the policy as their permission boundary are listed too
+	paginator := iam.NewListEntitiesForPolicyPaginator(iamClient, &iam.ListEntitiesForPolicyInput{
+		PolicyArn:         aws.String(policyArn),
+		PolicyUsageFilter: iamtypes.PolicyUsageTypePermissionsPolicy,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Printf("Couldn't list the entities %v is attached to. Here's why: %v\n", policyArn, err)
+			return result, err
+		}
+		for _, user := range page.PolicyUsers {
+			result.Users = append(result.Users, aws.ToString(user.UserName))
+		}
+		for _, group := range page.PolicyGroups {
+			result.Groups = append(result.Groups, aws.ToString(group.GroupName))
+		}
+		for _, role := range page.PolicyRoles {
+			result.Roles = append(result.Roles, aws.ToString(role.RoleName))
+		}
+	}
+
+	// Members of an attached group have the policy as much as users it's attached to directly
+	// i.e. aws iam get-group --group-name <group-name>
+	for _, group := range result.Groups {
+		members, err := ListGroupMembers(ctx, iamClient, group)
+		if err != nil {
+			continue
+		}
+		for _, member := range members {
+			result.GroupMembers[group] = append(result.GroupMembers[group], aws.ToString(member.UserName))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// Actions that let a principal take on a role
+var ASSUME_ROLE_ACTIONS = []string{"sts:AssumeRole", "sts:AssumeRoleWithWebIdentity", "sts:AssumeRoleWithSAML"}
+
+// Condition keys that narrow who can assume a role, lowercase. Anything else, i.e. aws:SecureTransport
+// or aws:MultiFactorAuthPresent, still lets any AWS account in
+var RESTRICTING_TRUST_CONDITION_KEYS = []string{
+	"aws:principalorgid", "aws:principalorgpaths", "aws:principalaccount", "aws:principalarn", "aws:userid",
+	"aws:username", "aws:sourceaccount", "aws:sourcearn", "aws:sourceorgid", "aws:sourceorgpaths",
+	"aws:sourceip", "aws:sourcevpc", "aws:sourcevpce", "sts:externalid",
+}
+
+// Identity providers that hand out tokens to anyone, keyed by the condition key that ties a role to
+// the tokens meant for it
+var PUBLIC_WEB_IDENTITY_PROVIDERS = map[string]string{
+	"cognito-identity.amazonaws.com":      "cognito-identity.amazonaws.com:aud",
+	"token.actions.githubusercontent.com": "token.actions.githubusercontent.com:sub",
+	"gitlab.com":                          "gitlab.com:sub",
+	"app.terraform.io":                    "app.terraform.io:sub",
+	"accounts.google.com":                 "accounts.google.com:sub",
+}
+
+type WildcardTrustResult struct {
+	RoleName string             `json:"roleName"`
+	RoleArn  string             `json:"roleArn"`
+	Issues   []TrustIssueResult `json:"issues"`
+}
+
+type TrustIssueResult struct {
+	// AWS or Federated
+	PrincipalType string `json:"principalType"`
+	Principal     string `json:"principal"`
+	// The other account, when the whole of it is trusted
+	Account string `json:"account,omitempty"`
+	// The identity provider, when anyone signed in to it is trusted
+	Provider string `json:"provider,omitempty"`
+	// internet-facing or cross-account, empty when conditions narrow it down
+	Exposure string `json:"exposure,omitempty"`
+	Reason   string `json:"reason"`
+	// The condition keys on the statement, lowercase
+	ConditionKeys []string `json:"conditionKeys"`
+}
+
+func RunWildcardTrustModule(ctx context.Context, sdkConfig aws.Config) error {
+	// list-roles returns every trust policy, so the whole account is one paginated call
+	// i.e. aws iam list-roles
+	fmt.Println(MAJOR_SEPARATOR)
+	fmt.Println("Checking every role's trust policy for principals anyone can use...")
+	fmt.Println(MAJOR_SEPARATOR)
+	roles, err := CachedRoles(ctx, iam.NewFromConfig(sdkConfig))
+	if err != nil {
+		fmt.Println("Couldn't list roles. Exiting...")
+		return err
+	}
+
+	flagged := 0
+	for _, role := range roles {
+		if role.AssumeRolePolicyDocument == nil {
+			continue
+		}
+		trustPolicy, err := ParsePolicyDocument(*role.AssumeRolePolicyDocument)
+		if err != nil {
+			fmt.Printf("Couldn't parse the trust policy for %v. Here's why: %v\n", *role.RoleName, err)
+			continue
+		}
+		result := WildcardTrustResult{
+			RoleName: aws.ToString(role.RoleName),
+			RoleArn:  aws.ToString(role.Arn),
+			Issues:   AnalyseTrustPolicy(aws.ToString(role.Arn), trustPolicy),
+		}
+		if len(result.Issues) == 0 {
+			continue
+		}
+		PrintWildcardTrustResult(result)
+		if slices.ContainsFunc(result.Issues, func(issue TrustIssueResult) bool { return issue.Exposure != "" }) {
+			flagged++
+		}
+	}
+	fmt.Printf("\tRoles checked: %v\n", len(roles))
+	fmt.Printf("\tRoles open beyond the account: %v\n", flagged)
+	fmt.Println(MAJOR_SEPARATOR)
+
+	return nil
+}
+
+func AnalyseTrustPolicy(roleArn string, trustPolicy *PolicyDocument) []TrustIssueResult {
+	var roleAccount string
+	if parsedArn, err := arn.Parse(roleArn); err == nil {
+		roleAccount = parsedArn.AccountID
+	}
+
+	var issues []TrustIssueResult
+	for _, statement := range trustPolicy.Statement {
+		if statement.Effect != "Allow" || !slices.ContainsFunc(ASSUME_ROLE_ACTIONS, func(action string) bool { return StatementCoversAction(statement, action) }) {
+			continue
+		}
+		var conditionKeys []string
+		for _, keys := range statement.Condition {
+			for key := range keys {
+				conditionKeys = append(conditionKeys, strings.ToLower(key))
+			}
+		}
+		sort.Strings(conditionKeys)
+		restricted := slices.ContainsFunc(conditionKeys, func(key string) bool { return slices.Contains(RESTRICTING_TRUST_CONDITION_KEYS, key) })
+
+		for _, principal := range statement.Principal["AWS"] {
+			issue := TrustIssueResult{PrincipalType: "AWS", Principal: principal, ConditionKeys: conditionKeys}
+			switch account := PrincipalAccount(principal); {
+			case principal == "*" && restricted:
+				issue.Reason = "Any AWS principal, narrowed down by its conditions"
+			case principal == "*":
+				issue.Exposure = EXPOSURE_INTERNET
+				issue.Reason = "Anyone can assume this role"
+			case account != "" && account != roleAccount && (principal == account || strings.HasSuffix(principal, ":root")) && !restricted:
+				// The whole account rather than a named role or user, with nothing to narrow it down
+				issue.Account = account
+				issue.Exposure = EXPOSURE_CROSS_ACCOUNT
+				issue.Reason = fmt.Sprintf("Any principal in account %v allowed sts:AssumeRole can assume this role", account)
+			default:
+				continue
+			}
+			issues = append(issues, issue)
+		}
+
+		// Anyone can sign up with these providers, so without the condition tying the role to its own
+		// tokens any user of them, i.e. any GitHub repository, can assume it
+		for _, principal := range statement.Principal["Federated"] {
+			provider := principal
+			if parsedArn, err := arn.Parse(principal); err == nil {
+				provider = strings.TrimPrefix(parsedArn.Resource, "oidc-provider/")
+			}
+			key, ok := PUBLIC_WEB_IDENTITY_PROVIDERS[provider]
+			if !ok || slices.Contains(conditionKeys, strings.ToLower(key)) {
+				continue
+			}
+			issues = append(issues, TrustIssueResult{
+				PrincipalType: "Federated",
+				Principal:     principal,
+				Provider:      provider,
+				Exposure:      EXPOSURE_INTERNET,
+				Reason:        fmt.Sprintf("Any %v user can assume this role, there's no %v condition", provider, key),
+				ConditionKeys: conditionKeys,
+			})
+		}
+	}
+
+	return issues
+}
+
+func PrintWildcardTrustResult(result WildcardTrustResult) {
+	fmt.Printf("\tRole: %v\n", result.RoleArn)
+	for _, issue := range result.Issues {
+		fmt.Printf("\tTrusted %v: %v\n", issue.PrincipalType, issue.Principal)
+		if len(issue.ConditionKeys) > 0 {
+			fmt.Printf("\tCondition keys: %v\n", strings.Join(issue.ConditionKeys, ", "))
+		}
+		if issue.Exposure == "" {
+			fmt.Printf("\t[-] %v\n", issue.Reason)
+			continue
+		}
+		fmt.Printf("\t[!] %v\n", issue.Reason)
+		switch {
+		case issue.Provider != "":
+			EmitExposedFinding("", result.RoleArn, issue.Exposure, fmt.Sprintf("Trust policy allows any %v user to assume the role", issue.Provider))
+		case issue.Account != "":
+			EmitExposedFinding("", result.RoleArn, issue.Exposure, fmt.Sprintf("Trust policy allows account %v to assume the role", issue.Account))
+		default:
+			EmitExposedFinding("", result.RoleArn, issue.Exposure, "Trust policy allows anyone to assume the role")
+		}
+	}
+	fmt.Println(MINOR_SEPARATOR)
+	Emit("wildcard-trust", "", result)
+}
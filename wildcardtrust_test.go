@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAnalyseTrustPolicy(t *testing.T) {
+	const roleArn = "arn:aws:iam::123456789012:role/target"
+	for _, test := range []struct {
+		name   string
+		policy string
+		// The exposure of each issue found, empty for the ones narrowed down by conditions
+		want []string
+	}{
+		{
+			"anyone",
+			`{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:AssumeRole"}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"anyone in the AWS form",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"sts:assumerole"}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"anyone through a wildcard action",
+			`{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:*"}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"anyone with a restricting condition",
+			`{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:AssumeRole","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abcdefghij"}}}}`,
+			[]string{""},
+		},
+		{
+			"anyone with a condition that doesn't restrict",
+			`{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:AssumeRole","Condition":{"Bool":{"aws:MultiFactorAuthPresent":"true"}}}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"other account root",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":"sts:AssumeRole"}}`,
+			[]string{EXPOSURE_CROSS_ACCOUNT},
+		},
+		{
+			"other account ID",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"210987654321"},"Action":"sts:AssumeRole"}}`,
+			[]string{EXPOSURE_CROSS_ACCOUNT},
+		},
+		{
+			"other account with an external ID",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":"sts:AssumeRole","Condition":{"StringEquals":{"sts:ExternalId":"secret"}}}}`,
+			nil,
+		},
+		{
+			"named role in another account",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:role/deploy"},"Action":"sts:AssumeRole"}}`,
+			nil,
+		},
+		{
+			"own account root",
+			`{"Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"sts:AssumeRole"}}`,
+			nil,
+		},
+		{
+			"denied",
+			`{"Statement":{"Effect":"Deny","Principal":"*","Action":"sts:AssumeRole"}}`,
+			nil,
+		},
+		{
+			"other action",
+			`{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:TagSession"}}`,
+			nil,
+		},
+		{
+			"GitHub without a subject",
+			`{"Statement":{"Effect":"Allow","Principal":{"Federated":"arn:aws:iam::123456789012:oidc-provider/token.actions.githubusercontent.com"},"Action":"sts:AssumeRoleWithWebIdentity","Condition":{"StringEquals":{"token.actions.githubusercontent.com:aud":"sts.amazonaws.com"}}}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"GitHub with a subject",
+			`{"Statement":{"Effect":"Allow","Principal":{"Federated":"arn:aws:iam::123456789012:oidc-provider/token.actions.githubusercontent.com"},"Action":"sts:AssumeRoleWithWebIdentity","Condition":{"StringLike":{"token.actions.githubusercontent.com:sub":"repo:org/repo:*"}}}}`,
+			nil,
+		},
+		{
+			"Cognito without an audience",
+			`{"Statement":{"Effect":"Allow","Principal":{"Federated":"cognito-identity.amazonaws.com"},"Action":"sts:AssumeRoleWithWebIdentity"}}`,
+			[]string{EXPOSURE_INTERNET},
+		},
+		{
+			"Cognito with an audience",
+			`{"Statement":{"Effect":"Allow","Principal":{"Federated":"cognito-identity.amazonaws.com"},"Action":"sts:AssumeRoleWithWebIdentity","Condition":{"StringEquals":{"cognito-identity.amazonaws.com:aud":"us-east-1:pool"}}}}`,
+			nil,
+		},
+		{
+			"private identity provider",
+			`{"Statement":{"Effect":"Allow","Principal":{"Federated":"arn:aws:iam::123456789012:saml-provider/okta"},"Action":"sts:AssumeRoleWithSAML"}}`,
+			nil,
+		},
+	} {
+		policy, err := ParsePolicyDocument(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		issues := AnalyseTrustPolicy(roleArn, policy)
+		if len(issues) != len(test.want) {
+			t.Errorf("%v: got %v issues, want %v", test.name, len(issues), len(test.want))
+			continue
+		}
+		for i, issue := range issues {
+			if issue.Exposure != test.want[i] {
+				t.Errorf("%v: issue %v has exposure %q, want %q", test.name, i, issue.Exposure, test.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// How many users, roles, groups or policies are looked up at once, set with --concurrency
+var Concurrency = 8
+
+func ForEachConcurrently(ctx context.Context, count int, lookup func(ctx context.Context, i int), report func(i int)) {
+	// Each lookup makes its API calls one after another, several lookups run side by side,
+	// and report is called on this goroutine in the original order as soon as each is ready,
+	// so what's printed and emitted is the same as a sequential run
+	workers := min(max(Concurrency, 1), count)
+	done := make([]chan struct{}, count)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := 0; i < count; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				lookup(ctx, i)
+				close(done[i])
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-done[i]:
+			report(i)
+		case <-ctx.Done():
+			return
+		}
+	}
+}